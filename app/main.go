@@ -1,13 +1,16 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"notification/config"
+	"notification/domain"
+	"notification/middleware"
 	"notification/services/notification/delivery"
 	"notification/services/notification/repository"
 	"notification/services/notification/usecase"
 	"os"
 	"os/signal"
+	"strconv"
 	"sync"
 	"syscall"
 	"time"
@@ -41,6 +44,9 @@ func startHTTP() {
 		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
 	}))
 
+	app.Use(middleware.RequestLogger())
+	app.Use(middleware.Metrics())
+
 	app.Get("/", func(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusOK).SendString("OK")
 	})
@@ -51,10 +57,9 @@ func startHTTP() {
 		return
 	}
 
-	meow, eAuth, eAdress, schoolPhone, emailSender, err := config.InitSender()
+	meow, mailer, schoolPhone, emailSender, err := config.InitSender()
 	if err != nil {
-		fmt.Println(err)
-		log.Fatal("Failed to boot Sender Service")
+		log.WithError(err).Fatal("Failed to boot Sender Service")
 		return
 	}
 
@@ -63,10 +68,11 @@ func startHTTP() {
 	notifRepo := repository.NewNotificationRepository(db)
 	notifUC := usecase.NewNotificationUseCase(notifRepo)
 	// Auth
-	authRepo := repository.NewAuthRepository(db)
+	authRepo := repository.NewAuthRepository(db, mailer, *emailSender, meow)
 	authUC := usecase.NewAuthUseCase(authRepo)
+	middleware.UseSessionChecker(authRepo)
 	// User
-	userRepo := repository.NewUserRepository(db)
+	userRepo := repository.NewUserRepository(db, config.GetWhatsappSession())
 	userUC := usecase.NewUserUseCase(userRepo, 100*time.Second)
 	// StudentParent
 	studentParentRepo := repository.NewStudentParentRepository(db)
@@ -75,8 +81,35 @@ func startHTTP() {
 	studentRepo := repository.NewStudentRepository(db)
 	studentUC := usecase.NewStudentUseCase(studentRepo, 100*time.Second)
 	// Sender
-	senderRepo := repository.NewSenderRepository(db, eAuth, *eAdress, *schoolPhone, *emailSender, meow)
+	senderRepo := repository.NewSenderRepository(db, mailer, *schoolPhone, *emailSender, meow)
 	senderUC := usecase.NewSenderUseCase(senderRepo, 30*time.Second)
+	// Consent
+	consentRepo := repository.NewConsentRepository(db)
+	consentUC := usecase.NewConsentUseCase(consentRepo, 30*time.Second)
+	// WhatsApp outbox, flushed whenever the session comes back
+	outboxRepo := repository.NewWhatsappOutboxRepository(db, meow)
+	config.GetWhatsappSession().AttachOutbox(outboxRepo)
+	// Email bounces
+	bounceRepo := repository.NewBounceRepository(db, os.Getenv("BOUNCE_MAILBOX_PATH"))
+	bounceUC := usecase.NewBounceUseCase(bounceRepo, 5*time.Minute)
+	// Exams
+	examRepo := repository.NewExamRepository(db)
+	examUC := usecase.NewExamUseCase(examRepo, 30*time.Second)
+
+	// Roles and their permissions
+	roleRepo := repository.NewRoleRepository(db)
+	roleUC := usecase.NewRoleUseCase(roleRepo, 30*time.Second)
+	middleware.UsePermissionChecker(roleRepo)
+
+	// Audit log
+	auditRepo := repository.NewAuditRepository(db)
+	auditUC := usecase.NewAuditUseCase(auditRepo, 30*time.Second)
+
+	analyticsRepo := repository.NewAnalyticsRepository(db)
+	analyticsUC := usecase.NewAnalyticsUseCase(analyticsRepo, 30*time.Second)
+	// Parent bot
+	botRepo := repository.NewBotRepository(db, *schoolPhone, meow)
+	botUC := usecase.NewBotUseCase(botRepo, studentRepo, consentRepo, 30*time.Second)
 
 	// // Register delivery here
 	// delivery.NewNotificationHandler(app, notifUC)
@@ -93,6 +126,29 @@ func startHTTP() {
 	delivery.NewStudentParentHandlerDeploy(app, studentParentUC)
 	delivery.NewSenderDeliveryDeploy(app, senderUC)
 	delivery.NewStudentDeliveryDeploy(app, studentUC)
+	delivery.NewConsentHandlerDeploy(app, consentUC)
+	delivery.NewBounceHandlerDeploy(app, bounceUC)
+	delivery.NewExamHandlerDeploy(app, examUC)
+	delivery.NewAnalyticsHandlerDeploy(app, analyticsUC)
+	delivery.NewAuditHandlerDeploy(app, auditUC)
+	delivery.NewRoleHandlerDeploy(app, roleUC)
+
+	// WhatsApp inbound
+	delivery.NewWhatsappHandlerDeploy(meow, notifUC, botUC)
+	delivery.NewWhatsappSessionHandlerDeploy(app, config.GetWhatsappSession())
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatal("Failed to read the DB pool")
+		return
+	}
+	if err := delivery.NewMetricsHandlerDeploy(app, sqlDB, config.GetWhatsappSession()); err != nil {
+		log.WithError(err).Fatal("Failed to register the metrics")
+		return
+	}
+
+	stopBouncePoller := startBouncePoller(bounceUC)
+	stopSessionCleanup := startSessionCleanup(authUC)
 
 	wg.Add(1)
 	go func() {
@@ -114,6 +170,77 @@ func startHTTP() {
 		log.Errorf("Error during server shutdown: %v", err)
 	}
 
+	close(stopBouncePoller)
+	close(stopSessionCleanup)
 	wg.Wait()
+	mailer.Close()
 	log.Info("Server shut down gracefully")
 }
+
+// startBouncePoller reads the bounce mailbox every BOUNCE_POLL_MINUTES when BOUNCE_MAILBOX_PATH is set
+func startBouncePoller(bounceUC domain.BounceUseCase) chan struct{} {
+	stop := make(chan struct{})
+	if os.Getenv("BOUNCE_MAILBOX_PATH") == "" {
+		return stop
+	}
+
+	interval := 10 * time.Minute
+	if minutes, err := strconv.Atoi(os.Getenv("BOUNCE_POLL_MINUTES")); err == nil && minutes > 0 {
+		interval = time.Duration(minutes) * time.Minute
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				result, err := bounceUC.ProcessMailbox(context.Background())
+				if err != nil {
+					log.WithError(err).Error("bounce processing failed")
+					continue
+				}
+				if result.BouncesFound > 0 {
+					log.WithFields(logrus.Fields{"bounces": result.BouncesFound, "parents_marked": result.ParentsMarked}).Info("processed bounce mailbox")
+				}
+			}
+		}
+	}()
+
+	return stop
+}
+
+// startSessionCleanup deletes expired sessions and refresh tokens once an hour
+func startSessionCleanup(authUC domain.AuthUseCase) chan struct{} {
+	stop := make(chan struct{})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				purged, err := authUC.PurgeExpiredSessions(context.Background())
+				if err != nil {
+					log.WithError(err).Error("session cleanup failed")
+					continue
+				}
+				if purged > 0 {
+					log.WithField("sessions", purged).Info("purged expired sessions")
+				}
+			}
+		}
+	}()
+
+	return stop
+}
@@ -9,6 +9,7 @@ import (
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var db *gorm.DB
@@ -36,7 +37,7 @@ func BootDB() (*gorm.DB, error) {
 		return db, err
 	}
 
-	fmt.Println("DB initialized")
+	GetLogrusInstance().Info("DB initialized")
 	return db, nil
 }
 
@@ -50,20 +51,15 @@ func autoMigrate(db *gorm.DB) error {
 		return fmt.Errorf("failed to create gender ENUM: %w", err)
 	}
 
-	if err := db.Exec(`DO $$ BEGIN
-		IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'role_enum') THEN
-			CREATE TYPE role_enum AS ENUM ('admin', 'staff');
-		END IF;
-	END $$`).Error; err != nil {
-		return fmt.Errorf("failed to create role ENUM: %w", err)
-	}
-
 	// Migrasi tabel yang tidak memiliki foreign key lebih dulu
 	if err := db.AutoMigrate(
 		&domain.Parent{},
 		&domain.Student{},
 		&domain.User{},
+		&domain.Role{},
 		&domain.Subject{},
+		&domain.WhatsappOutbox{},
+		&domain.Exam{},
 	); err != nil {
 		return fmt.Errorf("failed to migrate base tables: %w", err)
 	}
@@ -72,15 +68,86 @@ func autoMigrate(db *gorm.DB) error {
 	if err := db.AutoMigrate(
 		&domain.TestScore{},
 		&domain.AttendanceNotificationHistory{},
+		&domain.ExamResultNotificationHistory{},
+		&domain.ExamBroadcast{},
+		&domain.ExamBroadcastRecipient{},
 		&domain.ParentDataChangeRequest{},
+		&domain.ParentReply{},
+		&domain.ParentConsent{},
+		&domain.StudentGuardian{},
+		&domain.SentEmail{},
+		&domain.EmailBounce{},
+		&domain.AuditLog{},
+		&domain.UserSession{},
+		&domain.RefreshToken{},
+		&domain.PasswordReset{},
+		&domain.LoginThrottle{},
+		&domain.RolePermission{},
 	); err != nil {
 		return fmt.Errorf("failed to migrate relational tables: %w", err)
 	}
 
+	// The audit log is append-only, the database refuses to change or remove its rows
+	if err := db.Exec(`CREATE OR REPLACE FUNCTION audit_logs_append_only() RETURNS trigger AS $$ BEGIN
+		RAISE EXCEPTION 'audit_logs is append-only';
+	END $$ LANGUAGE plpgsql`).Error; err != nil {
+		return fmt.Errorf("failed to create audit log guard: %w", err)
+	}
+	if err := db.Exec(`DO $$ BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'audit_logs_append_only') THEN
+			CREATE TRIGGER audit_logs_append_only BEFORE UPDATE OR DELETE ON audit_logs
+				FOR EACH ROW EXECUTE FUNCTION audit_logs_append_only();
+		END IF;
+	END $$`).Error; err != nil {
+		return fmt.Errorf("failed to create audit log guard: %w", err)
+	}
+
+	// Every existing student keeps its current parent as primary guardian
+	if err := db.Exec(`INSERT INTO student_guardians (student_nsn, parent_id, relationship, is_primary, receive_attendance, receive_exam_result, created_at, updated_at)
+		SELECT student_nsn, parent_id, 'parent', TRUE, TRUE, TRUE, NOW(), NOW() FROM students WHERE parent_id IS NOT NULL AND parent_id <> 0
+		ON CONFLICT DO NOTHING`).Error; err != nil {
+		return fmt.Errorf("failed to backfill student guardians: %w", err)
+	}
+
+	// History written before per-channel delivery status only knew whether a channel succeeded
+	if err := db.Exec(`UPDATE attendance_notification_histories SET
+		whatsapp_delivery = CASE WHEN whatsapp_status THEN 'sent' ELSE whatsapp_delivery END,
+		email_delivery = CASE WHEN email_status THEN 'sent' ELSE email_delivery END
+		WHERE (whatsapp_delivery IS NULL AND whatsapp_status) OR (email_delivery IS NULL AND email_status)`).Error; err != nil {
+		return fmt.Errorf("failed to backfill notification delivery status: %w", err)
+	}
+
+	// The outbox used to tell sent and abandoned messages apart by sent_at and the attempt counter only
+	if err := db.Exec(`UPDATE whatsapp_outboxes SET status = CASE WHEN sent_at IS NOT NULL THEN 'sent' ELSE 'failed' END
+		WHERE status = 'pending' AND (sent_at IS NOT NULL OR attempts >= 5)`).Error; err != nil {
+		return fmt.Errorf("failed to backfill whatsapp outbox status: %w", err)
+	}
+
+	// Bounces recorded before the source key existed only had it as prefix of the source column
+	if err := db.Exec(`UPDATE email_bounces SET source_key = split_part(source, '#', 1), source_index = COALESCE(NULLIF(split_part(source, '#', 2), ''), '0')::int
+		WHERE source_key IS NULL AND source IS NOT NULL`).Error; err != nil {
+		return fmt.Errorf("failed to backfill bounce source keys: %w", err)
+	}
+
+	// Data change requests used to be tied to their parent by telephone only, an approved one carries the new number
+	if err := db.Exec(`UPDATE parent_data_change_requests r SET parent_id = p.parent_id FROM parents p
+		WHERE r.parent_id IS NULL AND p.deleted_at IS NULL
+		AND p.telephone = CASE WHEN r.is_reviewed THEN COALESCE(r.new_parent_telephone, r.old_parent_telephone) ELSE r.old_parent_telephone END`).Error; err != nil {
+		return fmt.Errorf("failed to backfill data change request parents: %w", err)
+	}
+
+	if err := backfillTestScoreExam(db); err != nil {
+		return err
+	}
+
+	if err := seedBuiltInRoles(db); err != nil {
+		return err
+	}
+
 	var existingAdmin domain.User
 	err := db.Where("role = 'admin' AND deleted_at IS NULL").First(&existingAdmin).Error
 	if err != nil {
-		fmt.Println("Creating default admin account....")
+		GetLogrusInstance().Info("Creating default admin account")
 		adminUsername := os.Getenv("ADMIN_USERNAME")
 		adminName := os.Getenv("ADMIN_NAME")
 		adminPassword := os.Getenv("ADMIN_PASSWORD")
@@ -92,19 +159,80 @@ func autoMigrate(db *gorm.DB) error {
 
 		now := time.Now()
 		admin := domain.User{
-			Username:  adminUsername,
-			Name:      adminName,
-			Password:  string(hashedPassword),
-			Role:      "admin",
-			CreatedAt: now,
-			UpdatedAt: now,
+			Username:           adminUsername,
+			Name:               adminName,
+			Password:           string(hashedPassword),
+			Role:               "admin",
+			MustChangePassword: true,
+			CreatedAt:          now,
+			UpdatedAt:          now,
 		}
 
 		err = db.Create(&admin).Error
 		if err != nil {
 			return err
 		}
-		fmt.Println("Admin account created")
+		GetLogrusInstance().WithField("username", adminUsername).Info("Admin account created")
+	}
+
+	return nil
+}
+
+// backfillTestScoreExam moves scores entered before exams existed into one exam so they can still be broadcast.
+// Scores that were soft deleted by earlier broadcasts are left alone, their exam can no longer be told apart.
+func backfillTestScoreExam(db *gorm.DB) error {
+	var pending int64
+	if err := db.Model(&domain.TestScore{}).Where("exam_id IS NULL AND deleted_at IS NULL").Count(&pending).Error; err != nil {
+		return fmt.Errorf("failed to count unassigned test scores: %w", err)
+	}
+	if pending == 0 {
+		return nil
+	}
+
+	// The academic year starts in July
+	now := time.Now()
+	startYear, semester := now.Year(), 1
+	if now.Month() < time.July {
+		startYear, semester = now.Year()-1, 2
+	}
+
+	exam := domain.Exam{
+		Name:         "Imported scores",
+		ExamType:     domain.ExamTypeOther,
+		AcademicYear: fmt.Sprintf("%d/%d", startYear, startYear+1),
+		Semester:     semester,
+	}
+	if err := db.Create(&exam).Error; err != nil {
+		return fmt.Errorf("failed to create exam for unassigned test scores: %w", err)
+	}
+
+	if err := db.Model(&domain.TestScore{}).Where("exam_id IS NULL AND deleted_at IS NULL").Update("exam_id", exam.ExamID).Error; err != nil {
+		return fmt.Errorf("failed to assign test scores to exam: %w", err)
+	}
+
+	return nil
+}
+
+// seedBuiltInRoles creates the built-in roles that are missing with their default permissions,
+// roles that already exist keep the permissions they were given since
+func seedBuiltInRoles(db *gorm.DB) error {
+	for _, role := range domain.BuiltInRoles {
+		role.BuiltIn = true
+		result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&role)
+		if result.Error != nil {
+			return fmt.Errorf("failed to seed role %s: %w", role.Name, result.Error)
+		}
+		if result.RowsAffected == 0 || len(role.Permissions) == 0 {
+			continue
+		}
+
+		grants := make([]domain.RolePermission, 0, len(role.Permissions))
+		for _, permission := range role.Permissions {
+			grants = append(grants, domain.RolePermission{RoleName: role.Name, Permission: permission})
+		}
+		if err := db.Omit("Role").Create(&grants).Error; err != nil {
+			return fmt.Errorf("failed to seed permissions of role %s: %w", role.Name, err)
+		}
 	}
 
 	return nil
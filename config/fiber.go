@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/bytedance/sonic"
@@ -23,9 +24,25 @@ func GetFiberConfig() fiber.Config {
 		AppName:               GetAppName(),
 		ReadTimeout:           time.Second * 60,
 		CaseSensitive:         true,
+		// c.IP() reads ProxyHeader only on requests from TrustedProxies, everyone else gets the socket address
+		ProxyHeader:             os.Getenv("PROXY_HEADER"),
+		EnableTrustedProxyCheck: true,
+		EnableIPValidation:      true,
+		TrustedProxies:          GetTrustedProxies(),
 	}
 }
 
+// GetTrustedProxies reads TRUSTED_PROXIES, a comma separated list of addresses or CIDR ranges
+func GetTrustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
 func GetAppName() string {
 	v := os.Getenv("APP_NAME")
 	if v == "" {
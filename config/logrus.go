@@ -1,12 +1,12 @@
 package config
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 
-	"github.com/gofiber/fiber"
-	"github.com/gofiber/fiber/v2/log"
+	"github.com/gofiber/fiber/v2"
 	"github.com/sirupsen/logrus"
 )
 
@@ -20,35 +20,53 @@ func GetLogrusInstance() *logrus.Logger {
 	return logrusInstance
 }
 
-const (
-	green  = "\033[32m" // Green for 200 OK
-	yellow = "\033[33m" // Yellow for 300 series
-	red    = "\033[31m" // Red for 400 and 500 series
-	reset  = "\033[0m"  // Reset to default color
-)
+// loggerKey stores the request logger in fiber locals, the same store fasthttp answers context lookups from,
+// so the entry follows c.Context() into usecases, repositories and their goroutines
+type loggerKey struct{}
 
-func PrintLogInfo(username *string, statusCode int, functionName string) {
-	var logColor string
+// WithLogger returns a context carrying entry, for work that runs outside a request
+func WithLogger(ctx context.Context, entry *logrus.Entry) context.Context {
+	return context.WithValue(ctx, loggerKey{}, entry)
+}
 
-	switch statusCode {
-	case fiber.StatusOK, fiber.StatusCreated:
-		logColor = green
-	case fiber.StatusAccepted:
-		logColor = yellow
-	case fiber.StatusBadRequest, fiber.StatusUnauthorized, fiber.StatusInternalServerError:
-		logColor = red
-	default:
-		logColor = reset
+// SetRequestLogger attaches entry to the request so Logger(c.Context()) finds it
+func SetRequestLogger(c *fiber.Ctx, entry *logrus.Entry) {
+	c.Locals(loggerKey{}, entry)
+}
+
+// Logger returns the logger of the request or job behind ctx, the plain JSON logger when there is none
+func Logger(ctx context.Context) *logrus.Entry {
+	if ctx != nil {
+		if entry, ok := ctx.Value(loggerKey{}).(*logrus.Entry); ok {
+			return entry
+		}
 	}
+	return logrus.NewEntry(GetLogrusInstance())
+}
 
+// PrintLogInfo records which handler answered and how, with the request id and route of c
+func PrintLogInfo(c *fiber.Ctx, username *string, statusCode int, functionName string) {
 	// Handle a nil `username` by using a placeholder
 	user := "Unknown"
 	if username != nil {
 		user = *username
 	}
 
-	logMsg := fmt.Sprintf("\nUser: %s, (%s) => Status: %s[%d] - %s%s\n\n\n", user, functionName, logColor, statusCode, http.StatusText(statusCode), reset)
-	log.Info(logMsg)
+	entry := Logger(c.Context()).WithFields(logrus.Fields{
+		"route":    c.Route().Path,
+		"user":     user,
+		"function": functionName,
+		"status":   statusCode,
+	})
+
+	switch {
+	case statusCode >= fiber.StatusInternalServerError:
+		entry.Error(http.StatusText(statusCode))
+	case statusCode >= fiber.StatusBadRequest:
+		entry.Warn(http.StatusText(statusCode))
+	default:
+		entry.Info(http.StatusText(statusCode))
+	}
 }
 
 func PrintStruct(strck interface{}) {
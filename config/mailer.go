@@ -0,0 +1,257 @@
+package config
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"notification/domain"
+	"notification/metrics"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SMTPTLSModeStartTLS = "starttls"
+	SMTPTLSModeTLS      = "tls"
+	SMTPTLSModeNone     = "none"
+)
+
+// Idle connections older than this are dropped instead of reused, most servers close them around a minute
+const smtpMaxIdle = 45 * time.Second
+
+type pooledSMTPConn struct {
+	client   *smtp.Client
+	conn     net.Conn
+	lastUsed time.Time
+}
+
+// SMTPMailer keeps a small pool of authenticated SMTP connections so bulk sends don't reconnect per message
+type SMTPMailer struct {
+	host     string
+	addr     string
+	auth     smtp.Auth
+	tlsMode  string
+	timeout  time.Duration
+	idle     chan *pooledSMTPConn
+	slots    chan struct{}
+	tlsConf  *tls.Config
+	hostname string
+}
+
+// NewSMTPMailer builds a mailer from SMTP_TLS_MODE, SMTP_POOL_SIZE and SMTP_TIMEOUT_SECONDS
+func NewSMTPMailer(host, port string, auth smtp.Auth) (*SMTPMailer, error) {
+	tlsMode := strings.ToLower(os.Getenv("SMTP_TLS_MODE"))
+	if tlsMode == "" {
+		// Port 465 is implicit TLS by convention, everything else upgrades with STARTTLS
+		tlsMode = SMTPTLSModeStartTLS
+		if port == "465" {
+			tlsMode = SMTPTLSModeTLS
+		}
+	}
+	if tlsMode != SMTPTLSModeStartTLS && tlsMode != SMTPTLSModeTLS && tlsMode != SMTPTLSModeNone {
+		return nil, fmt.Errorf("smtp tls mode invalid, value : %s", tlsMode)
+	}
+
+	poolSize := 4
+	if v := os.Getenv("SMTP_POOL_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("smtp pool size invalid, value : %s", v)
+		}
+		poolSize = n
+	}
+
+	timeout := 30 * time.Second
+	if v := os.Getenv("SMTP_TIMEOUT_SECONDS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("smtp timeout invalid, value : %s", v)
+		}
+		timeout = time.Duration(n) * time.Second
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "localhost"
+	}
+
+	return &SMTPMailer{
+		host:     host,
+		addr:     net.JoinHostPort(host, port),
+		auth:     auth,
+		tlsMode:  tlsMode,
+		timeout:  timeout,
+		idle:     make(chan *pooledSMTPConn, poolSize),
+		slots:    make(chan struct{}, poolSize),
+		tlsConf:  &tls.Config{ServerName: host},
+		hostname: hostname,
+	}, nil
+}
+
+// Send delivers one message, reusing a pooled connection when possible.
+// Failures are returned as *domain.MailError so callers can tell bounces from outages.
+func (m *SMTPMailer) Send(from string, to []string, msg []byte) (err error) {
+	m.slots <- struct{}{}
+	defer func() { <-m.slots }()
+
+	// Waiting for a free slot is not part of the SMTP latency
+	start := time.Now()
+	defer func() { metrics.ObserveSMTP(time.Since(start), err) }()
+
+	pc, err := m.acquire()
+	if err != nil {
+		return &domain.MailError{Class: domain.MailErrorConnection, Err: err}
+	}
+
+	err = m.deliver(pc, from, to, msg)
+	if err == nil {
+		m.release(pc)
+		return nil
+	}
+
+	mailErr := classifySMTPError(err)
+	if mailErr.Class == domain.MailErrorConnection {
+		pc.client.Close()
+		return mailErr
+	}
+
+	// The server rejected this message only, the connection is still good for the next one
+	if resetErr := pc.client.Reset(); resetErr != nil {
+		pc.client.Close()
+	} else {
+		m.release(pc)
+	}
+	return mailErr
+}
+
+// Close shuts every idle connection down
+func (m *SMTPMailer) Close() {
+	for {
+		select {
+		case pc := <-m.idle:
+			pc.client.Quit()
+		default:
+			return
+		}
+	}
+}
+
+func (m *SMTPMailer) deliver(pc *pooledSMTPConn, from string, to []string, msg []byte) error {
+	if err := pc.conn.SetDeadline(time.Now().Add(m.timeout)); err != nil {
+		return err
+	}
+
+	if err := pc.client.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := pc.client.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+
+	w, err := pc.client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func (m *SMTPMailer) acquire() (*pooledSMTPConn, error) {
+	for {
+		select {
+		case pc := <-m.idle:
+			if time.Since(pc.lastUsed) > smtpMaxIdle {
+				pc.client.Close()
+				continue
+			}
+			pc.conn.SetDeadline(time.Now().Add(m.timeout))
+			if err := pc.client.Noop(); err != nil {
+				pc.client.Close()
+				continue
+			}
+			return pc, nil
+		default:
+			return m.dial()
+		}
+	}
+}
+
+func (m *SMTPMailer) release(pc *pooledSMTPConn) {
+	pc.lastUsed = time.Now()
+	select {
+	case m.idle <- pc:
+	default:
+		pc.client.Quit()
+	}
+}
+
+func (m *SMTPMailer) dial() (*pooledSMTPConn, error) {
+	dialer := &net.Dialer{Timeout: m.timeout}
+
+	var conn net.Conn
+	var err error
+	if m.tlsMode == SMTPTLSModeTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", m.addr, m.tlsConf)
+	} else {
+		conn, err = dialer.Dial("tcp", m.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", m.addr, err)
+	}
+	conn.SetDeadline(time.Now().Add(m.timeout))
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("smtp greeting failed: %w", err)
+	}
+
+	if err := client.Hello(m.hostname); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("smtp hello failed: %w", err)
+	}
+
+	if m.tlsMode == SMTPTLSModeStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, errors.New("smtp server does not support STARTTLS, set SMTP_TLS_MODE")
+		}
+		if err := client.StartTLS(m.tlsConf); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("starttls failed: %w", err)
+		}
+	}
+
+	// Credentials are only sent when the server asks for them; smtp.PlainAuth refuses plaintext outside localhost
+	if ok, _ := client.Extension("AUTH"); ok && m.auth != nil {
+		if err := client.Auth(m.auth); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("smtp auth failed: %w", err)
+		}
+	}
+
+	return &pooledSMTPConn{client: client, conn: conn, lastUsed: time.Now()}, nil
+}
+
+// classifySMTPError maps reply codes to temporary (4xx) or permanent (5xx), anything else lost the connection
+func classifySMTPError(err error) *domain.MailError {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		class := domain.MailErrorTemporary
+		if protoErr.Code >= 500 {
+			class = domain.MailErrorPermanent
+		}
+		return &domain.MailError{Class: class, Code: protoErr.Code, Err: errors.New(protoErr.Msg)}
+	}
+
+	return &domain.MailError{Class: domain.MailErrorConnection, Err: err}
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// PasswordPolicy is the strength every new staff password must meet.
+// It is read from PASSWORD_MIN_LENGTH and PASSWORD_REQUIRE_UPPER, _LOWER, _DIGIT and _SYMBOL.
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+}
+
+// GetPasswordPolicy defaults to at least 8 characters with a lower case letter, an upper case letter and a digit
+func GetPasswordPolicy() PasswordPolicy {
+	policy := PasswordPolicy{
+		MinLength:     8,
+		RequireUpper:  envBool("PASSWORD_REQUIRE_UPPER", true),
+		RequireLower:  envBool("PASSWORD_REQUIRE_LOWER", true),
+		RequireDigit:  envBool("PASSWORD_REQUIRE_DIGIT", true),
+		RequireSymbol: envBool("PASSWORD_REQUIRE_SYMBOL", false),
+	}
+	if v, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH")); err == nil && v > 0 {
+		policy.MinLength = v
+	}
+	return policy
+}
+
+// Validate lists every rule the password breaks, nil means it is strong enough
+func (p PasswordPolicy) Validate(password string) []string {
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+
+	var errList []string
+	if len([]rune(password)) < p.MinLength {
+		errList = append(errList, fmt.Sprintf("password must be at least %d characters", p.MinLength))
+	}
+	// bcrypt ignores everything after 72 bytes
+	if len(password) > 72 {
+		errList = append(errList, "password must not be longer than 72 bytes")
+	}
+	if p.RequireUpper && !upper {
+		errList = append(errList, "password must contain an upper case letter")
+	}
+	if p.RequireLower && !lower {
+		errList = append(errList, "password must contain a lower case letter")
+	}
+	if p.RequireDigit && !digit {
+		errList = append(errList, "password must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		errList = append(errList, "password must contain a symbol")
+	}
+
+	return errList
+}
+
+func envBool(key string, fallback bool) bool {
+	v, err := strconv.ParseBool(strings.TrimSpace(os.Getenv(key)))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
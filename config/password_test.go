@@ -0,0 +1,73 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	strict := PasswordPolicy{MinLength: 8, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+	lenient := PasswordPolicy{MinLength: 4}
+
+	tests := []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		want     []string
+	}{
+		{"meets every rule", strict, "Secret12!", nil},
+		{"too short", strict, "Se1!", []string{"password must be at least 8 characters"}},
+		{"length counts characters, not bytes", PasswordPolicy{MinLength: 4}, "äöüß", nil},
+		{"longer than bcrypt reads", lenient, strings.Repeat("a", 73), []string{"password must not be longer than 72 bytes"}},
+		{"exactly 72 bytes", lenient, strings.Repeat("a", 72), nil},
+		{"no upper case", strict, "secret12!", []string{"password must contain an upper case letter"}},
+		{"no lower case", strict, "SECRET12!", []string{"password must contain a lower case letter"}},
+		{"no digit", strict, "Secretxx!", []string{"password must contain a digit"}},
+		{"no symbol", strict, "Secret123", []string{"password must contain a symbol"}},
+		{"every rule broken", strict, "", []string{
+			"password must be at least 8 characters",
+			"password must contain an upper case letter",
+			"password must contain a lower case letter",
+			"password must contain a digit",
+			"password must contain a symbol",
+		}},
+		{"rules that are not required are ignored", lenient, "abcd", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Validate(tt.password); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate(%q) = %q, want %q", tt.password, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetPasswordPolicy(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want PasswordPolicy
+	}{
+		{"defaults", nil, PasswordPolicy{MinLength: 8, RequireUpper: true, RequireLower: true, RequireDigit: true}},
+		{"configured", map[string]string{
+			"PASSWORD_MIN_LENGTH":     "12",
+			"PASSWORD_REQUIRE_UPPER":  "false",
+			"PASSWORD_REQUIRE_SYMBOL": "true",
+		}, PasswordPolicy{MinLength: 12, RequireLower: true, RequireDigit: true, RequireSymbol: true}},
+		{"invalid values fall back", map[string]string{
+			"PASSWORD_MIN_LENGTH":    "-3",
+			"PASSWORD_REQUIRE_DIGIT": "sometimes",
+		}, PasswordPolicy{MinLength: 8, RequireUpper: true, RequireLower: true, RequireDigit: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"PASSWORD_MIN_LENGTH", "PASSWORD_REQUIRE_UPPER", "PASSWORD_REQUIRE_LOWER", "PASSWORD_REQUIRE_DIGIT", "PASSWORD_REQUIRE_SYMBOL"} {
+				t.Setenv(key, tt.env[key])
+			}
+			if got := GetPasswordPolicy(); got != tt.want {
+				t.Errorf("GetPasswordPolicy() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,142 +1,107 @@
 package config
 
 import (
-	"context"
 	"encoding/base64"
 	"fmt"
 	"net/smtp"
+	"notification/domain"
 	"os"
 	"path/filepath"
-	"sync"
 
 	_ "github.com/lib/pq"
 	"github.com/skip2/go-qrcode"
 
-	"go.mau.fi/whatsmeow"
 	"go.mau.fi/whatsmeow/store/sqlstore"
 )
 
-var (
-	meowWhatsapp *whatsmeow.Client
-	qrCodeSent   bool
-	mu           sync.Mutex
-)
-
-func InitSender() (*whatsmeow.Client, smtp.Auth, *string, *string, *string, error) {
+func InitSender() (*WhatsappSession, *SMTPMailer, *string, *string, error) {
 	// SMTP Emailer
 	emailSender, err := getSender()
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 
 	emailPassword, err := getPassword()
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 
 	smtpHost, err := getHost()
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 
 	smtpPort, err := getSMTPPort()
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 
 	schoolPhone, err := getSchoolPhone()
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 
 	smtpAuth := smtp.PlainAuth("", *emailSender, *emailPassword, *smtpHost)
 
-	smtpAddr := fmt.Sprintf("%s:%s", *smtpHost, *smtpPort)
+	mailer, err := NewSMTPMailer(*smtpHost, *smtpPort, smtpAuth)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
 
-	fmt.Println("SMTP initialized")
+	GetLogrusInstance().Info("SMTP initialized")
 
 	//Meow
 	dbms, err := getDBMS()
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 
 	user, err := getDBUser()
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 
 	pass, err := getDBPassword()
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 
 	dbname, err := getDBName()
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 
 	meowAddress := fmt.Sprintf("user=%s password=%s dbname=%s sslmode=disable", *user, *pass, *dbname)
 
 	container, err := sqlstore.New(*dbms, meowAddress, nil)
 	if err != nil {
-		panic(err)
+		return nil, nil, nil, nil, fmt.Errorf("failed to open whatsapp store: %w", err)
 	}
 
 	deviceStore, err := container.GetFirstDevice()
 	if err != nil {
-		panic(err)
+		return nil, nil, nil, nil, fmt.Errorf("failed to load whatsapp device: %w", err)
 	}
-	mClient := whatsmeow.NewClient(deviceStore, nil)
-	meowWhatsapp = mClient
+	whatsappSession = newWhatsappSession(deviceStore, container, mailer, *emailSender)
 
-	if meowWhatsapp.Store.ID == nil {
-		qrChan, _ := meowWhatsapp.GetQRChannel(context.Background())
-		err = meowWhatsapp.Connect()
+	// WhatsApp problems never block startup, the channel reports "unavailable" until the session returns
+	if deviceStore.ID == nil {
+		// The first QR code is mailed to the sender address, admins can also fetch it from the session API
+		err = whatsappSession.startLogin(true)
 		if err != nil {
-			panic(err)
-		}
-
-		// Process QR code
-		for evt := range qrChan {
-			if evt.Event == "code" {
-				mu.Lock()
-				if !qrCodeSent {
-					fmt.Println("")
-					fmt.Println("IMPORTANT no WhatsApp session was found !!")
-					fmt.Println("Need admin to scan the QR code for the server to run properly!")
-					// fmt.Println("==============   QR CODE   ==============")
-					// fmt.Println(evt.Code)
-					fmt.Println("Loading...")
-
-					err := generateQRCode(evt.Code, "qrcode.png")
-					if err != nil {
-						panic(err)
-					}
-
-					err = SendQRtoEmail(smtpAddr, &smtpAuth, *emailSender, "qrcode.png")
-					if err != nil {
-						panic(err)
-					}
-					fmt.Printf("Image of QR Code is sent to %s, go ahead and scan them :)\n", *emailSender)
-					fmt.Println("")
-
-					qrCodeSent = true
-				}
-				mu.Unlock()
-			} else {
-				fmt.Println("Login event:", evt.Event)
-			}
+			GetLogrusInstance().WithError(err).Warn("WhatsApp login could not start, link it later from the session API")
 		}
 	} else {
-		err = meowWhatsapp.Connect()
+		err = whatsappSession.Client().Connect()
 		if err != nil {
-			panic(err)
+			GetLogrusInstance().WithError(err).Warn("WhatsApp unavailable, reconnecting in background")
+			go whatsappSession.reconnect()
+		} else {
+			GetLogrusInstance().Info("WhatsMeow initialized")
 		}
-		fmt.Println("WhatsMeow initialized")
 	}
 
-	return meowWhatsapp, smtpAuth, &smtpAddr, schoolPhone, emailSender, nil
+	return whatsappSession, mailer, schoolPhone, emailSender, nil
 }
 func getSender() (*string, error) {
 	sender := os.Getenv("EMAIL_SENDER")
@@ -219,7 +184,7 @@ func generateQRCode(data, filePath string) error {
 	return nil
 }
 
-func SendQRtoEmail(smtpAddr string, smtpAuth *smtp.Auth, emailSender string, qrFilePath string) error {
+func SendQRtoEmail(mailer domain.Mailer, emailSender string, qrFilePath string) error {
 	// Subject and body of the email
 	subject := "Subject: SINOAN QR Code Login\n"
 	body := "Please find the attached QR code for login.\n\n"
@@ -253,7 +218,7 @@ func SendQRtoEmail(smtpAddr string, smtpAuth *smtp.Auth, emailSender string, qrF
 	msg = append(msg, []byte("\n--"+boundary+"--")...)
 
 	// Send the email with the attachment
-	err = smtp.SendMail(smtpAddr, *smtpAuth, emailSender, []string{emailSender}, msg)
+	err = mailer.Send(emailSender, []string{emailSender}, msg)
 	if err != nil {
 		return fmt.Errorf("failed to send email: %v", err)
 	}
@@ -0,0 +1,445 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"notification/domain"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/store/sqlstore"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+var whatsappSession *WhatsappSession
+
+// Reconnect attempts back off exponentially between these bounds
+const (
+	whatsappReconnectMinDelay = 2 * time.Second
+	whatsappReconnectMaxDelay = 5 * time.Minute
+)
+
+// ErrWhatsappAlreadyLinked is returned when a login is requested while a device is still linked
+var ErrWhatsappAlreadyLinked = errors.New("whatsapp is already linked, log out first")
+
+// WhatsappSession owns the whatsmeow client and lets admins (re)link it without restarting the server.
+// Logging out publishes a fresh client for the new device, so callers fetch it through Client on every use
+// and event handlers are registered through the session to be carried over to the next client.
+type WhatsappSession struct {
+	mu        sync.Mutex
+	client    atomic.Pointer[whatsmeow.Client]
+	container *sqlstore.Container
+	handlers  []whatsmeow.EventHandler
+
+	// login flow
+	loginCancel context.CancelFunc
+	qrCode      string
+	qrExpiresAt time.Time
+	qrReady     chan struct{}
+
+	// mail the first QR code of a login flow to the sender address
+	mailer      domain.Mailer
+	emailSender string
+
+	lastConnectedAt *time.Time
+	lastEvent       string
+	reconnecting    bool
+	outbox          domain.WhatsappOutboxRepo
+}
+
+func newWhatsappSession(device *store.Device, container *sqlstore.Container, mailer domain.Mailer, emailSender string) *WhatsappSession {
+	session := &WhatsappSession{
+		container:   container,
+		mailer:      mailer,
+		emailSender: emailSender,
+	}
+	session.client.Store(session.newClient(device))
+	return session
+}
+
+// newClient creates a client for device with the session and every registered handler attached.
+// The caller must hold s.mu once the session is in use.
+func (s *WhatsappSession) newClient(device *store.Device) *whatsmeow.Client {
+	client := whatsmeow.NewClient(device, nil)
+	// Reconnection is handled by the session so it can back off and report its state
+	client.EnableAutoReconnect = false
+	client.AddEventHandler(s.handleEvent)
+	for _, handler := range s.handlers {
+		client.AddEventHandler(handler)
+	}
+	return client
+}
+
+// Client returns the current whatsmeow client. It is replaced when the device is logged out.
+func (s *WhatsappSession) Client() *whatsmeow.Client {
+	return s.client.Load()
+}
+
+// AddEventHandler registers handler on the current client and on every client that replaces it
+func (s *WhatsappSession) AddEventHandler(handler whatsmeow.EventHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handlers = append(s.handlers, handler)
+	s.client.Load().AddEventHandler(handler)
+}
+
+// AttachOutbox flushes messages queued while WhatsApp was unavailable every time the session connects
+func (s *WhatsappSession) AttachOutbox(outbox domain.WhatsappOutboxRepo) {
+	s.mu.Lock()
+	s.outbox = outbox
+	s.mu.Unlock()
+
+	// The session may have connected before the outbox was attached
+	if s.Client().IsLoggedIn() {
+		go s.flushOutbox(outbox)
+	}
+}
+
+// GetWhatsappSession returns the session created by InitSender
+func GetWhatsappSession() *WhatsappSession {
+	return whatsappSession
+}
+
+func (s *WhatsappSession) handleEvent(evt interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch v := evt.(type) {
+	case *events.Connected:
+		now := time.Now()
+		s.lastConnectedAt = &now
+		s.lastEvent = "connected"
+		if s.outbox != nil {
+			go s.flushOutbox(s.outbox)
+		}
+	case *events.Disconnected:
+		s.lastEvent = "disconnected"
+		go s.reconnect()
+	case *events.LoggedOut:
+		s.lastEvent = fmt.Sprintf("logged out: %s", v.Reason.String())
+	case *events.PairSuccess:
+		s.lastEvent = fmt.Sprintf("paired with %s", v.ID.User)
+		s.qrCode = ""
+	}
+}
+
+// Status reports the current state of the linked device
+func (s *WhatsappSession) Status() domain.WhatsappSessionStatus {
+	client := s.Client()
+	s.mu.Lock()
+
+	status := domain.WhatsappSessionStatus{
+		Connected:       client.IsConnected(),
+		LoggedIn:        client.IsLoggedIn(),
+		LastConnectedAt: s.lastConnectedAt,
+		LastEvent:       s.lastEvent,
+	}
+
+	if client.Store.ID != nil {
+		status.JID = client.Store.ID.User
+		status.PushName = client.Store.PushName
+	}
+
+	if s.qrCode != "" && time.Now().Before(s.qrExpiresAt) {
+		expiresAt := s.qrExpiresAt
+		status.QRAvailable = true
+		status.QRExpiresAt = &expiresAt
+	}
+
+	switch {
+	case status.LoggedIn:
+		status.State = domain.WhatsappStateConnected
+	case s.loginCancel != nil:
+		status.State = domain.WhatsappStateAwaitingLogin
+	case client.Store.ID != nil:
+		status.State = domain.WhatsappStateUnavailable
+	default:
+		status.State = domain.WhatsappStateLoggedOut
+	}
+
+	outbox := s.outbox
+	s.mu.Unlock()
+
+	if outbox != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if counter, err := outbox.PendingCount(ctx); err == nil {
+			status.QueuedMessages = counter
+		}
+	}
+
+	return status
+}
+
+// IsAvailable reports whether messages can be sent right now
+func (s *WhatsappSession) IsAvailable() bool {
+	return s.Client().IsLoggedIn()
+}
+
+// reconnect keeps trying to reach WhatsApp with exponential backoff until it connects or the device is unlinked
+func (s *WhatsappSession) reconnect() {
+	s.mu.Lock()
+	if s.reconnecting || s.loginCancel != nil {
+		s.mu.Unlock()
+		return
+	}
+	s.reconnecting = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.reconnecting = false
+		s.mu.Unlock()
+	}()
+
+	delay := whatsappReconnectMinDelay
+	for attempt := 1; ; attempt++ {
+		time.Sleep(delay)
+
+		client := s.Client()
+		if client.Store.ID == nil || client.IsConnected() {
+			return
+		}
+
+		err := client.Connect()
+		if err == nil || errors.Is(err, whatsmeow.ErrAlreadyConnected) {
+			GetLogrusInstance().WithField("attempt", attempt).Info("WhatsApp reconnected")
+			return
+		}
+
+		s.mu.Lock()
+		s.lastEvent = fmt.Sprintf("reconnect attempt %d failed: %v", attempt, err)
+		s.mu.Unlock()
+		GetLogrusInstance().WithError(err).WithFields(logrus.Fields{
+			"attempt":  attempt,
+			"retry_in": (delay * 2).String(),
+		}).Warn("WhatsApp reconnect attempt failed")
+
+		delay *= 2
+		if delay > whatsappReconnectMaxDelay {
+			delay = whatsappReconnectMaxDelay
+		}
+	}
+}
+
+func (s *WhatsappSession) flushOutbox(outbox domain.WhatsappOutboxRepo) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	sent, err := outbox.Flush(ctx)
+	if err != nil {
+		Logger(ctx).WithError(err).Error("Failed to flush queued WhatsApp messages")
+	}
+	if sent > 0 {
+		Logger(ctx).WithField("sent", sent).Info("Sent queued WhatsApp messages")
+	}
+}
+
+// QRCode returns the current login QR code, starting a login flow if none is running
+func (s *WhatsappSession) QRCode(ctx context.Context) (*string, error) {
+	if err := s.startLogin(false); err != nil {
+		return nil, err
+	}
+
+	if err := s.waitForQR(ctx); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	code := s.qrCode
+	s.mu.Unlock()
+
+	return &code, nil
+}
+
+// PairPhone requests an 8 character pairing code for the given phone number as an alternative to the QR code
+func (s *WhatsappSession) PairPhone(ctx context.Context, phone string) (*string, error) {
+	if err := s.startLogin(false); err != nil {
+		return nil, err
+	}
+
+	// Pairing codes can only be requested once the login websocket is up, which the first QR code signals
+	if err := s.waitForQR(ctx); err != nil {
+		return nil, err
+	}
+
+	code, err := s.Client().PairPhone(phone, true, whatsmeow.PairClientChrome, "Chrome (Linux)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to request pairing code: %w", err)
+	}
+
+	return &code, nil
+}
+
+// Logout unlinks the current device and prepares a fresh one, so another number can be linked afterwards
+func (s *WhatsappSession) Logout(ctx context.Context) error {
+	s.mu.Lock()
+	if s.loginCancel != nil {
+		s.loginCancel()
+		s.loginCancel = nil
+	}
+	s.mu.Unlock()
+
+	// Network calls run without the lock, event handlers need it while the client talks to the server
+	client := s.Client()
+	if client.Store.ID != nil {
+		if err := client.Logout(); err != nil {
+			// The phone may have removed us already, drop the local session anyway
+			client.Disconnect()
+			if err := client.Store.Delete(); err != nil {
+				return fmt.Errorf("failed to delete whatsapp session: %w", err)
+			}
+		}
+	} else {
+		client.Disconnect()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The old client keeps its device, senders pick up the fresh one on their next call
+	s.client.Store(s.newClient(s.container.NewDevice()))
+	s.qrCode = ""
+	s.lastEvent = "logged out by admin"
+
+	return nil
+}
+
+// startLogin connects an unlinked client and keeps the latest QR code in memory until the device is paired
+func (s *WhatsappSession) startLogin(notifyByEmail bool) error {
+	s.mu.Lock()
+	if s.loginCancel != nil {
+		s.mu.Unlock()
+		return nil
+	}
+
+	client := s.Client()
+	if client.Store.ID != nil {
+		s.mu.Unlock()
+		return ErrWhatsappAlreadyLinked
+	}
+
+	// Claim the login flow before connecting, so concurrent callers wait for the same QR code
+	ctx, cancel := context.WithCancel(context.Background())
+	ready := make(chan struct{})
+	s.loginCancel = cancel
+	s.qrCode = ""
+	s.qrReady = ready
+	s.mu.Unlock()
+
+	// Connecting runs without the lock, the event handlers need it while the client talks to the server
+	if client.IsConnected() {
+		client.Disconnect()
+	}
+
+	qrChan, err := client.GetQRChannel(ctx)
+	if err != nil {
+		s.abortLogin(ready, cancel)
+		return fmt.Errorf("failed to open qr channel: %w", err)
+	}
+
+	if err := client.Connect(); err != nil {
+		s.abortLogin(ready, cancel)
+		return fmt.Errorf("failed to connect to whatsapp: %w", err)
+	}
+
+	go s.consumeQR(qrChan, ready, notifyByEmail)
+
+	return nil
+}
+
+// abortLogin releases a login flow that failed to start and wakes up anyone waiting for its QR code
+func (s *WhatsappSession) abortLogin(ready chan struct{}, cancel context.CancelFunc) {
+	cancel()
+
+	s.mu.Lock()
+	if s.qrReady == ready {
+		s.loginCancel = nil
+		s.qrCode = ""
+	}
+	s.mu.Unlock()
+
+	close(ready)
+}
+
+func (s *WhatsappSession) consumeQR(qrChan <-chan whatsmeow.QRChannelItem, ready chan struct{}, notifyByEmail bool) {
+	readyClosed := false
+	for evt := range qrChan {
+		if evt.Event != whatsmeow.QRChannelEventCode {
+			GetLogrusInstance().WithField("event", evt.Event).Info("WhatsApp login event")
+			continue
+		}
+
+		s.mu.Lock()
+		s.qrCode = evt.Code
+		s.qrExpiresAt = time.Now().Add(evt.Timeout)
+		s.mu.Unlock()
+
+		if !readyClosed {
+			close(ready)
+			readyClosed = true
+
+			if notifyByEmail {
+				s.mailQRCode(evt.Code)
+			}
+		}
+	}
+
+	s.mu.Lock()
+	if s.qrReady == ready {
+		s.loginCancel = nil
+		s.qrCode = ""
+	}
+	s.mu.Unlock()
+
+	if !readyClosed {
+		close(ready)
+	}
+}
+
+func (s *WhatsappSession) waitForQR(ctx context.Context) error {
+	s.mu.Lock()
+	ready := s.qrReady
+	s.mu.Unlock()
+
+	if ready == nil {
+		return errors.New("no whatsapp login in progress")
+	}
+
+	select {
+	case <-ready:
+	case <-ctx.Done():
+		return fmt.Errorf("timed out waiting for whatsapp qr code: %w", ctx.Err())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.qrCode == "" {
+		return errors.New("whatsapp login ended before a qr code was issued")
+	}
+
+	return nil
+}
+
+func (s *WhatsappSession) mailQRCode(code string) {
+	log := GetLogrusInstance().WithField("email", s.emailSender)
+	log.Warn("No WhatsApp session was found, an admin has to scan the QR code for the server to run properly")
+
+	if err := generateQRCode(code, "qrcode.png"); err != nil {
+		log.WithError(err).Error("Failed to generate the WhatsApp QR code")
+		return
+	}
+
+	if err := SendQRtoEmail(s.mailer, s.emailSender, "qrcode.png"); err != nil {
+		log.WithError(err).Error("Failed to mail the WhatsApp QR code")
+		return
+	}
+
+	log.Info("WhatsApp QR code mailed, go ahead and scan it")
+}
@@ -0,0 +1,102 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	AnalyticsDefaultDays     = 30
+	AnalyticsDefaultTopLimit = 10
+	AnalyticsMaxTopLimit     = 100
+)
+
+// AnalyticsFilter is the period the statistics cover, To is exclusive
+type AnalyticsFilter struct {
+	From  time.Time
+	To    time.Time
+	Limit int
+}
+
+// ClassAbsenceStat and the other statistics count an absence once per student, subject and day, however many guardians were notified.
+// Only absences are recorded, not every lesson held, so Rate is the number of absences per enrolled student.
+type ClassAbsenceStat struct {
+	Grade          int     `json:"grade"`
+	GradeLabel     string  `json:"grade_label"`
+	Students       int64   `json:"students"`
+	AbsentStudents int64   `json:"absent_students"`
+	Absences       int64   `json:"absences"`
+	Rate           float64 `json:"rate"`
+}
+
+type SubjectAbsenceStat struct {
+	SubjectCode    string  `json:"subject_code"`
+	Name           string  `json:"name"`
+	Grade          int     `json:"grade"`
+	Students       int64   `json:"students"`
+	AbsentStudents int64   `json:"absent_students"`
+	Absences       int64   `json:"absences"`
+	Rate           float64 `json:"rate"`
+}
+
+// TeacherAbsenceStat counts the absences a teacher reported, Students are the ones in the grades they teach
+type TeacherAbsenceStat struct {
+	UserID         int     `json:"user_id"`
+	Name           string  `json:"name"`
+	Students       int64   `json:"students"`
+	AbsentStudents int64   `json:"absent_students"`
+	Absences       int64   `json:"absences"`
+	Rate           float64 `json:"rate"`
+}
+
+type MonthlyAbsenceStat struct {
+	Month          string  `json:"month"`
+	Students       int64   `json:"students"`
+	AbsentStudents int64   `json:"absent_students"`
+	Absences       int64   `json:"absences"`
+	Rate           float64 `json:"rate"`
+}
+
+type StudentAbsenceStat struct {
+	StudentNSN   string    `json:"student_nsn"`
+	Name         string    `json:"name"`
+	Grade        int       `json:"grade"`
+	GradeLabel   string    `json:"grade_label"`
+	Absences     int64     `json:"absences"`
+	Subjects     int64     `json:"subjects"`
+	LastAbsentOn time.Time `json:"last_absent_on"`
+}
+
+type AbsencePeriodStat struct {
+	From           time.Time `json:"from"`
+	To             time.Time `json:"to"`
+	Students       int64     `json:"students"`
+	AbsentStudents int64     `json:"absent_students"`
+	Absences       int64     `json:"absences"`
+	Rate           float64   `json:"rate"`
+}
+
+// AbsenceTrend compares a period with the one of the same length right before it, Change is nil when the previous period had no absences
+type AbsenceTrend struct {
+	Current  AbsencePeriodStat `json:"current"`
+	Previous AbsencePeriodStat `json:"previous"`
+	Change   *float64          `json:"change_percent"`
+}
+
+type AnalyticsRepo interface {
+	GetClassAbsenceStats(ctx context.Context, userID int, filter *AnalyticsFilter) (*[]ClassAbsenceStat, error)
+	GetSubjectAbsenceStats(ctx context.Context, userID int, filter *AnalyticsFilter) (*[]SubjectAbsenceStat, error)
+	GetTeacherAbsenceStats(ctx context.Context, userID int, filter *AnalyticsFilter) (*[]TeacherAbsenceStat, error)
+	GetMonthlyAbsenceStats(ctx context.Context, userID int, filter *AnalyticsFilter) (*[]MonthlyAbsenceStat, error)
+	GetTopAbsentStudents(ctx context.Context, userID int, filter *AnalyticsFilter) (*[]StudentAbsenceStat, error)
+	GetAbsenceTrend(ctx context.Context, userID int, filter *AnalyticsFilter) (*AbsenceTrend, error)
+}
+
+type AnalyticsUseCase interface {
+	GetClassAbsenceStats(ctx context.Context, userID int, filter *AnalyticsFilter) (*[]ClassAbsenceStat, error)
+	GetSubjectAbsenceStats(ctx context.Context, userID int, filter *AnalyticsFilter) (*[]SubjectAbsenceStat, error)
+	GetTeacherAbsenceStats(ctx context.Context, userID int, filter *AnalyticsFilter) (*[]TeacherAbsenceStat, error)
+	GetMonthlyAbsenceStats(ctx context.Context, userID int, filter *AnalyticsFilter) (*[]MonthlyAbsenceStat, error)
+	GetTopAbsentStudents(ctx context.Context, userID int, filter *AnalyticsFilter) (*[]StudentAbsenceStat, error)
+	GetAbsenceTrend(ctx context.Context, userID int, filter *AnalyticsFilter) (*AbsenceTrend, error)
+}
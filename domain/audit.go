@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditActionCreate        = "create"
+	AuditActionUpdate        = "update"
+	AuditActionDelete        = "delete"
+	AuditActionImport        = "import"
+	AuditActionApprove       = "approve"
+	AuditActionPasswordReset = "password_reset"
+	AuditActionLockout       = "lockout"
+	AuditActionUnlock        = "unlock"
+
+	AuditEntityStaff             = "staff"
+	AuditEntitySubject           = "subject"
+	AuditEntityStudent           = "student"
+	AuditEntityParent            = "parent"
+	AuditEntityStudentGuardian   = "student_guardian"
+	AuditEntityDataChangeRequest = "data_change_request"
+	AuditEntityTestScore         = "test_score"
+	AuditEntityLoginThrottle     = "login_throttle"
+	AuditEntityRole              = "role"
+
+	AuditLogDefaultLimit = 50
+	AuditLogMaxLimit     = 200
+)
+
+// AuditLog records one administrative change, rows are only ever inserted.
+// Before and After hold the fields that changed, Before is empty on create and After on delete.
+type AuditLog struct {
+	AuditLogID    int             `gorm:"primaryKey;autoIncrement" json:"audit_log_id"`
+	ActorID       *int            `gorm:"index" json:"actor_id"`
+	ActorUsername string          `gorm:"type:varchar(50)" json:"actor_username"`
+	Action        string          `gorm:"type:varchar(20);not null;index" json:"action"`
+	Entity        string          `gorm:"type:varchar(30);not null;index:idx_audit_logs_entity" json:"entity"`
+	EntityID      string          `gorm:"type:varchar(50);not null;index:idx_audit_logs_entity" json:"entity_id"`
+	Before        json.RawMessage `gorm:"type:jsonb" json:"before"`
+	After         json.RawMessage `gorm:"type:jsonb" json:"after"`
+	CreatedAt     time.Time       `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// AuditLogFilter narrows the audit log, every field is optional
+type AuditLogFilter struct {
+	From     *time.Time
+	To       *time.Time
+	ActorID  int
+	Action   string
+	Entity   string
+	EntityID string
+	Cursor   string
+	Limit    int
+}
+
+// AuditLogPage is one page of the audit log, newest first, NextCursor is empty on the last page
+type AuditLogPage struct {
+	Items      []AuditLog `json:"items"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+type AuditRepo interface {
+	GetAuditLogs(ctx context.Context, filter *AuditLogFilter) (*AuditLogPage, error)
+}
+
+type AuditUseCase interface {
+	GetAuditLogs(ctx context.Context, filter *AuditLogFilter) (*AuditLogPage, error)
+}
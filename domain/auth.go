@@ -2,32 +2,178 @@ package domain
 
 import (
 	"context"
+	"fmt"
+	"math"
+	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
+const (
+	AccessTokenTTL   = 15 * time.Minute
+	RefreshTokenTTL  = 7 * 24 * time.Hour
+	PasswordResetTTL = 30 * time.Minute
+
+	PasswordResetChannelEmail    = "email"
+	PasswordResetChannelWhatsapp = "whatsapp"
+
+	LoginThrottleUsername = "username"
+	LoginThrottleIP       = "ip"
+
+	// Failures older than LoginFailureWindow are forgotten. From LoginDelayAfterFailures on every
+	// failure doubles the wait before the next attempt up to LoginMaxDelay, at the lockout count
+	// the username or address is refused for LoginLockoutDuration.
+	LoginFailureWindow           = 15 * time.Minute
+	LoginDelayAfterFailures      = 3
+	LoginMaxDelay                = 30 * time.Second
+	LoginUsernameLockoutFailures = 10
+	LoginIPLockoutFailures       = 30
+	LoginLockoutDuration         = 15 * time.Minute
+)
+
 type LoginRequest struct {
 	Username string `json:"username" valid:"required~Username is required"`
 	Password string `json:"password" valid:"required~Password is required"`
+	// UserAgent and IP describe the device the session is opened from
+	UserAgent string `json:"-"`
+	IP        string `json:"-"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" valid:"required~Refresh token is required"`
 }
 
 type LoginResponse struct {
-	Token    string `json:"token"`
-	Role     string `json:"role"`
-	Username string `json:"username"`
+	Token              string `json:"token"`
+	RefreshToken       string `json:"refresh_token"`
+	ExpiresIn          int    `json:"expires_in"`
+	Role               string `json:"role"`
+	Username           string `json:"username"`
+	MustChangePassword bool   `json:"must_change_password"`
 }
 
+// Claims are carried by the access token, SessionID ties it to the session it can be revoked with.
+// A token with MustChangePassword only opens the password change and logout endpoints.
 type Claims struct {
-	UserID   int    `json:"user_id"`
-	Username string `json:"username"`
-	Role     string `json:"role"`
+	UserID             int    `json:"user_id"`
+	Username           string `json:"username"`
+	Role               string `json:"role"`
+	SessionID          string `json:"sid"`
+	MustChangePassword bool   `json:"pwd,omitempty"`
 	jwt.RegisteredClaims
 }
 
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" valid:"required~Current password is required"`
+	NewPassword     string `json:"new_password" valid:"required~New password is required"`
+	UserAgent       string `json:"-"`
+	IP              string `json:"-"`
+}
+
+// PasswordResetRequest is sent by an admin, Channel is email or whatsapp and defaults to whichever the staff member has
+type PasswordResetRequest struct {
+	Channel string `json:"channel"`
+}
+
+// PasswordResetDelivery tells where a reset code went, Status is sent or queued when WhatsApp is down
+type PasswordResetDelivery struct {
+	Channel string `json:"channel"`
+	Status  string `json:"status"`
+}
+
+type ResetPasswordRequest struct {
+	Code        string `json:"code" valid:"required~Code is required"`
+	NewPassword string `json:"new_password" valid:"required~New password is required"`
+}
+
+// PasswordReset is a one-time code an admin had sent to a staff member, only its hash is kept
+type PasswordReset struct {
+	CodeHash    string    `gorm:"primaryKey;type:char(64)"`
+	UserID      int       `gorm:"not null;index"`
+	User        User      `gorm:"foreignKey:UserID;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	RequestedBy *int      `gorm:"index"`
+	Channel     string    `gorm:"type:varchar(10);not null"`
+	ExpiresAt   time.Time `gorm:"not null"`
+	UsedAt      *time.Time
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+}
+
+// LoginThrottle counts the recent failed logins of one username or one address
+type LoginThrottle struct {
+	Kind         string     `gorm:"primaryKey;type:varchar(10)" json:"kind"`
+	Subject      string     `gorm:"primaryKey;type:varchar(50)" json:"subject"`
+	Failures     int        `gorm:"not null;default:0" json:"failures"`
+	LastFailedAt time.Time  `gorm:"not null;index" json:"last_failed_at"`
+	LockedUntil  *time.Time `json:"locked_until"`
+}
+
+// LoginUnlockRequest clears the failed logins of a username, an address or both
+type LoginUnlockRequest struct {
+	Username string `json:"username"`
+	IP       string `json:"ip"`
+}
+
+// LoginThrottledError refuses a login until RetryAfter has passed, Locked tells a lockout from a delay
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (e *LoginThrottledError) Error() string {
+	seconds := int(math.Ceil(e.RetryAfter.Seconds()))
+	if e.Locked {
+		return fmt.Sprintf("too many failed logins, locked for %d seconds", seconds)
+	}
+	return fmt.Sprintf("too many failed logins, try again in %d seconds", seconds)
+}
+
+// UserSession is one login on one device, revoking it ends every token issued for it
+type UserSession struct {
+	SessionID  string     `gorm:"primaryKey;type:varchar(64)" json:"session_id"`
+	UserID     int        `gorm:"not null;index" json:"user_id"`
+	User       User       `gorm:"foreignKey:UserID;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	UserAgent  string     `gorm:"type:varchar(255)" json:"user_agent"`
+	IP         string     `gorm:"type:varchar(64)" json:"ip"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt  *time.Time `gorm:"index" json:"revoked_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// RefreshToken is stored as a hash and can be used once, refreshing hands out its successor.
+// A token presented a second time means it leaked, the whole session is revoked.
+type RefreshToken struct {
+	TokenHash string      `gorm:"primaryKey;type:char(64)"`
+	SessionID string      `gorm:"type:varchar(64);not null;index"`
+	Session   UserSession `gorm:"foreignKey:SessionID;references:SessionID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	ExpiresAt time.Time   `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
 type AuthRepo interface {
-	Login(ctx context.Context, data *LoginRequest) (*[]string, error)
+	Login(ctx context.Context, data *LoginRequest) (*LoginResponse, error)
+	Refresh(ctx context.Context, refreshToken string) (*LoginResponse, error)
+	PurgeExpiredSessions(ctx context.Context) (int64, error)
+	Logout(ctx context.Context, userID int, sessionID string) error
+	LogoutAll(ctx context.Context, userID int) error
+	IsSessionActive(ctx context.Context, userID int, sessionID string) (bool, error)
+	ChangePassword(ctx context.Context, userID int, req *ChangePasswordRequest) (*LoginResponse, *[]string, error)
+	RequestPasswordReset(ctx context.Context, userID int, channel string) (*PasswordResetDelivery, error)
+	ResetPassword(ctx context.Context, req *ResetPasswordRequest) (*[]string, error)
+	GetLoginThrottles(ctx context.Context) (*[]LoginThrottle, error)
+	UnlockLogin(ctx context.Context, req *LoginUnlockRequest) error
 }
 
 type AuthUseCase interface {
-	Login(ctx context.Context, data *LoginRequest) (*[]string, error)
+	Login(ctx context.Context, data *LoginRequest) (*LoginResponse, error)
+	Refresh(ctx context.Context, refreshToken string) (*LoginResponse, error)
+	PurgeExpiredSessions(ctx context.Context) (int64, error)
+	Logout(ctx context.Context, userID int, sessionID string) error
+	LogoutAll(ctx context.Context, userID int) error
+	ChangePassword(ctx context.Context, userID int, req *ChangePasswordRequest) (*LoginResponse, *[]string, error)
+	RequestPasswordReset(ctx context.Context, userID int, channel string) (*PasswordResetDelivery, error)
+	ResetPassword(ctx context.Context, req *ResetPasswordRequest) (*[]string, error)
+	GetLoginThrottles(ctx context.Context) (*[]LoginThrottle, error)
+	UnlockLogin(ctx context.Context, req *LoginUnlockRequest) error
 }
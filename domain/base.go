@@ -5,14 +5,54 @@ import (
 )
 
 type SafeStaffData struct {
-	UserID    int        `json:"user_id"`
-	Username  string     `json:"username"`
-	Name      string     `json:"name"`
-	Role      string     `json:"role"`
-	CreatedAt time.Time  `json:"created_at"`
-	UpdatedAt time.Time  `json:"updated_at"`
-	DeletedAt *time.Time `json:"deleted_at"`
-	Teaching  []Subject  `json:"teaching"`
+	UserID             int        `json:"user_id"`
+	Username           string     `json:"username"`
+	Name               string     `json:"name"`
+	Role               string     `json:"role"`
+	Email              *string    `json:"email"`
+	Telephone          *string    `json:"telephone"`
+	MustChangePassword bool       `json:"must_change_password"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+	DeletedAt          *time.Time `json:"deleted_at"`
+	Teaching           []Subject  `json:"teaching"`
+}
+
+// ProfileDashboard is the profile of the signed in user, AdminMetrics is filled for roles allowed to view analytics and StaffMetrics otherwise
+type ProfileDashboard struct {
+	SafeStaffData
+	StaffMetrics *StaffDashboardMetrics `json:"staff_metrics,omitempty"`
+	AdminMetrics *AdminDashboardMetrics `json:"admin_metrics,omitempty"`
+}
+
+type StaffDashboardMetrics struct {
+	WeekStart                 time.Time `json:"week_start"`
+	AttendanceNoticesThisWeek int64     `json:"attendance_notices_this_week"`
+	ExamResultNoticesThisWeek int64     `json:"exam_result_notices_this_week"`
+	// FailedDeliveries counts notices of this user whose last attempt still failed on some channel
+	FailedDeliveries int64 `json:"failed_deliveries"`
+	// PendingScoreInputs is measured against the latest exam, nil when there is no exam yet
+	PendingScoreExam   *Exam               `json:"pending_score_exam"`
+	PendingScoreInputs []PendingScoreInput `json:"pending_score_inputs"`
+}
+
+type PendingScoreInput struct {
+	SubjectCode string `json:"subject_code"`
+	Name        string `json:"name"`
+	Grade       int    `json:"grade"`
+	Students    int64  `json:"students"`
+	Scored      int64  `json:"scored"`
+	Pending     int64  `json:"pending"`
+}
+
+type AdminDashboardMetrics struct {
+	PendingDataChangeRequests int64                 `json:"pending_data_change_requests"`
+	Whatsapp                  WhatsappSessionStatus `json:"whatsapp"`
+	QueueDepth                int64                 `json:"queue_depth"`
+	DeliveriesSentToday       int64                 `json:"deliveries_sent_today"`
+	DeliveriesFailedToday     int64                 `json:"deliveries_failed_today"`
+	// DeliverySuccessRateToday is a percentage of sent over attempted deliveries, nil when nothing was attempted today
+	DeliverySuccessRateToday *float64 `json:"delivery_success_rate_today"`
 }
 
 type SafeStaffUpdatePayload struct {
@@ -106,13 +146,34 @@ type UserResponse struct {
 }
 
 type AttendanceNotificationHistoryResponse struct {
-	Student        Student      `json:"student"`
-	Parent         Parent       `json:"parent"`
-	User           UserResponse `json:"user"`
-	Subject        Subject      `json:"subject"`
-	WhatsappStatus bool         `json:"whatsapp_status"`
-	EmailStatus    bool         `json:"email_status"`
-	CreatedAt      time.Time    `json:"created_at"`
+	NotificationHistoryID int           `json:"notification_history_id"`
+	Student               Student       `json:"student"`
+	Parent                Parent        `json:"parent"`
+	User                  UserResponse  `json:"user"`
+	Subject               Subject       `json:"subject"`
+	WhatsappStatus        bool          `json:"whatsapp_status"`
+	WhatsappDelivery      string        `json:"whatsapp_delivery"`
+	WhatsappError         *string       `json:"whatsapp_error"`
+	EmailStatus           bool          `json:"email_status"`
+	EmailDelivery         string        `json:"email_delivery"`
+	EmailError            *string       `json:"email_error"`
+	Attempts              int           `json:"attempts"`
+	Replies               []ParentReply `json:"replies"`
+	CreatedAt             time.Time     `json:"created_at"`
+	UpdatedAt             time.Time     `json:"updated_at"`
+}
+
+type ExamResultNotificationHistoryResponse struct {
+	ExamResultHistoryID int          `json:"exam_result_history_id"`
+	Exam                Exam         `json:"exam"`
+	Student             Student      `json:"student"`
+	Parent              Parent       `json:"parent"`
+	User                UserResponse `json:"user"`
+	WhatsappStatus      bool         `json:"whatsapp_status"`
+	WhatsappReason      string       `json:"whatsapp_reason,omitempty"`
+	EmailStatus         bool         `json:"email_status"`
+	EmailReason         string       `json:"email_reason,omitempty"`
+	CreatedAt           time.Time    `json:"created_at"`
 }
 
 type StudentTestScore struct {
@@ -123,6 +184,7 @@ type StudentTestScore struct {
 type InputTestScorePayload struct {
 	StudentTestScore []StudentTestScore `json:"students_test_score"`
 	SubjectCode      string             `json:"subject_code"`
+	ExamID           int                `json:"exam_id"`
 }
 
 type SubjectAndScoreResult struct {
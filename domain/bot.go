@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"context"
+	"strings"
+)
+
+// Keywords parents can send to the school WhatsApp number, both languages are always accepted
+const (
+	BotCommandScores   = "NILAI"
+	BotCommandAbsences = "ABSEN"
+	BotCommandHelp     = "BANTUAN"
+	BotCommandStop     = "BERHENTI"
+	BotCommandStart    = "MULAI"
+)
+
+var botCommandAliases = map[string]string{
+	"NILAI":    BotCommandScores,
+	"SCORES":   BotCommandScores,
+	"ABSEN":    BotCommandAbsences,
+	"ABSENCES": BotCommandAbsences,
+	"BANTUAN":  BotCommandHelp,
+	"HELP":     BotCommandHelp,
+	"BERHENTI": BotCommandStop,
+	"STOP":     BotCommandStop,
+	"MULAI":    BotCommandStart,
+	"START":    BotCommandStart,
+}
+
+// ParseBotCommand returns the canonical command for a message, or an empty string when the message is not a command
+func ParseBotCommand(text string) string {
+	fields := strings.Fields(strings.ToUpper(text))
+	if len(fields) != 1 {
+		return ""
+	}
+	return botCommandAliases[fields[0]]
+}
+
+type BotRepo interface {
+	ComposeReply(ctx context.Context, command string, parentAndStudents *StudentsAssociateWithParent) (*string, error)
+	ComposeUnregisteredReply() string
+	ComposeRateLimitedReply() string
+	ComposeTemporaryFailureReply() string
+	SendReply(ctx context.Context, telephone string, body string) error
+}
+
+type BotUseCase interface {
+	HandleCommand(ctx context.Context, telephone string, text string) (bool, error)
+}
@@ -0,0 +1,33 @@
+package domain
+
+import "testing"
+
+func TestParseBotCommand(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"NILAI", BotCommandScores},
+		{"nilai", BotCommandScores},
+		{"  Scores\n", BotCommandScores},
+		{"absen", BotCommandAbsences},
+		{"ABSENCES", BotCommandAbsences},
+		{"bantuan", BotCommandHelp},
+		{"help", BotCommandHelp},
+		{"Berhenti", BotCommandStop},
+		{"stop", BotCommandStop},
+		{"mulai", BotCommandStart},
+		{"START", BotCommandStart},
+		{"", ""},
+		{"   ", ""},
+		{"nilai anak saya", ""},
+		{"stop please", ""},
+		{"terima kasih", ""},
+		{"NILAI!", ""},
+	}
+	for _, tt := range tests {
+		if got := ParseBotCommand(tt.text); got != tt.want {
+			t.Errorf("ParseBotCommand(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
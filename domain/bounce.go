@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// SentEmail records the Message-ID of every notification email so bounces can be traced back to a parent
+type SentEmail struct {
+	SentEmailID      int        `gorm:"primaryKey;autoIncrement" json:"sent_email_id"`
+	MessageID        string     `gorm:"type:varchar(255);uniqueIndex;not null" json:"message_id"`
+	ParentID         int        `gorm:"index;not null" json:"parent_id"`
+	StudentNSN       string     `gorm:"type:varchar(10)" json:"student_nsn"`
+	Recipient        string     `gorm:"type:varchar(255);index;not null" json:"recipient"`
+	NotificationType string     `gorm:"type:varchar(20);not null" json:"notification_type"`
+	BouncedAt        *time.Time `json:"bounced_at,omitempty"`
+	CreatedAt        time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// EmailBounce is one delivery status notification read from the bounce mailbox
+type EmailBounce struct {
+	BounceID    int        `gorm:"primaryKey;autoIncrement" json:"bounce_id"`
+	MessageID   string     `gorm:"type:varchar(255);index" json:"message_id"`
+	Recipient   string     `gorm:"type:varchar(255);index" json:"recipient"`
+	Action      string     `gorm:"type:varchar(20)" json:"action"`
+	Status      string     `gorm:"type:varchar(20)" json:"status"`
+	Diagnostic  string     `gorm:"type:text" json:"diagnostic"`
+	IsHard      bool       `gorm:"not null;default:false" json:"is_hard"`
+	ParentID    *int       `gorm:"index" json:"parent_id"`
+	SentEmailID *int       `json:"sent_email_id"`
+	SentEmail   *SentEmail `gorm:"foreignKey:SentEmailID;references:SentEmailID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"-"`
+	Source      string     `gorm:"type:varchar(255);uniqueIndex:idx_bounce_source" json:"source"`
+	// SourceKey names the mailbox message exactly (maildir file name or mbox content hash),
+	// SourceIndex is the recipient block within it
+	SourceKey   string    `gorm:"type:varchar(255);uniqueIndex:idx_bounce_source_key,priority:1" json:"-"`
+	SourceIndex int       `gorm:"not null;default:0;uniqueIndex:idx_bounce_source_key,priority:2" json:"-"`
+	ReceivedAt  time.Time `gorm:"autoCreateTime" json:"received_at"`
+}
+
+// BounceProcessResult summarizes one pass over the bounce mailbox
+type BounceProcessResult struct {
+	MessagesRead  int `json:"messages_read"`
+	BouncesFound  int `json:"bounces_found"`
+	HardBounces   int `json:"hard_bounces"`
+	Unmatched     int `json:"unmatched"`
+	ParentsMarked int `json:"parents_marked"`
+}
+
+// BouncedParent is a row of the invalid email report
+type BouncedParent struct {
+	Parent      Parent        `json:"parent"`
+	Students    []Student     `json:"students"`
+	LastBounces []EmailBounce `json:"last_bounces"`
+}
+
+type BounceRepo interface {
+	ProcessMailbox(ctx context.Context) (*BounceProcessResult, error)
+	GetInvalidEmailReport(ctx context.Context) (*[]BouncedParent, error)
+}
+
+type BounceUseCase interface {
+	ProcessMailbox(ctx context.Context) (*BounceProcessResult, error)
+	GetInvalidEmailReport(ctx context.Context) (*[]BouncedParent, error)
+}
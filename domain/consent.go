@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	ChannelWhatsapp = "whatsapp"
+	ChannelEmail    = "email"
+
+	NotificationTypeAttendance = "attendance"
+	NotificationTypeExamResult = "exam_result"
+	NotificationTypeAll        = "all"
+)
+
+// ParentConsent records whether a parent wants to receive a notification type on a channel.
+// A row for a specific notification type takes precedence over the "all" row of the same channel.
+type ParentConsent struct {
+	ConsentID        int       `gorm:"primaryKey;autoIncrement" json:"consent_id"`
+	ParentID         int       `gorm:"not null;uniqueIndex:idx_parent_channel_type" json:"parent_id"`
+	Parent           Parent    `gorm:"foreignKey:ParentID;references:ParentID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	Channel          string    `gorm:"type:varchar(10);not null;uniqueIndex:idx_parent_channel_type" json:"channel" valid:"required~Channel is required,in(whatsapp|email)~Invalid channel"`
+	NotificationType string    `gorm:"type:varchar(20);not null;uniqueIndex:idx_parent_channel_type" json:"notification_type" valid:"required~Notification type is required,in(attendance|exam_result|all)~Invalid notification type"`
+	OptedOut         bool      `gorm:"not null;default:false" json:"opted_out"`
+	Source           string    `gorm:"type:varchar(20);not null" json:"source"`
+	CreatedAt        time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+type UnsubscribeClaims struct {
+	ParentID         int    `json:"parent_id"`
+	Channel          string `json:"channel"`
+	NotificationType string `json:"notification_type"`
+	jwt.RegisteredClaims
+}
+
+type ConsentRepo interface {
+	GetParentConsents(ctx context.Context, parentID int) (*[]ParentConsent, error)
+	SetParentConsent(ctx context.Context, consent *ParentConsent) error
+	SetConsentByTelephone(ctx context.Context, telephone string, channel string, notificationType string, optedOut bool, source string) error
+	Unsubscribe(ctx context.Context, token string) (*ParentConsent, error)
+}
+
+type ConsentUseCase interface {
+	GetParentConsents(ctx context.Context, parentID int) (*[]ParentConsent, error)
+	SetParentConsent(ctx context.Context, consent *ParentConsent) error
+	Unsubscribe(ctx context.Context, token string) (*ParentConsent, error)
+}
@@ -0,0 +1,11 @@
+package domain
+
+import "errors"
+
+// Repositories wrap these so handlers can tell a missing record or a bad request from a failure
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrInvalidInput = errors.New("invalid input")
+	ErrConflict     = errors.New("conflict")
+	ErrUnauthorized = errors.New("unauthorized")
+)
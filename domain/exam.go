@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	ExamTypeMidterm = "UTS"
+	ExamTypeFinal   = "UAS"
+	ExamTypeOther   = "OTHER"
+)
+
+// Exam groups the test scores of one assessment so results stay queryable after they are broadcast
+type Exam struct {
+	ExamID       int        `gorm:"primaryKey;autoIncrement" json:"exam_id"`
+	Name         string     `gorm:"type:varchar(150);not null" json:"name" valid:"required~Name is required"`
+	ExamType     string     `gorm:"type:varchar(10);not null;index" json:"exam_type" valid:"required~Exam type is required,in(UTS|UAS|OTHER)~Invalid exam type"`
+	AcademicYear string     `gorm:"type:varchar(9);not null;index" json:"academic_year" valid:"required~Academic year is required,matches(^[0-9]{4}/[0-9]{4}$)~Academic year must look like 2024/2025"`
+	Semester     int        `gorm:"not null" json:"semester"`
+	StartDate    *time.Time `gorm:"type:date" json:"start_date"`
+	EndDate      *time.Time `gorm:"type:date" json:"end_date"`
+	BroadcastAt  *time.Time `json:"broadcast_at"`
+	CreatedAt    time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt    *time.Time `gorm:"index" json:"deleted_at,omitempty"`
+}
+
+const (
+	ExamBroadcastRunning    = "running"
+	ExamBroadcastIncomplete = "incomplete"
+	ExamBroadcastCompleted  = "completed"
+)
+
+// ExamBroadcast is one run of sending the results of an exam to parents, it can be resumed until every recipient is done
+type ExamBroadcast struct {
+	BroadcastID int        `gorm:"primaryKey;autoIncrement" json:"broadcast_id"`
+	ExamID      int        `gorm:"not null;index" json:"exam_id"`
+	Exam        Exam       `gorm:"foreignKey:ExamID;references:ExamID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	UserID      int        `gorm:"not null" json:"user_id"`
+	Status      string     `gorm:"type:varchar(20);not null;index" json:"status"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// ExamBroadcastRecipient is the send state of one channel of one guardian within a broadcast
+type ExamBroadcastRecipient struct {
+	RecipientID int           `gorm:"primaryKey;autoIncrement" json:"recipient_id"`
+	BroadcastID int           `gorm:"not null;uniqueIndex:idx_broadcast_recipient" json:"broadcast_id"`
+	Broadcast   ExamBroadcast `gorm:"foreignKey:BroadcastID;references:BroadcastID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	StudentNSN  string        `gorm:"type:varchar(10);not null;uniqueIndex:idx_broadcast_recipient" json:"student_nsn"`
+	Student     Student       `gorm:"foreignKey:StudentNSN;references:StudentNSN;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"student"`
+	ParentID    int           `gorm:"not null;uniqueIndex:idx_broadcast_recipient" json:"parent_id"`
+	Parent      Parent        `gorm:"foreignKey:ParentID;references:ParentID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"parent"`
+	Channel     string        `gorm:"type:varchar(10);not null;uniqueIndex:idx_broadcast_recipient" json:"channel"`
+	Status      string        `gorm:"type:varchar(20);not null;index" json:"status"`
+	Attempts    int           `gorm:"not null;default:0" json:"attempts"`
+	Reason      *string       `gorm:"type:text" json:"reason,omitempty"`
+	SentAt      *time.Time    `json:"sent_at,omitempty"`
+	CreatedAt   time.Time     `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time     `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// ExamBroadcastSummary counts recipients per status and lists the ones still waiting to be sent
+type ExamBroadcastSummary struct {
+	Broadcast ExamBroadcast            `json:"broadcast"`
+	Counts    map[string]int64         `json:"counts"`
+	Unsent    []ExamBroadcastRecipient `json:"unsent"`
+}
+
+// ExamBroadcastReport is returned by a broadcast or a resume, dry runs only fill Results
+type ExamBroadcastReport struct {
+	BroadcastID int                   `json:"broadcast_id,omitempty"`
+	Results     []DeliveryResult      `json:"results"`
+	Summary     *ExamBroadcastSummary `json:"summary,omitempty"`
+}
+
+type ExamRepo interface {
+	CreateExam(ctx context.Context, exam *Exam) error
+	GetAllExams(ctx context.Context, academicYear string, semester int) (*[]Exam, error)
+	GetExamDetail(ctx context.Context, examID int) (*Exam, error)
+	UpdateExam(ctx context.Context, examID int, exam *Exam) error
+	DeleteExam(ctx context.Context, examID int) error
+	GetExamScores(ctx context.Context, examID int, subjectCode string) (*[]TestScore, error)
+}
+
+type ExamUseCase interface {
+	CreateExam(ctx context.Context, exam *Exam) error
+	GetAllExams(ctx context.Context, academicYear string, semester int) (*[]Exam, error)
+	GetExamDetail(ctx context.Context, examID int) (*Exam, error)
+	UpdateExam(ctx context.Context, examID int, exam *Exam) error
+	DeleteExam(ctx context.Context, examID int) error
+	GetExamScores(ctx context.Context, examID int, subjectCode string) (*[]TestScore, error)
+}
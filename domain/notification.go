@@ -6,24 +6,106 @@ import (
 )
 
 type AttendanceNotificationHistory struct {
-	NotificationHistoryID int       `gorm:"primaryKey;autoIncrement" json:"notification_history_id"`
-	SubjectCode           string    `gorm:"not null" json:"subject_code"`
-	Subject               Subject   `gorm:"foreignKey:SubjectCode;references:SubjectCode;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"subject"`
-	StudentNSN            string    `gorm:"not null" json:"student_nsn"`
-	Student               Student   `gorm:"foreignKey:StudentNSN;references:StudentNSN;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"student"` // ✅ Ensures StudentNSN updates
-	ParentID              int       `gorm:"not null;index" json:"parent_id"`
-	Parent                Parent    `gorm:"foreignKey:ParentID;references:ParentID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"parent"`
-	UserID                int       `gorm:"not null" json:"user_id"`
-	User                  User      `gorm:"foreignKey:UserID;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"user"`
-	WhatsappStatus        bool      `gorm:"not null" json:"whatsapp"`
-	EmailStatus           bool      `gorm:"not null" json:"email"`
+	NotificationHistoryID int           `gorm:"primaryKey;autoIncrement" json:"notification_history_id"`
+	SubjectCode           string        `gorm:"not null;index" json:"subject_code"`
+	Subject               Subject       `gorm:"foreignKey:SubjectCode;references:SubjectCode;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"subject"`
+	StudentNSN            string        `gorm:"not null;index" json:"student_nsn"`
+	Student               Student       `gorm:"foreignKey:StudentNSN;references:StudentNSN;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"student"` // ✅ Ensures StudentNSN updates
+	ParentID              int           `gorm:"not null;index" json:"parent_id"`
+	Parent                Parent        `gorm:"foreignKey:ParentID;references:ParentID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"parent"`
+	UserID                int           `gorm:"not null;index" json:"user_id"`
+	User                  User          `gorm:"foreignKey:UserID;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"user"`
+	WhatsappStatus        bool          `gorm:"not null" json:"whatsapp"`
+	WhatsappDelivery      string        `gorm:"type:varchar(20)" json:"whatsapp_delivery"`
+	WhatsappError         *string       `gorm:"type:text" json:"whatsapp_error"`
+	EmailStatus           bool          `gorm:"not null" json:"email"`
+	EmailDelivery         string        `gorm:"type:varchar(20)" json:"email_delivery"`
+	EmailError            *string       `gorm:"type:text" json:"email_error"`
+	Attempts              int           `gorm:"not null;default:1" json:"attempts"`
+	Replies               []ParentReply `gorm:"foreignKey:NotificationHistoryID;references:NotificationHistoryID" json:"replies"`
+	CreatedAt             time.Time     `gorm:"autoCreateTime;index" json:"created_at"`
+	UpdatedAt             time.Time     `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+const (
+	AttendanceHistoryDefaultLimit = 50
+	AttendanceHistoryMaxLimit     = 500
+)
+
+// AttendanceHistoryFilter narrows and orders the truancy history, every field is optional
+type AttendanceHistoryFilter struct {
+	From             *time.Time
+	To               *time.Time
+	Grade            int
+	GradeLabel       string
+	SubjectCode      string
+	StudentNSN       string
+	UserID           int
+	WhatsappDelivery string
+	EmailDelivery    string
+	// SortBy is created_at, student_nsn or subject_code, ties are broken by history id
+	SortBy     string
+	Descending bool
+	Cursor     string
+	Limit      int
+}
+
+// AttendanceHistoryPage is one page of truancy history, NextCursor is empty on the last page
+type AttendanceHistoryPage struct {
+	Items      []AttendanceNotificationHistoryResponse `json:"items"`
+	NextCursor string                                  `json:"next_cursor,omitempty"`
+}
+
+// ExamResultNotificationHistory records one exam result notice sent to one guardian of a student
+type ExamResultNotificationHistory struct {
+	ExamResultHistoryID int       `gorm:"primaryKey;autoIncrement" json:"exam_result_history_id"`
+	ExamID              int       `gorm:"not null;index" json:"exam_id"`
+	Exam                Exam      `gorm:"foreignKey:ExamID;references:ExamID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"exam"`
+	StudentNSN          string    `gorm:"not null;index" json:"student_nsn"`
+	Student             Student   `gorm:"foreignKey:StudentNSN;references:StudentNSN;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"student"`
+	ParentID            int       `gorm:"not null;index" json:"parent_id"`
+	Parent              Parent    `gorm:"foreignKey:ParentID;references:ParentID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"parent"`
+	UserID              int       `gorm:"not null" json:"user_id"`
+	User                User      `gorm:"foreignKey:UserID;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"user"`
+	WhatsappStatus      bool      `gorm:"not null" json:"whatsapp"`
+	WhatsappReason      string    `gorm:"type:text" json:"whatsapp_reason,omitempty"`
+	EmailStatus         bool      `gorm:"not null" json:"email"`
+	EmailReason         string    `gorm:"type:text" json:"email_reason,omitempty"`
+	CreatedAt           time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// ParentReply is an inbound WhatsApp message sent by a parent to the school number.
+// It is linked to the latest attendance notification sent to the same parent, when there is one.
+type ParentReply struct {
+	ReplyID               int       `gorm:"primaryKey;autoIncrement" json:"reply_id"`
+	MessageID             string    `gorm:"type:varchar(128);uniqueIndex;not null" json:"message_id"`
+	SenderTelephone       string    `gorm:"type:varchar(20);not null;index" json:"sender_telephone"`
+	SenderName            string    `gorm:"type:varchar(150)" json:"sender_name"`
+	ParentID              *int      `gorm:"index" json:"parent_id"`
+	Parent                *Parent   `gorm:"foreignKey:ParentID;references:ParentID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"parent,omitempty"`
+	NotificationHistoryID *int      `gorm:"index" json:"notification_history_id"`
+	Body                  string    `gorm:"type:text;not null" json:"body"`
+	IsRead                bool      `gorm:"default:false" json:"is_read"`
+	ReceivedAt            time.Time `gorm:"not null" json:"received_at"`
 	CreatedAt             time.Time `gorm:"autoCreateTime" json:"created_at"`
 }
 
 type NotificationRepo interface {
-	GetAllAttendanceNotificationHistory(ctx context.Context) (*[]AttendanceNotificationHistoryResponse, error)
+	GetAllAttendanceNotificationHistory(ctx context.Context, filter *AttendanceHistoryFilter) (*AttendanceHistoryPage, error)
+	GetAllExamResultNotificationHistory(ctx context.Context, examID int) (*[]ExamResultNotificationHistoryResponse, error)
+
+	// Parent replies
+	SaveParentReply(ctx context.Context, reply *ParentReply) error
+	GetAllParentReplies(ctx context.Context, unreadOnly bool) (*[]ParentReply, error)
+	MarkParentReplyRead(ctx context.Context, replyID int) error
 }
 
 type NotificationUseCase interface {
-	GetAllAttendanceNotificationHistory(ctx context.Context) (*[]AttendanceNotificationHistoryResponse, error)
+	GetAllAttendanceNotificationHistory(ctx context.Context, filter *AttendanceHistoryFilter) (*AttendanceHistoryPage, error)
+	GetAllExamResultNotificationHistory(ctx context.Context, examID int) (*[]ExamResultNotificationHistoryResponse, error)
+
+	// Parent replies
+	SaveParentReply(ctx context.Context, reply *ParentReply) error
+	GetAllParentReplies(ctx context.Context, unreadOnly bool) (*[]ParentReply, error)
+	MarkParentReplyRead(ctx context.Context, replyID int) error
 }
@@ -4,13 +4,17 @@ import (
 	"time"
 )
 
+// Parent.EmailInvalid is set after a hard bounce and cleared when the email address changes
 type Parent struct {
-	ParentID  int        `gorm:"primaryKey;autoIncrement" json:"parent_id"`
-	Name      string     `gorm:"type:varchar(150);not null;" json:"name" valid:"required~Name is required"`
-	Gender    string     `gorm:"type:gender_enum;not null" json:"gender" valid:"required~Gender is required,in(male|female|other)~Invalid gender"`
-	Telephone string     `gorm:"type:varchar(13);not null;" json:"telephone" valid:"required~Telephone is required"`
-	Email     *string    `gorm:"type:varchar(255)" json:"email" valid:"email~Invalid email format,optional"`
-	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
-	DeletedAt *time.Time `gorm:"index" json:"deleted_at"`
+	ParentID          int        `gorm:"primaryKey;autoIncrement" json:"parent_id"`
+	Name              string     `gorm:"type:varchar(150);not null;" json:"name" valid:"required~Name is required"`
+	Gender            string     `gorm:"type:gender_enum;not null" json:"gender" valid:"required~Gender is required,in(male|female|other)~Invalid gender"`
+	Telephone         string     `gorm:"type:varchar(13);not null;" json:"telephone" valid:"required~Telephone is required"`
+	Email             *string    `gorm:"type:varchar(255)" json:"email" valid:"email~Invalid email format,optional"`
+	EmailInvalid      bool       `gorm:"not null;default:false" json:"email_invalid"`
+	EmailInvalidAt    *time.Time `json:"email_invalid_at,omitempty"`
+	EmailBounceReason *string    `gorm:"type:text" json:"email_bounce_reason,omitempty"`
+	CreatedAt         time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt         time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt         *time.Time `gorm:"index" json:"deleted_at"`
 }
@@ -0,0 +1,164 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	RoleAdmin           = "admin"
+	RoleStaff           = "staff"
+	RolePrincipal       = "principal"
+	RoleHomeroomTeacher = "homeroom_teacher"
+	RoleCounselor       = "counselor"
+)
+
+const (
+	PermissionSendAbsence     = "send_absence"
+	PermissionSendExamResults = "send_exam_results"
+	PermissionViewHistory     = "view_history"
+	PermissionManageInbox     = "manage_inbox"
+	PermissionViewStudents    = "view_students"
+	PermissionViewAllStudents = "view_all_students"
+	PermissionManageStudents  = "manage_students"
+	PermissionApproveDCR      = "approve_dcr"
+	PermissionManageConsent   = "manage_consent"
+	PermissionViewAnalytics   = "view_analytics"
+	PermissionViewExams       = "view_exams"
+	PermissionManageExams     = "manage_exams"
+	PermissionViewScores      = "view_scores"
+	PermissionInputScores     = "input_scores"
+	PermissionViewSubjects    = "view_subjects"
+	PermissionAllSubjects     = "all_subjects"
+	PermissionManageSubjects  = "manage_subjects"
+	PermissionManageStaff     = "manage_staff"
+	PermissionManageRoles     = "manage_roles"
+	PermissionManageWhatsapp  = "manage_whatsapp"
+	PermissionManageBounces   = "manage_bounces"
+	PermissionViewAuditLog    = "view_audit_log"
+	PermissionManageLogins    = "manage_logins"
+)
+
+type PermissionInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Permissions is every permission a role can be given
+var Permissions = []PermissionInfo{
+	{PermissionSendAbsence, "Send absence notifications to parents"},
+	{PermissionSendExamResults, "Broadcast exam results to parents"},
+	{PermissionViewHistory, "View the notification history"},
+	{PermissionManageInbox, "Read and mark parent replies"},
+	{PermissionViewStudents, "View students of the grades taught"},
+	{PermissionViewAllStudents, "View every student, not only those of the grades taught"},
+	{PermissionManageStudents, "Create, import and modify students and parents"},
+	{PermissionApproveDCR, "Review and approve parent data change requests"},
+	{PermissionManageConsent, "View and change parent notification consent"},
+	{PermissionViewAnalytics, "View absence analytics"},
+	{PermissionViewExams, "View exams and their scores"},
+	{PermissionManageExams, "Create, modify and delete exams"},
+	{PermissionViewScores, "View test scores"},
+	{PermissionInputScores, "Input test scores for the subjects taught"},
+	{PermissionViewSubjects, "View subjects"},
+	{PermissionAllSubjects, "Work with every subject, not only those taught"},
+	{PermissionManageSubjects, "Create and modify subjects"},
+	{PermissionManageStaff, "Create, modify and delete staff and reset their passwords"},
+	{PermissionManageRoles, "Create, modify and delete roles"},
+	{PermissionManageWhatsapp, "Pair and log out the WhatsApp session"},
+	{PermissionManageBounces, "View and process bounced emails"},
+	{PermissionViewAuditLog, "View the audit log"},
+	{PermissionManageLogins, "View and unlock locked out logins"},
+}
+
+// IsPermission tells whether name is one of Permissions
+func IsPermission(name string) bool {
+	for _, permission := range Permissions {
+		if permission.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Role is a named set of permissions staff are assigned, admin always holds every permission.
+// Built-in roles are seeded on migration and can be modified but not deleted.
+type Role struct {
+	Name        string    `gorm:"primaryKey;type:varchar(30)" json:"name"`
+	Description string    `gorm:"type:varchar(255)" json:"description"`
+	BuiltIn     bool      `gorm:"not null;default:false" json:"built_in"`
+	Permissions []string  `gorm:"-" json:"permissions"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+type RolePermission struct {
+	RoleName   string `gorm:"primaryKey;type:varchar(30)"`
+	Role       Role   `gorm:"foreignKey:RoleName;references:Name;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Permission string `gorm:"primaryKey;type:varchar(50)"`
+}
+
+type RoleRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// BuiltInRoles are seeded with these permissions the first time the roles table is migrated
+var BuiltInRoles = []Role{
+	{
+		Name:        RoleAdmin,
+		Description: "School administrator, holds every permission",
+	},
+	{
+		Name:        RoleStaff,
+		Description: "Teacher",
+		Permissions: []string{
+			PermissionSendAbsence, PermissionViewHistory, PermissionManageInbox, PermissionViewStudents,
+			PermissionViewAnalytics, PermissionViewExams, PermissionViewScores, PermissionInputScores, PermissionViewSubjects,
+		},
+	},
+	{
+		Name:        RolePrincipal,
+		Description: "Principal, oversees the whole school",
+		Permissions: []string{
+			PermissionSendExamResults, PermissionViewHistory, PermissionViewStudents, PermissionViewAllStudents,
+			PermissionApproveDCR, PermissionViewAnalytics, PermissionViewExams, PermissionViewScores,
+			PermissionViewSubjects, PermissionAllSubjects, PermissionViewAuditLog,
+		},
+	},
+	{
+		Name:        RoleHomeroomTeacher,
+		Description: "Teacher in charge of a class and its parents",
+		Permissions: []string{
+			PermissionSendAbsence, PermissionViewHistory, PermissionManageInbox, PermissionViewStudents,
+			PermissionApproveDCR, PermissionManageConsent, PermissionViewAnalytics, PermissionViewExams,
+			PermissionViewScores, PermissionInputScores, PermissionViewSubjects,
+		},
+	},
+	{
+		Name:        RoleCounselor,
+		Description: "Counselor following up on absences",
+		Permissions: []string{
+			PermissionSendAbsence, PermissionViewHistory, PermissionManageInbox, PermissionViewStudents,
+			PermissionViewAllStudents, PermissionViewAnalytics,
+		},
+	},
+}
+
+type RoleRepo interface {
+	GetAllRoles(ctx context.Context) (*[]Role, error)
+	GetRole(ctx context.Context, name string) (*Role, error)
+	CreateRole(ctx context.Context, req *RoleRequest) (*Role, error)
+	UpdateRole(ctx context.Context, name string, req *RoleRequest) (*Role, error)
+	DeleteRole(ctx context.Context, name string) error
+	HasPermission(ctx context.Context, role, permission string) (bool, error)
+}
+
+type RoleUseCase interface {
+	GetAllRoles(ctx context.Context) (*[]Role, error)
+	GetRole(ctx context.Context, name string) (*Role, error)
+	CreateRole(ctx context.Context, req *RoleRequest) (*Role, error)
+	UpdateRole(ctx context.Context, name string, req *RoleRequest) (*Role, error)
+	DeleteRole(ctx context.Context, name string) error
+}
@@ -1,13 +1,84 @@
 package domain
 
-import "context"
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	DeliveryStatusSent       = "sent"
+	DeliveryStatusFailed     = "failed"
+	DeliveryStatusSuppressed = "suppressed"
+	DeliveryStatusSkipped    = "skipped"
+	DeliveryStatusQueued     = "queued"
+	DeliveryStatusWouldSend  = "would_send"
+	DeliveryStatusPending    = "pending"
+
+	DeliveryReasonSuppressed   = "suppressed by preference"
+	DeliveryReasonQueued       = "whatsapp unavailable, queued until the session returns"
+	DeliveryReasonEmailInvalid = "email marked invalid after a hard bounce"
+	DeliveryReasonNoEmail      = "parent has no email address"
+	DeliveryReasonNotRecipient = "no longer a recipient of this notification"
+)
+
+// DeliveryResult is the outcome of one notification on one channel
+type DeliveryResult struct {
+	StudentNSN string `json:"student_nsn"`
+	ParentID   int    `json:"parent_id"`
+	Channel    string `json:"channel"`
+	Status     string `json:"status"`
+	Reason     string `json:"reason,omitempty"`
+	// Filled on dry runs only, the rendered message that would have been sent
+	Recipient string `json:"recipient,omitempty"`
+	Subject   string `json:"subject,omitempty"`
+	Body      string `json:"body,omitempty"`
+	// The outbox row of a queued WhatsApp message
+	OutboxID *int `json:"-"`
+}
 
 type SenderRepo interface {
-	SendMass(ctx context.Context, nsnList *[]string, userID *int, subjectCode string) error
-	SendTestScores(ctx context.Context, examType string) error
+	SendMass(ctx context.Context, nsnList *[]string, userID *int, subjectCode string, dryRun bool) (*[]DeliveryResult, error)
+	SendTestScores(ctx context.Context, examID int, userID int, dryRun bool, resend bool) (*ExamBroadcastReport, error)
+	ResumeExamBroadcast(ctx context.Context, broadcastID int, userID int) (*ExamBroadcastReport, error)
+	GetExamBroadcasts(ctx context.Context, examID int) (*[]ExamBroadcast, error)
+	GetExamBroadcastSummary(ctx context.Context, broadcastID int) (*ExamBroadcastSummary, error)
+	ResendAttendanceNotification(ctx context.Context, historyID int) (*[]DeliveryResult, error)
 }
 
 type SenderUseCase interface {
-	SendMass(ctx context.Context, nsnList *[]string, userID *int, subjectCode string) error
-	SendTestScores(ctx context.Context, examType string) error
+	SendMass(ctx context.Context, nsnList *[]string, userID *int, subjectCode string, dryRun bool) (*[]DeliveryResult, error)
+	SendTestScores(ctx context.Context, examID int, userID int, dryRun bool, resend bool) (*ExamBroadcastReport, error)
+	ResumeExamBroadcast(ctx context.Context, broadcastID int, userID int) (*ExamBroadcastReport, error)
+	GetExamBroadcasts(ctx context.Context, examID int) (*[]ExamBroadcast, error)
+	GetExamBroadcastSummary(ctx context.Context, broadcastID int) (*ExamBroadcastSummary, error)
+	ResendAttendanceNotification(ctx context.Context, historyID int) (*[]DeliveryResult, error)
+}
+
+const (
+	MailErrorTemporary  = "temporary"
+	MailErrorPermanent  = "permanent"
+	MailErrorConnection = "connection"
+)
+
+// MailError classifies why a single email was not accepted by the SMTP server
+type MailError struct {
+	Class string
+	Code  int
+	Err   error
+}
+
+func (e *MailError) Error() string {
+	if e.Code != 0 {
+		return fmt.Sprintf("%s smtp error (%d): %v", e.Class, e.Code, e.Err)
+	}
+	return fmt.Sprintf("%s smtp error: %v", e.Class, e.Err)
+}
+
+func (e *MailError) Unwrap() error {
+	return e.Err
+}
+
+// Mailer delivers raw messages through the configured SMTP server
+type Mailer interface {
+	Send(from string, to []string, msg []byte) error
 }
@@ -14,20 +14,38 @@ type Subject struct {
 }
 
 type Student struct {
-	StudentNSN string    `gorm:"primaryKey;type:varchar(10);not null;" json:"student_nsn" valid:"required~NSN is required"`
-	Name       string    `gorm:"type:varchar(150);not null;" json:"name" valid:"required~Name is required"`
-	Grade      int       `gorm:"not null" json:"grade" valid:"required~Grade is required"`
-	GradeLabel string    `gorm:"type:varchar(5);not null;" json:"grade_label"`
-	Gender     string    `gorm:"type:gender_enum;not null" json:"gender" valid:"required~Gender is required,in(male|female)~Invalid gender"`
-	Telephone  string    `gorm:"type:varchar(13);not null;" json:"telephone" valid:"required~Telephone is required"`
-	ParentID   int       `json:"parent_id"`
-	Parent     Parent    `gorm:"references:ParentID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"parent" valid:"-"`
-	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	StudentNSN string            `gorm:"primaryKey;type:varchar(10);not null;" json:"student_nsn" valid:"required~NSN is required"`
+	Name       string            `gorm:"type:varchar(150);not null;" json:"name" valid:"required~Name is required"`
+	Grade      int               `gorm:"not null" json:"grade" valid:"required~Grade is required"`
+	GradeLabel string            `gorm:"type:varchar(5);not null;" json:"grade_label"`
+	Gender     string            `gorm:"type:gender_enum;not null" json:"gender" valid:"required~Gender is required,in(male|female)~Invalid gender"`
+	Telephone  string            `gorm:"type:varchar(13);not null;" json:"telephone" valid:"required~Telephone is required"`
+	ParentID   int               `json:"parent_id"`
+	Parent     Parent            `gorm:"references:ParentID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"parent" valid:"-"`
+	Guardians  []StudentGuardian `gorm:"foreignKey:StudentNSN;references:StudentNSN" json:"guardians,omitempty" valid:"-"`
+	CreatedAt  time.Time         `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time         `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// StudentGuardian links a student to every parent or guardian who should receive notices.
+// The primary guardian is always the student's ParentID.
+type StudentGuardian struct {
+	StudentNSN        string    `gorm:"primaryKey;type:varchar(10)" json:"student_nsn"`
+	Student           Student   `gorm:"foreignKey:StudentNSN;references:StudentNSN;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	ParentID          int       `gorm:"primaryKey" json:"parent_id"`
+	Parent            Parent    `gorm:"foreignKey:ParentID;references:ParentID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"parent"`
+	Relationship      string    `gorm:"type:varchar(20);not null;default:'parent'" json:"relationship"`
+	IsPrimary         bool      `gorm:"not null;default:false" json:"is_primary"`
+	ReceiveAttendance bool      `gorm:"not null;default:true" json:"receive_attendance"`
+	ReceiveExamResult bool      `gorm:"not null;default:true" json:"receive_exam_result"`
+	CreatedAt         time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt         time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
 type TestScore struct {
 	TestScoreID int        `gorm:"primaryKey;autoIncrement" json:"test_score_id"`
+	ExamID      *int       `gorm:"index" json:"exam_id"`
+	Exam        *Exam      `gorm:"foreignKey:ExamID;references:ExamID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"exam,omitempty"`
 	StudentNSN  string     `gorm:"not null" json:"student_nsn"`
 	Student     Student    `gorm:"foreignKey:StudentNSN;references:StudentNSN;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"student"`
 	SubjectCode string     `gorm:"not null" json:"subject_code"`
@@ -42,12 +60,14 @@ type TestScore struct {
 
 type StudentRepo interface {
 	GetAllStudent(ctx context.Context, userID int) (*[]Student, error)
+	GetStudentTimeline(ctx context.Context, userID int, nsn string, cursor string, limit int) (*StudentTimeline, error)
 	DownloadInputDataTemplate(ctx context.Context) (*string, error)
 	GetStudentByParentTelephone(ctx context.Context, parTel string) (*StudentsAssociateWithParent, error)
 }
 
 type StudentUseCase interface {
 	GetAllStudent(ctx context.Context, userID int) (*[]Student, error)
+	GetStudentTimeline(ctx context.Context, userID int, nsn string, cursor string, limit int) (*StudentTimeline, error)
 	DownloadInputDataTemplate(ctx context.Context) (*string, error)
 	GetStudentByParentTelephone(ctx context.Context, parTel string) (*StudentsAssociateWithParent, error)
 }
@@ -6,13 +6,33 @@ import (
 )
 
 type StudentAndParent struct {
-	Student Student `json:"student"`
-	Parent  Parent  `json:"parent"`
+	Student      Student           `json:"student"`
+	Parent       Parent            `json:"parent"`
+	Relationship string            `json:"relationship,omitempty"`
+	Guardians    []GuardianPayload `json:"guardians,omitempty"`
 }
 
+// GuardianPayload is an additional guardian submitted together with a student
+type GuardianPayload struct {
+	Parent            Parent `json:"parent"`
+	Relationship      string `json:"relationship"`
+	ReceiveAttendance *bool  `json:"receive_attendance"`
+	ReceiveExamResult *bool  `json:"receive_exam_result"`
+}
+
+// GuardianSettingsPayload changes how one guardian of a student is notified; nil fields are left unchanged
+type GuardianSettingsPayload struct {
+	Relationship      *string `json:"relationship"`
+	ReceiveAttendance *bool   `json:"receive_attendance"`
+	ReceiveExamResult *bool   `json:"receive_exam_result"`
+}
+
+var GuardianRelationships = []string{"parent", "father", "mother", "grandparent", "sibling", "guardian", "other"}
+
 type ParentDataChangeRequest struct {
 	RequestID          int        `gorm:"primaryKey;autoIncrement" json:"request_id"`
 	OldParentTelephone string     `json:"old_parent_telephone,omitempty"`
+	ParentID           *int       `gorm:"index" json:"parent_id,omitempty"`
 	NewParentName      *string    `json:"new_parent_name,omitempty"`
 	NewParentTelephone *string    `json:"new_parent_telephone,omitempty"`
 	NewParentEmail     *string    `json:"new_parent_email,omitempty"`
@@ -36,6 +56,7 @@ type StudentParentRepo interface {
 	DataChangeRequest(ctx context.Context, datas ParentDataChangeRequest) error
 	ApproveDCR(ctx context.Context, req map[string]interface{}) (*string, error)
 	DeleteDCR(ctx context.Context, dcrID int) error
+	UpdateGuardianSettings(ctx context.Context, studentNSN string, parentID int, payload *GuardianSettingsPayload) (*StudentGuardian, error)
 }
 
 type StudentParentUseCase interface {
@@ -52,4 +73,5 @@ type StudentParentUseCase interface {
 	DataChangeRequest(ctx context.Context, datas ParentDataChangeRequest) error
 	ApproveDCR(ctx context.Context, req map[string]interface{}) (*string, error)
 	DeleteDCR(ctx context.Context, dcrID int) error
+	UpdateGuardianSettings(ctx context.Context, studentNSN string, parentID int, payload *GuardianSettingsPayload) (*StudentGuardian, error)
 }
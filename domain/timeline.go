@@ -0,0 +1,35 @@
+package domain
+
+import "time"
+
+const (
+	TimelineKindAbsence           = "absence"
+	TimelineKindParentReply       = "parent_reply"
+	TimelineKindDataChangeRequest = "data_change_request"
+	TimelineKindExamResult        = "exam_result"
+
+	TimelineDefaultLimit = 20
+	TimelineMaxLimit     = 100
+)
+
+// TimelineEvent is one entry of a student timeline, Data holds the record matching Kind
+type TimelineEvent struct {
+	Kind       string      `json:"kind"`
+	ID         int         `json:"id"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+// TimelineAbsence is a subject the student missed on one day together with every notice sent about it
+type TimelineAbsence struct {
+	Subject       Subject                         `json:"subject"`
+	AbsentOn      string                          `json:"absent_on"`
+	Notifications []AttendanceNotificationHistory `json:"notifications"`
+}
+
+// StudentTimeline is one page of the timeline newest first, NextCursor is empty on the last page
+type StudentTimeline struct {
+	Student    Student         `json:"student"`
+	Events     []TimelineEvent `json:"events"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
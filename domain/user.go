@@ -5,16 +5,22 @@ import (
 	"time"
 )
 
+// User is an admin or staff account. Email and Telephone are where password reset codes are sent.
+// MustChangePassword is set while the password is one an admin chose, the user has to replace it before anything else.
 type User struct {
-	UserID    int        `gorm:"primaryKey;autoIncrement" json:"user_id"`
-	Username  string     `gorm:"type:varchar(50);not null;" json:"username"`
-	Name      string     `gorm:"type:varchar(150);not null;" json:"name"`
-	Password  string     `gorm:"type:varchar(100);not null" json:"password"`
-	Role      string     `gorm:"type:varchar(10);not null" json:"role"`
-	Teaching  []*Subject `gorm:"many2many:user_subjects" json:"teaching"`
-	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
-	DeletedAt *time.Time `gorm:"index" json:"deleted_at"`
+	UserID             int        `gorm:"primaryKey;autoIncrement" json:"user_id"`
+	Username           string     `gorm:"type:varchar(50);not null;" json:"username"`
+	Name               string     `gorm:"type:varchar(150);not null;" json:"name"`
+	Password           string     `gorm:"type:varchar(100);not null" json:"password"`
+	Role               string     `gorm:"type:varchar(30);not null" json:"role"`
+	Email              *string    `gorm:"type:varchar(255)" json:"email"`
+	Telephone          *string    `gorm:"type:varchar(13)" json:"telephone"`
+	Teaching           []*Subject `gorm:"many2many:user_subjects" json:"teaching"`
+	MustChangePassword bool       `gorm:"not null;default:false" json:"must_change_password"`
+	PasswordChangedAt  *time.Time `json:"password_changed_at"`
+	CreatedAt          time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt          time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt          *time.Time `gorm:"index" json:"deleted_at"`
 }
 
 type Profile struct {
@@ -26,7 +32,7 @@ type Profile struct {
 
 type UserRepo interface {
 	GetAdminByAdmin(ctx context.Context) (*SafeStaffData, error)
-	ShowProfile(ctx context.Context, uID int) (*SafeStaffData, error)
+	ShowProfile(ctx context.Context, uID int) (*ProfileDashboard, error)
 	// Staff
 	GetAllStaff(ctx context.Context) (*[]SafeStaffData, error)
 	GetStaffDetail(ctx context.Context, id int) (*SafeStaffData, error)
@@ -48,13 +54,13 @@ type UserRepo interface {
 
 	// TestScore
 	InputTestScores(ctx context.Context, teacherID int, testScores *InputTestScorePayload) error
-	GetAllTestScores(ctx context.Context) (*[]TestScore, error)
-	GetAllTestScoresBySubjectID(ctx context.Context, subjectCode string) (*[]TestScore, error)
+	GetAllTestScores(ctx context.Context, examID int) (*[]TestScore, error)
+	GetAllTestScoresBySubjectID(ctx context.Context, subjectCode string, examID int) (*[]TestScore, error)
 }
 
 type UserUseCase interface {
 	GetAdminByAdmin(ctx context.Context) (*SafeStaffData, error)
-	ShowProfile(ctx context.Context, uID int) (*SafeStaffData, error)
+	ShowProfile(ctx context.Context, uID int) (*ProfileDashboard, error)
 
 	// Staff
 	GetAllStaff(ctx context.Context) (*[]SafeStaffData, error)
@@ -77,6 +83,6 @@ type UserUseCase interface {
 
 	// TestScore
 	InputTestScores(ctx context.Context, teacherID int, testScores *InputTestScorePayload) error
-	GetAllTestScores(ctx context.Context) (*[]TestScore, error)
-	GetAllTestScoresBySubjectID(ctx context.Context, subjectCode string) (*[]TestScore, error)
+	GetAllTestScores(ctx context.Context, examID int) (*[]TestScore, error)
+	GetAllTestScoresBySubjectID(ctx context.Context, subjectCode string, examID int) (*[]TestScore, error)
 }
@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+)
+
+const (
+	WhatsappStateConnected     = "connected"
+	WhatsappStateUnavailable   = "unavailable"
+	WhatsappStateAwaitingLogin = "awaiting_login"
+	WhatsappStateLoggedOut     = "logged_out"
+)
+
+// WhatsappOutbox holds messages that were written while the WhatsApp session was unavailable.
+// Status is pending until the message is sent or given up as failed, LastError then holds the reason.
+type WhatsappOutbox struct {
+	OutboxID  int    `gorm:"primaryKey;autoIncrement" json:"outbox_id"`
+	Telephone string `gorm:"type:varchar(20);not null" json:"telephone"`
+	Body      string `gorm:"type:text;not null" json:"body"`
+	Status    string `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"`
+	// The attendance notice the message belongs to, its history row gets the final outcome
+	NotificationHistoryID *int `gorm:"index" json:"notification_history_id,omitempty"`
+	// The exam result notice the message belongs to, its history row gets the final outcome
+	ExamResultHistoryID *int `gorm:"index" json:"exam_result_history_id,omitempty"`
+	// The exam broadcast recipient the message belongs to, a failure is sent again by the next resume
+	ExamBroadcastRecipientID *int       `gorm:"index" json:"exam_broadcast_recipient_id,omitempty"`
+	Attempts                 int        `gorm:"not null;default:0" json:"attempts"`
+	LastError                *string    `gorm:"type:text" json:"last_error,omitempty"`
+	SentAt                   *time.Time `gorm:"index" json:"sent_at,omitempty"`
+	CreatedAt                time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt                time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// WhatsappSessionStatus describes the linked WhatsApp device of the server
+type WhatsappSessionStatus struct {
+	State           string     `json:"state"`
+	Connected       bool       `json:"connected"`
+	LoggedIn        bool       `json:"logged_in"`
+	JID             string     `json:"jid,omitempty"`
+	PushName        string     `json:"push_name,omitempty"`
+	QRAvailable     bool       `json:"qr_available"`
+	QRExpiresAt     *time.Time `json:"qr_expires_at,omitempty"`
+	LastConnectedAt *time.Time `json:"last_connected_at,omitempty"`
+	LastEvent       string     `json:"last_event,omitempty"`
+	QueuedMessages  int64      `json:"queued_messages"`
+}
+
+// WhatsappSessionManager controls the WhatsApp session while the server is running
+type WhatsappSessionManager interface {
+	Status() WhatsappSessionStatus
+	QRCode(ctx context.Context) (*string, error)
+	PairPhone(ctx context.Context, phone string) (*string, error)
+	Logout(ctx context.Context) error
+}
+
+// WhatsappClientProvider hands out the current whatsmeow client, which is replaced when the device is re-linked
+type WhatsappClientProvider interface {
+	Client() *whatsmeow.Client
+}
+
+type WhatsappOutboxRepo interface {
+	Flush(ctx context.Context) (int, error)
+	PendingCount(ctx context.Context) (int64, error)
+}
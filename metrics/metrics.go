@@ -0,0 +1,83 @@
+// Package metrics holds the Prometheus registry of the server.
+// Counters and histograms live here, gauges are collected when /metrics is scraped.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	registry = prometheus.NewRegistry()
+
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "notification_http_requests_total",
+		Help: "HTTP requests handled, by route and status code.",
+	}, []string{"method", "route", "status"})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "notification_http_request_duration_seconds",
+		Help:    "Time spent handling HTTP requests, by route.",
+		Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"method", "route"})
+	messages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "notification_messages_total",
+		Help: "Notices that went out to a channel, by notification type and outcome.",
+	}, []string{"channel", "notification_type", "status"})
+	outboxMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "notification_whatsapp_outbox_messages_total",
+		Help: "Queued WhatsApp messages that left the outbox, by outcome.",
+	}, []string{"status"})
+	smtpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "notification_smtp_send_duration_seconds",
+		Help:    "Time spent handing one email to the SMTP server, by outcome.",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"result"})
+)
+
+func init() {
+	registry.MustRegister(httpRequests, httpDuration, messages, outboxMessages, smtpDuration)
+}
+
+// Register adds collectors whose values are only known at scrape time
+func Register(collectors ...prometheus.Collector) error {
+	for _, collector := range collectors {
+		if err := registry.Register(collector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler serves every registered metric in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// ObserveHTTPRequest counts one handled request, route is the registered path so ids do not blow up the label set
+func ObserveHTTPRequest(method, route string, status int, duration time.Duration) {
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// RecordMessage counts one notice that was sent, failed or queued on a channel
+func RecordMessage(channel, notificationType, status string) {
+	messages.WithLabelValues(channel, notificationType, status).Inc()
+}
+
+// RecordOutboxMessage counts one queued WhatsApp message that was finally sent or given up
+func RecordOutboxMessage(status string) {
+	outboxMessages.WithLabelValues(status).Inc()
+}
+
+// ObserveSMTP records how long one SMTP delivery took
+func ObserveSMTP(duration time.Duration, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	smtpDuration.WithLabelValues(result).Observe(duration.Seconds())
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"errors"
+	"notification/metrics"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Metrics counts every request and how long it took, labelled with the registered route
+func Metrics() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		route := c.Route().Path
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			status = fiberErr.Code
+			// No route matched, the raw path would give every scanner its own series
+			if fiberErr.Code == fiber.StatusNotFound {
+				route = "unmatched"
+			}
+		} else if err != nil {
+			status = fiber.StatusInternalServerError
+		}
+
+		// The method points into the request buffer which is reused after the handler returns
+		metrics.ObserveHTTPRequest(strings.Clone(c.Method()), route, status, time.Since(start))
+		return err
+	}
+}
@@ -1,6 +1,11 @@
 package middleware
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
 	"notification/domain"
 	"os"
 	"time"
@@ -11,19 +16,22 @@ import (
 
 var jwtKey = []byte(os.Getenv("BYTE_KEY"))
 
-func GenerateJWT(userID int, username, role string) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
+// GenerateJWT signs a short-lived access token for one session, the refresh token renews it
+func GenerateJWT(userID int, username, role, sessionID string, mustChangePassword bool) (string, error) {
+	expirationTime := time.Now().Add(domain.AccessTokenTTL)
 	claims := &domain.Claims{
-		UserID:   userID,
-		Username: username,
-		Role:     role,
+		UserID:             userID,
+		Username:           username,
+		Role:               role,
+		SessionID:          sessionID,
+		MustChangePassword: mustChangePassword,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
 	tokenString, err := token.SignedString(jwtKey)
 	if err != nil {
 		return "", err
@@ -32,28 +40,141 @@ func GenerateJWT(userID int, username, role string) (string, error) {
 	return tokenString, nil
 }
 
-func RoleRequired(roles ...string) fiber.Handler {
+// SessionChecker tells whether the session behind an access token is still open and its user not deleted
+type SessionChecker interface {
+	IsSessionActive(ctx context.Context, userID int, sessionID string) (bool, error)
+}
+
+var sessionChecker SessionChecker
+
+// UseSessionChecker must be called before serving, AuthRequired refuses every token until it is
+func UseSessionChecker(checker SessionChecker) {
+	sessionChecker = checker
+}
+
+const (
+	unsubscribeSubject  = "unsubscribe"
+	unsubscribeAudience = "unsubscribe"
+	unsubscribeIssuer   = "sinoan"
+)
+
+// errUnsubscribeKeyMissing refuses opt-out links while no key is configured, a key derived from nothing could be forged by anyone
+var errUnsubscribeKeyMissing = errors.New("unsubscribe links are disabled, set UNSUBSCRIBE_KEY or BYTE_KEY")
+
+// unsubscribeKey signs the opt-out links, it never equals the staff token key so a link can not be turned into a staff token.
+// It reads UNSUBSCRIBE_KEY on every call, after the .env file was loaded, and derives a key from BYTE_KEY when it is not set.
+func unsubscribeKey() ([]byte, error) {
+	if key := os.Getenv("UNSUBSCRIBE_KEY"); key != "" {
+		return []byte(key), nil
+	}
+
+	byteKey := os.Getenv("BYTE_KEY")
+	if byteKey == "" {
+		return nil, errUnsubscribeKeyMissing
+	}
+	mac := hmac.New(sha256.New, []byte(byteKey))
+	mac.Write([]byte("unsubscribe-links"))
+	return mac.Sum(nil), nil
+}
+
+// GenerateUnsubscribeToken signs the opt-out link placed in outgoing emails
+func GenerateUnsubscribeToken(parentID int, channel, notificationType string) (string, error) {
+	claims := &domain.UnsubscribeClaims{
+		ParentID:         parentID,
+		Channel:          channel,
+		NotificationType: notificationType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   unsubscribeSubject,
+			Audience:  jwt.ClaimStrings{unsubscribeAudience},
+			Issuer:    unsubscribeIssuer,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(180 * 24 * time.Hour)),
+		},
+	}
+
+	key, err := unsubscribeKey()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(key)
+}
+
+func ParseUnsubscribeToken(tokenStr string) (*domain.UnsubscribeClaims, error) {
+	key, err := unsubscribeKey()
+	if err != nil {
+		return nil, err
+	}
+
+	claims := new(domain.UnsubscribeClaims)
+	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return key, nil
+	})
+	if err != nil || !token.Valid || claims.Subject != unsubscribeSubject ||
+		!claims.VerifyAudience(unsubscribeAudience, true) || !claims.VerifyIssuer(unsubscribeIssuer, true) {
+		return nil, fmt.Errorf("invalid or expired unsubscribe link")
+	}
+
+	return claims, nil
+}
+
+// PermissionChecker tells whether a role holds a permission, roles are editable so it is asked on every request
+type PermissionChecker interface {
+	HasPermission(ctx context.Context, role, permission string) (bool, error)
+}
+
+var permissionChecker PermissionChecker
+
+// UsePermissionChecker must be called before serving, PermissionRequired refuses every request until it is
+func UsePermissionChecker(checker PermissionChecker) {
+	permissionChecker = checker
+}
+
+// PermissionRequired lets a request through when the role of the token holds permission, it runs after AuthRequired
+func PermissionRequired(permission string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		userToken := c.Locals("user").(*domain.Claims)
+		userToken, _ := c.Locals("user").(*domain.Claims)
 		if userToken == nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"error": "unauthorized: missing token",
 			})
 		}
 
-		for _, role := range roles {
-			if userToken.Role == role {
-				return c.Next()
-			}
+		if permissionChecker == nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "could not verify permissions",
+			})
+		}
+		allowed, err := permissionChecker.HasPermission(c.Context(), userToken.Role, permission)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "could not verify permissions",
+			})
+		}
+		if !allowed {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "forbidden: insufficient permissions",
+			})
 		}
 
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"error": "forbidden: insufficient permissions",
-		})
+		return c.Next()
 	}
 }
 
+// AuthRequired lets a request through with a valid token of an open session whose password needs no change
 func AuthRequired() fiber.Handler {
+	return authenticate(false)
+}
+
+// AuthRequiredForPasswordChange also accepts a token that still has to change its password, for the endpoints that do so
+func AuthRequiredForPasswordChange() fiber.Handler {
+	return authenticate(true)
+}
+
+func authenticate(allowPasswordChange bool) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		tokenStr := c.Get("Authorization")
 		if tokenStr == "" {
@@ -67,12 +188,30 @@ func AuthRequired() fiber.Handler {
 			return jwtKey, nil
 		})
 
-		if err != nil || !token.Valid {
+		if err != nil || !token.Valid || claims.SessionID == "" || sessionChecker == nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"error": "invalid or expired token",
 			})
 		}
 
+		active, err := sessionChecker.IsSessionActive(c.Context(), claims.UserID, claims.SessionID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "could not verify session",
+			})
+		}
+		if !active {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "session has been revoked",
+			})
+		}
+
+		if claims.MustChangePassword && !allowPasswordChange {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "password change required",
+			})
+		}
+
 		c.Locals("user", claims)
 
 		return c.Next()
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"notification/domain"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type fakePermissionChecker struct {
+	granted map[string]bool
+	err     error
+}
+
+func (f fakePermissionChecker) HasPermission(ctx context.Context, role, permission string) (bool, error) {
+	return f.granted[role+"/"+permission], f.err
+}
+
+func TestPermissionRequired(t *testing.T) {
+	staff := &domain.Claims{UserID: 2, Username: "teacher", Role: domain.RoleStaff}
+	granted := fakePermissionChecker{granted: map[string]bool{domain.RoleStaff + "/" + domain.PermissionSendAbsence: true}}
+
+	tests := []struct {
+		name       string
+		claims     *domain.Claims
+		checker    PermissionChecker
+		permission string
+		wantStatus int
+	}{
+		{"granted", staff, granted, domain.PermissionSendAbsence, fiber.StatusOK},
+		{"not granted", staff, granted, domain.PermissionManageRoles, fiber.StatusForbidden},
+		{"no token", nil, granted, domain.PermissionSendAbsence, fiber.StatusUnauthorized},
+		{"no checker configured", staff, nil, domain.PermissionSendAbsence, fiber.StatusInternalServerError},
+		{"checker fails", staff, fakePermissionChecker{err: errors.New("database down")}, domain.PermissionSendAbsence, fiber.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := permissionChecker
+			permissionChecker = tt.checker
+			t.Cleanup(func() { permissionChecker = previous })
+
+			app := fiber.New()
+			app.Get("/", func(c *fiber.Ctx) error {
+				if tt.claims != nil {
+					c.Locals("user", tt.claims)
+				}
+				return c.Next()
+			}, PermissionRequired(tt.permission), func(c *fiber.Ctx) error {
+				return c.SendStatus(fiber.StatusOK)
+			})
+
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil))
+			if err != nil {
+				t.Fatalf("app.Test() error = %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"notification/config"
+	"notification/domain"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+const HeaderRequestID = "X-Request-ID"
+
+// RequestLogger gives every request an id and a logger carrying it, then logs one line once the request is done.
+// A well formed X-Request-ID from the caller is kept so ids can be followed across services.
+func RequestLogger() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+
+		requestID := c.Get(HeaderRequestID)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		} else {
+			// Header values point into the request buffer which is reused after the handler returns
+			requestID = strings.Clone(requestID)
+		}
+		c.Set(HeaderRequestID, requestID)
+
+		entry := config.GetLogrusInstance().WithFields(logrus.Fields{
+			"request_id": requestID,
+			"method":     strings.Clone(c.Method()),
+			"path":       strings.Clone(c.Path()),
+		})
+		config.SetRequestLogger(c, entry)
+
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			status = fiberErr.Code
+		} else if err != nil {
+			status = fiber.StatusInternalServerError
+		}
+
+		fields := logrus.Fields{
+			"route":      c.Route().Path,
+			"status":     status,
+			"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"ip":         strings.Clone(c.IP()),
+		}
+		if user, ok := c.Locals("user").(*domain.Claims); ok && user != nil {
+			fields["user"] = user.Username
+			fields["user_id"] = user.UserID
+		}
+		if nsn := c.Params("student_nsn", c.Params("nsn")); nsn != "" {
+			fields["student_nsn"] = strings.Clone(nsn)
+		}
+
+		done := entry.WithFields(fields)
+		switch {
+		case err != nil && status >= fiber.StatusInternalServerError:
+			done.WithError(err).Error("request failed")
+		case status >= fiber.StatusInternalServerError:
+			done.Error("request handled")
+		case status >= fiber.StatusBadRequest:
+			done.Warn("request handled")
+		default:
+			done.Info("request handled")
+		}
+
+		return err
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return hex.EncodeToString([]byte(time.Now().Format(time.RFC3339Nano)))
+	}
+	return hex.EncodeToString(random)
+}
@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"errors"
+	"notification/domain"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestParseUnsubscribeToken(t *testing.T) {
+	const staffKey = "staff signing key"
+	t.Setenv("UNSUBSCRIBE_KEY", "")
+	t.Setenv("BYTE_KEY", staffKey)
+
+	key, err := unsubscribeKey()
+	if err != nil {
+		t.Fatalf("unsubscribeKey() error = %v", err)
+	}
+
+	valid, err := GenerateUnsubscribeToken(7, domain.ChannelEmail, domain.NotificationTypeAttendance)
+	if err != nil {
+		t.Fatalf("GenerateUnsubscribeToken() error = %v", err)
+	}
+
+	sign := func(method jwt.SigningMethod, key interface{}, edit func(*domain.UnsubscribeClaims)) string {
+		claims := &domain.UnsubscribeClaims{
+			ParentID:         7,
+			Channel:          domain.ChannelEmail,
+			NotificationType: domain.NotificationTypeAttendance,
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   unsubscribeSubject,
+				Audience:  jwt.ClaimStrings{unsubscribeAudience},
+				Issuer:    unsubscribeIssuer,
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+		}
+		if edit != nil {
+			edit(claims)
+		}
+		token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		if err != nil {
+			t.Fatalf("SignedString() error = %v", err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"generated token", valid, false},
+		{"signed by hand", sign(jwt.SigningMethodHS256, key, nil), false},
+		{"tampered signature", valid[:len(valid)-2] + "xx", true},
+		{"empty", "", true},
+		{"not a token", "unsubscribe-me", true},
+		{"expired", sign(jwt.SigningMethodHS256, key, func(c *domain.UnsubscribeClaims) {
+			c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+		}), true},
+		{"signed with the staff key", sign(jwt.SigningMethodHS256, []byte(staffKey), nil), true},
+		{"signed with an empty key", sign(jwt.SigningMethodHS256, []byte{}, nil), true},
+		{"signed with another key", sign(jwt.SigningMethodHS256, []byte("another key"), nil), true},
+		{"other hmac algorithm", sign(jwt.SigningMethodHS512, key, nil), true},
+		{"unsigned", sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, nil), true},
+		{"wrong subject", sign(jwt.SigningMethodHS256, key, func(c *domain.UnsubscribeClaims) { c.Subject = "login" }), true},
+		{"wrong audience", sign(jwt.SigningMethodHS256, key, func(c *domain.UnsubscribeClaims) { c.Audience = jwt.ClaimStrings{"staff"} }), true},
+		{"no audience", sign(jwt.SigningMethodHS256, key, func(c *domain.UnsubscribeClaims) { c.Audience = nil }), true},
+		{"wrong issuer", sign(jwt.SigningMethodHS256, key, func(c *domain.UnsubscribeClaims) { c.Issuer = "elsewhere" }), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := ParseUnsubscribeToken(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseUnsubscribeToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if claims.ParentID != 7 || claims.Channel != domain.ChannelEmail || claims.NotificationType != domain.NotificationTypeAttendance {
+				t.Errorf("ParseUnsubscribeToken() claims = %+v", claims)
+			}
+		})
+	}
+}
+
+func TestUnsubscribeKey(t *testing.T) {
+	t.Run("dedicated key wins", func(t *testing.T) {
+		t.Setenv("UNSUBSCRIBE_KEY", "dedicated")
+		t.Setenv("BYTE_KEY", "staff")
+
+		key, err := unsubscribeKey()
+		if err != nil {
+			t.Fatalf("unsubscribeKey() error = %v", err)
+		}
+		if string(key) != "dedicated" {
+			t.Errorf("unsubscribeKey() = %q, want the UNSUBSCRIBE_KEY value", key)
+		}
+	})
+
+	t.Run("derived key differs from the staff key", func(t *testing.T) {
+		t.Setenv("UNSUBSCRIBE_KEY", "")
+		t.Setenv("BYTE_KEY", "staff")
+
+		key, err := unsubscribeKey()
+		if err != nil {
+			t.Fatalf("unsubscribeKey() error = %v", err)
+		}
+		if len(key) == 0 || string(key) == "staff" {
+			t.Errorf("unsubscribeKey() = %q, want a key derived from BYTE_KEY", key)
+		}
+	})
+
+	t.Run("no key configured", func(t *testing.T) {
+		t.Setenv("UNSUBSCRIBE_KEY", "")
+		t.Setenv("BYTE_KEY", "")
+
+		if _, err := GenerateUnsubscribeToken(7, domain.ChannelEmail, domain.NotificationTypeAttendance); !errors.Is(err, errUnsubscribeKeyMissing) {
+			t.Errorf("GenerateUnsubscribeToken() error = %v, want %v", err, errUnsubscribeKeyMissing)
+		}
+
+		forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &domain.UnsubscribeClaims{
+			ParentID: 7,
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:  unsubscribeSubject,
+				Audience: jwt.ClaimStrings{unsubscribeAudience},
+				Issuer:   unsubscribeIssuer,
+			},
+		}).SignedString([]byte{})
+		if err != nil {
+			t.Fatalf("SignedString() error = %v", err)
+		}
+		if _, err := ParseUnsubscribeToken(forged); !errors.Is(err, errUnsubscribeKeyMissing) {
+			t.Errorf("ParseUnsubscribeToken() error = %v, want %v", err, errUnsubscribeKeyMissing)
+		}
+	})
+}
@@ -0,0 +1,249 @@
+package delivery
+
+import (
+	"fmt"
+	"notification/config"
+	"notification/domain"
+	"notification/middleware"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type analyticsHandler struct {
+	uc domain.AnalyticsUseCase
+}
+
+// NewAnalyticsHandlerDeploy registers the absence statistics.
+// Every endpoint takes from and to (YYYY-MM-DD, inclusive) and covers the last 30 days by default.
+func NewAnalyticsHandlerDeploy(app *fiber.App, uc domain.AnalyticsUseCase) {
+	handler := &analyticsHandler{
+		uc: uc,
+	}
+
+	route := app.Group("/analytics")
+	route.Get("/classes", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionViewAnalytics), handler.GetClassAbsenceStats)
+	route.Get("/subjects", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionViewAnalytics), handler.GetSubjectAbsenceStats)
+	route.Get("/teachers", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionViewAnalytics), handler.GetTeacherAbsenceStats)
+	route.Get("/months", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionViewAnalytics), handler.GetMonthlyAbsenceStats)
+	route.Get("/top-students", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionViewAnalytics), handler.GetTopAbsentStudents)
+	route.Get("/trend", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionViewAnalytics), handler.GetAbsenceTrend)
+}
+
+func parseAnalyticsFilter(c *fiber.Ctx) (*domain.AnalyticsFilter, error) {
+	now := time.Now()
+	filter := domain.AnalyticsFilter{
+		To:    time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, 1),
+		Limit: c.QueryInt("limit", domain.AnalyticsDefaultTopLimit),
+	}
+
+	if v := c.Query("to"); v != "" {
+		to, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("to must be a date like 2024-07-15")
+		}
+		// The whole end day is included
+		filter.To = to.AddDate(0, 0, 1)
+	}
+
+	filter.From = filter.To.AddDate(0, 0, -domain.AnalyticsDefaultDays)
+	if v := c.Query("from"); v != "" {
+		from, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("from must be a date like 2024-07-15")
+		}
+		filter.From = from
+	}
+
+	if !filter.To.After(filter.From) {
+		return nil, fmt.Errorf("from must not be after to")
+	}
+
+	return &filter, nil
+}
+
+func (ah *analyticsHandler) GetClassAbsenceStats(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	filter, err := parseAnalyticsFilter(c)
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "GetClassAbsenceStats")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid analytics period",
+			"error":   err.Error(),
+		})
+	}
+
+	data, err := ah.uc.GetClassAbsenceStats(c.Context(), userToken.UserID, filter)
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "GetClassAbsenceStats")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to get class absence statistics",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetClassAbsenceStats")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Class absence statistics retrieved successfully",
+		"data":    data,
+	})
+}
+
+func (ah *analyticsHandler) GetSubjectAbsenceStats(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	filter, err := parseAnalyticsFilter(c)
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "GetSubjectAbsenceStats")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid analytics period",
+			"error":   err.Error(),
+		})
+	}
+
+	data, err := ah.uc.GetSubjectAbsenceStats(c.Context(), userToken.UserID, filter)
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "GetSubjectAbsenceStats")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to get subject absence statistics",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetSubjectAbsenceStats")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Subject absence statistics retrieved successfully",
+		"data":    data,
+	})
+}
+
+func (ah *analyticsHandler) GetTeacherAbsenceStats(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	filter, err := parseAnalyticsFilter(c)
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "GetTeacherAbsenceStats")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid analytics period",
+			"error":   err.Error(),
+		})
+	}
+
+	data, err := ah.uc.GetTeacherAbsenceStats(c.Context(), userToken.UserID, filter)
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "GetTeacherAbsenceStats")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to get teacher absence statistics",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetTeacherAbsenceStats")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Teacher absence statistics retrieved successfully",
+		"data":    data,
+	})
+}
+
+func (ah *analyticsHandler) GetMonthlyAbsenceStats(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	filter, err := parseAnalyticsFilter(c)
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "GetMonthlyAbsenceStats")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid analytics period",
+			"error":   err.Error(),
+		})
+	}
+
+	data, err := ah.uc.GetMonthlyAbsenceStats(c.Context(), userToken.UserID, filter)
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "GetMonthlyAbsenceStats")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to get monthly absence statistics",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetMonthlyAbsenceStats")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Monthly absence statistics retrieved successfully",
+		"data":    data,
+	})
+}
+
+func (ah *analyticsHandler) GetTopAbsentStudents(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	filter, err := parseAnalyticsFilter(c)
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "GetTopAbsentStudents")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid analytics period",
+			"error":   err.Error(),
+		})
+	}
+
+	data, err := ah.uc.GetTopAbsentStudents(c.Context(), userToken.UserID, filter)
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "GetTopAbsentStudents")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to get top absent students",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetTopAbsentStudents")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Top absent students retrieved successfully",
+		"data":    data,
+	})
+}
+
+func (ah *analyticsHandler) GetAbsenceTrend(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	filter, err := parseAnalyticsFilter(c)
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "GetAbsenceTrend")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid analytics period",
+			"error":   err.Error(),
+		})
+	}
+
+	data, err := ah.uc.GetAbsenceTrend(c.Context(), userToken.UserID, filter)
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "GetAbsenceTrend")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to get absence trend",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetAbsenceTrend")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Absence trend retrieved successfully",
+		"data":    data,
+	})
+}
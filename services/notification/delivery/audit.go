@@ -0,0 +1,96 @@
+package delivery
+
+import (
+	"fmt"
+	"notification/config"
+	"notification/domain"
+	"notification/middleware"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type auditHandler struct {
+	uc domain.AuditUseCase
+}
+
+func NewAuditHandlerDeploy(app *fiber.App, uc domain.AuditUseCase) {
+	handler := &auditHandler{
+		uc: uc,
+	}
+
+	app.Get("/audit-logs", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionViewAuditLog), handler.GetAuditLogs)
+}
+
+// GetAuditLogs lists administrative changes newest first.
+// It filters on actor_id, action, entity, entity_id and from/to (YYYY-MM-DD, inclusive) and pages with cursor and limit.
+func (ah *auditHandler) GetAuditLogs(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	filter, err := parseAuditLogFilter(c)
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "GetAuditLogs")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid audit log filter",
+			"error":   err.Error(),
+		})
+	}
+
+	page, err := ah.uc.GetAuditLogs(c.Context(), filter)
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "GetAuditLogs")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to get audit log",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetAuditLogs")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success":     true,
+		"message":     "Successfully retrieved audit log",
+		"data":        page.Items,
+		"next_cursor": page.NextCursor,
+	})
+}
+
+func parseAuditLogFilter(c *fiber.Ctx) (*domain.AuditLogFilter, error) {
+	filter := domain.AuditLogFilter{
+		Action:   c.Query("action"),
+		Entity:   c.Query("entity"),
+		EntityID: c.Query("entity_id"),
+		Cursor:   c.Query("cursor"),
+	}
+
+	if v := c.Query("from"); v != "" {
+		from, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("from must be a date like 2024-07-15")
+		}
+		filter.From = &from
+	}
+	if v := c.Query("to"); v != "" {
+		to, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("to must be a date like 2024-07-15")
+		}
+		// The whole end day is included
+		to = to.AddDate(0, 0, 1)
+		filter.To = &to
+	}
+
+	for name, target := range map[string]*int{"actor_id": &filter.ActorID, "limit": &filter.Limit} {
+		if v := c.Query(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("%s must be a positive number", name)
+			}
+			*target = n
+		}
+	}
+
+	return &filter, nil
+}
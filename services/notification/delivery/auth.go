@@ -1,7 +1,13 @@
 package delivery
 
 import (
+	"errors"
+	"math"
+	"notification/config"
 	"notification/domain"
+	"notification/middleware"
+	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -16,6 +22,14 @@ func NewUserAuthHandler(app *fiber.App, uc domain.AuthUseCase) {
 	}
 
 	app.Post("/login", handler.Login)
+	app.Post("/refresh", handler.Refresh)
+	app.Post("/logout", middleware.AuthRequiredForPasswordChange(), handler.Logout)
+	app.Post("/logout/all", middleware.AuthRequiredForPasswordChange(), handler.LogoutAll)
+	app.Post("/password/change", middleware.AuthRequiredForPasswordChange(), handler.ChangePassword)
+	app.Post("/password/reset", handler.ResetPassword)
+	app.Post("/user/reset-password/:id", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageStaff), handler.RequestPasswordReset)
+	app.Get("/user/login-lockouts", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageLogins), handler.GetLoginThrottles)
+	app.Post("/user/login-lockouts/unlock", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageLogins), handler.UnlockLogin)
 }
 
 func (h *userHandler) Login(c *fiber.Ctx) error {
@@ -25,8 +39,18 @@ func (h *userHandler) Login(c *fiber.Ctx) error {
 			"error": "invalid request body",
 		})
 	}
+	req.UserAgent = strings.Clone(c.Get(fiber.HeaderUserAgent))
+	req.IP = strings.Clone(c.IP())
 
-	dataList, err := h.uc.Login(c.Context(), &req)
+	response, err := h.uc.Login(c.Context(), &req)
+	var throttled *domain.LoginThrottledError
+	if errors.As(err, &throttled) {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"error":   err.Error(),
+			"message": "Failed to login",
+		})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   err.Error(),
@@ -34,14 +58,241 @@ func (h *userHandler) Login(c *fiber.Ctx) error {
 		})
 	}
 
-	theRole := (*dataList)[0]
-	theUsername := (*dataList)[1]
-	theToken := (*dataList)[2]
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// Refresh returns a new access token and refresh token, the refresh token sent can not be used again
+func (h *userHandler) Refresh(c *fiber.Ctx) error {
+	var req domain.RefreshRequest
+	if err := c.BodyParser(&req); err != nil || req.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	response, err := h.uc.Refresh(c.Context(), req.RefreshToken)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"error":   err.Error(),
+			"message": "Failed to refresh token",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// Logout ends the session of the access token
+func (h *userHandler) Logout(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	if err := h.uc.Logout(c.Context(), userToken.UserID, userToken.SessionID); err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "Logout")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to logout",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "Logout")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Successfully logged out",
+	})
+}
+
+// LogoutAll ends every session of the user, this one included
+func (h *userHandler) LogoutAll(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	if err := h.uc.LogoutAll(c.Context(), userToken.UserID); err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "LogoutAll")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to logout from all sessions",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "LogoutAll")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Successfully logged out from all sessions",
+	})
+}
+
+// ChangePassword also clears a forced password change, the response holds the tokens of a new session
+func (h *userHandler) ChangePassword(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	var req domain.ChangePasswordRequest
+	if err := c.BodyParser(&req); err != nil || req.CurrentPassword == "" || req.NewPassword == "" {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "ChangePassword")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+			"error":   "current_password and new_password are required",
+		})
+	}
+	req.UserAgent = strings.Clone(c.Get(fiber.HeaderUserAgent))
+	req.IP = strings.Clone(c.IP())
+
+	response, errList, err := h.uc.ChangePassword(c.Context(), userToken.UserID, &req)
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "ChangePassword")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to change password",
+			"error":   err.Error(),
+		})
+	}
+	if errList != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "ChangePassword")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to change password",
+			"error":   errList,
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "ChangePassword")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Password changed successfully",
+		"data":    response,
+	})
+}
+
+// RequestPasswordReset sends a staff member a one-time reset code by email or WhatsApp
+func (h *userHandler) RequestPasswordReset(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "RequestPasswordReset")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "converter failure",
+		})
+	}
+
+	var req domain.PasswordResetRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "RequestPasswordReset")
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid request body",
+				"error":   err.Error(),
+			})
+		}
+	}
+
+	delivery, err := h.uc.RequestPasswordReset(c.Context(), id, req.Channel)
+	if err != nil {
+		status := errorStatus(err)
+		config.PrintLogInfo(c, &userToken.Username, status, "RequestPasswordReset")
+		return c.Status(status).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to reset password",
+			"error":   err.Error(),
+		})
+	}
+
+	message := "Reset code sent via " + delivery.Channel
+	if delivery.Status == domain.DeliveryStatusQueued {
+		message = "Reset code queued for " + delivery.Channel + ", it goes out once the WhatsApp session is back"
+	}
 
-	return c.Status(fiber.StatusOK).JSON(domain.LoginResponse{
-		Token:    theToken,
-		Role:     theRole,
-		Username: theUsername,
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "RequestPasswordReset")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": message,
+		"data":    delivery,
 	})
+}
+
+// ResetPassword sets a new password with the code a staff member received, no token is needed
+func (h *userHandler) ResetPassword(c *fiber.Ctx) error {
+	var req domain.ResetPasswordRequest
+	if err := c.BodyParser(&req); err != nil || req.Code == "" || req.NewPassword == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+			"error":   "code and new_password are required",
+		})
+	}
+
+	errList, err := h.uc.ResetPassword(c.Context(), &req)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to reset password",
+			"error":   err.Error(),
+		})
+	}
+	if errList != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to reset password",
+			"error":   errList,
+		})
+	}
 
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Password reset successfully, please login with the new password",
+	})
+}
+
+// GetLoginThrottles lists the usernames and addresses with recent failed logins, locked_until is set on a lockout
+func (h *userHandler) GetLoginThrottles(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	throttles, err := h.uc.GetLoginThrottles(c.Context())
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "GetLoginThrottles")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to get login lockouts",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetLoginThrottles")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Login lockouts retrieved successfully",
+		"data":    throttles,
+	})
+}
+
+// UnlockLogin clears the failed logins of a username and/or an address so they can login right away
+func (h *userHandler) UnlockLogin(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	var req domain.LoginUnlockRequest
+	if err := c.BodyParser(&req); err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "UnlockLogin")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := h.uc.UnlockLogin(c.Context(), &req); err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "UnlockLogin")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to unlock login",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "UnlockLogin")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Login unlocked successfully",
+	})
 }
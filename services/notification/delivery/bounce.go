@@ -0,0 +1,67 @@
+package delivery
+
+import (
+	"notification/config"
+	"notification/domain"
+	"notification/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type bounceHandler struct {
+	uc domain.BounceUseCase
+}
+
+func NewBounceHandlerDeploy(app *fiber.App, uc domain.BounceUseCase) {
+	handler := &bounceHandler{
+		uc: uc,
+	}
+
+	group := app.Group("/notification/bounces")
+	group.Get("/", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageBounces), handler.GetInvalidEmailReport)
+	group.Post("/process", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageBounces), handler.ProcessMailbox)
+}
+
+func (bh *bounceHandler) GetInvalidEmailReport(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	datas, err := bh.uc.GetInvalidEmailReport(c.Context())
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "GetInvalidEmailReport")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to get invalid email report",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetInvalidEmailReport")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Invalid email report retrieved successfully",
+		"data":    datas,
+	})
+}
+
+// ProcessMailbox reads the bounce mailbox right away instead of waiting for the next poll
+func (bh *bounceHandler) ProcessMailbox(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	result, err := bh.uc.ProcessMailbox(c.Context())
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "ProcessBounceMailbox")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to process bounce mailbox",
+			"error":   err.Error(),
+			"data":    result,
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "ProcessBounceMailbox")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Bounce mailbox processed successfully",
+		"data":    result,
+	})
+}
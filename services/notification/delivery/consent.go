@@ -0,0 +1,177 @@
+package delivery
+
+import (
+	"bytes"
+	"html/template"
+	"notification/config"
+	"notification/domain"
+	"notification/middleware"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type consentHandler struct {
+	uc domain.ConsentUseCase
+}
+
+func NewConsentHandlerDeploy(app *fiber.App, uc domain.ConsentUseCase) {
+	handler := &consentHandler{
+		uc: uc,
+	}
+
+	route := app.Group("/consent")
+	route.Get("/unsubscribe", handler.ConfirmUnsubscribe)
+	route.Post("/unsubscribe", handler.Unsubscribe)
+	route.Get("/parent/:parent_id", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageConsent), handler.GetParentConsents)
+	route.Put("/parent/:parent_id", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageConsent), handler.SetParentConsent)
+}
+
+// unsubscribePage asks the parent to confirm, link scanners that only follow the link change nothing
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>{{.Title}}</title></head>
+<body><p>{{.Question}}</p>
+<form method="post"><input type="hidden" name="token" value="{{.Token}}"><button type="submit">{{.Button}}</button></form>
+</body></html>`))
+
+// ConfirmUnsubscribe shows the opt-out confirmation for the link in an email, the opt-out itself is a POST
+func (ch *consentHandler) ConfirmUnsubscribe(c *fiber.Ctx) error {
+	guest := "Guest"
+	isIndonesian := strings.ToLower(os.Getenv("MESSENGER_LANGUAGE")) == "ind"
+
+	token := c.Query("token")
+	if _, err := middleware.ParseUnsubscribeToken(token); err != nil {
+		config.PrintLogInfo(c, &guest, fiber.StatusBadRequest, "ConfirmUnsubscribe")
+		return c.Status(fiber.StatusBadRequest).SendString(unsubscribeInvalidMessage(isIndonesian))
+	}
+
+	page := map[string]string{
+		"Title":    "Unsubscribe",
+		"Question": "Do you want to stop receiving these email notifications from the school?",
+		"Button":   "Unsubscribe",
+		"Token":    token,
+	}
+	if isIndonesian {
+		page["Title"] = "Berhenti berlangganan"
+		page["Question"] = "Apakah Anda ingin berhenti menerima email pemberitahuan ini dari sekolah?"
+		page["Button"] = "Berhenti berlangganan"
+	}
+
+	var body bytes.Buffer
+	if err := unsubscribePage.Execute(&body, page); err != nil {
+		config.PrintLogInfo(c, &guest, fiber.StatusInternalServerError, "ConfirmUnsubscribe")
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	config.PrintLogInfo(c, &guest, fiber.StatusOK, "ConfirmUnsubscribe")
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return c.Status(fiber.StatusOK).Send(body.Bytes())
+}
+
+// Unsubscribe opts the parent out, it takes the token from the confirmation form or, for a
+// One-Click request from a mail client (RFC 8058), from the query of the List-Unsubscribe link
+func (ch *consentHandler) Unsubscribe(c *fiber.Ctx) error {
+	guest := "Guest"
+	isIndonesian := strings.ToLower(os.Getenv("MESSENGER_LANGUAGE")) == "ind"
+
+	token := c.FormValue("token")
+	if token == "" {
+		token = c.Query("token")
+	}
+
+	_, err := ch.uc.Unsubscribe(c.Context(), token)
+	if err != nil {
+		config.PrintLogInfo(c, &guest, fiber.StatusBadRequest, "Unsubscribe")
+		return c.Status(fiber.StatusBadRequest).SendString(unsubscribeInvalidMessage(isIndonesian))
+	}
+
+	config.PrintLogInfo(c, &guest, fiber.StatusOK, "Unsubscribe")
+	if isIndonesian {
+		return c.Status(fiber.StatusOK).SendString("Anda telah berhenti berlangganan email pemberitahuan ini. Hubungi sekolah untuk mengaktifkannya kembali.")
+	}
+	return c.Status(fiber.StatusOK).SendString("You have been unsubscribed from these email notifications. Contact the school to turn them back on.")
+}
+
+func unsubscribeInvalidMessage(isIndonesian bool) string {
+	if isIndonesian {
+		return "Tautan berhenti berlangganan tidak valid atau sudah kedaluwarsa."
+	}
+	return "This unsubscribe link is invalid or has expired."
+}
+
+func (ch *consentHandler) GetParentConsents(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	parentID, err := strconv.Atoi(c.Params("parent_id"))
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "GetParentConsents")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Converter failure on parent_id",
+			"error":   err.Error(),
+		})
+	}
+
+	datas, err := ch.uc.GetParentConsents(c.Context(), parentID)
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "GetParentConsents")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to get parent preferences",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetParentConsents")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Parent preferences retrieved successfully",
+		"data":    datas,
+	})
+}
+
+func (ch *consentHandler) SetParentConsent(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	parentID, err := strconv.Atoi(c.Params("parent_id"))
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "SetParentConsent")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Converter failure on parent_id",
+			"error":   err.Error(),
+		})
+	}
+
+	var payload domain.ParentConsent
+	if err := c.BodyParser(&payload); err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "SetParentConsent")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+
+	payload.ParentID = parentID
+	payload.Source = "admin"
+
+	err = ch.uc.SetParentConsent(c.Context(), &payload)
+	if err != nil {
+		status := errorStatus(err)
+		config.PrintLogInfo(c, &userToken.Username, status, "SetParentConsent")
+		return c.Status(status).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to update parent preferences",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "SetParentConsent")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Parent preferences updated successfully",
+	})
+}
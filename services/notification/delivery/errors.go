@@ -0,0 +1,24 @@
+package delivery
+
+import (
+	"errors"
+	"notification/domain"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// errorStatus answers 404, 400, 409 and 401 for the domain errors and 500 for everything else
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, domain.ErrInvalidInput):
+		return fiber.StatusBadRequest
+	case errors.Is(err, domain.ErrConflict):
+		return fiber.StatusConflict
+	case errors.Is(err, domain.ErrUnauthorized):
+		return fiber.StatusUnauthorized
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
@@ -0,0 +1,217 @@
+package delivery
+
+import (
+	"notification/config"
+	"notification/domain"
+	"notification/middleware"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type examHandler struct {
+	uc domain.ExamUseCase
+}
+
+func NewExamHandlerDeploy(app *fiber.App, uc domain.ExamUseCase) {
+	handler := &examHandler{
+		uc: uc,
+	}
+
+	route := app.Group("/exam")
+	route.Get("/", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionViewExams), handler.GetAllExams)
+	route.Post("/", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageExams), handler.CreateExam)
+	route.Get("/:exam_id", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionViewExams), handler.GetExamDetail)
+	route.Put("/:exam_id", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageExams), handler.UpdateExam)
+	route.Delete("/:exam_id", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageExams), handler.DeleteExam)
+	route.Get("/:exam_id/scores", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionViewExams), handler.GetExamScores)
+}
+
+func (eh *examHandler) CreateExam(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	var payload domain.Exam
+	if err := c.BodyParser(&payload); err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "CreateExam")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := eh.uc.CreateExam(c.Context(), &payload); err != nil {
+		status := errorStatus(err)
+		config.PrintLogInfo(c, &userToken.Username, status, "CreateExam")
+		return c.Status(status).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to create exam",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusCreated, "CreateExam")
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"message": "Exam created successfully",
+		"data":    payload,
+	})
+}
+
+// GetAllExams lists exams, ?academic_year=2024/2025 and ?semester=1 narrow the list
+func (eh *examHandler) GetAllExams(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	datas, err := eh.uc.GetAllExams(c.Context(), c.Query("academic_year"), c.QueryInt("semester", 0))
+	if err != nil {
+		status := errorStatus(err)
+		config.PrintLogInfo(c, &userToken.Username, status, "GetAllExams")
+		return c.Status(status).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to get exams",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetAllExams")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Exams retrieved successfully",
+		"data":    datas,
+	})
+}
+
+func (eh *examHandler) GetExamDetail(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	examID, err := strconv.Atoi(c.Params("exam_id"))
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "GetExamDetail")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Converter failure on exam_id",
+			"error":   err.Error(),
+		})
+	}
+
+	data, err := eh.uc.GetExamDetail(c.Context(), examID)
+	if err != nil {
+		status := errorStatus(err)
+		config.PrintLogInfo(c, &userToken.Username, status, "GetExamDetail")
+		return c.Status(status).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to get exam detail",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetExamDetail")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Exam retrieved successfully",
+		"data":    data,
+	})
+}
+
+func (eh *examHandler) UpdateExam(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	examID, err := strconv.Atoi(c.Params("exam_id"))
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "UpdateExam")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Converter failure on exam_id",
+			"error":   err.Error(),
+		})
+	}
+
+	var payload domain.Exam
+	if err := c.BodyParser(&payload); err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "UpdateExam")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := eh.uc.UpdateExam(c.Context(), examID, &payload); err != nil {
+		status := errorStatus(err)
+		config.PrintLogInfo(c, &userToken.Username, status, "UpdateExam")
+		return c.Status(status).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to update exam",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "UpdateExam")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Exam updated successfully",
+	})
+}
+
+func (eh *examHandler) DeleteExam(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	examID, err := strconv.Atoi(c.Params("exam_id"))
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "DeleteExam")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Converter failure on exam_id",
+			"error":   err.Error(),
+		})
+	}
+
+	if err := eh.uc.DeleteExam(c.Context(), examID); err != nil {
+		status := errorStatus(err)
+		config.PrintLogInfo(c, &userToken.Username, status, "DeleteExam")
+		return c.Status(status).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to delete exam",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "DeleteExam")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Exam deleted successfully",
+	})
+}
+
+// GetExamScores returns every score recorded for an exam, ?subject_code narrows it to one subject
+func (eh *examHandler) GetExamScores(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	examID, err := strconv.Atoi(c.Params("exam_id"))
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "GetExamScores")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Converter failure on exam_id",
+			"error":   err.Error(),
+		})
+	}
+
+	datas, err := eh.uc.GetExamScores(c.Context(), examID, c.Query("subject_code"))
+	if err != nil {
+		status := errorStatus(err)
+		config.PrintLogInfo(c, &userToken.Username, status, "GetExamScores")
+		return c.Status(status).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to get exam scores",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetExamScores")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Exam scores retrieved successfully",
+		"data":    datas,
+	})
+}
@@ -0,0 +1,109 @@
+package delivery
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"notification/domain"
+	"notification/metrics"
+	"os"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type metricsHandler struct {
+	serve fiber.Handler
+}
+
+// NewMetricsHandlerDeploy exposes /metrics for Prometheus.
+// When METRICS_TOKEN is set the scraper has to send it as a bearer token.
+func NewMetricsHandlerDeploy(app *fiber.App, db *sql.DB, session domain.WhatsappSessionManager) error {
+	if err := metrics.Register(&scrapeCollector{db: db, session: session}); err != nil {
+		return err
+	}
+
+	handler := &metricsHandler{
+		serve: adaptor.HTTPHandler(metrics.Handler()),
+	}
+
+	app.Get("/metrics", handler.GetMetrics)
+	return nil
+}
+
+func (mh *metricsHandler) GetMetrics(c *fiber.Ctx) error {
+	if token := os.Getenv("METRICS_TOKEN"); token != "" {
+		if subtle.ConstantTimeCompare([]byte(c.Get(fiber.HeaderAuthorization)), []byte("Bearer "+token)) != 1 {
+			return c.Status(fiber.StatusUnauthorized).SendString("unauthorized")
+		}
+	}
+
+	return mh.serve(c)
+}
+
+var (
+	whatsappConnectedDesc = prometheus.NewDesc("notification_whatsapp_connected", "Whether the WhatsApp websocket is connected.", nil, nil)
+	whatsappStateDesc     = prometheus.NewDesc("notification_whatsapp_state", "Current state of the linked WhatsApp device, the active state is 1.", []string{"state"}, nil)
+	whatsappQueueDesc     = prometheus.NewDesc("notification_whatsapp_queue_depth", "WhatsApp messages waiting in the outbox for the session to come back.", nil, nil)
+
+	dbMaxOpenDesc           = prometheus.NewDesc("notification_db_max_open_connections", "Maximum number of open connections to the database.", nil, nil)
+	dbOpenDesc              = prometheus.NewDesc("notification_db_open_connections", "Established connections to the database, in use and idle.", nil, nil)
+	dbInUseDesc             = prometheus.NewDesc("notification_db_in_use_connections", "Connections currently in use.", nil, nil)
+	dbIdleDesc              = prometheus.NewDesc("notification_db_idle_connections", "Idle connections.", nil, nil)
+	dbWaitCountDesc         = prometheus.NewDesc("notification_db_wait_count_total", "Connections waited for.", nil, nil)
+	dbWaitDurationDesc      = prometheus.NewDesc("notification_db_wait_duration_seconds_total", "Time spent waiting for a connection.", nil, nil)
+	dbMaxIdleClosedDesc     = prometheus.NewDesc("notification_db_max_idle_closed_total", "Connections closed because of the idle limit.", nil, nil)
+	dbMaxLifetimeClosedDesc = prometheus.NewDesc("notification_db_max_lifetime_closed_total", "Connections closed because of their maximum lifetime.", nil, nil)
+)
+
+// scrapeCollector reads the values that are only known at scrape time
+type scrapeCollector struct {
+	db      *sql.DB
+	session domain.WhatsappSessionManager
+}
+
+func (sc *scrapeCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		whatsappConnectedDesc, whatsappStateDesc, whatsappQueueDesc,
+		dbMaxOpenDesc, dbOpenDesc, dbInUseDesc, dbIdleDesc,
+		dbWaitCountDesc, dbWaitDurationDesc, dbMaxIdleClosedDesc, dbMaxLifetimeClosedDesc,
+	} {
+		ch <- desc
+	}
+}
+
+func (sc *scrapeCollector) Collect(ch chan<- prometheus.Metric) {
+	if sc.session != nil {
+		status := sc.session.Status()
+
+		connected := 0.0
+		if status.Connected {
+			connected = 1
+		}
+		ch <- prometheus.MustNewConstMetric(whatsappConnectedDesc, prometheus.GaugeValue, connected)
+
+		for _, state := range []string{domain.WhatsappStateConnected, domain.WhatsappStateUnavailable, domain.WhatsappStateAwaitingLogin, domain.WhatsappStateLoggedOut} {
+			value := 0.0
+			if status.State == state {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(whatsappStateDesc, prometheus.GaugeValue, value, state)
+		}
+
+		ch <- prometheus.MustNewConstMetric(whatsappQueueDesc, prometheus.GaugeValue, float64(status.QueuedMessages))
+	}
+
+	if sc.db == nil {
+		return
+	}
+
+	stats := sc.db.Stats()
+	ch <- prometheus.MustNewConstMetric(dbMaxOpenDesc, prometheus.GaugeValue, float64(stats.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(dbOpenDesc, prometheus.GaugeValue, float64(stats.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(dbInUseDesc, prometheus.GaugeValue, float64(stats.InUse))
+	ch <- prometheus.MustNewConstMetric(dbIdleDesc, prometheus.GaugeValue, float64(stats.Idle))
+	ch <- prometheus.MustNewConstMetric(dbWaitCountDesc, prometheus.CounterValue, float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(dbWaitDurationDesc, prometheus.CounterValue, stats.WaitDuration.Seconds())
+	ch <- prometheus.MustNewConstMetric(dbMaxIdleClosedDesc, prometheus.CounterValue, float64(stats.MaxIdleClosed))
+	ch <- prometheus.MustNewConstMetric(dbMaxLifetimeClosedDesc, prometheus.CounterValue, float64(stats.MaxLifetimeClosed))
+}
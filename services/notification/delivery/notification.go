@@ -1,9 +1,16 @@
 package delivery
 
 import (
+	"bufio"
+	"context"
+	"fmt"
 	"notification/config"
 	"notification/domain"
 	"notification/middleware"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -18,26 +25,360 @@ func NewNotificationHandlerDeploy(app *fiber.App, uc domain.NotificationUseCase)
 	}
 
 	group := app.Group("/notification")
-	group.Get("/truancy-history", middleware.AuthRequired(), middleware.RoleRequired("admin", "staff"), handler.GetAllAttendanceNotificationHistory)
+	group.Get("/truancy-history", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionViewHistory), handler.GetAllAttendanceNotificationHistory)
+	group.Get("/truancy-history/export", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionViewHistory), handler.ExportAttendanceNotificationHistory)
+	group.Get("/exam-history", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionViewHistory), handler.GetAllExamResultNotificationHistory)
+	group.Get("/inbox", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageInbox), handler.GetAllParentReplies)
+	group.Put("/inbox/:reply_id/read", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageInbox), handler.MarkParentReplyRead)
 }
 
+// GetAllAttendanceNotificationHistory returns one page of truancy history.
+// Filters: from, to (YYYY-MM-DD, inclusive), grade, grade_label, subject_code, student_nsn, user_id, whatsapp, email (delivery status).
+// Paging: sort (created_at, student_nsn, subject_code), order (asc, desc), limit and the cursor of the previous page.
+// Without limit only the first 50 rows are returned, clients follow next_cursor for the rest.
 func (nh *notifHandler) GetAllAttendanceNotificationHistory(c *fiber.Ctx) error {
 	userToken := c.Locals("user").(*domain.Claims)
 
-	datas, err := nh.uc.GetAllAttendanceNotificationHistory(c.Context())
+	filter, err := parseAttendanceHistoryFilter(c)
 	if err != nil {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusInternalServerError, "GetAllAttendanceNotificationHistory")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "GetAllAttendanceNotificationHistory")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid truancy history filter",
+			"error":   err.Error(),
+		})
+	}
 
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+	page, err := nh.uc.GetAllAttendanceNotificationHistory(c.Context(), filter)
+	if err != nil {
+		status := errorStatus(err)
+		config.PrintLogInfo(c, &userToken.Username, status, "GetAllAttendanceNotificationHistory")
+
+		return c.Status(status).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to get all truancy history",
+			"error":   err.Error(),
 		})
 	}
 
-	config.PrintLogInfo(&userToken.Username, fiber.StatusOK, "GetAllAttendanceNotificationHistory")
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetAllAttendanceNotificationHistory")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success":     true,
+		"message":     "Successfully retrieved all truancy history",
+		"data":        page.Items,
+		"next_cursor": page.NextCursor,
+	})
+}
+
+func parseAttendanceHistoryFilter(c *fiber.Ctx) (*domain.AttendanceHistoryFilter, error) {
+	filter := domain.AttendanceHistoryFilter{
+		GradeLabel:       c.Query("grade_label"),
+		SubjectCode:      c.Query("subject_code"),
+		StudentNSN:       c.Query("student_nsn"),
+		WhatsappDelivery: c.Query("whatsapp"),
+		EmailDelivery:    c.Query("email"),
+		SortBy:           c.Query("sort", "created_at"),
+		Cursor:           c.Query("cursor"),
+	}
+
+	if v := c.Query("from"); v != "" {
+		from, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("from must be a date like 2024-07-15")
+		}
+		filter.From = &from
+	}
+	if v := c.Query("to"); v != "" {
+		to, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("to must be a date like 2024-07-15")
+		}
+		// The whole end day is included
+		to = to.AddDate(0, 0, 1)
+		filter.To = &to
+	}
+
+	for name, target := range map[string]*int{"grade": &filter.Grade, "user_id": &filter.UserID, "limit": &filter.Limit} {
+		if v := c.Query(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("%s must be a positive number", name)
+			}
+			*target = n
+		}
+	}
+
+	switch strings.ToLower(c.Query("order", "desc")) {
+	case "desc":
+		filter.Descending = true
+	case "asc":
+	default:
+		return nil, fmt.Errorf("order must be asc or desc")
+	}
+
+	return &filter, nil
+}
+
+// ExportAttendanceNotificationHistory streams the truancy history as a CSV or XLSX file.
+// It takes the same filters and sort as the history endpoint plus format (csv, xlsx) and lang (ind, eng).
+func (nh *notifHandler) ExportAttendanceNotificationHistory(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	format := strings.ToLower(c.Query("format", "csv"))
+	if format != "csv" && format != "xlsx" {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "ExportAttendanceNotificationHistory")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid export format",
+			"error":   "format must be csv or xlsx",
+		})
+	}
+
+	filter, err := parseAttendanceHistoryFilter(c)
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "ExportAttendanceNotificationHistory")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid truancy history filter",
+			"error":   err.Error(),
+		})
+	}
+
+	// Query values point into the request buffer, the filter is used again after the handler returns
+	filter.GradeLabel = strings.Clone(filter.GradeLabel)
+	filter.SubjectCode = strings.Clone(filter.SubjectCode)
+	filter.StudentNSN = strings.Clone(filter.StudentNSN)
+	filter.WhatsappDelivery = strings.Clone(filter.WhatsappDelivery)
+	filter.EmailDelivery = strings.Clone(filter.EmailDelivery)
+	filter.SortBy = strings.Clone(filter.SortBy)
+	filter.Cursor = ""
+	filter.Limit = domain.AttendanceHistoryMaxLimit
+
+	isIndonesian := strings.ToLower(os.Getenv("MESSENGER_LANGUAGE")) == "ind"
+	switch strings.ToLower(c.Query("lang")) {
+	case "ind":
+		isIndonesian = true
+	case "eng":
+		isIndonesian = false
+	}
+
+	// The first page is read up front so a bad filter still gets a proper error response
+	page, err := nh.uc.GetAllAttendanceNotificationHistory(c.Context(), filter)
+	if err != nil {
+		status := errorStatus(err)
+		config.PrintLogInfo(c, &userToken.Username, status, "ExportAttendanceNotificationHistory")
+		return c.Status(status).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to export truancy history",
+			"error":   err.Error(),
+		})
+	}
+
+	filename := fmt.Sprintf("truancy-history-%s.%s", time.Now().Format("20060102"), format)
+	sheetName := "Truancy History"
+	if isIndonesian {
+		filename = fmt.Sprintf("riwayat-bolos-%s.%s", time.Now().Format("20060102"), format)
+		sheetName = "Riwayat Bolos"
+	}
+
+	c.Attachment(filename)
+	if format == "csv" {
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	}
+
+	// The request context is recycled once the handler returns, the stream keeps its own logger
+	logger := config.Logger(c.Context()).WithField("user", userToken.Username)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		ctx := config.WithLogger(context.Background(), logger)
+
+		rows, err := newRowWriter(format, w, sheetName)
+		if err != nil {
+			logger.WithError(err).Error("could not start truancy history export")
+			return
+		}
+
+		if err := rows.WriteRow(attendanceHistoryExportHeader(isIndonesian)); err != nil {
+			logger.WithError(err).Error("could not write truancy history export")
+			return
+		}
+
+		number := 0
+		for {
+			for _, history := range page.Items {
+				number++
+				if err := rows.WriteRow(attendanceHistoryExportRow(number, history, isIndonesian)); err != nil {
+					logger.WithError(err).Error("could not write truancy history export")
+					return
+				}
+			}
+			if err := w.Flush(); err != nil {
+				// The client went away
+				return
+			}
+
+			if page.NextCursor == "" {
+				break
+			}
+			filter.Cursor = page.NextCursor
+			page, err = nh.uc.GetAllAttendanceNotificationHistory(ctx, filter)
+			if err != nil {
+				logger.WithError(err).WithField("rows", number).Error("truancy history export stopped early")
+				// The status line is already sent, the file itself has to say that it is incomplete
+				if err := rows.WriteRow(attendanceHistoryExportFailure(isIndonesian)); err != nil {
+					logger.WithError(err).Error("could not write truancy history export")
+					return
+				}
+				break
+			}
+		}
+
+		if err := rows.Close(); err != nil {
+			logger.WithError(err).Error("could not finish truancy history export")
+			return
+		}
+		w.Flush()
+	})
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "ExportAttendanceNotificationHistory")
+	return nil
+}
+
+func attendanceHistoryExportHeader(isIndonesian bool) []interface{} {
+	if isIndonesian {
+		return []interface{}{"No", "Tanggal", "NSN", "Nama Siswa", "Kelas", "Mata Pelajaran", "Orang Tua", "Telepon Orang Tua", "Guru", "WhatsApp", "Email", "Percobaan", "Kesalahan WhatsApp", "Kesalahan Email"}
+	}
+	return []interface{}{"No", "Date", "NSN", "Student Name", "Class", "Subject", "Parent", "Parent Phone", "Teacher", "WhatsApp", "Email", "Attempts", "WhatsApp Error", "Email Error"}
+}
+
+// attendanceHistoryExportFailure is the last row of an export that could not read every page
+func attendanceHistoryExportFailure(isIndonesian bool) []interface{} {
+	if isIndonesian {
+		return []interface{}{"GAGAL", "Ekspor tidak lengkap, terjadi kesalahan saat membaca data. Silakan ulangi ekspor."}
+	}
+	return []interface{}{"FAILED", "Export incomplete, an error occurred while reading the data. Please run the export again."}
+}
+
+func attendanceHistoryExportRow(number int, history domain.AttendanceNotificationHistoryResponse, isIndonesian bool) []interface{} {
+	optional := func(value *string) string {
+		if value == nil {
+			return ""
+		}
+		return *value
+	}
+
+	return []interface{}{
+		number,
+		history.CreatedAt.Local().Format("02/01/2006 15:04"),
+		history.Student.StudentNSN,
+		history.Student.Name,
+		strings.TrimSpace(fmt.Sprintf("%d %s", history.Student.Grade, history.Student.GradeLabel)),
+		history.Subject.Name,
+		history.Parent.Name,
+		history.Parent.Telephone,
+		history.User.Name,
+		deliveryStatusLabel(history.WhatsappDelivery, isIndonesian),
+		deliveryStatusLabel(history.EmailDelivery, isIndonesian),
+		history.Attempts,
+		optional(history.WhatsappError),
+		optional(history.EmailError),
+	}
+}
+
+func deliveryStatusLabel(status string, isIndonesian bool) string {
+	labels := map[string][2]string{
+		domain.DeliveryStatusSent:       {"Sent", "Terkirim"},
+		domain.DeliveryStatusFailed:     {"Failed", "Gagal"},
+		domain.DeliveryStatusSuppressed: {"Suppressed", "Dinonaktifkan"},
+		domain.DeliveryStatusSkipped:    {"Skipped", "Dilewati"},
+		domain.DeliveryStatusQueued:     {"Queued", "Antre"},
+		domain.DeliveryStatusPending:    {"Pending", "Menunggu"},
+	}
+
+	label, ok := labels[status]
+	if !ok {
+		if status == "" {
+			return "-"
+		}
+		return status
+	}
+	if isIndonesian {
+		return label[1]
+	}
+	return label[0]
+}
+
+// GetAllExamResultNotificationHistory lists exam result notices, ?exam_id narrows it to one exam
+func (nh *notifHandler) GetAllExamResultNotificationHistory(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	datas, err := nh.uc.GetAllExamResultNotificationHistory(c.Context(), c.QueryInt("exam_id", 0))
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "GetAllExamResultNotificationHistory")
+
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to get all exam result history",
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetAllExamResultNotificationHistory")
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
-		"message": "Successfully retrieved all truancy history",
+		"message": "Successfully retrieved all exam result history",
 		"data":    datas,
 	})
 }
+
+func (nh *notifHandler) GetAllParentReplies(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+	unreadOnly := c.QueryBool("unread", false)
+
+	datas, err := nh.uc.GetAllParentReplies(c.Context(), unreadOnly)
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "GetAllParentReplies")
+
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to get parent replies",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetAllParentReplies")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Successfully retrieved parent replies",
+		"data":    datas,
+	})
+}
+
+func (nh *notifHandler) MarkParentReplyRead(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	replyID, err := strconv.Atoi(c.Params("reply_id"))
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "MarkParentReplyRead")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Converter failure on reply_id",
+			"error":   err.Error(),
+		})
+	}
+
+	err = nh.uc.MarkParentReplyRead(c.Context(), replyID)
+	if err != nil {
+		status := errorStatus(err)
+		config.PrintLogInfo(c, &userToken.Username, status, "MarkParentReplyRead")
+		return c.Status(status).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to mark parent reply as read",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "MarkParentReplyRead")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Parent reply marked as read",
+	})
+}
@@ -0,0 +1,192 @@
+package delivery
+
+import (
+	"notification/config"
+	"notification/domain"
+	"notification/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type roleHandler struct {
+	uc domain.RoleUseCase
+}
+
+func NewRoleHandlerDeploy(app *fiber.App, uc domain.RoleUseCase) {
+	handler := &roleHandler{
+		uc: uc,
+	}
+
+	route := app.Group("/role")
+	route.Get("/", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageRoles), handler.GetAllRoles)
+	route.Get("/permissions", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageRoles), handler.GetAllPermissions)
+	route.Get("/mine", middleware.AuthRequired(), handler.GetMyRole)
+	route.Get("/:name", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageRoles), handler.GetRole)
+	route.Post("/", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageRoles), handler.CreateRole)
+	route.Put("/:name", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageRoles), handler.UpdateRole)
+	route.Delete("/:name", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageRoles), handler.DeleteRole)
+}
+
+func (rh *roleHandler) GetAllRoles(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	roles, err := rh.uc.GetAllRoles(c.Context())
+	if err != nil {
+		status := errorStatus(err)
+		config.PrintLogInfo(c, &userToken.Username, status, "GetAllRoles")
+		return c.Status(status).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to get roles",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetAllRoles")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Roles retrieved successfully",
+		"data":    roles,
+	})
+}
+
+// GetAllPermissions lists every permission a role can be given
+func (rh *roleHandler) GetAllPermissions(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetAllPermissions")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Permissions retrieved successfully",
+		"data":    domain.Permissions,
+	})
+}
+
+// GetMyRole returns the role of the signed in user with its permissions, for the client to show what it may do
+func (rh *roleHandler) GetMyRole(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	role, err := rh.uc.GetRole(c.Context(), userToken.Role)
+	if err != nil {
+		status := errorStatus(err)
+		config.PrintLogInfo(c, &userToken.Username, status, "GetMyRole")
+		return c.Status(status).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to get role",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetMyRole")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Role retrieved successfully",
+		"data":    role,
+	})
+}
+
+func (rh *roleHandler) GetRole(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	role, err := rh.uc.GetRole(c.Context(), c.Params("name"))
+	if err != nil {
+		status := errorStatus(err)
+		config.PrintLogInfo(c, &userToken.Username, status, "GetRole")
+		return c.Status(status).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to get role",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetRole")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Role retrieved successfully",
+		"data":    role,
+	})
+}
+
+func (rh *roleHandler) CreateRole(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	var req domain.RoleRequest
+	if err := c.BodyParser(&req); err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "CreateRole")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+
+	role, err := rh.uc.CreateRole(c.Context(), &req)
+	if err != nil {
+		status := errorStatus(err)
+		config.PrintLogInfo(c, &userToken.Username, status, "CreateRole")
+		return c.Status(status).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to create role",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusCreated, "CreateRole")
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"message": "Role created successfully",
+		"data":    role,
+	})
+}
+
+// UpdateRole replaces the description and the whole permission list of a role
+func (rh *roleHandler) UpdateRole(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	var req domain.RoleRequest
+	if err := c.BodyParser(&req); err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "UpdateRole")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+
+	role, err := rh.uc.UpdateRole(c.Context(), c.Params("name"), &req)
+	if err != nil {
+		status := errorStatus(err)
+		config.PrintLogInfo(c, &userToken.Username, status, "UpdateRole")
+		return c.Status(status).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to update role",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "UpdateRole")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Role updated successfully",
+		"data":    role,
+	})
+}
+
+func (rh *roleHandler) DeleteRole(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	if err := rh.uc.DeleteRole(c.Context(), c.Params("name")); err != nil {
+		status := errorStatus(err)
+		config.PrintLogInfo(c, &userToken.Username, status, "DeleteRole")
+		return c.Status(status).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to delete role",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "DeleteRole")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Role deleted successfully",
+	})
+}
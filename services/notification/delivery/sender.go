@@ -1,9 +1,15 @@
 package delivery
 
 import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
 	"notification/config"
 	"notification/domain"
 	"notification/middleware"
+	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -18,20 +24,32 @@ func NewSenderDeliveryDeploy(app *fiber.App, uc domain.SenderUseCase) {
 	}
 
 	route := app.Group("/sender")
-	route.Post("/send-mass", middleware.AuthRequired(), middleware.RoleRequired("admin", "staff"), handler.sendMassHandler)
-	route.Post("/send-mass/exam-result", middleware.AuthRequired(), middleware.RoleRequired("admin"), handler.SendTestScores)
+	route.Post("/send-mass", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionSendAbsence), handler.sendMassHandler)
+	route.Post("/send-mass/exam-result", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionSendExamResults), handler.SendTestScores)
+	route.Get("/exam-broadcast", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionSendExamResults), handler.GetExamBroadcasts)
+	route.Get("/exam-broadcast/:broadcast_id", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionSendExamResults), handler.GetExamBroadcastSummary)
+	route.Post("/exam-broadcast/:broadcast_id/resume", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionSendExamResults), handler.ResumeExamBroadcast)
+
+	// Resending goes through the sender, the route sits next to the history it acts on
+	app.Post("/notification/truancy-history/:history_id/resend", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionSendAbsence), handler.ResendAttendanceNotification)
 }
 
 func (h *senderHandler) SendTestScores(c *fiber.Ctx) error {
 	userToken := c.Locals("user").(*domain.Claims)
 
+	// Resend has to be set to broadcast an exam whose results already went out
 	var payload struct {
-		ExamType string `json:"exam_type"`
+		ExamID int  `json:"exam_id"`
+		DryRun bool `json:"dry_run"`
+		Resend bool `json:"resend"`
 	}
 
 	err := c.BodyParser(&payload)
+	if err == nil && payload.ExamID == 0 {
+		err = errors.New("exam_id is required")
+	}
 	if err != nil {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusBadRequest, "SendTestScores")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "SendTestScores")
 		return c.Status(fiber.StatusBadRequest).JSON((fiber.Map{
 			"error":   err.Error(),
 			"success": false,
@@ -39,34 +57,164 @@ func (h *senderHandler) SendTestScores(c *fiber.Ctx) error {
 		}))
 	}
 
-	err = h.suc.SendTestScores(c.Context(), payload.ExamType)
+	report, err := h.suc.SendTestScores(c.Context(), payload.ExamID, userToken.UserID, payload.DryRun, payload.Resend)
 	if err != nil {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusInternalServerError, "SendTestScores")
-		return c.Status(fiber.StatusInternalServerError).JSON((fiber.Map{
+		status := errorStatus(err)
+		config.PrintLogInfo(c, &userToken.Username, status, "SendTestScores")
+		return c.Status(status).JSON((fiber.Map{
 			"error":   err.Error(),
 			"success": false,
 			"message": "Failed to announce test scores",
+			"data":    report,
 		}))
 	}
 
-	config.PrintLogInfo(&userToken.Username, fiber.StatusOK, "SendTestScores")
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "SendTestScores")
+	if payload.DryRun {
+		return sendPreview(c, &report.Results, "exam-result-preview.zip")
+	}
 	return c.Status(fiber.StatusOK).JSON((fiber.Map{
 		"success": true,
 		"message": "Successfully announce test scores",
+		"data":    report,
 	}))
 }
 
+// ResendAttendanceNotification retries the failed channels of one truancy history entry
+func (h *senderHandler) ResendAttendanceNotification(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	historyID, err := strconv.Atoi(c.Params("history_id"))
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "ResendAttendanceNotification")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Converter failure on history_id",
+			"error":   err.Error(),
+		})
+	}
+
+	results, err := h.suc.ResendAttendanceNotification(c.Context(), historyID)
+	if err != nil {
+		status := errorStatus(err)
+		config.PrintLogInfo(c, &userToken.Username, status, "ResendAttendanceNotification")
+		return c.Status(status).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to resend attendance notification",
+			"error":   err.Error(),
+			"data":    results,
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "ResendAttendanceNotification")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Attendance notification resent",
+		"data":    results,
+	})
+}
+
+// ResumeExamBroadcast sends the exam result to the recipients a broadcast has not reached yet
+func (h *senderHandler) ResumeExamBroadcast(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	broadcastID, err := strconv.Atoi(c.Params("broadcast_id"))
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "ResumeExamBroadcast")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Converter failure on broadcast_id",
+			"error":   err.Error(),
+		})
+	}
+
+	report, err := h.suc.ResumeExamBroadcast(c.Context(), broadcastID, userToken.UserID)
+	if err != nil {
+		status := errorStatus(err)
+		config.PrintLogInfo(c, &userToken.Username, status, "ResumeExamBroadcast")
+		return c.Status(status).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to resume exam result broadcast",
+			"error":   err.Error(),
+			"data":    report,
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "ResumeExamBroadcast")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Exam result broadcast resumed successfully",
+		"data":    report,
+	})
+}
+
+// GetExamBroadcasts lists exam result broadcasts, ?exam_id narrows it to one exam
+func (h *senderHandler) GetExamBroadcasts(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	datas, err := h.suc.GetExamBroadcasts(c.Context(), c.QueryInt("exam_id", 0))
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "GetExamBroadcasts")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to get exam result broadcasts",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetExamBroadcasts")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Exam result broadcasts retrieved successfully",
+		"data":    datas,
+	})
+}
+
+// GetExamBroadcastSummary counts recipients per status and lists the students still unsent
+func (h *senderHandler) GetExamBroadcastSummary(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	broadcastID, err := strconv.Atoi(c.Params("broadcast_id"))
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "GetExamBroadcastSummary")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Converter failure on broadcast_id",
+			"error":   err.Error(),
+		})
+	}
+
+	data, err := h.suc.GetExamBroadcastSummary(c.Context(), broadcastID)
+	if err != nil {
+		status := errorStatus(err)
+		config.PrintLogInfo(c, &userToken.Username, status, "GetExamBroadcastSummary")
+		return c.Status(status).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to get exam result broadcast",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetExamBroadcastSummary")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Exam result broadcast retrieved successfully",
+		"data":    data,
+	})
+}
+
 func (h *senderHandler) sendMassHandler(c *fiber.Ctx) error {
 	var payload struct {
 		NSNList     []string `json:"nsn_list"`
 		SubjectCode string   `json:"subject_code"`
+		DryRun      bool     `json:"dry_run"`
 	}
 
 	userToken := c.Locals("user").(*domain.Claims)
 	userID := userToken.UserID
 
 	if err := c.BodyParser(&payload); err != nil {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusBadRequest, "sendMassHandler")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "sendMassHandler")
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error":   "invalid request body",
 			"success": false,
@@ -74,20 +222,110 @@ func (h *senderHandler) sendMassHandler(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.suc.SendMass(c.Context(), &payload.NSNList, &userID, payload.SubjectCode); err != nil {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusInternalServerError, "sendMassHandler")
+	results, err := h.suc.SendMass(c.Context(), &payload.NSNList, &userID, payload.SubjectCode, payload.DryRun)
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "sendMassHandler")
 
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
 			"error":   "failed to send notifications",
 			"detail":  err.Error(),
+			"data":    results,
 		})
 	}
 
-	config.PrintLogInfo(&userToken.Username, fiber.StatusOK, "sendMassHandler")
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "sendMassHandler")
+	if payload.DryRun {
+		return sendPreview(c, results, "attendance-preview.zip")
+	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "notifications sent successfully",
 		"success": true,
+		"data":    results,
 	})
 }
+
+// sendPreview answers a dry run with the rendered messages, or as a zip archive with ?format=zip
+func sendPreview(c *fiber.Ctx, results *[]domain.DeliveryResult, fileName string) error {
+	if c.Query("format") != "zip" {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"success": true,
+			"message": "Dry run, nothing was sent",
+			"data":    results,
+		})
+	}
+
+	archive, err := buildPreviewArchive(results)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to build preview archive",
+			"error":   err.Error(),
+		})
+	}
+
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, fileName))
+	c.Set(fiber.HeaderContentType, "application/zip")
+	return c.Status(fiber.StatusOK).Send(archive)
+}
+
+// buildPreviewArchive writes summary.csv with every recipient and one text file per rendered message
+func buildPreviewArchive(results *[]domain.DeliveryResult) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	var items []domain.DeliveryResult
+	if results != nil {
+		items = *results
+	}
+
+	files := make([]string, len(items))
+	for i, result := range items {
+		if result.Body != "" {
+			files[i] = fmt.Sprintf("messages/%04d_%s_%d_%s.txt", i+1, result.StudentNSN, result.ParentID, result.Channel)
+		}
+	}
+
+	// The summary is complete before the next entry is created, zip closes the previous entry on Create
+	summary, err := zw.Create("summary.csv")
+	if err != nil {
+		return nil, err
+	}
+	cw := csv.NewWriter(summary)
+	if err := cw.Write([]string{"student_nsn", "parent_id", "channel", "status", "reason", "recipient", "subject", "file"}); err != nil {
+		return nil, err
+	}
+	for i, result := range items {
+		if err := cw.Write([]string{result.StudentNSN, strconv.Itoa(result.ParentID), result.Channel, result.Status, result.Reason, result.Recipient, result.Subject, files[i]}); err != nil {
+			return nil, err
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return nil, err
+	}
+
+	for i, result := range items {
+		if files[i] == "" {
+			continue
+		}
+		w, err := zw.Create(files[i])
+		if err != nil {
+			return nil, err
+		}
+		content := result.Body
+		if result.Subject != "" {
+			content = "Subject: " + result.Subject + "\n\n" + content
+		}
+		if _, err := w.Write([]byte("To: " + result.Recipient + "\n" + content)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,87 @@
+package delivery
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"notification/domain"
+	"reflect"
+	"testing"
+)
+
+func TestBuildPreviewArchive(t *testing.T) {
+	results := []domain.DeliveryResult{
+		{StudentNSN: "0051", ParentID: 3, Channel: domain.ChannelEmail, Status: "preview", Recipient: "parent@example.com", Subject: "Absence notice", Body: "Your child was absent today."},
+		{StudentNSN: "0051", ParentID: 3, Channel: domain.ChannelWhatsapp, Status: "preview", Recipient: "6281234567890", Body: "Anak Anda tidak hadir hari ini."},
+		{StudentNSN: "0052", ParentID: 4, Channel: domain.ChannelEmail, Status: "skipped", Reason: "opted out"},
+	}
+
+	archive, err := buildPreviewArchive(&results)
+	if err != nil {
+		t.Fatalf("buildPreviewArchive() error = %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatalf("zip.NewReader() error = %v", err)
+	}
+
+	files := make(map[string]string)
+	var names []string
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("read %s: %v", f.Name, err)
+		}
+		files[f.Name] = string(content)
+		names = append(names, f.Name)
+	}
+
+	emailFile := "messages/0001_0051_3_" + domain.ChannelEmail + ".txt"
+	whatsappFile := "messages/0002_0051_3_" + domain.ChannelWhatsapp + ".txt"
+	if want := []string{"summary.csv", emailFile, whatsappFile}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("archive entries = %v, want %v", names, want)
+	}
+
+	rows, err := csv.NewReader(bytes.NewReader([]byte(files["summary.csv"]))).ReadAll()
+	if err != nil {
+		t.Fatalf("read summary.csv: %v", err)
+	}
+	wantRows := [][]string{
+		{"student_nsn", "parent_id", "channel", "status", "reason", "recipient", "subject", "file"},
+		{"0051", "3", domain.ChannelEmail, "preview", "", "parent@example.com", "Absence notice", emailFile},
+		{"0051", "3", domain.ChannelWhatsapp, "preview", "", "6281234567890", "", whatsappFile},
+		{"0052", "4", domain.ChannelEmail, "skipped", "opted out", "", "", ""},
+	}
+	if !reflect.DeepEqual(rows, wantRows) {
+		t.Errorf("summary.csv = %v, want %v", rows, wantRows)
+	}
+
+	if want := "To: parent@example.com\nSubject: Absence notice\n\nYour child was absent today."; files[emailFile] != want {
+		t.Errorf("%s = %q, want %q", emailFile, files[emailFile], want)
+	}
+	if want := "To: 6281234567890\nAnak Anda tidak hadir hari ini."; files[whatsappFile] != want {
+		t.Errorf("%s = %q, want %q", whatsappFile, files[whatsappFile], want)
+	}
+}
+
+func TestBuildPreviewArchiveEmpty(t *testing.T) {
+	archive, err := buildPreviewArchive(nil)
+	if err != nil {
+		t.Fatalf("buildPreviewArchive() error = %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatalf("zip.NewReader() error = %v", err)
+	}
+	if len(zr.File) != 1 || zr.File[0].Name != "summary.csv" {
+		t.Fatalf("archive entries = %d, want only summary.csv", len(zr.File))
+	}
+}
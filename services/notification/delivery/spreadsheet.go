@@ -0,0 +1,166 @@
+package delivery
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// rowWriter writes an export one row at a time so it can be streamed to the client
+type rowWriter interface {
+	WriteRow(cells []interface{}) error
+	Close() error
+}
+
+// exportCell turns a cell into text a spreadsheet shows as is. Control characters are dropped and text that
+// starts like a formula gets a leading apostrophe, so a name such as =HYPERLINK(...) is never evaluated.
+func exportCell(cell interface{}) string {
+	text := strings.Map(func(r rune) rune {
+		if r != '\n' && unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, fmt.Sprint(cell))
+
+	if text != "" && strings.ContainsRune("=+-@", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+func newRowWriter(format string, w io.Writer, sheetName string) (rowWriter, error) {
+	if format == "xlsx" {
+		return newXLSXWriter(w, sheetName)
+	}
+	return newCSVRowWriter(w)
+}
+
+type csvRowWriter struct {
+	writer *csv.Writer
+}
+
+func newCSVRowWriter(w io.Writer) (*csvRowWriter, error) {
+	// The byte order mark makes Excel read the file as UTF-8
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return nil, err
+	}
+	return &csvRowWriter{writer: csv.NewWriter(w)}, nil
+}
+
+func (cw *csvRowWriter) WriteRow(cells []interface{}) error {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		record[i] = exportCell(cell)
+	}
+	return cw.writer.Write(record)
+}
+
+func (cw *csvRowWriter) Close() error {
+	cw.writer.Flush()
+	return cw.writer.Error()
+}
+
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`
+	xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+	xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`
+	xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	xlsxSheetEnd = `</sheetData></worksheet>`
+)
+
+// xlsxWriter writes a single sheet workbook with inline strings, the sheet is the last zip entry so rows stream straight out
+type xlsxWriter struct {
+	archive *zip.Writer
+	sheet   io.Writer
+	row     int
+}
+
+func newXLSXWriter(w io.Writer, sheetName string) (*xlsxWriter, error) {
+	archive := zip.NewWriter(w)
+
+	var escapedName strings.Builder
+	if err := xml.EscapeText(&escapedName, []byte(sheetName)); err != nil {
+		return nil, err
+	}
+
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", fmt.Sprintf(xlsxWorkbook, escapedName.String())},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+	}
+	for _, part := range parts {
+		entry, err := archive.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(entry, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sheet, xlsxSheetStart); err != nil {
+		return nil, err
+	}
+
+	return &xlsxWriter{archive: archive, sheet: sheet}, nil
+}
+
+func (xw *xlsxWriter) WriteRow(cells []interface{}) error {
+	xw.row++
+
+	var row strings.Builder
+	fmt.Fprintf(&row, `<row r="%d">`, xw.row)
+	for i, cell := range cells {
+		ref := xlsxColumn(i) + strconv.Itoa(xw.row)
+		switch value := cell.(type) {
+		case int:
+			fmt.Fprintf(&row, `<c r="%s"><v>%d</v></c>`, ref, value)
+		default:
+			fmt.Fprintf(&row, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+			if err := xml.EscapeText(&row, []byte(exportCell(value))); err != nil {
+				return err
+			}
+			row.WriteString(`</t></is></c>`)
+		}
+	}
+	row.WriteString(`</row>`)
+
+	_, err := io.WriteString(xw.sheet, row.String())
+	return err
+}
+
+func (xw *xlsxWriter) Close() error {
+	if _, err := io.WriteString(xw.sheet, xlsxSheetEnd); err != nil {
+		return err
+	}
+	return xw.archive.Close()
+}
+
+// xlsxColumn turns a zero based index into a column name, 0 is A and 26 is AA
+func xlsxColumn(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
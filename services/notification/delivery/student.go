@@ -1,13 +1,11 @@
 package delivery
 
 import (
-	"fmt"
 	"notification/config"
 	"notification/domain"
 	"notification/middleware"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/log"
 )
 
 type studentHandler struct {
@@ -20,9 +18,10 @@ func NewStudentDeliveryDeploy(app *fiber.App, uc domain.StudentUseCase) {
 	}
 
 	route := app.Group("/student")
-	route.Get("/get-all", middleware.AuthRequired(), middleware.RoleRequired("admin", "staff"), handler.deliveryGetAllStudent)
-	route.Get("/download_input_template", middleware.AuthRequired(), middleware.RoleRequired("admin"), handler.deliveryDownloadTemplate)
-	route.Get("/telephone/:telephone", middleware.AuthRequired(), middleware.RoleRequired("admin"), handler.GetStudentByParentTelephone)
+	route.Get("/get-all", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionViewStudents), handler.deliveryGetAllStudent)
+	route.Get("/download_input_template", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageStudents), handler.deliveryDownloadTemplate)
+	route.Get("/telephone/:telephone", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageStudents), handler.GetStudentByParentTelephone)
+	route.Get("/:nsn/timeline", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionViewStudents), handler.GetStudentTimeline)
 }
 
 func (sh *studentHandler) GetStudentByParentTelephone(c *fiber.Ctx) error {
@@ -31,7 +30,7 @@ func (sh *studentHandler) GetStudentByParentTelephone(c *fiber.Ctx) error {
 
 	data, err := sh.suc.GetStudentByParentTelephone(c.Context(), tel)
 	if err != nil {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusInternalServerError, "GetStudentByParentTelephone")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "GetStudentByParentTelephone")
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to retrieve student",
@@ -40,7 +39,7 @@ func (sh *studentHandler) GetStudentByParentTelephone(c *fiber.Ctx) error {
 		})
 	}
 
-	config.PrintLogInfo(&userToken.Username, fiber.StatusOK, "GetStudentByParentTelephone")
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetStudentByParentTelephone")
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
 		"message": "Student retrieved successfully",
@@ -49,13 +48,36 @@ func (sh *studentHandler) GetStudentByParentTelephone(c *fiber.Ctx) error {
 
 }
 
+// GetStudentTimeline returns everything that happened around a student newest first, ?limit sets the page size and ?cursor continues from the previous page
+func (sh *studentHandler) GetStudentTimeline(c *fiber.Ctx) error {
+	userToken, _ := c.Locals("user").(*domain.Claims)
+
+	timeline, err := sh.suc.GetStudentTimeline(c.Context(), userToken.UserID, c.Params("nsn"), c.Query("cursor"), c.QueryInt("limit", domain.TimelineDefaultLimit))
+	if err != nil {
+		status := errorStatus(err)
+		config.PrintLogInfo(c, &userToken.Username, status, "GetStudentTimeline")
+		return c.Status(status).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to retrieve student timeline",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetStudentTimeline")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Student timeline retrieved successfully",
+		"data":    timeline,
+	})
+}
+
 func (sh *studentHandler) deliveryGetAllStudent(c *fiber.Ctx) error {
 	userToken, _ := c.Locals("user").(*domain.Claims)
 
 	students, err := sh.suc.GetAllStudent(c.Context(), userToken.UserID)
 	if err != nil {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusInternalServerError, "GetAllStudent")
-		log.Error(fmt.Sprintf("User: %s => Failed to get all students: %v", userToken.Username, err))
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "GetAllStudent")
+		config.Logger(c.Context()).WithField("user", userToken.Username).WithError(err).Error("failed to get all students")
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to retrieve students",
@@ -63,7 +85,7 @@ func (sh *studentHandler) deliveryGetAllStudent(c *fiber.Ctx) error {
 		})
 	}
 
-	config.PrintLogInfo(&userToken.Username, fiber.StatusOK, "GetAllStudent")
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetAllStudent")
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
 		"message": "Students retrieved successfully",
@@ -76,7 +98,7 @@ func (sh *studentHandler) deliveryDownloadTemplate(c *fiber.Ctx) error {
 
 	filePath, err := sh.suc.DownloadInputDataTemplate(c.Context())
 	if err != nil {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusInternalServerError, "DownloadTemplate")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "DownloadTemplate")
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to get the input data template",
@@ -87,6 +109,6 @@ func (sh *studentHandler) deliveryDownloadTemplate(c *fiber.Ctx) error {
 	c.Set("Content-Disposition", "attachment; filename=input_data_template.csv")
 	c.Set("Content-Type", "text/csv")
 
-	config.PrintLogInfo(&userToken.Username, fiber.StatusOK, "DownloadTemplate")
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "DownloadTemplate")
 	return c.SendFile(*filePath)
 }
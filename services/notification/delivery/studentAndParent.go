@@ -5,7 +5,6 @@ import (
 	"encoding/csv"
 	"errors"
 	"fmt"
-	"log"
 	"notification/config"
 	"notification/domain"
 	"notification/middleware"
@@ -31,18 +30,19 @@ func NewStudentParentHandlerDeploy(app *fiber.App, useCase domain.StudentParentU
 	}
 
 	route := app.Group("/student-and-parent")
-	route.Post("/insert", middleware.AuthRequired(), middleware.RoleRequired("admin"), handler.CreateStudentAndParent)
-	route.Post("/import", middleware.AuthRequired(), middleware.RoleRequired("admin"), handler.UploadAndImport)
-	route.Put("/modify/:student_nsn", middleware.AuthRequired(), middleware.RoleRequired("admin"), handler.UpdateStudentAndParent)
-	// route.Delete("/rm/:id", middleware.AuthRequired(), middleware.RoleRequired("admin"), handler.DeleteStudentAndParent)
-	route.Get("/student/:student_nsn", middleware.AuthRequired(), middleware.RoleRequired("admin", "staff"), handler.GetStudentDetailsByID)
+	route.Post("/insert", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageStudents), handler.CreateStudentAndParent)
+	route.Post("/import", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageStudents), handler.UploadAndImport)
+	route.Put("/modify/:student_nsn", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageStudents), handler.UpdateStudentAndParent)
+	route.Put("/modify/:student_nsn/guardians/:parent_id", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageStudents), handler.UpdateGuardianSettings)
+	// route.Delete("/rm/:id", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageStudents), handler.DeleteStudentAndParent)
+	route.Get("/student/:student_nsn", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionViewStudents), handler.GetStudentDetailsByID)
 	route.Post("/req/data-change-request", handler.DataChangeRequest)
-	route.Get("/get-all-data-change-request", middleware.AuthRequired(), middleware.RoleRequired("admin"), handler.GetAllDataChangeRequest)
-	route.Get("/get-all-data-change-request/:request_id", middleware.AuthRequired(), middleware.RoleRequired("admin"), handler.GetAllDataChangeRequestByID)
-	// route.Post("/rms", middleware.AuthRequired(), middleware.RoleRequired("admin"), handler.SPMassDelete)
-	route.Get("/download-template", middleware.AuthRequired(), middleware.RoleRequired("admin"), handler.DownloadTemplate)
-	route.Delete("/review/dcr/:request_id", middleware.AuthRequired(), middleware.RoleRequired("admin"), handler.DeleteDCR)
-	route.Post("/approve/dcr", middleware.AuthRequired(), middleware.RoleRequired("admin"), handler.ApproveDCR)
+	route.Get("/get-all-data-change-request", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionApproveDCR), handler.GetAllDataChangeRequest)
+	route.Get("/get-all-data-change-request/:request_id", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionApproveDCR), handler.GetAllDataChangeRequestByID)
+	// route.Post("/rms", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageStudents), handler.SPMassDelete)
+	route.Get("/download-template", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageStudents), handler.DownloadTemplate)
+	route.Delete("/review/dcr/:request_id", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionApproveDCR), handler.DeleteDCR)
+	route.Post("/approve/dcr", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionApproveDCR), handler.ApproveDCR)
 }
 
 func (sph *studentParentHandler) ApproveDCR(c *fiber.Ctx) error {
@@ -57,7 +57,7 @@ func (sph *studentParentHandler) ApproveDCR(c *fiber.Ctx) error {
 	}
 
 	if err := c.BodyParser(&payloadReadyForApprove); err != nil {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusBadRequest, "ApproveDCR")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "ApproveDCR")
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
 			"error":   err.Error(),
@@ -66,7 +66,7 @@ func (sph *studentParentHandler) ApproveDCR(c *fiber.Ctx) error {
 	}
 
 	if payloadReadyForApprove.OldTelephone == nil || *payloadReadyForApprove.OldTelephone == "" {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusBadRequest, "ApproveDCR")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "ApproveDCR")
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
 			"message": "Old telephone is required",
@@ -83,7 +83,7 @@ func (sph *studentParentHandler) ApproveDCR(c *fiber.Ctx) error {
 
 	allocated, err := sph.uc.ApproveDCR(c.Context(), repoPayload)
 	if err != nil {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusInternalServerError, "ApproveDCR")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "ApproveDCR")
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
 			"error":   err.Error(),
@@ -93,14 +93,14 @@ func (sph *studentParentHandler) ApproveDCR(c *fiber.Ctx) error {
 
 	if allocated != nil {
 		msgs := fmt.Sprintf("Data changes approved, %s", *allocated)
-		config.PrintLogInfo(&userToken.Username, fiber.StatusOK, "ApproveDCR")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "ApproveDCR")
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
 			"success": true,
 			"message": msgs,
 		})
 	}
 
-	config.PrintLogInfo(&userToken.Username, fiber.StatusOK, "ApproveDCR")
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "ApproveDCR")
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
 		"message": "Data changes approved",
@@ -117,23 +117,65 @@ func (sph *studentParentHandler) DownloadTemplate(c *fiber.Ctx) error {
 
 	err := c.SendFile(filePath, true)
 	if err != nil {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusInternalServerError, "DownloadTemplate")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "DownloadTemplate")
 
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to download template: " + err.Error(),
 		})
 	}
 
-	config.PrintLogInfo(&userToken.Username, fiber.StatusOK, "DownloadTemplate")
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "DownloadTemplate")
 	return nil
 }
 
+func (sph *studentParentHandler) UpdateGuardianSettings(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+	studentNSN := c.Params("student_nsn")
+	parentID, err := strconv.Atoi(c.Params("parent_id"))
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "UpdateGuardianSettings")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   err.Error(),
+			"message": "Converter Failure on parent_id",
+		})
+	}
+
+	var req domain.GuardianSettingsPayload
+	if err := c.BodyParser(&req); err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "UpdateGuardianSettings")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   err.Error(),
+			"message": "Invalid request body",
+		})
+	}
+
+	guardian, err := sph.uc.UpdateGuardianSettings(c.Context(), studentNSN, parentID, &req)
+	if err != nil {
+		status := errorStatus(err)
+		config.PrintLogInfo(c, &userToken.Username, status, "UpdateGuardianSettings")
+		return c.Status(status).JSON(fiber.Map{
+			"success": false,
+			"error":   err.Error(),
+			"message": "Failed to update guardian settings",
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "UpdateGuardianSettings")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Guardian settings updated successfully",
+		"data":    guardian,
+	})
+}
+
 func (sph *studentParentHandler) DeleteDCR(c *fiber.Ctx) error {
 	userToken := c.Locals("user").(*domain.Claims)
 	id := c.Params("request_id")
 	convertedID, err := strconv.Atoi(id)
 	if err != nil {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusBadRequest, "DeleteDCR")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "DeleteDCR")
 		return c.Status(fiber.StatusBadRequest).JSON((fiber.Map{
 			"success": false,
 			"error":   err.Error(),
@@ -142,7 +184,7 @@ func (sph *studentParentHandler) DeleteDCR(c *fiber.Ctx) error {
 	}
 	err = sph.uc.DeleteDCR(c.Context(), convertedID)
 	if err != nil {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusInternalServerError, "DeleteDCR")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "DeleteDCR")
 		return c.Status(fiber.StatusInternalServerError).JSON((fiber.Map{
 			"success": false,
 			"error":   err.Error(),
@@ -150,7 +192,7 @@ func (sph *studentParentHandler) DeleteDCR(c *fiber.Ctx) error {
 		}))
 	}
 
-	config.PrintLogInfo(&userToken.Username, fiber.StatusOK, "DeleteDCR")
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "DeleteDCR")
 	return c.Status(fiber.StatusOK).JSON((fiber.Map{
 		"success": true,
 		"message": "Data Change Request deleted successfully",
@@ -162,7 +204,7 @@ func (sph *studentParentHandler) GetAllDataChangeRequest(c *fiber.Ctx) error {
 
 	v, err := sph.uc.GetAllDataChangeRequest(c.Context())
 	if err != nil {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusInternalServerError, "GetAllDataChangeRequest")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "GetAllDataChangeRequest")
 		return c.Status(fiber.StatusInternalServerError).JSON((fiber.Map{
 			"success": false,
 			"error":   err.Error(),
@@ -171,7 +213,7 @@ func (sph *studentParentHandler) GetAllDataChangeRequest(c *fiber.Ctx) error {
 		}))
 	}
 
-	config.PrintLogInfo(&userToken.Username, fiber.StatusOK, "GetAllDataChangeRequest")
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetAllDataChangeRequest")
 	return c.Status(fiber.StatusOK).JSON((fiber.Map{
 		"success": true,
 		"message": "Data Change Request Retrieved Successfully",
@@ -185,7 +227,7 @@ func (sph *studentParentHandler) GetAllDataChangeRequestByID(c *fiber.Ctx) error
 	id := c.Params("request_id")
 	v, err := strconv.Atoi(id)
 	if err != nil {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusBadRequest, "GetAllDataChangeRequestByID")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "GetAllDataChangeRequestByID")
 		return c.Status(fiber.StatusBadRequest).JSON((fiber.Map{
 			"success": false,
 			"error":   err.Error(),
@@ -195,7 +237,7 @@ func (sph *studentParentHandler) GetAllDataChangeRequestByID(c *fiber.Ctx) error
 
 	data, err := sph.uc.GetAllDataChangeRequestByID(c.Context(), v)
 	if err != nil {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusInternalServerError, "GetAllDataChangeRequestByID")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "GetAllDataChangeRequestByID")
 		return c.Status(fiber.StatusInternalServerError).JSON((fiber.Map{
 			"success": false,
 			"error":   err.Error(),
@@ -203,7 +245,7 @@ func (sph *studentParentHandler) GetAllDataChangeRequestByID(c *fiber.Ctx) error
 		}))
 	}
 
-	config.PrintLogInfo(&userToken.Username, fiber.StatusOK, "GetAllDataChangeRequestByID")
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetAllDataChangeRequestByID")
 	return c.Status(fiber.StatusOK).JSON((fiber.Map{
 		"success": true,
 		"message": "Data Change Request Retrieved Successfully",
@@ -220,7 +262,7 @@ func (sph *studentParentHandler) GetAllDataChangeRequestByID(c *fiber.Ctx) error
 
 // 	err := c.BodyParser(&payload)
 // 	if err != nil {
-// 		config.PrintLogInfo(&userToken.Username, fiber.StatusBadRequest, "SPMassDelete")
+// 		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "SPMassDelete")
 // 		return c.Status(fiber.StatusInternalServerError).JSON((fiber.Map{
 // 			"success": false,
 // 			"error":   err.Error(),
@@ -230,7 +272,7 @@ func (sph *studentParentHandler) GetAllDataChangeRequestByID(c *fiber.Ctx) error
 
 // 	err = sph.uc.SPMassDelete(c.Context(), &payload.IDS)
 // 	if err != nil {
-// 		config.PrintLogInfo(&userToken.Username, fiber.StatusInternalServerError, "SPMassDelete")
+// 		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "SPMassDelete")
 // 		return c.Status(fiber.StatusInternalServerError).JSON((fiber.Map{
 // 			"success": false,
 // 			"error":   err.Error(),
@@ -238,7 +280,7 @@ func (sph *studentParentHandler) GetAllDataChangeRequestByID(c *fiber.Ctx) error
 // 		}))
 // 	}
 
-// 	config.PrintLogInfo(&userToken.Username, fiber.StatusOK, "SPMassDelete")
+// 	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "SPMassDelete")
 // 	return c.Status(fiber.StatusOK).JSON((fiber.Map{
 // 		"success": true,
 // 		"message": "Students deleted successfully",
@@ -250,7 +292,7 @@ func (sph *studentParentHandler) CreateStudentAndParent(c *fiber.Ctx) error {
 
 	var req domain.StudentAndParent
 	if err := c.BodyParser(&req); err != nil {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusBadRequest, "CreateStudentAndParent")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "CreateStudentAndParent")
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
 			"error":   []string{"Invalid request body: %v", err.Error()},
@@ -259,7 +301,7 @@ func (sph *studentParentHandler) CreateStudentAndParent(c *fiber.Ctx) error {
 	}
 
 	if req.Student.GradeLabel == "" {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusBadRequest, "CreateStudentAndParent")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "CreateStudentAndParent")
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
 			"error":   []string{"Invalid Grade Label: Grade Label is required"},
@@ -269,7 +311,7 @@ func (sph *studentParentHandler) CreateStudentAndParent(c *fiber.Ctx) error {
 
 	allocated, errList := sph.uc.CreateStudentAndParentUC(c.Context(), &req)
 	if errList != nil {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusInternalServerError, "CreateStudentAndParent")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "CreateStudentAndParent")
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
 			"error":   errList,
@@ -279,14 +321,14 @@ func (sph *studentParentHandler) CreateStudentAndParent(c *fiber.Ctx) error {
 
 	if allocated != nil {
 		msgs := fmt.Sprintf("Student and Parent created successfully, %s", *allocated)
-		config.PrintLogInfo(&userToken.Username, fiber.StatusOK, "ApproveDCR")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "ApproveDCR")
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
 			"success": true,
 			"message": msgs,
 		})
 	}
 
-	config.PrintLogInfo(&userToken.Username, fiber.StatusCreated, "CreateStudentAndParent")
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusCreated, "CreateStudentAndParent")
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"success": true,
@@ -299,7 +341,7 @@ func (sph *studentParentHandler) UploadAndImport(c *fiber.Ctx) error {
 
 	file, err := c.FormFile("file")
 	if err != nil {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusBadRequest, "UploadAndImport")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "UploadAndImport")
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
 			"error":   err.Error(),
@@ -312,7 +354,7 @@ func (sph *studentParentHandler) UploadAndImport(c *fiber.Ctx) error {
 	// Ensure upload directory exists
 	if _, err := os.Stat(uploadDir); os.IsNotExist(err) {
 		if err := os.MkdirAll(uploadDir, os.ModePerm); err != nil {
-			config.PrintLogInfo(&userToken.Username, fiber.StatusInternalServerError, "UploadAndImport")
+			config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "UploadAndImport")
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"success": false,
 				"error":   err.Error(),
@@ -325,7 +367,7 @@ func (sph *studentParentHandler) UploadAndImport(c *fiber.Ctx) error {
 	filePath := filepath.Join(uploadDir, file.Filename)
 	err = c.SaveFile(file, filePath)
 	if err != nil {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusBadRequest, "UploadAndImport")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "UploadAndImport")
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
 			"error":   err.Error(),
@@ -337,7 +379,7 @@ func (sph *studentParentHandler) UploadAndImport(c *fiber.Ctx) error {
 	badRequests, internalServerResponse, _ := sph.processCSVFile(c.Context(), filePath)
 
 	if badRequests != nil && len(*badRequests) > 0 {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusBadRequest, "UploadAndImport")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "UploadAndImport")
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
 			"message": "Import Failure, bad input found.",
@@ -346,7 +388,7 @@ func (sph *studentParentHandler) UploadAndImport(c *fiber.Ctx) error {
 	}
 
 	if internalServerResponse != nil && len(*internalServerResponse) > 0 {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusInternalServerError, "UploadAndImport")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "UploadAndImport")
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
 			"message": "Import Failure, duplicates found.",
@@ -354,7 +396,7 @@ func (sph *studentParentHandler) UploadAndImport(c *fiber.Ctx) error {
 		})
 	}
 
-	config.PrintLogInfo(&userToken.Username, fiber.StatusOK, "UploadAndImport")
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "UploadAndImport")
 	// If no errors and no duplicates, return success
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
@@ -374,13 +416,15 @@ func (sph *studentParentHandler) processCSVFile(c context.Context, filePath stri
 
 	defer func() {
 		if err := os.Remove(filePath); err != nil {
-			log.Printf("Failed to delete file: %v", err)
+			config.GetLogrusInstance().WithField("file", filePath).WithError(err).Warn("failed to delete uploaded file")
 		}
 	}()
 
 	defer file.Close()
 
 	reader := csv.NewReader(file)
+	// The second guardian columns are optional, so rows may differ in length
+	reader.FieldsPerRecord = -1
 	records, err := reader.ReadAll()
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to read CSV file: %v", err)
@@ -420,13 +464,42 @@ func (sph *studentParentHandler) processCSVFile(c context.Context, filePath stri
 
 		trimmedEmail := strings.TrimSpace(row[9])
 		row[9] = trimmedEmail
-		parentErrors := validateParent(row[6:], i+2, emailRegex)
+		parentErrors := validateParent(row[6:10], i+2, emailRegex)
 		if len(parentErrors) > 0 {
 			errList = append(errList, parentErrors...)
 		}
 
+		// Validate optional second guardian (columns 11-15)
+		var guardians []domain.GuardianPayload
+		var guardianErrors []string
+		if len(row) > 10 && strings.TrimSpace(row[10]) != "" {
+			guardianRow := make([]string, 5)
+			for j := range guardianRow {
+				if 10+j < len(row) {
+					guardianRow[j] = strings.TrimSpace(row[10+j])
+				}
+			}
+
+			for _, e := range validateParent(guardianRow[:4], i+2, emailRegex) {
+				guardianErrors = append(guardianErrors, strings.Replace(e, ": Parent", ": Guardian", 1))
+			}
+			errList = append(errList, guardianErrors...)
+
+			guardians = append(guardians, domain.GuardianPayload{
+				Parent: domain.Parent{
+					Name:      guardianRow[0],
+					Gender:    strings.ToLower(guardianRow[1]),
+					Telephone: guardianRow[2],
+					Email:     getStringPointer(guardianRow[3]),
+					CreatedAt: time.Now(),
+					UpdatedAt: time.Now(),
+				},
+				Relationship: strings.ToLower(guardianRow[4]),
+			})
+		}
+
 		// Populate student and parent data if no errors
-		if len(studentErrors) == 0 && len(parentErrors) == 0 {
+		if len(studentErrors) == 0 && len(parentErrors) == 0 && len(guardianErrors) == 0 {
 			student := domain.Student{
 				StudentNSN: row[0],
 				Name:       row[1],
@@ -449,8 +522,9 @@ func (sph *studentParentHandler) processCSVFile(c context.Context, filePath stri
 			}
 
 			listStudentAndParent = append(listStudentAndParent, domain.StudentAndParent{
-				Student: student,
-				Parent:  parent,
+				Student:   student,
+				Parent:    parent,
+				Guardians: guardians,
 			})
 		}
 	}
@@ -647,7 +721,7 @@ func (sph *studentParentHandler) UpdateStudentAndParent(c *fiber.Ctx) error {
 
 	studentNSN := c.Params("student_nsn")
 	if studentNSN == "" {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusBadRequest, "UpdateStudentAndParent")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "UpdateStudentAndParent")
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
 			"message": "student nsn Required",
@@ -656,7 +730,7 @@ func (sph *studentParentHandler) UpdateStudentAndParent(c *fiber.Ctx) error {
 
 	var req domain.StudentAndParent
 	if err := c.BodyParser(&req); err != nil {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusBadRequest, "UpdateStudentAndParent")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "UpdateStudentAndParent")
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
 			"error":   []string{"Invalid request body: %v", err.Error()},
@@ -665,7 +739,7 @@ func (sph *studentParentHandler) UpdateStudentAndParent(c *fiber.Ctx) error {
 	}
 
 	if req.Student.StudentNSN == "" || req.Student.Name == "" || req.Student.Grade == 0 || req.Student.GradeLabel == "" || req.Student.Gender == "" || req.Student.Telephone == "" || req.Parent.Name == "" || req.Parent.Telephone == "" || req.Parent.Gender == "" {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusBadRequest, "UpdateStudentAndParent")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "UpdateStudentAndParent")
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
 			"error":   []string{"fields cannot be blank"}, // Error as an array
@@ -676,7 +750,7 @@ func (sph *studentParentHandler) UpdateStudentAndParent(c *fiber.Ctx) error {
 	var validatorResponse []string
 	_, err := govalidator.ValidateStruct(&req)
 	if err != nil {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusBadRequest, "UpdateStudentAndParent")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "UpdateStudentAndParent")
 		validationErrors := govalidator.ErrorsByField(err)
 		for i := range validationErrors {
 			validatorResponse = append(validatorResponse, validationErrors[i])
@@ -690,8 +764,8 @@ func (sph *studentParentHandler) UpdateStudentAndParent(c *fiber.Ctx) error {
 
 	allocated, errList := sph.uc.UpdateStudentAndParent(c.Context(), studentNSN, &req)
 	if errList != nil && len(*errList) > 0 {
-		fmt.Println(errList)
-		config.PrintLogInfo(&userToken.Username, fiber.StatusInternalServerError, "UpdateStudentAndParent")
+		config.Logger(c.Context()).WithField("student_nsn", studentNSN).WithField("errors", *errList).Error("failed to update student and parent")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "UpdateStudentAndParent")
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
 			"error":   *errList,
@@ -701,14 +775,14 @@ func (sph *studentParentHandler) UpdateStudentAndParent(c *fiber.Ctx) error {
 
 	if allocated != nil {
 		msgs := fmt.Sprintf("Student and Parent updated successfully, %s", *allocated)
-		config.PrintLogInfo(&userToken.Username, fiber.StatusOK, "ApproveDCR")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "ApproveDCR")
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
 			"success": true,
 			"message": msgs,
 		})
 	}
 
-	config.PrintLogInfo(&userToken.Username, fiber.StatusOK, "UpdateStudentAndParent")
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "UpdateStudentAndParent")
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
 		"message": "Student and Parent updated successfully",
@@ -719,7 +793,7 @@ func (sph *studentParentHandler) UpdateStudentAndParent(c *fiber.Ctx) error {
 // 	userToken, _ := c.Locals("user").(*domain.Claims)
 // 	id, err := strconv.Atoi(c.Params("id"))
 // 	if err != nil {
-// 		config.PrintLogInfo(&userToken.Username, fiber.StatusBadRequest, "DeleteStudentAndParent")
+// 		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "DeleteStudentAndParent")
 
 // 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 // 			"success": false,
@@ -729,7 +803,7 @@ func (sph *studentParentHandler) UpdateStudentAndParent(c *fiber.Ctx) error {
 // 	}
 
 // 	if err := sph.uc.DeleteStudentAndParent(c.Context(), id); err != nil {
-// 		config.PrintLogInfo(&userToken.Username, fiber.StatusInternalServerError, "DeleteStudentAndParent")
+// 		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "DeleteStudentAndParent")
 
 // 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 // 			"success": false,
@@ -738,7 +812,7 @@ func (sph *studentParentHandler) UpdateStudentAndParent(c *fiber.Ctx) error {
 // 		})
 // 	}
 
-// 	config.PrintLogInfo(&userToken.Username, fiber.StatusOK, "DeleteStudentAndParent")
+// 	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "DeleteStudentAndParent")
 // 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 // 		"success": true,
 // 		"message": "Student deleted successfully",
@@ -751,7 +825,7 @@ func (sph *studentParentHandler) GetStudentDetailsByID(c *fiber.Ctx) error {
 	studentNSN := c.Params("student_nsn")
 	student, err := sph.uc.GetStudentDetailsByID(c.Context(), studentNSN)
 	if err != nil {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusInternalServerError, "GetStudentDetailsByID")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "GetStudentDetailsByID")
 
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
@@ -760,7 +834,7 @@ func (sph *studentParentHandler) GetStudentDetailsByID(c *fiber.Ctx) error {
 		})
 	}
 
-	config.PrintLogInfo(&userToken.Username, fiber.StatusOK, "GetStudentDetailsByID")
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetStudentDetailsByID")
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
 		"message": "Student and Parent retrieved successfully",
@@ -789,7 +863,7 @@ func (sph *studentParentHandler) DataChangeRequest(c *fiber.Ctx) error {
 
 	err := c.BodyParser(&datas)
 	if err != nil {
-		config.PrintLogInfo(&guess, fiber.StatusBadRequest, "DataChangeRequest")
+		config.PrintLogInfo(c, &guess, fiber.StatusBadRequest, "DataChangeRequest")
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
 			"message": "Invalid Request",
@@ -816,7 +890,7 @@ func (sph *studentParentHandler) DataChangeRequest(c *fiber.Ctx) error {
 
 	err = sph.uc.DataChangeRequest(c.Context(), datas)
 	if err != nil {
-		config.PrintLogInfo(&guess, fiber.StatusInternalServerError, "DataChangeRequest")
+		config.PrintLogInfo(c, &guess, fiber.StatusInternalServerError, "DataChangeRequest")
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to send data change request",
@@ -824,7 +898,7 @@ func (sph *studentParentHandler) DataChangeRequest(c *fiber.Ctx) error {
 		})
 	}
 
-	config.PrintLogInfo(&guess, fiber.StatusOK, "DataChangeRequest")
+	config.PrintLogInfo(c, &guess, fiber.StatusOK, "DataChangeRequest")
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
 		"message": "Successfully sent data changes request",
@@ -19,34 +19,37 @@ func NewUserHandlerDeploy(app *fiber.App, useCase domain.UserUseCase) {
 	}
 	group := app.Group("/user") // All routes under /user
 
-	group.Post("/create-staff", middleware.AuthRequired(), middleware.RoleRequired("admin"), handler.CreateStaff)
-	group.Get("/get-all", middleware.AuthRequired(), middleware.RoleRequired("admin"), handler.GetAllStaff)
-	group.Delete("/rm/:id", middleware.AuthRequired(), middleware.RoleRequired("admin"), handler.DeleteStaff)
-	group.Get("/details/:id", middleware.AuthRequired(), middleware.RoleRequired("admin"), handler.GetStaffDetail)
-	group.Put("/modify/:id", middleware.AuthRequired(), middleware.RoleRequired("admin"), handler.ModifyStaff)
-	group.Post("/add-subject", middleware.AuthRequired(), middleware.RoleRequired("admin"), handler.CreateSubject)
-	group.Post("/add-subject-bulk", middleware.AuthRequired(), middleware.RoleRequired("admin"), handler.CreateSubjectBulk)
-	group.Get("/subject/all", middleware.AuthRequired(), middleware.RoleRequired("admin", "staff"), handler.GetAllSubject)
-	group.Put("/subject/modify/:subject_code", middleware.AuthRequired(), middleware.RoleRequired("admin"), handler.UpdateSubject)
-	// group.Delete("/subject/rm/:id", middleware.AuthRequired(), middleware.RoleRequired("admin"), handler.DeleteSubject)
-	group.Get("/show-user-assigned-subject", middleware.AuthRequired(), middleware.RoleRequired("admin", "staff"), handler.GetSubjectsForTeacher)
-	group.Post("/input-test-scores", middleware.AuthRequired(), middleware.RoleRequired("admin", "staff"), handler.InputTestScores)
-	group.Get("/profile-dashboard", middleware.AuthRequired(), middleware.RoleRequired("admin", "staff"), handler.ShowProfile)
-	group.Post("/rm/users", middleware.AuthRequired(), middleware.RoleRequired("admin"), handler.DeleteStaffMass)
-	group.Get("/subject/:subject_code", middleware.AuthRequired(), middleware.RoleRequired("admin"), handler.GetSubjectDetail)
-	// group.Post("/rm/subjects", middleware.AuthRequired(), middleware.RoleRequired("admin"), handler.DeleteSubjectMass)
-	group.Get("/get-all/test-scores", middleware.AuthRequired(), middleware.RoleRequired("admin", "staff"), handler.GetAllTestScores)
-	group.Get("/get/test-scores/:subject_code", middleware.AuthRequired(), middleware.RoleRequired("admin", "staff"), handler.GetAllTestScoresBySubjectID)
-	// group.Get("/reset/test-scores", middleware.AuthRequired(), middleware.RoleRequired("admin"), handler.ResetTestScore)
+	group.Post("/create-staff", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageStaff), handler.CreateStaff)
+	group.Get("/get-all", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageStaff), handler.GetAllStaff)
+	group.Delete("/rm/:id", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageStaff), handler.DeleteStaff)
+	group.Get("/details/:id", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageStaff), handler.GetStaffDetail)
+	group.Put("/modify/:id", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageStaff), handler.ModifyStaff)
+	group.Post("/add-subject", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageSubjects), handler.CreateSubject)
+	group.Post("/add-subject-bulk", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageSubjects), handler.CreateSubjectBulk)
+	group.Get("/subject/all", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionViewSubjects), handler.GetAllSubject)
+	group.Put("/subject/modify/:subject_code", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageSubjects), handler.UpdateSubject)
+	// group.Delete("/subject/rm/:id", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageSubjects), handler.DeleteSubject)
+	group.Get("/show-user-assigned-subject", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionViewSubjects), handler.GetSubjectsForTeacher)
+	group.Post("/input-test-scores", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionInputScores), handler.InputTestScores)
+	group.Get("/profile-dashboard", middleware.AuthRequired(), handler.ShowProfile)
+	group.Post("/rm/users", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageStaff), handler.DeleteStaffMass)
+	group.Get("/subject/:subject_code", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageSubjects), handler.GetSubjectDetail)
+	// group.Post("/rm/subjects", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageSubjects), handler.DeleteSubjectMass)
+	group.Get("/get-all/test-scores", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionViewScores), handler.GetAllTestScores)
+	group.Get("/get/test-scores/:subject_code", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionViewScores), handler.GetAllTestScoresBySubjectID)
+	// group.Get("/reset/test-scores", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageExams), handler.ResetTestScore)
 }
 
 func (h *uHandler) GetAllTestScoresBySubjectID(c *fiber.Ctx) error {
 	userToken := c.Locals("user").(*domain.Claims)
 	subjectCode := c.Params("subject_code")
 
-	data, err := h.uc.GetAllTestScoresBySubjectID(c.Context(), subjectCode)
+	// exam_id picks the exam, the latest exam is used when it is left out
+	examID := c.QueryInt("exam_id", 0)
+
+	data, err := h.uc.GetAllTestScoresBySubjectID(c.Context(), subjectCode, examID)
 	if err != nil {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusInternalServerError, "GetAllTestScoresBySubjectID")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "GetAllTestScoresBySubjectID")
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   err.Error(),
 			"message": "Failed to get all test score by subject id",
@@ -54,7 +57,7 @@ func (h *uHandler) GetAllTestScoresBySubjectID(c *fiber.Ctx) error {
 		})
 	}
 
-	config.PrintLogInfo(&userToken.Username, fiber.StatusOK, "GetAllTestScoresBySubjectID")
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetAllTestScoresBySubjectID")
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"data":    data,
 		"success": true,
@@ -65,9 +68,9 @@ func (h *uHandler) GetAllTestScoresBySubjectID(c *fiber.Ctx) error {
 func (h *uHandler) GetAllTestScores(c *fiber.Ctx) error {
 	userToken := c.Locals("user").(*domain.Claims)
 
-	datas, err := h.uc.GetAllTestScores(c.Context())
+	datas, err := h.uc.GetAllTestScores(c.Context(), c.QueryInt("exam_id", 0))
 	if err != nil {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusInternalServerError, "GetAllTestScores")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "GetAllTestScores")
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
 			"success": true,
 			"message": "Get all Test Scores fail to deliver data",
@@ -75,7 +78,7 @@ func (h *uHandler) GetAllTestScores(c *fiber.Ctx) error {
 		})
 	}
 
-	config.PrintLogInfo(&userToken.Username, fiber.StatusOK, "GetAllTestScores")
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetAllTestScores")
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
 		"message": "Test Score successsfully retrieved",
@@ -91,7 +94,7 @@ func (h *uHandler) GetAllTestScores(c *fiber.Ctx) error {
 
 // 	err := c.BodyParser(&payload)
 // 	if err != nil {
-// 		config.PrintLogInfo(&userToken.Username, fiber.StatusBadRequest, "DeleteSubjectMass")
+// 		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "DeleteSubjectMass")
 
 // 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 // 			"error":   "parsing failure",
@@ -101,7 +104,7 @@ func (h *uHandler) GetAllTestScores(c *fiber.Ctx) error {
 
 // 	err = h.uc.DeleteSubjectMass(c.Context(), &payload.SubjectCodes)
 // 	if err != nil {
-// 		config.PrintLogInfo(&userToken.Username, fiber.StatusInternalServerError, "DeleteSubjectMass")
+// 		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "DeleteSubjectMass")
 
 // 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 // 			"error":   err.Error(),
@@ -110,7 +113,7 @@ func (h *uHandler) GetAllTestScores(c *fiber.Ctx) error {
 // 		})
 // 	}
 
-// 	config.PrintLogInfo(&userToken.Username, fiber.StatusOK, "DeleteSubjectMass")
+// 	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "DeleteSubjectMass")
 
 // 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 // 		"success": true,
@@ -125,7 +128,7 @@ func (h *uHandler) GetSubjectDetail(c *fiber.Ctx) error {
 
 	v, err := h.uc.GetSubjectDetail(c.Context(), subjectCode)
 	if err != nil {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusInternalServerError, "GetSubjectDetail")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "GetSubjectDetail")
 
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
@@ -134,7 +137,7 @@ func (h *uHandler) GetSubjectDetail(c *fiber.Ctx) error {
 		})
 	}
 
-	config.PrintLogInfo(&userToken.Username, fiber.StatusOK, "GetSubjectDetail")
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetSubjectDetail")
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
 		"message": "Subject detail retrieved successfully",
@@ -150,7 +153,7 @@ func (h *uHandler) DeleteStaffMass(c *fiber.Ctx) error {
 
 	err := c.BodyParser(&payload)
 	if err != nil {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusBadRequest, "DeleteStaffMass")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "DeleteStaffMass")
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to delete users",
@@ -160,7 +163,7 @@ func (h *uHandler) DeleteStaffMass(c *fiber.Ctx) error {
 
 	err = h.uc.DeleteStaffMass(c.Context(), &payload.IDS)
 	if err != nil {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusInternalServerError, "DeleteStaffMass")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "DeleteStaffMass")
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to delete users",
@@ -168,7 +171,7 @@ func (h *uHandler) DeleteStaffMass(c *fiber.Ctx) error {
 		})
 	}
 
-	config.PrintLogInfo(&userToken.Username, fiber.StatusOK, "DeleteStaffMass")
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "DeleteStaffMass")
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
@@ -180,7 +183,7 @@ func (h *uHandler) ShowProfile(c *fiber.Ctx) error {
 	userToken := c.Locals("user").(*domain.Claims)
 	v, err := h.uc.ShowProfile(c.Context(), userToken.UserID)
 	if err != nil {
-		config.PrintLogInfo(&userToken.Username, fiber.StatusInternalServerError, "ShowProfile")
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "ShowProfile")
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
 			"error":   err.Error(),
@@ -189,7 +192,7 @@ func (h *uHandler) ShowProfile(c *fiber.Ctx) error {
 		})
 	}
 
-	config.PrintLogInfo(&userToken.Username, fiber.StatusOK, "ShowProfile")
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "ShowProfile")
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
 		"message": "Profile data loaded",
@@ -204,13 +207,22 @@ func (h *uHandler) InputTestScores(c *fiber.Ctx) error {
 	// var testScores []domain.TestScore
 	var thePayload domain.InputTestScorePayload
 	if err := c.BodyParser(&thePayload); err != nil {
-		config.PrintLogInfo(&userClaims.Username, fiber.StatusBadRequest, "InputTestScores")
+		config.PrintLogInfo(c, &userClaims.Username, fiber.StatusBadRequest, "InputTestScores")
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request payload"})
 	}
 
+	if thePayload.ExamID == 0 {
+		config.PrintLogInfo(c, &userClaims.Username, fiber.StatusBadRequest, "InputTestScores")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "exam_id is required",
+			"success": false,
+			"message": "Failed to input test scores",
+		})
+	}
+
 	err := h.uc.InputTestScores(c.Context(), teacherID, &thePayload)
 	if err != nil {
-		config.PrintLogInfo(&userClaims.Username, fiber.StatusBadRequest, "InputTestScores")
+		config.PrintLogInfo(c, &userClaims.Username, fiber.StatusBadRequest, "InputTestScores")
 
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error":   err.Error(),
@@ -219,7 +231,7 @@ func (h *uHandler) InputTestScores(c *fiber.Ctx) error {
 		})
 	}
 
-	config.PrintLogInfo(&userClaims.Username, fiber.StatusOK, "InputTestScores")
+	config.PrintLogInfo(c, &userClaims.Username, fiber.StatusOK, "InputTestScores")
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
 		"message": "Test scores successfully inputted",
@@ -233,7 +245,7 @@ func (h *uHandler) GetSubjectsForTeacher(c *fiber.Ctx) error {
 
 	subjects, err := h.uc.GetSubjectsForTeacher(c.Context(), userID)
 	if err != nil {
-		config.PrintLogInfo(&userClaims.Username, fiber.StatusBadRequest, "GetSubjectsForTeacher")
+		config.PrintLogInfo(c, &userClaims.Username, fiber.StatusBadRequest, "GetSubjectsForTeacher")
 
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error":   err.Error(),
@@ -255,7 +267,7 @@ func (uh *uHandler) CreateSubject(c *fiber.Ctx) error {
 
 	err := c.BodyParser(&subject)
 	if err != nil {
-		config.PrintLogInfo(&userClaims.Username, fiber.StatusBadRequest, "CreateSubject")
+		config.PrintLogInfo(c, &userClaims.Username, fiber.StatusBadRequest, "CreateSubject")
 
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error":   err.Error(),
@@ -266,7 +278,7 @@ func (uh *uHandler) CreateSubject(c *fiber.Ctx) error {
 
 	err = uh.uc.CreateSubject(c.Context(), &subject)
 	if err != nil {
-		config.PrintLogInfo(&userClaims.Username, fiber.StatusInternalServerError, "CreateSubject")
+		config.PrintLogInfo(c, &userClaims.Username, fiber.StatusInternalServerError, "CreateSubject")
 
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   err.Error(),
@@ -275,7 +287,7 @@ func (uh *uHandler) CreateSubject(c *fiber.Ctx) error {
 		})
 	}
 
-	config.PrintLogInfo(&userClaims.Username, fiber.StatusOK, "CreateSubject")
+	config.PrintLogInfo(c, &userClaims.Username, fiber.StatusOK, "CreateSubject")
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
 		"message": "Subject successsfully added",
@@ -289,7 +301,7 @@ func (uh *uHandler) CreateSubjectBulk(c *fiber.Ctx) error {
 
 	err := c.BodyParser(&subjects)
 	if err != nil {
-		config.PrintLogInfo(&userClaims.Username, fiber.StatusBadRequest, "CreateSubjectBulk")
+		config.PrintLogInfo(c, &userClaims.Username, fiber.StatusBadRequest, "CreateSubjectBulk")
 
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error":   err.Error(),
@@ -300,7 +312,7 @@ func (uh *uHandler) CreateSubjectBulk(c *fiber.Ctx) error {
 
 	duplicateList, _ := uh.uc.CreateSubjectBulk(c.Context(), &subjects)
 	if duplicateList != nil {
-		config.PrintLogInfo(&userClaims.Username, fiber.StatusInternalServerError, "CreateSubjectBulk")
+		config.PrintLogInfo(c, &userClaims.Username, fiber.StatusInternalServerError, "CreateSubjectBulk")
 
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   duplicateList,
@@ -309,7 +321,7 @@ func (uh *uHandler) CreateSubjectBulk(c *fiber.Ctx) error {
 		})
 	}
 
-	config.PrintLogInfo(&userClaims.Username, fiber.StatusOK, "CreateSubjectBulk")
+	config.PrintLogInfo(c, &userClaims.Username, fiber.StatusOK, "CreateSubjectBulk")
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
@@ -322,7 +334,7 @@ func (uh *uHandler) GetAllSubject(c *fiber.Ctx) error {
 
 	datas, err := uh.uc.GetAllSubject(c.Context(), userClaims.UserID)
 	if err != nil {
-		config.PrintLogInfo(&userClaims.Username, fiber.StatusInternalServerError, "GetAllSubject")
+		config.PrintLogInfo(c, &userClaims.Username, fiber.StatusInternalServerError, "GetAllSubject")
 
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   err.Error(),
@@ -331,7 +343,7 @@ func (uh *uHandler) GetAllSubject(c *fiber.Ctx) error {
 		})
 	}
 
-	config.PrintLogInfo(&userClaims.Username, fiber.StatusOK, "GetAllSubject")
+	config.PrintLogInfo(c, &userClaims.Username, fiber.StatusOK, "GetAllSubject")
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
@@ -347,7 +359,7 @@ func (uh *uHandler) UpdateSubject(c *fiber.Ctx) error {
 
 	err := c.BodyParser(&subject)
 	if err != nil {
-		config.PrintLogInfo(&userClaims.Username, fiber.StatusBadRequest, "UpdateSubject")
+		config.PrintLogInfo(c, &userClaims.Username, fiber.StatusBadRequest, "UpdateSubject")
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error":   err.Error(),
 			"success": false,
@@ -356,7 +368,7 @@ func (uh *uHandler) UpdateSubject(c *fiber.Ctx) error {
 	}
 
 	if subject.SubjectCode == "" || subject.Name == "" || subject.Grade == 0 {
-		config.PrintLogInfo(&userClaims.Username, fiber.StatusBadRequest, "UpdateSubject")
+		config.PrintLogInfo(c, &userClaims.Username, fiber.StatusBadRequest, "UpdateSubject")
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error":   "Field cannot be empty",
 			"success": false,
@@ -366,7 +378,7 @@ func (uh *uHandler) UpdateSubject(c *fiber.Ctx) error {
 
 	err = uh.uc.UpdateSubject(c.Context(), subjectCode, &subject)
 	if err != nil {
-		config.PrintLogInfo(&userClaims.Username, fiber.StatusInternalServerError, "UpdateSubject")
+		config.PrintLogInfo(c, &userClaims.Username, fiber.StatusInternalServerError, "UpdateSubject")
 
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   err.Error(),
@@ -375,7 +387,7 @@ func (uh *uHandler) UpdateSubject(c *fiber.Ctx) error {
 		})
 	}
 
-	config.PrintLogInfo(&userClaims.Username, fiber.StatusOK, "UpdateSubject")
+	config.PrintLogInfo(c, &userClaims.Username, fiber.StatusOK, "UpdateSubject")
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
 		"message": "Subjects successsfully updated",
@@ -388,7 +400,7 @@ func (uh *uHandler) UpdateSubject(c *fiber.Ctx) error {
 // 	id := c.Params("id")
 // 	subjectID, err := strconv.Atoi(id)
 // 	if err != nil {
-// 		config.PrintLogInfo(&userClaims.Username, fiber.StatusBadRequest, "DeleteSubject")
+// 		config.PrintLogInfo(c, &userClaims.Username, fiber.StatusBadRequest, "DeleteSubject")
 // 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 // 			"error":   err.Error(),
 // 			"success": false,
@@ -398,7 +410,7 @@ func (uh *uHandler) UpdateSubject(c *fiber.Ctx) error {
 
 // 	err = uh.uc.DeleteSubject(c.Context(), subjectID)
 // 	if err != nil {
-// 		config.PrintLogInfo(&userClaims.Username, fiber.StatusInternalServerError, "DeleteSubject")
+// 		config.PrintLogInfo(c, &userClaims.Username, fiber.StatusInternalServerError, "DeleteSubject")
 
 // 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 // 			"error":   err.Error(),
@@ -407,7 +419,7 @@ func (uh *uHandler) UpdateSubject(c *fiber.Ctx) error {
 // 		})
 // 	}
 
-// 	config.PrintLogInfo(&userClaims.Username, fiber.StatusOK, "DeleteSubject")
+// 	config.PrintLogInfo(c, &userClaims.Username, fiber.StatusOK, "DeleteSubject")
 // 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 // 		"success": true,
 // 		"message": "Subjects successsfully deleted",
@@ -424,7 +436,7 @@ func (uh *uHandler) CreateStaff(c *fiber.Ctx) error {
 	var req domain.User
 
 	if err := c.BodyParser(&req); err != nil {
-		config.PrintLogInfo(&userClaims.Username, fiber.StatusBadRequest, "CreateStaff")
+		config.PrintLogInfo(c, &userClaims.Username, fiber.StatusBadRequest, "CreateStaff")
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error":   err.Error(),
 			"success": false,
@@ -432,7 +444,7 @@ func (uh *uHandler) CreateStaff(c *fiber.Ctx) error {
 	}
 
 	if req.Username == "" || req.Password == "" || req.Name == "" {
-		config.PrintLogInfo(&userClaims.Username, fiber.StatusBadRequest, "CreateStaff")
+		config.PrintLogInfo(c, &userClaims.Username, fiber.StatusBadRequest, "CreateStaff")
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error":   "invalid data",
 			"success": false,
@@ -441,14 +453,14 @@ func (uh *uHandler) CreateStaff(c *fiber.Ctx) error {
 
 	_, err := uh.uc.CreateStaff(c.Context(), &req)
 	if err != nil {
-		config.PrintLogInfo(&userClaims.Username, fiber.StatusInternalServerError, "CreateStaff")
+		config.PrintLogInfo(c, &userClaims.Username, fiber.StatusInternalServerError, "CreateStaff")
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   err.Error(),
 			"success": false,
 		})
 	}
 
-	config.PrintLogInfo(&userClaims.Username, fiber.StatusOK, "CreateStaff")
+	config.PrintLogInfo(c, &userClaims.Username, fiber.StatusOK, "CreateStaff")
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
 		"message": "Account created successfully",
@@ -460,14 +472,14 @@ func (uh *uHandler) GetAllStaff(c *fiber.Ctx) error {
 
 	v, err := uh.uc.GetAllStaff(c.Context())
 	if err != nil {
-		config.PrintLogInfo(&userClaims.Username, fiber.StatusInternalServerError, "GetAllStaff")
+		config.PrintLogInfo(c, &userClaims.Username, fiber.StatusInternalServerError, "GetAllStaff")
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   err.Error(),
 			"success": false,
 		})
 	}
 
-	config.PrintLogInfo(&userClaims.Username, fiber.StatusOK, "GetAllStaff")
+	config.PrintLogInfo(c, &userClaims.Username, fiber.StatusOK, "GetAllStaff")
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
 		"message": "Staff retrieved successfully",
@@ -480,7 +492,7 @@ func (uh *uHandler) DeleteStaff(c *fiber.Ctx) error {
 
 	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
-		config.PrintLogInfo(&userClaims.Username, fiber.StatusBadRequest, "DeleteStaff")
+		config.PrintLogInfo(c, &userClaims.Username, fiber.StatusBadRequest, "DeleteStaff")
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error":   "converter failure",
 			"success": false,
@@ -489,7 +501,7 @@ func (uh *uHandler) DeleteStaff(c *fiber.Ctx) error {
 
 	err = uh.uc.DeleteStaff(c.Context(), id)
 	if err != nil {
-		config.PrintLogInfo(&userClaims.Username, fiber.StatusInternalServerError, "DeleteStaff")
+		config.PrintLogInfo(c, &userClaims.Username, fiber.StatusInternalServerError, "DeleteStaff")
 
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   err.Error(),
@@ -497,7 +509,7 @@ func (uh *uHandler) DeleteStaff(c *fiber.Ctx) error {
 		})
 	}
 
-	config.PrintLogInfo(&userClaims.Username, fiber.StatusOK, "DeleteStaff")
+	config.PrintLogInfo(c, &userClaims.Username, fiber.StatusOK, "DeleteStaff")
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
@@ -511,7 +523,7 @@ func (uh *uHandler) GetStaffDetail(c *fiber.Ctx) error {
 	if userClaims.UserID == 1 && id == 1 && userClaims.Role == "admin" {
 		v, err := uh.uc.GetAdminByAdmin(c.Context())
 		if err != nil {
-			config.PrintLogInfo(&userClaims.Username, fiber.StatusInternalServerError, "GetAdminByAdmin")
+			config.PrintLogInfo(c, &userClaims.Username, fiber.StatusInternalServerError, "GetAdminByAdmin")
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error":   err.Error(),
 				"success": false,
@@ -519,7 +531,7 @@ func (uh *uHandler) GetStaffDetail(c *fiber.Ctx) error {
 			})
 		}
 
-		config.PrintLogInfo(&userClaims.Username, fiber.StatusOK, "GetAdminByAdmin")
+		config.PrintLogInfo(c, &userClaims.Username, fiber.StatusOK, "GetAdminByAdmin")
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
 			"success": true,
 			"data":    v,
@@ -527,7 +539,7 @@ func (uh *uHandler) GetStaffDetail(c *fiber.Ctx) error {
 	}
 
 	if err != nil {
-		config.PrintLogInfo(&userClaims.Username, fiber.StatusBadRequest, "GetStaffDetail")
+		config.PrintLogInfo(c, &userClaims.Username, fiber.StatusBadRequest, "GetStaffDetail")
 
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error":   "converter failure",
@@ -538,7 +550,7 @@ func (uh *uHandler) GetStaffDetail(c *fiber.Ctx) error {
 
 	v, err := uh.uc.GetStaffDetail(c.Context(), id)
 	if err != nil {
-		config.PrintLogInfo(&userClaims.Username, fiber.StatusInternalServerError, "GetStaffDetail")
+		config.PrintLogInfo(c, &userClaims.Username, fiber.StatusInternalServerError, "GetStaffDetail")
 
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   err.Error(),
@@ -547,7 +559,7 @@ func (uh *uHandler) GetStaffDetail(c *fiber.Ctx) error {
 		})
 	}
 
-	config.PrintLogInfo(&userClaims.Username, fiber.StatusOK, "GetStaffDetail")
+	config.PrintLogInfo(c, &userClaims.Username, fiber.StatusOK, "GetStaffDetail")
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
 		"message": "Staff retrieved successfully",
@@ -562,7 +574,7 @@ func (uh *uHandler) ModifyStaff(c *fiber.Ctx) error {
 	idParam := c.Params("id")
 	id, err := strconv.Atoi(idParam)
 	if err != nil {
-		config.PrintLogInfo(&userClaims.Username, fiber.StatusBadRequest, "ModifyStaff")
+		config.PrintLogInfo(c, &userClaims.Username, fiber.StatusBadRequest, "ModifyStaff")
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
 			"message": "Invalid staff ID",
@@ -575,7 +587,7 @@ func (uh *uHandler) ModifyStaff(c *fiber.Ctx) error {
 	}
 
 	if err := c.BodyParser(&payload); err != nil {
-		config.PrintLogInfo(&userClaims.Username, fiber.StatusBadRequest, "ModifyStaff")
+		config.PrintLogInfo(c, &userClaims.Username, fiber.StatusBadRequest, "ModifyStaff")
 
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
@@ -586,7 +598,7 @@ func (uh *uHandler) ModifyStaff(c *fiber.Ctx) error {
 
 	err = uh.uc.UpdateStaff(c.Context(), id, &payload.User, payload.SubjectCode)
 	if err != nil {
-		config.PrintLogInfo(&userClaims.Username, fiber.StatusInternalServerError, "ModifyStaff")
+		config.PrintLogInfo(c, &userClaims.Username, fiber.StatusInternalServerError, "ModifyStaff")
 
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
@@ -595,7 +607,7 @@ func (uh *uHandler) ModifyStaff(c *fiber.Ctx) error {
 		})
 	}
 
-	config.PrintLogInfo(&userClaims.Username, fiber.StatusOK, "ModifyStaff")
+	config.PrintLogInfo(c, &userClaims.Username, fiber.StatusOK, "ModifyStaff")
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
 		"message": "Staff modified successfully",
@@ -0,0 +1,82 @@
+package delivery
+
+import (
+	"context"
+	"notification/config"
+	"notification/domain"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+type whatsappHandler struct {
+	nuc domain.NotificationUseCase
+	buc domain.BotUseCase
+}
+
+// NewWhatsappHandlerDeploy registers the inbound WhatsApp message handler on every client of the session
+func NewWhatsappHandlerDeploy(meow *config.WhatsappSession, notifUC domain.NotificationUseCase, botUC domain.BotUseCase) {
+	handler := &whatsappHandler{
+		nuc: notifUC,
+		buc: botUC,
+	}
+
+	meow.AddEventHandler(handler.eventHandler)
+}
+
+func (wh *whatsappHandler) eventHandler(evt interface{}) {
+	switch v := evt.(type) {
+	case *events.Message:
+		wh.handleMessage(v)
+	}
+}
+
+func (wh *whatsappHandler) handleMessage(evt *events.Message) {
+	// Only direct messages from parents are relevant, skip our own, group and status messages
+	if evt.Info.IsFromMe || evt.Info.IsGroup || evt.Info.Sender.Server != types.DefaultUserServer {
+		return
+	}
+
+	body := strings.TrimSpace(evt.Message.GetConversation())
+	if body == "" {
+		body = strings.TrimSpace(evt.Message.GetExtendedTextMessage().GetText())
+	}
+	if body == "" {
+		return
+	}
+
+	sender := evt.Info.Sender.User
+	ctx := config.WithLogger(context.Background(), config.Logger(context.Background()).WithFields(logrus.Fields{
+		"message_id": evt.Info.ID,
+		"sender":     sender,
+	}))
+
+	// Keyword commands are answered by the bot and are not kept in the inbox
+	handled, err := wh.buc.HandleCommand(ctx, sender, body)
+	if handled {
+		if err != nil {
+			config.Logger(ctx).WithError(err).Error("failed to answer bot command")
+			return
+		}
+		config.Logger(ctx).Info("bot command answered")
+		return
+	}
+
+	reply := domain.ParentReply{
+		MessageID:       evt.Info.ID,
+		SenderTelephone: sender,
+		SenderName:      evt.Info.PushName,
+		Body:            body,
+		ReceivedAt:      evt.Info.Timestamp,
+	}
+
+	err = wh.nuc.SaveParentReply(ctx, &reply)
+	if err != nil {
+		config.Logger(ctx).WithError(err).Error("failed to save parent reply")
+		return
+	}
+
+	config.Logger(ctx).Info("parent reply saved")
+}
@@ -0,0 +1,155 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"notification/config"
+	"notification/domain"
+	"notification/middleware"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/skip2/go-qrcode"
+)
+
+type whatsappSessionHandler struct {
+	session domain.WhatsappSessionManager
+}
+
+// Linking a device needs the websocket to come up first, give it a moment
+const whatsappLoginWait = 20 * time.Second
+
+func NewWhatsappSessionHandlerDeploy(app *fiber.App, session domain.WhatsappSessionManager) {
+	handler := &whatsappSessionHandler{
+		session: session,
+	}
+
+	route := app.Group("/whatsapp/session")
+	route.Get("/", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageWhatsapp), handler.GetStatus)
+	route.Get("/qr", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageWhatsapp), handler.GetQRCode)
+	route.Post("/pair", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageWhatsapp), handler.PairPhone)
+	route.Post("/logout", middleware.AuthRequired(), middleware.PermissionRequired(domain.PermissionManageWhatsapp), handler.Logout)
+}
+
+func (wh *whatsappSessionHandler) GetStatus(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetWhatsappStatus")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "WhatsApp session status retrieved successfully",
+		"data":    wh.session.Status(),
+	})
+}
+
+// GetQRCode returns the login QR code as a PNG, or as raw text with ?format=text
+func (wh *whatsappSessionHandler) GetQRCode(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	ctx, cancel := context.WithTimeout(c.Context(), whatsappLoginWait)
+	defer cancel()
+
+	code, err := wh.session.QRCode(ctx)
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		if errors.Is(err, config.ErrWhatsappAlreadyLinked) {
+			status = fiber.StatusConflict
+		}
+		config.PrintLogInfo(c, &userToken.Username, status, "GetWhatsappQRCode")
+		return c.Status(status).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to get WhatsApp QR code",
+			"error":   err.Error(),
+		})
+	}
+
+	if c.Query("format") == "text" {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetWhatsappQRCode")
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"success": true,
+			"message": "WhatsApp QR code retrieved successfully",
+			"data":    code,
+		})
+	}
+
+	png, err := qrcode.Encode(*code, qrcode.Medium, 256)
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "GetWhatsappQRCode")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to render WhatsApp QR code",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "GetWhatsappQRCode")
+	c.Set(fiber.HeaderContentType, "image/png")
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(fiber.StatusOK).Send(png)
+}
+
+func (wh *whatsappSessionHandler) PairPhone(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	var payload struct {
+		Phone string `json:"phone"`
+	}
+	if err := c.BodyParser(&payload); err != nil || payload.Phone == "" {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusBadRequest, "PairWhatsappPhone")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body, phone is required",
+		})
+	}
+
+	// Accept the local 08 format the rest of the app uses
+	phone := strings.TrimSpace(payload.Phone)
+	if strings.HasPrefix(phone, "0") {
+		phone = "62" + phone[1:]
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), whatsappLoginWait)
+	defer cancel()
+
+	code, err := wh.session.PairPhone(ctx, phone)
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		if errors.Is(err, config.ErrWhatsappAlreadyLinked) {
+			status = fiber.StatusConflict
+		}
+		config.PrintLogInfo(c, &userToken.Username, status, "PairWhatsappPhone")
+		return c.Status(status).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to request WhatsApp pairing code",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "PairWhatsappPhone")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Enter this code in WhatsApp > Linked devices > Link with phone number",
+		"data":    code,
+	})
+}
+
+func (wh *whatsappSessionHandler) Logout(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*domain.Claims)
+
+	err := wh.session.Logout(c.Context())
+	if err != nil {
+		config.PrintLogInfo(c, &userToken.Username, fiber.StatusInternalServerError, "LogoutWhatsapp")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to log out WhatsApp session",
+			"error":   err.Error(),
+		})
+	}
+
+	config.PrintLogInfo(c, &userToken.Username, fiber.StatusOK, "LogoutWhatsapp")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "WhatsApp session logged out, fetch a QR or pairing code to link a number",
+	})
+}
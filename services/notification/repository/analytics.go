@@ -0,0 +1,268 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"notification/domain"
+
+	"gorm.io/gorm"
+)
+
+type analyticsRepository struct {
+	db *gorm.DB
+}
+
+func NewAnalyticsRepository(db *gorm.DB) domain.AnalyticsRepo {
+	return &analyticsRepository{
+		db: db,
+	}
+}
+
+// absenceScope builds the CTEs every statistic reads from.
+// reported holds one row per absence and reporting teacher, absences one row per student, subject and day,
+// and enrolled the students the user may see. Staff only see the grades they teach, like GetAllStudent.
+// ok is false for staff that teach nothing, they have no statistics at all.
+func (ar *analyticsRepository) absenceScope(ctx context.Context, userID int, filter *domain.AnalyticsFilter) (cte string, args []interface{}, ok bool, err error) {
+	if !filter.To.After(filter.From) {
+		return "", nil, false, fmt.Errorf("the end of the period must be after its start")
+	}
+
+	grades, all, err := taughtGrades(ctx, ar.db, userID)
+	if err != nil {
+		return "", nil, false, err
+	}
+
+	gradeFilter, enrolledFilter := "", ""
+	args = []interface{}{filter.From, filter.To}
+	if !all {
+		if len(grades) == 0 {
+			return "", nil, false, nil
+		}
+
+		gradeFilter = " AND s.grade IN ?"
+		enrolledFilter = " WHERE grade IN ?"
+		args = append(args, grades, grades)
+	}
+
+	cte = `WITH reported AS (
+		SELECT DISTINCT h.student_nsn, h.subject_code, h.user_id, CAST(h.created_at AS date) AS absent_on
+		FROM attendance_notification_histories h
+		JOIN students s ON s.student_nsn = h.student_nsn
+		WHERE h.created_at >= ? AND h.created_at < ?` + gradeFilter + `
+	), absences AS (
+		SELECT DISTINCT student_nsn, subject_code, absent_on FROM reported
+	), enrolled AS (
+		SELECT student_nsn, grade, grade_label FROM students` + enrolledFilter + `
+	) `
+
+	return cte, args, true, nil
+}
+
+func (ar *analyticsRepository) GetClassAbsenceStats(ctx context.Context, userID int, filter *domain.AnalyticsFilter) (*[]domain.ClassAbsenceStat, error) {
+	stats := []domain.ClassAbsenceStat{}
+
+	cte, args, ok, err := ar.absenceScope(ctx, userID, filter)
+	if err != nil || !ok {
+		return &stats, err
+	}
+
+	err = ar.db.WithContext(ctx).Raw(cte+`
+		SELECT e.grade, e.grade_label,
+			COUNT(*) AS students,
+			COUNT(a.student_nsn) AS absent_students,
+			CAST(COALESCE(SUM(a.absences), 0) AS bigint) AS absences
+		FROM enrolled e
+		LEFT JOIN (SELECT student_nsn, COUNT(*) AS absences FROM absences GROUP BY student_nsn) a ON a.student_nsn = e.student_nsn
+		GROUP BY e.grade, e.grade_label
+		ORDER BY e.grade, e.grade_label`, args...).
+		Scan(&stats).Error
+	if err != nil {
+		return nil, fmt.Errorf("could not get class absence statistics: %w", err)
+	}
+
+	for i := range stats {
+		stats[i].Rate = absenceRate(stats[i].Absences, stats[i].Students)
+	}
+
+	return &stats, nil
+}
+
+func (ar *analyticsRepository) GetSubjectAbsenceStats(ctx context.Context, userID int, filter *domain.AnalyticsFilter) (*[]domain.SubjectAbsenceStat, error) {
+	stats := []domain.SubjectAbsenceStat{}
+
+	cte, args, ok, err := ar.absenceScope(ctx, userID, filter)
+	if err != nil || !ok {
+		return &stats, err
+	}
+
+	// Subjects outside the enrolled grades have no students and are left out
+	err = ar.db.WithContext(ctx).Raw(cte+`
+		SELECT sub.subject_code, sub.name, sub.grade,
+			(SELECT COUNT(*) FROM enrolled e WHERE e.grade = sub.grade) AS students,
+			COUNT(DISTINCT a.student_nsn) AS absent_students,
+			COUNT(a.student_nsn) AS absences
+		FROM subjects sub
+		LEFT JOIN absences a ON a.subject_code = sub.subject_code
+		WHERE sub.grade IN (SELECT grade FROM enrolled)
+		GROUP BY sub.subject_code, sub.name, sub.grade
+		ORDER BY absences DESC, sub.subject_code`, args...).
+		Scan(&stats).Error
+	if err != nil {
+		return nil, fmt.Errorf("could not get subject absence statistics: %w", err)
+	}
+
+	for i := range stats {
+		stats[i].Rate = absenceRate(stats[i].Absences, stats[i].Students)
+	}
+
+	return &stats, nil
+}
+
+func (ar *analyticsRepository) GetTeacherAbsenceStats(ctx context.Context, userID int, filter *domain.AnalyticsFilter) (*[]domain.TeacherAbsenceStat, error) {
+	stats := []domain.TeacherAbsenceStat{}
+
+	cte, args, ok, err := ar.absenceScope(ctx, userID, filter)
+	if err != nil || !ok {
+		return &stats, err
+	}
+
+	err = ar.db.WithContext(ctx).Raw(cte+`
+		SELECT u.user_id, u.name,
+			(SELECT COUNT(DISTINCT e.student_nsn)
+				FROM enrolled e
+				JOIN subjects sub ON sub.grade = e.grade
+				JOIN user_subjects us ON us.subject_subject_code = sub.subject_code
+				WHERE us.user_user_id = u.user_id) AS students,
+			COUNT(DISTINCT r.student_nsn) AS absent_students,
+			COUNT(r.student_nsn) AS absences
+		FROM users u
+		JOIN reported r ON r.user_id = u.user_id
+		GROUP BY u.user_id, u.name
+		ORDER BY absences DESC, u.user_id`, args...).
+		Scan(&stats).Error
+	if err != nil {
+		return nil, fmt.Errorf("could not get teacher absence statistics: %w", err)
+	}
+
+	for i := range stats {
+		stats[i].Rate = absenceRate(stats[i].Absences, stats[i].Students)
+	}
+
+	return &stats, nil
+}
+
+func (ar *analyticsRepository) GetMonthlyAbsenceStats(ctx context.Context, userID int, filter *domain.AnalyticsFilter) (*[]domain.MonthlyAbsenceStat, error) {
+	stats := []domain.MonthlyAbsenceStat{}
+
+	cte, args, ok, err := ar.absenceScope(ctx, userID, filter)
+	if err != nil || !ok {
+		return &stats, err
+	}
+
+	err = ar.db.WithContext(ctx).Raw(cte+`
+		SELECT TO_CHAR(absent_on, 'YYYY-MM') AS month,
+			(SELECT COUNT(*) FROM enrolled) AS students,
+			COUNT(DISTINCT student_nsn) AS absent_students,
+			COUNT(*) AS absences
+		FROM absences
+		GROUP BY month
+		ORDER BY month`, args...).
+		Scan(&stats).Error
+	if err != nil {
+		return nil, fmt.Errorf("could not get monthly absence statistics: %w", err)
+	}
+
+	for i := range stats {
+		stats[i].Rate = absenceRate(stats[i].Absences, stats[i].Students)
+	}
+
+	return &stats, nil
+}
+
+func (ar *analyticsRepository) GetTopAbsentStudents(ctx context.Context, userID int, filter *domain.AnalyticsFilter) (*[]domain.StudentAbsenceStat, error) {
+	stats := []domain.StudentAbsenceStat{}
+
+	cte, args, ok, err := ar.absenceScope(ctx, userID, filter)
+	if err != nil || !ok {
+		return &stats, err
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = domain.AnalyticsDefaultTopLimit
+	}
+	if limit > domain.AnalyticsMaxTopLimit {
+		limit = domain.AnalyticsMaxTopLimit
+	}
+
+	err = ar.db.WithContext(ctx).Raw(cte+`
+		SELECT s.student_nsn, s.name, s.grade, s.grade_label,
+			COUNT(*) AS absences,
+			COUNT(DISTINCT a.subject_code) AS subjects,
+			MAX(a.absent_on) AS last_absent_on
+		FROM absences a
+		JOIN students s ON s.student_nsn = a.student_nsn
+		GROUP BY s.student_nsn, s.name, s.grade, s.grade_label
+		ORDER BY absences DESC, last_absent_on DESC, s.student_nsn
+		LIMIT ?`, append(args, limit)...).
+		Scan(&stats).Error
+	if err != nil {
+		return nil, fmt.Errorf("could not get top absent students: %w", err)
+	}
+
+	return &stats, nil
+}
+
+// GetAbsenceTrend compares the period with the one of the same length right before it
+func (ar *analyticsRepository) GetAbsenceTrend(ctx context.Context, userID int, filter *domain.AnalyticsFilter) (*domain.AbsenceTrend, error) {
+	previous := domain.AnalyticsFilter{
+		From: filter.From.Add(-filter.To.Sub(filter.From)),
+		To:   filter.From,
+	}
+
+	var trend domain.AbsenceTrend
+	for _, period := range []struct {
+		filter *domain.AnalyticsFilter
+		stat   *domain.AbsencePeriodStat
+	}{
+		{filter, &trend.Current},
+		{&previous, &trend.Previous},
+	} {
+		cte, args, ok, err := ar.absenceScope(ctx, userID, period.filter)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		err = ar.db.WithContext(ctx).Raw(cte+`
+			SELECT (SELECT COUNT(*) FROM enrolled) AS students,
+				COUNT(DISTINCT student_nsn) AS absent_students,
+				COUNT(*) AS absences
+			FROM absences`, args...).
+			Scan(period.stat).Error
+		if err != nil {
+			return nil, fmt.Errorf("could not get absence trend: %w", err)
+		}
+		period.stat.Rate = absenceRate(period.stat.Absences, period.stat.Students)
+	}
+	trend.Current.From, trend.Current.To = filter.From, filter.To
+	trend.Previous.From, trend.Previous.To = previous.From, previous.To
+
+	if trend.Previous.Absences > 0 {
+		change := math.Round(float64(trend.Current.Absences-trend.Previous.Absences)/float64(trend.Previous.Absences)*10000) / 100
+		trend.Change = &change
+	}
+
+	return &trend, nil
+}
+
+// absenceRate is the number of absences per student rounded to two decimals
+func absenceRate(absences, students int64) float64 {
+	if students == 0 {
+		return 0
+	}
+	return math.Round(float64(absences)/float64(students)*100) / 100
+}
@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"notification/domain"
+	"reflect"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) domain.AuditRepo {
+	return &auditRepository{
+		db: db,
+	}
+}
+
+func (ar *auditRepository) GetAuditLogs(ctx context.Context, filter *domain.AuditLogFilter) (*domain.AuditLogPage, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = domain.AuditLogDefaultLimit
+	}
+	if limit > domain.AuditLogMaxLimit {
+		limit = domain.AuditLogMaxLimit
+	}
+
+	query := ar.db.WithContext(ctx).Model(&domain.AuditLog{})
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.Entity != "" {
+		query = query.Where("entity = ?", filter.Entity)
+	}
+	if filter.EntityID != "" {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.Cursor != "" {
+		before, err := decodeAuditCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("audit_log_id < ?", before)
+	}
+
+	var logs []domain.AuditLog
+	if err := query.Order("audit_log_id DESC").Limit(limit + 1).Find(&logs).Error; err != nil {
+		return nil, fmt.Errorf("could not get audit log: %w", err)
+	}
+
+	page := domain.AuditLogPage{Items: logs}
+	if len(logs) > limit {
+		page.Items = logs[:limit]
+		page.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(page.Items[limit-1].AuditLogID)))
+	}
+	if page.Items == nil {
+		page.Items = []domain.AuditLog{}
+	}
+
+	return &page, nil
+}
+
+func decodeAuditCursor(encoded string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	id, err := strconv.Atoi(string(raw))
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	return id, nil
+}
+
+// auditIgnoredFields change on every write or say nothing about who changed what
+var auditIgnoredFields = map[string]bool{"created_at": true, "updated_at": true}
+
+// auditRedactedFields are recorded as changed without their value
+var auditRedactedFields = map[string]bool{"password": true}
+
+// recordAudit appends one entry to the audit log using tx, so it is kept or rolled back with the change itself.
+// The actor is the user behind the request in ctx. An update that changed nothing is not recorded.
+func recordAudit(ctx context.Context, tx *gorm.DB, action, entity, entityID string, before, after interface{}) error {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return err
+	}
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return err
+	}
+
+	if beforeFields != nil && afterFields != nil {
+		changedBefore, changedAfter := map[string]interface{}{}, map[string]interface{}{}
+		for key, value := range beforeFields {
+			if !reflect.DeepEqual(value, afterFields[key]) {
+				changedBefore[key] = value
+				changedAfter[key] = afterFields[key]
+			}
+		}
+		for key, value := range afterFields {
+			if _, ok := beforeFields[key]; !ok {
+				changedBefore[key] = nil
+				changedAfter[key] = value
+			}
+		}
+		if len(changedAfter) == 0 {
+			return nil
+		}
+		beforeFields, afterFields = changedBefore, changedAfter
+	}
+
+	entry := domain.AuditLog{
+		Action:   action,
+		Entity:   entity,
+		EntityID: entityID,
+	}
+	if claims, ok := ctx.Value("user").(*domain.Claims); ok && claims != nil {
+		entry.ActorID = &claims.UserID
+		entry.ActorUsername = claims.Username
+	}
+	if entry.Before, err = marshalAuditFields(beforeFields); err != nil {
+		return err
+	}
+	if entry.After, err = marshalAuditFields(afterFields); err != nil {
+		return err
+	}
+
+	if err := tx.Create(&entry).Error; err != nil {
+		return fmt.Errorf("could not write audit log: %w", err)
+	}
+
+	return nil
+}
+
+// auditFields flattens a record into its JSON fields, nested records are left to their own entries
+func auditFields(value interface{}) (map[string]interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("could not read audit record: %w", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, fmt.Errorf("could not read audit record: %w", err)
+	}
+
+	for key, field := range fields {
+		if auditIgnoredFields[key] || isNestedRecord(field) {
+			delete(fields, key)
+		}
+	}
+
+	return fields, nil
+}
+
+func isNestedRecord(field interface{}) bool {
+	switch value := field.(type) {
+	case map[string]interface{}:
+		return true
+	case []interface{}:
+		for _, item := range value {
+			if _, ok := item.(map[string]interface{}); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func marshalAuditFields(fields map[string]interface{}) (json.RawMessage, error) {
+	if fields == nil {
+		return nil, nil
+	}
+	for key := range fields {
+		if auditRedactedFields[key] && fields[key] != nil {
+			fields[key] = "[redacted]"
+		}
+	}
+	return json.Marshal(fields)
+}
@@ -2,46 +2,299 @@ package repository
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"notification/domain"
 	"notification/middleware"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type authRepository struct {
-	db *gorm.DB
+	db          *gorm.DB
+	mailer      domain.Mailer
+	emailSender string
+	meowClient  domain.WhatsappClientProvider
 }
 
-func NewAuthRepository(db *gorm.DB) domain.AuthRepo {
+func NewAuthRepository(db *gorm.DB, mailer domain.Mailer, emailSender string, meow domain.WhatsappClientProvider) domain.AuthRepo {
 	return &authRepository{
-		db: db,
+		db:          db,
+		mailer:      mailer,
+		emailSender: emailSender,
+		meowClient:  meow,
 	}
 }
 
-func (ar *authRepository) Login(ctx context.Context, data *domain.LoginRequest) (*[]string, error) {
+var errInvalidRefreshToken = fmt.Errorf("%w: invalid or expired refresh token", domain.ErrUnauthorized)
+
+// Login refuses usernames and addresses with too many recent failures before looking at the password,
+// see reserveLoginAttempt for how failures add up
+func (ar *authRepository) Login(ctx context.Context, data *domain.LoginRequest) (*domain.LoginResponse, error) {
 	var user domain.User
-	var dataList []string
 
-	err := ar.db.Where("username = ?", data.Username).First(&user).Error
+	throttleKeys := loginThrottleKeys(data.Username, data.IP)
+	if err := ar.reserveLoginAttempt(ctx, throttleKeys); err != nil {
+		return nil, err
+	}
+
+	err := ar.db.WithContext(ctx).Where("username = ? AND deleted_at IS NULL", data.Username).First(&user).Error
 	if err != nil {
-		return nil, fmt.Errorf("invalid username or password")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("invalid username or password")
+		}
+		return nil, fmt.Errorf("could not read user: %w", err)
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(data.Password))
 	if err != nil {
 		return nil, fmt.Errorf("invalid username or password")
 	}
+	ar.releaseLoginAttempt(ctx, throttleKeys)
+
+	var response *domain.LoginResponse
+	err = ar.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		response, err = openSession(tx, &user, data.UserAgent, data.IP)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// openSession starts a session for user and returns its first token pair
+func openSession(tx *gorm.DB, user *domain.User, userAgent, ip string) (*domain.LoginResponse, error) {
+	sessionID, err := randomToken(24)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session, err : %v", err)
+	}
+
+	now := time.Now()
+	session := domain.UserSession{
+		SessionID:  sessionID,
+		UserID:     user.UserID,
+		UserAgent:  truncate(userAgent, 255),
+		IP:         truncate(ip, 64),
+		ExpiresAt:  now.Add(domain.RefreshTokenTTL),
+		LastUsedAt: now,
+	}
+	if err := tx.Create(&session).Error; err != nil {
+		return nil, fmt.Errorf("failed to open session, err : %v", err)
+	}
+
+	refreshToken, err := issueRefreshToken(tx, sessionID, session.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return loginResponse(user, sessionID, refreshToken)
+}
+
+// Refresh trades a refresh token for a new access token and its successor, the presented token is spent
+func (ar *authRepository) Refresh(ctx context.Context, refreshToken string) (*domain.LoginResponse, error) {
+	var response *domain.LoginResponse
+	reused := false
+
+	err := ar.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var stored domain.RefreshToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Session.User").
+			Where("token_hash = ?", hashToken(refreshToken)).
+			First(&stored).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errInvalidRefreshToken
+			}
+			return fmt.Errorf("could not read refresh token: %w", err)
+		}
+
+		now := time.Now()
+		session := stored.Session
+		reused, err = checkRefreshToken(stored, now)
+		if err != nil {
+			return err
+		}
+		if reused {
+			return tx.Model(&domain.UserSession{}).
+				Where("session_id = ?", session.SessionID).
+				Update("revoked_at", now).Error
+		}
+
+		if err := tx.Model(&stored).Update("used_at", now).Error; err != nil {
+			return fmt.Errorf("could not spend refresh token: %w", err)
+		}
+
+		expiresAt := now.Add(domain.RefreshTokenTTL)
+		err = tx.Model(&domain.UserSession{}).
+			Where("session_id = ?", session.SessionID).
+			Updates(map[string]interface{}{"expires_at": expiresAt, "last_used_at": now}).Error
+		if err != nil {
+			return fmt.Errorf("could not extend session: %w", err)
+		}
+
+		next, err := issueRefreshToken(tx, session.SessionID, expiresAt)
+		if err != nil {
+			return err
+		}
+
+		response, err = loginResponse(&session.User, session.SessionID, next)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, fmt.Errorf("%w: refresh token was already used, the session has been revoked", domain.ErrUnauthorized)
+	}
+
+	return response, nil
+}
+
+// checkRefreshToken tells whether stored may be traded at now. A spent token of a live session is reported as reused,
+// the caller revokes the session since the token must have leaked.
+func checkRefreshToken(stored domain.RefreshToken, now time.Time) (bool, error) {
+	session := stored.Session
+	if session.RevokedAt != nil || now.After(session.ExpiresAt) || session.User.DeletedAt != nil {
+		return false, errInvalidRefreshToken
+	}
+	if stored.UsedAt != nil {
+		return true, nil
+	}
+	if now.After(stored.ExpiresAt) {
+		return false, errInvalidRefreshToken
+	}
+	return false, nil
+}
+
+// PurgeExpiredSessions deletes sessions past their expiry, their refresh tokens go with them,
+// and the expired tokens of sessions that are still alive
+func (ar *authRepository) PurgeExpiredSessions(ctx context.Context) (int64, error) {
+	var purged int64
+	err := ar.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		// A revoked session keeps the expiry it had, so it is purged at the latest one refresh TTL after revocation
+		sessions := tx.Where("expires_at < ?", now).Delete(&domain.UserSession{})
+		if sessions.Error != nil {
+			return fmt.Errorf("could not purge sessions: %w", sessions.Error)
+		}
+
+		tokens := tx.Where("expires_at < ?", now).Delete(&domain.RefreshToken{})
+		if tokens.Error != nil {
+			return fmt.Errorf("could not purge refresh tokens: %w", tokens.Error)
+		}
+
+		purged = sessions.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return purged, nil
+}
+
+func (ar *authRepository) Logout(ctx context.Context, userID int, sessionID string) error {
+	err := ar.db.WithContext(ctx).Model(&domain.UserSession{}).
+		Where("session_id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("could not revoke session: %w", err)
+	}
+
+	return nil
+}
+
+func (ar *authRepository) LogoutAll(ctx context.Context, userID int) error {
+	return revokeUserSessions(ar.db.WithContext(ctx), userID)
+}
+
+func (ar *authRepository) IsSessionActive(ctx context.Context, userID int, sessionID string) (bool, error) {
+	var count int64
+	err := ar.db.WithContext(ctx).Model(&domain.UserSession{}).
+		Joins("JOIN users ON users.user_id = user_sessions.user_id").
+		Where("user_sessions.session_id = ? AND user_sessions.user_id = ?", sessionID, userID).
+		Where("user_sessions.revoked_at IS NULL AND user_sessions.expires_at > ? AND users.deleted_at IS NULL", time.Now()).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("could not check session: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+// revokeUserSessions logs a user out everywhere
+func revokeUserSessions(tx *gorm.DB, userID int) error {
+	err := tx.Model(&domain.UserSession{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("could not revoke sessions: %w", err)
+	}
+
+	return nil
+}
 
-	dataList = append(dataList, user.Role, user.Username)
+func issueRefreshToken(tx *gorm.DB, sessionID string, expiresAt time.Time) (string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate refresh token, err : %v", err)
+	}
 
-	token, err := middleware.GenerateJWT(user.UserID, user.Username, user.Role)
+	err = tx.Create(&domain.RefreshToken{
+		TokenHash: hashToken(token),
+		SessionID: sessionID,
+		ExpiresAt: expiresAt,
+	}).Error
+	if err != nil {
+		return "", fmt.Errorf("failed to store refresh token, err : %v", err)
+	}
+
+	return token, nil
+}
+
+func loginResponse(user *domain.User, sessionID, refreshToken string) (*domain.LoginResponse, error) {
+	token, err := middleware.GenerateJWT(user.UserID, user.Username, user.Role, sessionID, user.MustChangePassword)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate token, err : %v", err)
 	}
 
-	dataList = append(dataList, token)
+	return &domain.LoginResponse{
+		Token:              token,
+		RefreshToken:       refreshToken,
+		ExpiresIn:          int(domain.AccessTokenTTL.Seconds()),
+		Role:               user.Role,
+		Username:           user.Username,
+		MustChangePassword: user.MustChangePassword,
+	}, nil
+}
 
-	return &dataList, nil
+func randomToken(size int) (string, error) {
+	random := make([]byte, size)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(random), nil
+}
+
+// hashToken is how refresh tokens and reset codes are stored, a leaked table cannot be replayed
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func truncate(value string, max int) string {
+	if len(value) > max {
+		return value[:max]
+	}
+	return value
 }
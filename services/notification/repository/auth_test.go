@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"errors"
+	"notification/domain"
+	"testing"
+	"time"
+)
+
+func TestCheckRefreshToken(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	earlier := now.Add(-time.Minute)
+	later := now.Add(time.Hour)
+
+	token := func(edit func(*domain.RefreshToken)) domain.RefreshToken {
+		stored := domain.RefreshToken{
+			SessionID: "session",
+			ExpiresAt: later,
+			Session:   domain.UserSession{SessionID: "session", ExpiresAt: later},
+		}
+		if edit != nil {
+			edit(&stored)
+		}
+		return stored
+	}
+
+	tests := []struct {
+		name       string
+		stored     domain.RefreshToken
+		wantReused bool
+		wantErr    bool
+	}{
+		{"unused token of a live session", token(nil), false, false},
+		{"spent token is reuse", token(func(r *domain.RefreshToken) { r.UsedAt = &earlier }), true, false},
+		{"spent and expired token is still reuse", token(func(r *domain.RefreshToken) {
+			r.UsedAt = &earlier
+			r.ExpiresAt = earlier
+		}), true, false},
+		{"expired token", token(func(r *domain.RefreshToken) { r.ExpiresAt = earlier }), false, true},
+		{"revoked session", token(func(r *domain.RefreshToken) { r.Session.RevokedAt = &earlier }), false, true},
+		{"spent token of a revoked session is not reuse", token(func(r *domain.RefreshToken) {
+			r.UsedAt = &earlier
+			r.Session.RevokedAt = &earlier
+		}), false, true},
+		{"expired session", token(func(r *domain.RefreshToken) { r.Session.ExpiresAt = earlier }), false, true},
+		{"deleted user", token(func(r *domain.RefreshToken) { r.Session.User.DeletedAt = &earlier }), false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reused, err := checkRefreshToken(tt.stored, now)
+			if reused != tt.wantReused {
+				t.Errorf("checkRefreshToken() reused = %v, want %v", reused, tt.wantReused)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkRefreshToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, domain.ErrUnauthorized) {
+				t.Errorf("checkRefreshToken() error = %v, want it to wrap domain.ErrUnauthorized", err)
+			}
+		})
+	}
+}
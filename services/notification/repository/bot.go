@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"notification/domain"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// How far back the ABSEN command looks for absence notices
+const botAbsenceLookback = 30 * 24 * time.Hour
+
+type botRepository struct {
+	db          *gorm.DB
+	schoolPhone string
+	meowClient  domain.WhatsappClientProvider
+}
+
+func NewBotRepository(db *gorm.DB, schoolPhone string, meow domain.WhatsappClientProvider) domain.BotRepo {
+	return &botRepository{
+		db:          db,
+		schoolPhone: schoolPhone,
+		meowClient:  meow,
+	}
+}
+
+func (br *botRepository) isIndonesian() bool {
+	return strings.ToLower(os.Getenv("MESSENGER_LANGUAGE")) == "ind"
+}
+
+func (br *botRepository) ComposeReply(ctx context.Context, command string, parentAndStudents *domain.StudentsAssociateWithParent) (*string, error) {
+	var body string
+	var err error
+
+	switch command {
+	case domain.BotCommandScores:
+		body, err = br.composeScores(ctx, parentAndStudents)
+	case domain.BotCommandAbsences:
+		body, err = br.composeAbsences(ctx, parentAndStudents)
+	case domain.BotCommandStop:
+		body = br.composeStop()
+	case domain.BotCommandStart:
+		body = br.composeStart()
+	default:
+		body = br.composeHelp()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &body, nil
+}
+
+func (br *botRepository) composeHelp() string {
+	if br.isIndonesian() {
+		return fmt.Sprintf(`Layanan SINOAN 🔔
+
+Silakan kirim salah satu kata kunci berikut:
+- NILAI : nilai ujian terbaru anak anda
+- ABSEN : ketidakhadiran anak anda dalam 30 hari terakhir
+- BERHENTI : berhenti menerima pemberitahuan melalui WhatsApp
+- MULAI : kembali menerima pemberitahuan melalui WhatsApp
+- BANTUAN : menampilkan pesan ini
+
+Untuk pertanyaan lain, silakan hubungi sekolah di %s.`, br.schoolPhone)
+	}
+
+	return fmt.Sprintf(`SINOAN Service 🔔
+
+Please send one of the following keywords:
+- SCORES : your child's latest test scores
+- ABSENCES : your child's absences in the last 30 days
+- STOP : stop receiving notifications on WhatsApp
+- START : receive notifications on WhatsApp again
+- HELP : show this message
+
+For other questions, please contact the school at %s.`, br.schoolPhone)
+}
+
+func (br *botRepository) composeStop() string {
+	if br.isIndonesian() {
+		return fmt.Sprintf("Layanan SINOAN 🔔\n\nAnda tidak akan lagi menerima pemberitahuan sekolah melalui WhatsApp. Kirim MULAI untuk mengaktifkannya kembali, atau hubungi sekolah di %s.", br.schoolPhone)
+	}
+	return fmt.Sprintf("SINOAN Service 🔔\n\nYou will no longer receive school notifications on WhatsApp. Send START to turn them back on, or contact the school at %s.", br.schoolPhone)
+}
+
+func (br *botRepository) composeStart() string {
+	if br.isIndonesian() {
+		return "Layanan SINOAN 🔔\n\nAnda akan kembali menerima pemberitahuan sekolah melalui WhatsApp."
+	}
+	return "SINOAN Service 🔔\n\nYou will receive school notifications on WhatsApp again."
+}
+
+func (br *botRepository) composeScores(ctx context.Context, parentAndStudents *domain.StudentsAssociateWithParent) (string, error) {
+	var body string
+	if br.isIndonesian() {
+		body = "Layanan SINOAN 🔔\n\nNilai ujian terbaru:\n"
+	} else {
+		body = "SINOAN Service 🔔\n\nLatest test scores:\n"
+	}
+
+	for _, student := range parentAndStudents.AssociatedStudent {
+		// Only the most recent exam the student has scores in
+		var testScores []domain.TestScore
+		err := br.db.WithContext(ctx).
+			Preload("Subject").
+			Where("student_nsn = ? AND deleted_at IS NULL", student.StudentNSN).
+			Where("exam_id = (?)", br.db.Model(&domain.TestScore{}).
+				Select("MAX(exam_id)").
+				Where("student_nsn = ? AND deleted_at IS NULL", student.StudentNSN)).
+			Order("subject_code ASC").
+			Find(&testScores).Error
+		if err != nil {
+			return "", fmt.Errorf("failed to fetch test scores for %s: %w", student.StudentNSN, err)
+		}
+
+		body += fmt.Sprintf("\n%s (%d %s)\n", student.Name, student.Grade, student.GradeLabel)
+		if len(testScores) == 0 {
+			if br.isIndonesian() {
+				body += "- Belum ada nilai\n"
+			} else {
+				body += "- No scores yet\n"
+			}
+			continue
+		}
+
+		for _, score := range testScores {
+			value := "-"
+			if score.Score != nil {
+				value = fmt.Sprintf("%.1f", *score.Score)
+			}
+			body += fmt.Sprintf("- %s: %s\n", score.Subject.Name, value)
+		}
+	}
+
+	return body, nil
+}
+
+func (br *botRepository) composeAbsences(ctx context.Context, parentAndStudents *domain.StudentsAssociateWithParent) (string, error) {
+	var body string
+	if br.isIndonesian() {
+		body = "Layanan SINOAN 🔔\n\nKetidakhadiran dalam 30 hari terakhir:\n"
+	} else {
+		body = "SINOAN Service 🔔\n\nAbsences in the last 30 days:\n"
+	}
+
+	since := time.Now().Add(-botAbsenceLookback)
+	for _, student := range parentAndStudents.AssociatedStudent {
+		var histories []domain.AttendanceNotificationHistory
+		err := br.db.WithContext(ctx).
+			Preload("Subject").
+			Where("student_nsn = ? AND created_at >= ?", student.StudentNSN, since).
+			Order("created_at DESC").
+			Find(&histories).Error
+		if err != nil {
+			return "", fmt.Errorf("failed to fetch absences for %s: %w", student.StudentNSN, err)
+		}
+
+		body += fmt.Sprintf("\n%s (%d %s)\n", student.Name, student.Grade, student.GradeLabel)
+		if len(histories) == 0 {
+			if br.isIndonesian() {
+				body += "- Tidak ada ketidakhadiran\n"
+			} else {
+				body += "- No absences\n"
+			}
+			continue
+		}
+
+		for _, history := range histories {
+			body += fmt.Sprintf("- %s: %s\n", history.CreatedAt.Format("02/01/2006 15:04"), history.Subject.Name)
+		}
+	}
+
+	return body, nil
+}
+
+func (br *botRepository) ComposeUnregisteredReply() string {
+	if br.isIndonesian() {
+		return fmt.Sprintf("Layanan SINOAN 🔔\n\nNomor ini belum terdaftar sebagai nomor orang tua/wali. Silakan hubungi sekolah di %s.", br.schoolPhone)
+	}
+	return fmt.Sprintf("SINOAN Service 🔔\n\nThis number is not registered as a parent/guardian number. Please contact the school at %s.", br.schoolPhone)
+}
+
+func (br *botRepository) ComposeRateLimitedReply() string {
+	if br.isIndonesian() {
+		return "Layanan SINOAN 🔔\n\nAnda telah mengirim terlalu banyak permintaan. Silakan coba lagi dalam beberapa menit."
+	}
+	return "SINOAN Service 🔔\n\nYou have sent too many requests. Please try again in a few minutes."
+}
+
+// ComposeTemporaryFailureReply is sent when the parent could not be looked up, the command is worth retrying
+func (br *botRepository) ComposeTemporaryFailureReply() string {
+	if br.isIndonesian() {
+		return "Layanan SINOAN 🔔\n\nMaaf, layanan sedang mengalami gangguan. Silakan coba lagi dalam beberapa saat."
+	}
+	return "SINOAN Service 🔔\n\nSorry, the service is temporarily unavailable. Please try again in a moment."
+}
+
+// SendReply answers a parent, the reply waits in the outbox when the session dropped in the meantime
+func (br *botRepository) SendReply(ctx context.Context, telephone string, body string) error {
+	err := sendOrQueueWhatsapp(ctx, br.db, br.meowClient.Client(), internationalTelephone(normalizeTelephone(telephone)), body)
+	if err != nil && !errors.Is(err, errWhatsappQueued) {
+		return fmt.Errorf("failed to send bot reply: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,416 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"notification/config"
+	"notification/domain"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type bounceRepository struct {
+	db          *gorm.DB
+	mailboxPath string
+}
+
+// dsnRecipient is one per-recipient block of a delivery status notification
+type dsnRecipient struct {
+	recipient  string
+	action     string
+	status     string
+	diagnostic string
+}
+
+var (
+	messageIDPattern  = regexp.MustCompile(`(?im)^message-id:\s*(<[^>\s]+>)`)
+	statusCodePattern = regexp.MustCompile(`\b([245]\.\d{1,3}\.\d{1,3})\b`)
+	mboxFromPattern   = regexp.MustCompile(`(?m)^From .*\r?\n`)
+)
+
+// NewBounceRepository reads bounces from mailboxPath, a Maildir directory or an mbox file written by the MTA
+func NewBounceRepository(db *gorm.DB, mailboxPath string) domain.BounceRepo {
+	return &bounceRepository{
+		db:          db,
+		mailboxPath: mailboxPath,
+	}
+}
+
+func (br *bounceRepository) ProcessMailbox(ctx context.Context) (*domain.BounceProcessResult, error) {
+	result := &domain.BounceProcessResult{}
+	if br.mailboxPath == "" {
+		return result, errors.New("bounce mailbox is not configured, set BOUNCE_MAILBOX_PATH")
+	}
+
+	info, err := os.Stat(br.mailboxPath)
+	if err != nil {
+		return result, fmt.Errorf("failed to open bounce mailbox: %w", err)
+	}
+
+	if info.IsDir() {
+		err = br.processMaildir(ctx, result)
+	} else {
+		err = br.processMbox(ctx, result)
+	}
+
+	return result, err
+}
+
+// processMaildir handles every message in new/ and moves it to cur/ flagged as seen
+func (br *bounceRepository) processMaildir(ctx context.Context, result *domain.BounceProcessResult) error {
+	newDir := filepath.Join(br.mailboxPath, "new")
+	curDir := filepath.Join(br.mailboxPath, "cur")
+
+	entries, err := os.ReadDir(newDir)
+	if err != nil {
+		return fmt.Errorf("failed to read maildir: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		path := filepath.Join(newDir, entry.Name())
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", entry.Name(), err)
+		}
+
+		if err := br.processMessage(ctx, raw, "maildir:"+entry.Name(), result); err != nil {
+			return err
+		}
+
+		if err := os.Rename(path, filepath.Join(curDir, entry.Name()+":2,S")); err != nil {
+			return fmt.Errorf("failed to move %s to cur: %w", entry.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+// processMbox reads the whole file each pass; already recorded messages are skipped by their content hash
+func (br *bounceRepository) processMbox(ctx context.Context, result *domain.BounceProcessResult) error {
+	raw, err := os.ReadFile(br.mailboxPath)
+	if err != nil {
+		return fmt.Errorf("failed to read mbox: %w", err)
+	}
+
+	starts := mboxFromPattern.FindAllIndex(raw, -1)
+	for i, start := range starts {
+		end := len(raw)
+		if i+1 < len(starts) {
+			end = starts[i+1][0]
+		}
+
+		message := raw[start[1]:end]
+		sum := sha1.Sum(message)
+		if err := br.processMessage(ctx, message, "mbox:"+hex.EncodeToString(sum[:]), result); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (br *bounceRepository) processMessage(ctx context.Context, raw []byte, source string, result *domain.BounceProcessResult) error {
+	var seen int64
+	err := br.db.WithContext(ctx).Model(&domain.EmailBounce{}).Where("source_key = ?", source).Count(&seen).Error
+	if err != nil {
+		return fmt.Errorf("failed to check processed bounces: %w", err)
+	}
+	if seen > 0 {
+		return nil
+	}
+
+	result.MessagesRead++
+
+	messageID, recipients, ok := parseBounce(raw)
+	if !ok {
+		return nil
+	}
+
+	for i, rcpt := range recipients {
+		bounce := domain.EmailBounce{
+			MessageID:   messageID,
+			Recipient:   strings.ToLower(rcpt.recipient),
+			Action:      rcpt.action,
+			Status:      rcpt.status,
+			Diagnostic:  rcpt.diagnostic,
+			IsHard:      rcpt.action == "failed" && strings.HasPrefix(rcpt.status, "5"),
+			Source:      fmt.Sprintf("%s#%d", source, i),
+			SourceKey:   source,
+			SourceIndex: i,
+		}
+
+		if rcpt.action == "delivered" || rcpt.action == "relayed" || rcpt.action == "expanded" {
+			continue
+		}
+
+		sent, err := br.matchSentEmail(ctx, bounce.MessageID, bounce.Recipient)
+		if err != nil {
+			return err
+		}
+
+		if sent != nil {
+			bounce.SentEmailID = &sent.SentEmailID
+			bounce.ParentID = &sent.ParentID
+			if bounce.Recipient == "" {
+				bounce.Recipient = sent.Recipient
+			}
+		} else {
+			result.Unmatched++
+		}
+
+		marked := false
+		err = br.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&bounce).Error; err != nil {
+				return fmt.Errorf("failed to save bounce: %w", err)
+			}
+
+			if sent != nil {
+				now := time.Now()
+				if err := tx.Model(&domain.SentEmail{}).Where("sent_email_id = ?", sent.SentEmailID).Update("bounced_at", &now).Error; err != nil {
+					return fmt.Errorf("failed to update sent email: %w", err)
+				}
+			}
+
+			if !bounce.IsHard || bounce.Recipient == "" {
+				return nil
+			}
+
+			// Only the address that bounced is invalidated, a parent who changed email since then is left alone
+			reason := fmt.Sprintf("%s %s", bounce.Status, bounce.Diagnostic)
+			now := time.Now()
+			res := tx.Model(&domain.Parent{}).
+				Where("LOWER(email) = ? AND deleted_at IS NULL AND email_invalid IS FALSE", bounce.Recipient).
+				Updates(map[string]interface{}{
+					"email_invalid":       true,
+					"email_invalid_at":    &now,
+					"email_bounce_reason": strings.TrimSpace(reason),
+				})
+			if res.Error != nil {
+				return fmt.Errorf("failed to mark parent email invalid: %w", res.Error)
+			}
+			marked = res.RowsAffected > 0
+			result.ParentsMarked += int(res.RowsAffected)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		result.BouncesFound++
+		if bounce.IsHard {
+			result.HardBounces++
+		}
+		if marked {
+			config.Logger(ctx).WithField("recipient", bounce.Recipient).Info("parent email marked invalid after hard bounce")
+		}
+	}
+
+	return nil
+}
+
+// matchSentEmail finds the notification a bounce refers to, by Message-ID first and by recipient otherwise
+func (br *bounceRepository) matchSentEmail(ctx context.Context, messageID, recipient string) (*domain.SentEmail, error) {
+	var sent domain.SentEmail
+
+	if messageID != "" {
+		err := br.db.WithContext(ctx).Where("message_id = ?", messageID).First(&sent).Error
+		if err == nil {
+			return &sent, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to match bounce by message id: %w", err)
+		}
+	}
+
+	if recipient != "" {
+		err := br.db.WithContext(ctx).Where("recipient = ?", recipient).Order("created_at DESC").First(&sent).Error
+		if err == nil {
+			return &sent, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to match bounce by recipient: %w", err)
+		}
+	}
+
+	return nil, nil
+}
+
+func (br *bounceRepository) GetInvalidEmailReport(ctx context.Context) (*[]domain.BouncedParent, error) {
+	var parents []domain.Parent
+	err := br.db.WithContext(ctx).
+		Where("email_invalid IS TRUE AND deleted_at IS NULL").
+		Order("email_invalid_at DESC").
+		Find(&parents).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch parents with invalid email: %w", err)
+	}
+
+	report := make([]domain.BouncedParent, 0, len(parents))
+	for _, parent := range parents {
+		row := domain.BouncedParent{Parent: parent}
+
+		err := br.db.WithContext(ctx).
+			Where("parent_id = ? OR student_nsn IN (SELECT student_nsn FROM student_guardians WHERE parent_id = ?)", parent.ParentID, parent.ParentID).
+			Find(&row.Students).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch students of parent %d: %w", parent.ParentID, err)
+		}
+
+		err = br.db.WithContext(ctx).
+			Where("parent_id = ? OR recipient = ?", parent.ParentID, strings.ToLower(*parent.Email)).
+			Order("received_at DESC").
+			Limit(5).
+			Find(&row.LastBounces).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch bounces of parent %d: %w", parent.ParentID, err)
+		}
+
+		report = append(report, row)
+	}
+
+	return &report, nil
+}
+
+// parseBounce extracts the original Message-ID and the per-recipient status of a DSN.
+// Non-standard bounces fall back to scanning the text for a Message-ID and an enhanced status code.
+func parseBounce(raw []byte) (string, []dsnRecipient, bool) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return "", nil, false
+	}
+
+	mediaType, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if mediaType == "multipart/report" && params["boundary"] != "" {
+		var messageID string
+		var recipients []dsnRecipient
+
+		reader := multipart.NewReader(msg.Body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				break
+			}
+
+			body, err := io.ReadAll(decodePart(part))
+			if err != nil {
+				continue
+			}
+
+			partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			switch partType {
+			case "message/delivery-status":
+				recipients = append(recipients, parseDeliveryStatus(body)...)
+			case "message/rfc822", "text/rfc822-headers":
+				if match := messageIDPattern.FindSubmatch(body); match != nil {
+					messageID = string(match[1])
+				}
+			}
+		}
+
+		if len(recipients) > 0 {
+			return messageID, recipients, true
+		}
+	}
+
+	if !looksLikeBounce(msg.Header) {
+		return "", nil, false
+	}
+
+	body, _ := io.ReadAll(msg.Body)
+	var messageID string
+	if match := messageIDPattern.FindSubmatch(body); match != nil {
+		messageID = string(match[1])
+	}
+	if messageID == "" {
+		return "", nil, false
+	}
+
+	rcpt := dsnRecipient{action: "failed"}
+	if match := statusCodePattern.FindSubmatch(body); match != nil {
+		rcpt.status = string(match[1])
+	}
+	if rcpt.status == "" || !strings.HasPrefix(rcpt.status, "5") {
+		rcpt.action = "delayed"
+	}
+
+	return messageID, []dsnRecipient{rcpt}, true
+}
+
+func parseDeliveryStatus(body []byte) []dsnRecipient {
+	var recipients []dsnRecipient
+
+	reader := textproto.NewReader(bufio.NewReader(bytes.NewReader(body)))
+	for {
+		fields, err := reader.ReadMIMEHeader()
+		if len(fields) > 0 && fields.Get("Final-Recipient") != "" {
+			rcpt := dsnRecipient{
+				recipient:  addressFromDSNField(fields.Get("Final-Recipient")),
+				action:     strings.ToLower(strings.TrimSpace(fields.Get("Action"))),
+				status:     strings.TrimSpace(fields.Get("Status")),
+				diagnostic: strings.TrimSpace(fields.Get("Diagnostic-Code")),
+			}
+			if original := fields.Get("Original-Recipient"); rcpt.recipient == "" && original != "" {
+				rcpt.recipient = addressFromDSNField(original)
+			}
+			recipients = append(recipients, rcpt)
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	return recipients
+}
+
+// addressFromDSNField strips the address type of "rfc822; parent@example.com"
+func addressFromDSNField(value string) string {
+	if i := strings.Index(value, ";"); i != -1 {
+		value = value[i+1:]
+	}
+	return strings.Trim(strings.TrimSpace(value), "<>")
+}
+
+func decodePart(part *multipart.Part) io.Reader {
+	if strings.EqualFold(part.Header.Get("Content-Transfer-Encoding"), "base64") {
+		return base64.NewDecoder(base64.StdEncoding, part)
+	}
+	return part
+}
+
+func looksLikeBounce(header mail.Header) bool {
+	from := strings.ToLower(header.Get("From"))
+	subject := strings.ToLower(header.Get("Subject"))
+
+	if strings.Contains(from, "mailer-daemon") || strings.Contains(from, "postmaster") {
+		return true
+	}
+
+	for _, hint := range []string{"undeliver", "delivery status notification", "returned mail", "delivery failure", "failure notice"} {
+		if strings.Contains(subject, hint) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// crlf joins the lines of a test message the way an MTA writes them
+func crlf(lines ...string) []byte {
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+func TestParseBounce(t *testing.T) {
+	deliveryStatus := strings.Join([]string{
+		"Reporting-MTA: dns; mail.sinoan.sch.id",
+		"",
+		"Final-Recipient: rfc822; parent@example.com",
+		"Action: failed",
+		"Status: 5.1.1",
+		"Diagnostic-Code: smtp; 550 5.1.1 User unknown",
+		"",
+		"Final-Recipient: rfc822; <other@example.com>",
+		"Original-Recipient: rfc822; other@example.com",
+		"Action: Delayed",
+		"Status: 4.4.1",
+		"",
+	}, "\r\n")
+
+	tests := []struct {
+		name          string
+		raw           []byte
+		wantOK        bool
+		wantMessageID string
+		wantRcpts     []dsnRecipient
+	}{
+		{
+			name: "multipart report with two recipients",
+			raw: crlf(
+				"From: Mail Delivery System <MAILER-DAEMON@sinoan.sch.id>",
+				"Subject: Undelivered Mail Returned to Sender",
+				`Content-Type: multipart/report; report-type=delivery-status; boundary="b1"`,
+				"",
+				"--b1",
+				"Content-Type: text/plain",
+				"",
+				"Your message could not be delivered.",
+				"--b1",
+				"Content-Type: message/delivery-status",
+				"",
+				deliveryStatus,
+				"--b1",
+				"Content-Type: message/rfc822",
+				"",
+				"Message-ID: <absence-42@sinoan.sch.id>",
+				"Subject: Absence notice",
+				"",
+				"--b1--",
+			),
+			wantOK:        true,
+			wantMessageID: "<absence-42@sinoan.sch.id>",
+			wantRcpts: []dsnRecipient{
+				{recipient: "parent@example.com", action: "failed", status: "5.1.1", diagnostic: "smtp; 550 5.1.1 User unknown"},
+				{recipient: "other@example.com", action: "delayed", status: "4.4.1"},
+			},
+		},
+		{
+			name: "base64 status with headers only and an empty final recipient",
+			raw: crlf(
+				"From: postmaster@example.com",
+				"Subject: Delivery Status Notification (Failure)",
+				"Content-Type: multipart/report; report-type=delivery-status; boundary=b2",
+				"",
+				"--b2",
+				"Content-Type: message/delivery-status",
+				"Content-Transfer-Encoding: base64",
+				"",
+				base64.StdEncoding.EncodeToString([]byte(strings.Join([]string{
+					"Reporting-MTA: dns; mx.example.com",
+					"",
+					"Final-Recipient: rfc822;",
+					"Original-Recipient: rfc822; Parent@Example.com",
+					"Action: failed",
+					"Status: 5.2.2",
+					"",
+				}, "\r\n"))),
+				"--b2",
+				"Content-Type: text/rfc822-headers",
+				"",
+				"message-id: <exam-7@sinoan.sch.id>",
+				"--b2--",
+			),
+			wantOK:        true,
+			wantMessageID: "<exam-7@sinoan.sch.id>",
+			wantRcpts: []dsnRecipient{
+				{recipient: "Parent@Example.com", action: "failed", status: "5.2.2"},
+			},
+		},
+		{
+			name: "plain text bounce with a permanent failure",
+			raw: crlf(
+				"From: MAILER-DAEMON@mx.example.com",
+				"Subject: failure notice",
+				"",
+				"Sorry, we were unable to deliver your message.",
+				"<parent@example.com>: 550 5.1.1 mailbox unavailable",
+				"",
+				"--- Below this line is a copy of the message.",
+				"Message-ID: <absence-43@sinoan.sch.id>",
+			),
+			wantOK:        true,
+			wantMessageID: "<absence-43@sinoan.sch.id>",
+			wantRcpts:     []dsnRecipient{{action: "failed", status: "5.1.1"}},
+		},
+		{
+			name: "plain text bounce with a temporary failure",
+			raw: crlf(
+				"From: postmaster@example.com",
+				"Subject: Returned mail: still trying",
+				"",
+				"421 4.4.2 connection timed out, still retrying",
+				"Message-ID: <absence-44@sinoan.sch.id>",
+			),
+			wantOK:        true,
+			wantMessageID: "<absence-44@sinoan.sch.id>",
+			wantRcpts:     []dsnRecipient{{action: "delayed", status: "4.4.2"}},
+		},
+		{
+			name: "plain text bounce without a status code",
+			raw: crlf(
+				"From: MAILER-DAEMON@mx.example.com",
+				"Subject: Undeliverable",
+				"",
+				"Message-ID: <absence-45@sinoan.sch.id>",
+			),
+			wantOK:        true,
+			wantMessageID: "<absence-45@sinoan.sch.id>",
+			wantRcpts:     []dsnRecipient{{action: "delayed"}},
+		},
+		{
+			name: "bounce without the original message id",
+			raw: crlf(
+				"From: MAILER-DAEMON@mx.example.com",
+				"Subject: Undelivered Mail Returned to Sender",
+				"",
+				"550 5.1.1 User unknown",
+			),
+		},
+		{
+			name: "report without recipients from an ordinary sender",
+			raw: crlf(
+				"From: parent@example.com",
+				"Subject: Re: Absence notice",
+				"Content-Type: multipart/report; report-type=delivery-status; boundary=b3",
+				"",
+				"--b3",
+				"Content-Type: message/delivery-status",
+				"",
+				"Reporting-MTA: dns; mx.example.com",
+				"--b3--",
+			),
+		},
+		{
+			name: "ordinary reply",
+			raw: crlf(
+				"From: parent@example.com",
+				"Subject: Re: Absence notice",
+				"",
+				"Thank you, he was sick. Message-ID: <absence-42@sinoan.sch.id>",
+			),
+		},
+		{
+			name: "not a message",
+			raw:  []byte("garbage without headers"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messageID, rcpts, ok := parseBounce(tt.raw)
+			if ok != tt.wantOK {
+				t.Fatalf("parseBounce() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if messageID != tt.wantMessageID {
+				t.Errorf("parseBounce() message id = %q, want %q", messageID, tt.wantMessageID)
+			}
+			if !reflect.DeepEqual(rcpts, tt.wantRcpts) {
+				t.Errorf("parseBounce() recipients = %+v, want %+v", rcpts, tt.wantRcpts)
+			}
+		})
+	}
+}
+
+func TestAddressFromDSNField(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"rfc822; parent@example.com", "parent@example.com"},
+		{"rfc822;<parent@example.com>", "parent@example.com"},
+		{"parent@example.com", "parent@example.com"},
+		{"rfc822;", ""},
+	}
+	for _, tt := range tests {
+		if got := addressFromDSNField(tt.value); got != tt.want {
+			t.Errorf("addressFromDSNField(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
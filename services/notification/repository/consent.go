@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"notification/domain"
+	"notification/middleware"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type consentRepository struct {
+	db *gorm.DB
+}
+
+func NewConsentRepository(db *gorm.DB) domain.ConsentRepo {
+	return &consentRepository{
+		db: db,
+	}
+}
+
+func (cr *consentRepository) GetParentConsents(ctx context.Context, parentID int) (*[]domain.ParentConsent, error) {
+	var consents []domain.ParentConsent
+
+	err := cr.db.WithContext(ctx).
+		Where("parent_id = ?", parentID).
+		Order("channel ASC, notification_type ASC").
+		Find(&consents).Error
+	if err != nil {
+		return nil, fmt.Errorf("could not get consents for parent %d: %v", parentID, err)
+	}
+
+	return &consents, nil
+}
+
+func (cr *consentRepository) SetParentConsent(ctx context.Context, consent *domain.ParentConsent) error {
+	if _, err := govalidator.ValidateStruct(consent); err != nil {
+		return fmt.Errorf("%w: %v", domain.ErrInvalidInput, err)
+	}
+
+	var parentCount int64
+	err := cr.db.WithContext(ctx).Model(&domain.Parent{}).Where("parent_id = ? AND deleted_at IS NULL", consent.ParentID).Count(&parentCount).Error
+	if err != nil {
+		return fmt.Errorf("could not check parent: %v", err)
+	}
+	if parentCount == 0 {
+		return fmt.Errorf("%w: parent with id %d", domain.ErrNotFound, consent.ParentID)
+	}
+
+	consent.UpdatedAt = time.Now()
+	err = cr.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "parent_id"}, {Name: "channel"}, {Name: "notification_type"}},
+			DoUpdates: clause.AssignmentColumns([]string{"opted_out", "source", "updated_at"}),
+		}).
+		Create(consent).Error
+	if err != nil {
+		return fmt.Errorf("could not save consent: %v", err)
+	}
+
+	return nil
+}
+
+func (cr *consentRepository) SetConsentByTelephone(ctx context.Context, telephone string, channel string, notificationType string, optedOut bool, source string) error {
+	localTel := normalizeTelephone(telephone)
+
+	var parent domain.Parent
+	err := cr.db.WithContext(ctx).
+		Where("telephone IN ? AND deleted_at IS NULL", []string{localTel, internationalTelephone(localTel)}).
+		First(&parent).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: no parent with telephone %s", domain.ErrNotFound, localTel)
+		}
+		return fmt.Errorf("error fetching parent details: %v", err)
+	}
+
+	return cr.SetParentConsent(ctx, &domain.ParentConsent{
+		ParentID:         parent.ParentID,
+		Channel:          channel,
+		NotificationType: notificationType,
+		OptedOut:         optedOut,
+		Source:           source,
+	})
+}
+
+func (cr *consentRepository) Unsubscribe(ctx context.Context, token string) (*domain.ParentConsent, error) {
+	claims, err := middleware.ParseUnsubscribeToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	consent := domain.ParentConsent{
+		ParentID:         claims.ParentID,
+		Channel:          claims.Channel,
+		NotificationType: claims.NotificationType,
+		OptedOut:         true,
+		Source:           "email_link",
+	}
+
+	if err := cr.SetParentConsent(ctx, &consent); err != nil {
+		return nil, err
+	}
+
+	return &consent, nil
+}
+
+// isSuppressedByPreference reports whether the parent opted out of a notification type on a channel
+func isSuppressedByPreference(ctx context.Context, db *gorm.DB, parentID int, channel, notificationType string) (bool, error) {
+	var consents []domain.ParentConsent
+	err := db.WithContext(ctx).
+		Where("parent_id = ? AND channel = ? AND notification_type IN ?", parentID, channel, []string{notificationType, domain.NotificationTypeAll}).
+		Find(&consents).Error
+	if err != nil {
+		return false, fmt.Errorf("could not check parent preferences: %v", err)
+	}
+
+	suppressed := false
+	for _, consent := range consents {
+		if consent.NotificationType == notificationType {
+			return consent.OptedOut, nil
+		}
+		suppressed = consent.OptedOut
+	}
+
+	return suppressed, nil
+}
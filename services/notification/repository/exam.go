@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"notification/domain"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"gorm.io/gorm"
+)
+
+type examRepository struct {
+	db *gorm.DB
+}
+
+func NewExamRepository(db *gorm.DB) domain.ExamRepo {
+	return &examRepository{
+		db: db,
+	}
+}
+
+func (er *examRepository) CreateExam(ctx context.Context, exam *domain.Exam) error {
+	if err := validateExam(exam); err != nil {
+		return err
+	}
+
+	exam.ExamID = 0
+	exam.BroadcastAt = nil
+	if err := er.db.WithContext(ctx).Create(exam).Error; err != nil {
+		return fmt.Errorf("could not create exam: %w", err)
+	}
+
+	return nil
+}
+
+// GetAllExams lists exams newest first, academicYear and semester narrow the list when given
+func (er *examRepository) GetAllExams(ctx context.Context, academicYear string, semester int) (*[]domain.Exam, error) {
+	query := er.db.WithContext(ctx).Where("deleted_at IS NULL")
+	if academicYear != "" {
+		query = query.Where("academic_year = ?", academicYear)
+	}
+	if semester != 0 {
+		query = query.Where("semester = ?", semester)
+	}
+
+	var exams []domain.Exam
+	if err := query.Order("created_at DESC").Find(&exams).Error; err != nil {
+		return nil, fmt.Errorf("could not get exams: %w", err)
+	}
+
+	return &exams, nil
+}
+
+func (er *examRepository) GetExamDetail(ctx context.Context, examID int) (*domain.Exam, error) {
+	return findExam(ctx, er.db, examID)
+}
+
+func (er *examRepository) UpdateExam(ctx context.Context, examID int, exam *domain.Exam) error {
+	if _, err := findExam(ctx, er.db, examID); err != nil {
+		return err
+	}
+
+	if err := validateExam(exam); err != nil {
+		return err
+	}
+
+	err := er.db.WithContext(ctx).Model(&domain.Exam{}).
+		Where("exam_id = ?", examID).
+		Updates(map[string]interface{}{
+			"name":          exam.Name,
+			"exam_type":     exam.ExamType,
+			"academic_year": exam.AcademicYear,
+			"semester":      exam.Semester,
+			"start_date":    exam.StartDate,
+			"end_date":      exam.EndDate,
+			"updated_at":    time.Now(),
+		}).Error
+	if err != nil {
+		return fmt.Errorf("could not update exam: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExam only removes exams that have no scores yet, scores are never thrown away
+func (er *examRepository) DeleteExam(ctx context.Context, examID int) error {
+	if _, err := findExam(ctx, er.db, examID); err != nil {
+		return err
+	}
+
+	var counter int64
+	err := er.db.WithContext(ctx).Model(&domain.TestScore{}).
+		Where("exam_id = ? AND deleted_at IS NULL", examID).
+		Count(&counter).Error
+	if err != nil {
+		return fmt.Errorf("could not check exam scores: %w", err)
+	}
+	if counter > 0 {
+		return fmt.Errorf("%w: exam already has %d score(s) and cannot be deleted", domain.ErrConflict, counter)
+	}
+
+	err = er.db.WithContext(ctx).Model(&domain.Exam{}).
+		Where("exam_id = ?", examID).
+		Update("deleted_at", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("could not delete exam: %w", err)
+	}
+
+	return nil
+}
+
+func (er *examRepository) GetExamScores(ctx context.Context, examID int, subjectCode string) (*[]domain.TestScore, error) {
+	if _, err := findExam(ctx, er.db, examID); err != nil {
+		return nil, err
+	}
+
+	query := er.db.WithContext(ctx).
+		Preload("Student").
+		Preload("Subject").
+		Preload("User", func(db *gorm.DB) *gorm.DB {
+			return db.Select("user_id", "username", "name", "role", "created_at", "updated_at", "deleted_at")
+		}).
+		Where("exam_id = ? AND deleted_at IS NULL", examID)
+	if subjectCode != "" {
+		query = query.Where("subject_code = ?", subjectCode)
+	}
+
+	var testScores []domain.TestScore
+	if err := query.Order("subject_code ASC, student_nsn ASC").Find(&testScores).Error; err != nil {
+		return nil, fmt.Errorf("could not get exam scores: %w", err)
+	}
+
+	return &testScores, nil
+}
+
+func validateExam(exam *domain.Exam) error {
+	if _, err := govalidator.ValidateStruct(exam); err != nil {
+		return fmt.Errorf("%w: %v", domain.ErrInvalidInput, err)
+	}
+
+	if exam.Semester != 1 && exam.Semester != 2 {
+		return fmt.Errorf("%w: semester must be 1 or 2", domain.ErrInvalidInput)
+	}
+
+	if exam.StartDate != nil && exam.EndDate != nil && exam.EndDate.Before(*exam.StartDate) {
+		return fmt.Errorf("%w: end date must not be before start date", domain.ErrInvalidInput)
+	}
+
+	return nil
+}
+
+func findExam(ctx context.Context, db *gorm.DB, examID int) (*domain.Exam, error) {
+	var exam domain.Exam
+	err := db.WithContext(ctx).Where("exam_id = ? AND deleted_at IS NULL", examID).First(&exam).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: exam with id %d", domain.ErrNotFound, examID)
+		}
+		return nil, fmt.Errorf("could not get exam details: %w", err)
+	}
+
+	return &exam, nil
+}
+
+// latestExamID is the exam score screens fall back to when none is picked, 0 when no exam exists yet
+func latestExamID(ctx context.Context, db *gorm.DB) (int, error) {
+	var exam domain.Exam
+	err := db.WithContext(ctx).Where("deleted_at IS NULL").Order("created_at DESC").First(&exam).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("could not get latest exam: %w", err)
+	}
+
+	return exam.ExamID, nil
+}
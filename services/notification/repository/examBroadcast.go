@@ -0,0 +1,432 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"notification/config"
+	"notification/domain"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// A broadcast still marked running after this long was interrupted and may be resumed
+const examBroadcastStaleAfter = 10 * time.Minute
+
+// Recipients in these states have not received the exam result yet, a resume sends to them again
+var examBroadcastUnsentStatuses = []string{domain.DeliveryStatusPending, domain.DeliveryStatusFailed}
+
+// Recipients in these states keep the broadcast open, a queued message is settled by the outbox flush
+var examBroadcastOpenStatuses = []string{domain.DeliveryStatusPending, domain.DeliveryStatusFailed, domain.DeliveryStatusQueued}
+
+// checkExamBroadcastStart refuses a new broadcast while an earlier one can still be resumed,
+// and refuses to send the results of an exam twice unless resend is set
+func checkExamBroadcastStart(exam domain.Exam, unfinished *domain.ExamBroadcast, resend bool) error {
+	if unfinished != nil {
+		return fmt.Errorf("%w: exam already has an unfinished broadcast (id %d), resume it instead", domain.ErrConflict, unfinished.BroadcastID)
+	}
+	if exam.BroadcastAt != nil && !resend {
+		return fmt.Errorf("%w: exam results were already sent on %s, set resend to send them again", domain.ErrConflict, exam.BroadcastAt.Format(time.DateTime))
+	}
+	return nil
+}
+
+// startExamBroadcast stores every guardian and channel as pending before anything is sent,
+// so a failed send or a crash leaves the rest resumable
+func (m *senderRepository) startExamBroadcast(ctx context.Context, examID, userID int, work []examResultWork, resend bool) (*domain.ExamBroadcast, error) {
+	broadcast := domain.ExamBroadcast{
+		ExamID: examID,
+		UserID: userID,
+		Status: domain.ExamBroadcastRunning,
+	}
+
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Concurrent starts for the same exam queue up on the exam row, so only one of them finds no unfinished broadcast
+		var exam domain.Exam
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("exam_id = ? AND deleted_at IS NULL", examID).First(&exam).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: exam with id %d", domain.ErrNotFound, examID)
+		}
+		if err != nil {
+			return fmt.Errorf("could not lock exam: %w", err)
+		}
+
+		var previous domain.ExamBroadcast
+		var unfinished *domain.ExamBroadcast
+		err = tx.Where("exam_id = ? AND status <> ?", examID, domain.ExamBroadcastCompleted).
+			Order("broadcast_id DESC").
+			First(&previous).Error
+		if err == nil {
+			unfinished = &previous
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("could not check previous broadcasts: %w", err)
+		}
+
+		if err := checkExamBroadcastStart(exam, unfinished, resend); err != nil {
+			return err
+		}
+
+		if err := tx.Create(&broadcast).Error; err != nil {
+			return fmt.Errorf("could not create broadcast: %w", err)
+		}
+
+		recipients := make([]domain.ExamBroadcastRecipient, 0, 2*len(work))
+		for _, item := range work {
+			for _, channel := range item.channels {
+				recipients = append(recipients, domain.ExamBroadcastRecipient{
+					BroadcastID: broadcast.BroadcastID,
+					StudentNSN:  item.score.StudentNSN,
+					ParentID:    item.score.Student.Parent.ParentID,
+					Channel:     channel,
+					Status:      domain.DeliveryStatusPending,
+				})
+			}
+		}
+		if len(recipients) == 0 {
+			return nil
+		}
+
+		if err := tx.Omit("Broadcast", "Student", "Parent").CreateInBatches(&recipients, 200).Error; err != nil {
+			return fmt.Errorf("could not store broadcast recipients: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &broadcast, nil
+}
+
+// examBroadcastRecipientUpdates is the change one send result makes to its recipient row, only real send attempts are counted
+func examBroadcastRecipientUpdates(result domain.DeliveryResult, now time.Time) map[string]interface{} {
+	updates := map[string]interface{}{
+		"status": result.Status,
+		"reason": nil,
+	}
+	if result.Reason != "" {
+		updates["reason"] = result.Reason
+	}
+
+	switch result.Status {
+	case domain.DeliveryStatusSent:
+		updates["attempts"] = gorm.Expr("attempts + 1")
+		updates["sent_at"] = now
+	case domain.DeliveryStatusFailed, domain.DeliveryStatusQueued:
+		updates["attempts"] = gorm.Expr("attempts + 1")
+	}
+
+	return updates
+}
+
+// updateExamBroadcastRecipient stores the outcome of one send, failures here are only logged so sending goes on.
+// A queued message is linked to its recipient so the outbox flush can settle it.
+func (m *senderRepository) updateExamBroadcastRecipient(ctx context.Context, broadcastID int, result domain.DeliveryResult) {
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		recipient := func() *gorm.DB {
+			return tx.Model(&domain.ExamBroadcastRecipient{}).
+				Where("broadcast_id = ? AND student_nsn = ? AND parent_id = ? AND channel = ?", broadcastID, result.StudentNSN, result.ParentID, result.Channel)
+		}
+
+		if err := recipient().Updates(examBroadcastRecipientUpdates(result, time.Now())).Error; err != nil {
+			return err
+		}
+		if result.OutboxID == nil {
+			return nil
+		}
+		return tx.Model(&domain.WhatsappOutbox{}).
+			Where("outbox_id = ?", *result.OutboxID).
+			Update("exam_broadcast_recipient_id", recipient().Select("recipient_id")).Error
+	})
+	if err != nil {
+		config.Logger(ctx).WithError(err).WithFields(logrus.Fields{
+			"broadcast_id": broadcastID,
+			"student_nsn":  result.StudentNSN,
+			"parent_id":    result.ParentID,
+		}).Error("could not update broadcast recipient")
+	}
+}
+
+// examBroadcastStatusAfterRun is where a run leaves the broadcast, open counts the recipients that are unsent or queued
+func examBroadcastStatusAfterRun(open int64) string {
+	if open == 0 {
+		return domain.ExamBroadcastCompleted
+	}
+	return domain.ExamBroadcastIncomplete
+}
+
+// finishExamBroadcast marks the broadcast completed once no recipient is left unsent or queued, the exam is stamped at that point
+func (m *senderRepository) finishExamBroadcast(ctx context.Context, broadcastID int, results []domain.DeliveryResult, sendErr error) (*domain.ExamBroadcastReport, error) {
+	report := &domain.ExamBroadcastReport{
+		BroadcastID: broadcastID,
+		Results:     results,
+	}
+
+	var open int64
+	err := m.db.WithContext(ctx).Model(&domain.ExamBroadcastRecipient{}).
+		Where("broadcast_id = ? AND status IN ?", broadcastID, examBroadcastOpenStatuses).
+		Count(&open).Error
+	if err != nil {
+		return report, fmt.Errorf("could not count open recipients: %w", err)
+	}
+
+	status := examBroadcastStatusAfterRun(open)
+	updates := map[string]interface{}{"status": status}
+	now := time.Now()
+	if status == domain.ExamBroadcastCompleted {
+		updates["completed_at"] = now
+	}
+
+	var broadcast domain.ExamBroadcast
+	err = m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&domain.ExamBroadcast{}).Where("broadcast_id = ?", broadcastID).Updates(updates).Error; err != nil {
+			return fmt.Errorf("could not update broadcast: %w", err)
+		}
+		if err := tx.Where("broadcast_id = ?", broadcastID).First(&broadcast).Error; err != nil {
+			return fmt.Errorf("could not get broadcast: %w", err)
+		}
+		if status == domain.ExamBroadcastCompleted {
+			if err := tx.Model(&domain.Exam{}).Where("exam_id = ?", broadcast.ExamID).Update("broadcast_at", now).Error; err != nil {
+				return fmt.Errorf("failed to mark exam as broadcast: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	summary, err := m.GetExamBroadcastSummary(ctx, broadcastID)
+	if err != nil {
+		return report, err
+	}
+	report.Summary = summary
+
+	if sendErr != nil {
+		return report, fmt.Errorf("%w, %d recipient(s) not reached yet", sendErr, open)
+	}
+	return report, nil
+}
+
+// ResumeExamBroadcast retries only the recipients that are still pending or failed, queued ones are left to the outbox
+func (m *senderRepository) ResumeExamBroadcast(ctx context.Context, broadcastID int, userID int) (*domain.ExamBroadcastReport, error) {
+	var broadcast domain.ExamBroadcast
+	err := m.db.WithContext(ctx).Where("broadcast_id = ?", broadcastID).First(&broadcast).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: broadcast with id %d", domain.ErrNotFound, broadcastID)
+		}
+		return nil, fmt.Errorf("could not get broadcast: %w", err)
+	}
+
+	exam, err := findExam(ctx, m.db, broadcast.ExamID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Claim the broadcast so two runs never send the same recipients twice,
+	// a running broadcast is only taken over when none of its recipients moved for a while
+	staleBefore := time.Now().Add(-examBroadcastStaleAfter)
+	claim := m.db.WithContext(ctx).Model(&domain.ExamBroadcast{}).
+		Where("broadcast_id = ?", broadcastID).
+		Where("status = ? OR (status = ? AND updated_at < ? AND NOT EXISTS (?))",
+			domain.ExamBroadcastIncomplete, domain.ExamBroadcastRunning, staleBefore,
+			m.db.Model(&domain.ExamBroadcastRecipient{}).
+				Select("1").
+				Where("exam_broadcast_recipients.broadcast_id = exam_broadcasts.broadcast_id AND exam_broadcast_recipients.updated_at >= ?", staleBefore)).
+		Updates(map[string]interface{}{"status": domain.ExamBroadcastRunning, "updated_at": time.Now()})
+	if claim.Error != nil {
+		return nil, fmt.Errorf("could not claim broadcast: %w", claim.Error)
+	}
+	if claim.RowsAffected == 0 {
+		if broadcast.Status == domain.ExamBroadcastCompleted {
+			return nil, fmt.Errorf("%w: broadcast %d is already completed", domain.ErrConflict, broadcastID)
+		}
+		return nil, fmt.Errorf("%w: broadcast %d is still running", domain.ErrConflict, broadcastID)
+	}
+
+	var unsent []domain.ExamBroadcastRecipient
+	err = m.db.WithContext(ctx).
+		Where("broadcast_id = ? AND status IN ?", broadcastID, examBroadcastUnsentStatuses).
+		Find(&unsent).Error
+	if err != nil {
+		m.releaseExamBroadcast(ctx, broadcastID)
+		return nil, fmt.Errorf("could not get unsent recipients: %w", err)
+	}
+
+	// Messages are rebuilt from the current scores and guardians of the exam
+	recipients, err := m.examResultRecipients(ctx, exam.ExamID)
+	if err != nil {
+		m.releaseExamBroadcast(ctx, broadcastID)
+		return nil, err
+	}
+
+	work, skipped := planExamBroadcastResume(unsent, recipients)
+	for _, result := range skipped {
+		m.updateExamBroadcastRecipient(ctx, broadcastID, result)
+	}
+
+	results, sendErr := m.deliverExamResults(ctx, exam, userID, broadcastID, work, false)
+	return m.finishExamBroadcast(ctx, broadcastID, results, sendErr)
+}
+
+// planExamBroadcastResume groups the unsent rows per guardian in the order they were stored,
+// rows of a guardian that no longer receives exam results come back as skipped
+func planExamBroadcastResume(unsent []domain.ExamBroadcastRecipient, recipients []domain.IndividualExamScore) ([]examResultWork, []domain.DeliveryResult) {
+	byGuardian := make(map[string]domain.IndividualExamScore, len(recipients))
+	for _, recipient := range recipients {
+		byGuardian[fmt.Sprintf("%s/%d", recipient.StudentNSN, recipient.Student.Parent.ParentID)] = recipient
+	}
+
+	var skipped []domain.DeliveryResult
+	channels := make(map[string][]string)
+	var order []string
+	for _, row := range unsent {
+		key := fmt.Sprintf("%s/%d", row.StudentNSN, row.ParentID)
+		if _, ok := byGuardian[key]; !ok {
+			skipped = append(skipped, domain.DeliveryResult{
+				StudentNSN: row.StudentNSN,
+				ParentID:   row.ParentID,
+				Channel:    row.Channel,
+				Status:     domain.DeliveryStatusSkipped,
+				Reason:     domain.DeliveryReasonNotRecipient,
+			})
+			continue
+		}
+		if _, ok := channels[key]; !ok {
+			order = append(order, key)
+		}
+		channels[key] = append(channels[key], row.Channel)
+	}
+
+	work := make([]examResultWork, 0, len(order))
+	for _, key := range order {
+		work = append(work, examResultWork{score: byGuardian[key], channels: channels[key]})
+	}
+
+	return work, skipped
+}
+
+// settleQueuedExamBroadcastRecipient writes the flush outcome of a queued message onto its recipient.
+// A failure is sent again by the next resume, the broadcast is completed once nothing is left open.
+func settleQueuedExamBroadcastRecipient(tx *gorm.DB, recipientID int, status, reason string) error {
+	var recipient domain.ExamBroadcastRecipient
+	err := tx.Where("recipient_id = ? AND status = ?", recipientID, domain.DeliveryStatusQueued).First(&recipient).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not get broadcast recipient: %w", err)
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"status": status, "reason": nil}
+	if reason != "" {
+		updates["reason"] = reason
+	}
+	if status == domain.DeliveryStatusSent {
+		updates["sent_at"] = now
+	}
+	if err := tx.Model(&domain.ExamBroadcastRecipient{}).Where("recipient_id = ?", recipientID).Updates(updates).Error; err != nil {
+		return fmt.Errorf("could not update broadcast recipient: %w", err)
+	}
+
+	var open int64
+	err = tx.Model(&domain.ExamBroadcastRecipient{}).
+		Where("broadcast_id = ? AND status IN ?", recipient.BroadcastID, examBroadcastOpenStatuses).
+		Count(&open).Error
+	if err != nil {
+		return fmt.Errorf("could not count open recipients: %w", err)
+	}
+	if examBroadcastStatusAfterRun(open) != domain.ExamBroadcastCompleted {
+		return nil
+	}
+
+	// A running resume completes the broadcast itself when it finishes
+	completed := tx.Model(&domain.ExamBroadcast{}).
+		Where("broadcast_id = ? AND status = ?", recipient.BroadcastID, domain.ExamBroadcastIncomplete).
+		Updates(map[string]interface{}{"status": domain.ExamBroadcastCompleted, "completed_at": now})
+	if completed.Error != nil {
+		return fmt.Errorf("could not complete broadcast: %w", completed.Error)
+	}
+	if completed.RowsAffected == 0 {
+		return nil
+	}
+
+	err = tx.Model(&domain.Exam{}).
+		Where("exam_id = (?)", tx.Model(&domain.ExamBroadcast{}).Select("exam_id").Where("broadcast_id = ?", recipient.BroadcastID)).
+		Update("broadcast_at", now).Error
+	if err != nil {
+		return fmt.Errorf("failed to mark exam as broadcast: %w", err)
+	}
+	return nil
+}
+
+// releaseExamBroadcast hands a claimed broadcast back when a resume stops before sending anything
+func (m *senderRepository) releaseExamBroadcast(ctx context.Context, broadcastID int) {
+	err := m.db.WithContext(ctx).Model(&domain.ExamBroadcast{}).
+		Where("broadcast_id = ?", broadcastID).
+		Update("status", domain.ExamBroadcastIncomplete).Error
+	if err != nil {
+		config.Logger(ctx).WithError(err).WithField("broadcast_id", broadcastID).Error("could not release broadcast")
+	}
+}
+
+// GetExamBroadcasts lists broadcasts newest first, examID 0 returns every exam
+func (m *senderRepository) GetExamBroadcasts(ctx context.Context, examID int) (*[]domain.ExamBroadcast, error) {
+	query := m.db.WithContext(ctx)
+	if examID != 0 {
+		query = query.Where("exam_id = ?", examID)
+	}
+
+	var broadcasts []domain.ExamBroadcast
+	if err := query.Order("broadcast_id DESC").Find(&broadcasts).Error; err != nil {
+		return nil, fmt.Errorf("could not get broadcasts: %w", err)
+	}
+
+	return &broadcasts, nil
+}
+
+func (m *senderRepository) GetExamBroadcastSummary(ctx context.Context, broadcastID int) (*domain.ExamBroadcastSummary, error) {
+	var summary domain.ExamBroadcastSummary
+	err := m.db.WithContext(ctx).Where("broadcast_id = ?", broadcastID).First(&summary.Broadcast).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: broadcast with id %d", domain.ErrNotFound, broadcastID)
+		}
+		return nil, fmt.Errorf("could not get broadcast: %w", err)
+	}
+
+	var counts []struct {
+		Status  string
+		Counter int64
+	}
+	err = m.db.WithContext(ctx).Model(&domain.ExamBroadcastRecipient{}).
+		Select("status, COUNT(*) AS counter").
+		Where("broadcast_id = ?", broadcastID).
+		Group("status").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, fmt.Errorf("could not count broadcast recipients: %w", err)
+	}
+
+	summary.Counts = make(map[string]int64, len(counts))
+	for _, count := range counts {
+		summary.Counts[count.Status] = count.Counter
+	}
+
+	err = m.db.WithContext(ctx).
+		Preload("Student").
+		Preload("Parent").
+		Where("broadcast_id = ? AND status IN ?", broadcastID, examBroadcastUnsentStatuses).
+		Order("student_nsn ASC, parent_id ASC, channel ASC").
+		Find(&summary.Unsent).Error
+	if err != nil {
+		return nil, fmt.Errorf("could not get unsent recipients: %w", err)
+	}
+
+	return &summary, nil
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"errors"
+	"notification/domain"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCheckExamBroadcastStart(t *testing.T) {
+	sentAt := time.Date(2025, 6, 20, 9, 0, 0, 0, time.UTC)
+	fresh := domain.Exam{ExamID: 1}
+	broadcast := domain.Exam{ExamID: 1, BroadcastAt: &sentAt}
+	unfinished := &domain.ExamBroadcast{BroadcastID: 4, ExamID: 1, Status: domain.ExamBroadcastIncomplete}
+
+	tests := []struct {
+		name       string
+		exam       domain.Exam
+		unfinished *domain.ExamBroadcast
+		resend     bool
+		wantErr    bool
+	}{
+		{"first broadcast", fresh, nil, false, false},
+		{"resend flag on a fresh exam", fresh, nil, true, false},
+		{"already broadcast", broadcast, nil, false, true},
+		{"already broadcast and resend", broadcast, nil, true, false},
+		{"unfinished broadcast", fresh, unfinished, false, true},
+		{"unfinished broadcast and resend", broadcast, unfinished, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkExamBroadcastStart(tt.exam, tt.unfinished, tt.resend)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkExamBroadcastStart() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, domain.ErrConflict) {
+				t.Errorf("checkExamBroadcastStart() error = %v, want it to wrap %v", err, domain.ErrConflict)
+			}
+		})
+	}
+}
+
+func TestExamBroadcastRecipientUpdates(t *testing.T) {
+	now := time.Date(2025, 6, 20, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		status      string
+		reason      string
+		wantAttempt bool
+		wantSentAt  bool
+		wantReason  interface{}
+	}{
+		{domain.DeliveryStatusSent, "", true, true, nil},
+		{domain.DeliveryStatusFailed, "smtp timeout", true, false, "smtp timeout"},
+		{domain.DeliveryStatusQueued, domain.DeliveryReasonQueued, true, false, domain.DeliveryReasonQueued},
+		{domain.DeliveryStatusSkipped, domain.DeliveryReasonNoEmail, false, false, domain.DeliveryReasonNoEmail},
+		{domain.DeliveryStatusSuppressed, domain.DeliveryReasonSuppressed, false, false, domain.DeliveryReasonSuppressed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			updates := examBroadcastRecipientUpdates(domain.DeliveryResult{Status: tt.status, Reason: tt.reason}, now)
+
+			if updates["status"] != tt.status {
+				t.Errorf("status = %v, want %v", updates["status"], tt.status)
+			}
+			if updates["reason"] != tt.wantReason {
+				t.Errorf("reason = %v, want %v", updates["reason"], tt.wantReason)
+			}
+			if _, ok := updates["attempts"]; ok != tt.wantAttempt {
+				t.Errorf("attempts counted = %v, want %v", ok, tt.wantAttempt)
+			}
+			sentAt, ok := updates["sent_at"]
+			if ok != tt.wantSentAt {
+				t.Fatalf("sent_at set = %v, want %v", ok, tt.wantSentAt)
+			}
+			if ok && sentAt != now {
+				t.Errorf("sent_at = %v, want %v", sentAt, now)
+			}
+		})
+	}
+}
+
+func TestExamBroadcastStatusAfterRun(t *testing.T) {
+	if got := examBroadcastStatusAfterRun(0); got != domain.ExamBroadcastCompleted {
+		t.Errorf("examBroadcastStatusAfterRun(0) = %q, want %q", got, domain.ExamBroadcastCompleted)
+	}
+	if got := examBroadcastStatusAfterRun(3); got != domain.ExamBroadcastIncomplete {
+		t.Errorf("examBroadcastStatusAfterRun(3) = %q, want %q", got, domain.ExamBroadcastIncomplete)
+	}
+}
+
+func TestExamBroadcastStatuses(t *testing.T) {
+	// A queued message is neither sent again on resume nor allowed to complete the broadcast
+	contains := func(statuses []string, status string) bool {
+		for _, s := range statuses {
+			if s == status {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, status := range examBroadcastUnsentStatuses {
+		if !contains(examBroadcastOpenStatuses, status) {
+			t.Errorf("unsent status %q does not keep the broadcast open", status)
+		}
+	}
+	if contains(examBroadcastUnsentStatuses, domain.DeliveryStatusQueued) {
+		t.Errorf("queued recipients would be sent again on resume")
+	}
+	if !contains(examBroadcastOpenStatuses, domain.DeliveryStatusQueued) {
+		t.Errorf("queued recipients would let the broadcast complete")
+	}
+	for _, status := range []string{domain.DeliveryStatusSent, domain.DeliveryStatusSkipped, domain.DeliveryStatusSuppressed} {
+		if contains(examBroadcastOpenStatuses, status) {
+			t.Errorf("final status %q keeps the broadcast open", status)
+		}
+	}
+}
+
+func TestPlanExamBroadcastResume(t *testing.T) {
+	guardian := func(nsn string, parentID int) domain.IndividualExamScore {
+		return domain.IndividualExamScore{
+			StudentNSN: nsn,
+			Student:    domain.Student{StudentNSN: nsn, Parent: domain.Parent{ParentID: parentID}},
+		}
+	}
+	recipients := []domain.IndividualExamScore{guardian("0051", 3), guardian("0051", 8), guardian("0052", 4)}
+
+	unsent := []domain.ExamBroadcastRecipient{
+		{StudentNSN: "0052", ParentID: 4, Channel: domain.ChannelWhatsapp, Status: domain.DeliveryStatusFailed},
+		{StudentNSN: "0051", ParentID: 3, Channel: domain.ChannelEmail, Status: domain.DeliveryStatusPending},
+		{StudentNSN: "0053", ParentID: 5, Channel: domain.ChannelEmail, Status: domain.DeliveryStatusPending},
+		{StudentNSN: "0051", ParentID: 3, Channel: domain.ChannelWhatsapp, Status: domain.DeliveryStatusFailed},
+	}
+
+	work, skipped := planExamBroadcastResume(unsent, recipients)
+
+	wantWork := []examResultWork{
+		{score: guardian("0052", 4), channels: []string{domain.ChannelWhatsapp}},
+		{score: guardian("0051", 3), channels: []string{domain.ChannelEmail, domain.ChannelWhatsapp}},
+	}
+	if !reflect.DeepEqual(work, wantWork) {
+		t.Errorf("work = %+v, want %+v", work, wantWork)
+	}
+
+	wantSkipped := []domain.DeliveryResult{
+		{StudentNSN: "0053", ParentID: 5, Channel: domain.ChannelEmail, Status: domain.DeliveryStatusSkipped, Reason: domain.DeliveryReasonNotRecipient},
+	}
+	if !reflect.DeepEqual(skipped, wantSkipped) {
+		t.Errorf("skipped = %+v, want %+v", skipped, wantSkipped)
+	}
+
+	if work, skipped := planExamBroadcastResume(nil, recipients); len(work) != 0 || len(skipped) != 0 {
+		t.Errorf("planExamBroadcastResume(nil) = %v, %v, want nothing to do", work, skipped)
+	}
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"errors"
+	"notification/domain"
+	"testing"
+	"time"
+)
+
+func TestValidateExam(t *testing.T) {
+	start := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 5)
+
+	valid := func(edit func(*domain.Exam)) *domain.Exam {
+		exam := &domain.Exam{Name: "Midterm", ExamType: "UTS", AcademicYear: "2024/2025", Semester: 2, StartDate: &start, EndDate: &end}
+		if edit != nil {
+			edit(exam)
+		}
+		return exam
+	}
+
+	tests := []struct {
+		name    string
+		exam    *domain.Exam
+		wantErr bool
+	}{
+		{"valid", valid(nil), false},
+		{"no dates", valid(func(e *domain.Exam) { e.StartDate, e.EndDate = nil, nil }), false},
+		{"same day", valid(func(e *domain.Exam) { e.EndDate = &start }), false},
+		{"missing name", valid(func(e *domain.Exam) { e.Name = "" }), true},
+		{"unknown exam type", valid(func(e *domain.Exam) { e.ExamType = "QUIZ" }), true},
+		{"malformed academic year", valid(func(e *domain.Exam) { e.AcademicYear = "2024-2025" }), true},
+		{"semester out of range", valid(func(e *domain.Exam) { e.Semester = 3 }), true},
+		{"end before start", valid(func(e *domain.Exam) { e.StartDate, e.EndDate = &end, &start }), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateExam(tt.exam)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateExam() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, domain.ErrInvalidInput) {
+				t.Errorf("validateExam() error = %v, want it to wrap %v", err, domain.ErrInvalidInput)
+			}
+		})
+	}
+}
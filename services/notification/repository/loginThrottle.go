@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"notification/config"
+	"notification/domain"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// loginThrottleKeys are the username and address a login attempt is counted against
+func loginThrottleKeys(username, ip string) []domain.LoginThrottle {
+	keys := []domain.LoginThrottle{{
+		Kind:    domain.LoginThrottleUsername,
+		Subject: truncate(strings.ToLower(strings.TrimSpace(username)), 50),
+	}}
+	if ip != "" {
+		keys = append(keys, domain.LoginThrottle{Kind: domain.LoginThrottleIP, Subject: truncate(ip, 50)})
+	}
+	return keys
+}
+
+// reserveLoginAttempt counts the attempt as a failure before the password is compared, so parallel guesses
+// can not all pass the check at once. Any key that is locked out or still waiting out its delay refuses the attempt
+// and nothing is counted. A successful login hands the reservation back with releaseLoginAttempt.
+func (ar *authRepository) reserveLoginAttempt(ctx context.Context, keys []domain.LoginThrottle) error {
+	return ar.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		throttles := make([]domain.LoginThrottle, len(keys))
+		var refused *domain.LoginThrottledError
+
+		for i, key := range keys {
+			err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&domain.LoginThrottle{
+				Kind:         key.Kind,
+				Subject:      key.Subject,
+				LastFailedAt: now,
+			}).Error
+			if err != nil {
+				return fmt.Errorf("could not check failed logins: %w", err)
+			}
+
+			err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("kind = ? AND subject = ?", key.Kind, key.Subject).
+				First(&throttles[i]).Error
+			if err != nil {
+				return fmt.Errorf("could not check failed logins: %w", err)
+			}
+
+			if wait, locked := loginThrottleWait(throttles[i], now); wait > 0 && (refused == nil || wait > refused.RetryAfter) {
+				refused = &domain.LoginThrottledError{RetryAfter: wait, Locked: locked}
+			}
+		}
+		if refused != nil {
+			return refused
+		}
+
+		for i := range throttles {
+			throttle := &throttles[i]
+			lockout := countLoginAttempt(throttle, now)
+
+			err := tx.Model(throttle).
+				Clauses(clause.Returning{}).
+				Updates(map[string]interface{}{
+					"failures":       throttle.Failures,
+					"last_failed_at": throttle.LastFailedAt,
+					"locked_until":   throttle.LockedUntil,
+				}).Error
+			if err != nil {
+				return fmt.Errorf("could not count login attempt: %w", err)
+			}
+
+			if lockout {
+				err = recordAudit(ctx, tx, domain.AuditActionLockout, domain.AuditEntityLoginThrottle, throttle.Subject, nil, map[string]interface{}{
+					"kind":         throttle.Kind,
+					"failures":     throttle.Failures,
+					"locked_until": throttle.LockedUntil,
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// loginThrottleWait tells how long throttle still refuses logins and whether that is a lockout rather than a delay
+func loginThrottleWait(throttle domain.LoginThrottle, now time.Time) (time.Duration, bool) {
+	if throttle.LockedUntil != nil && now.Before(*throttle.LockedUntil) {
+		return throttle.LockedUntil.Sub(now), true
+	}
+	if throttle.Failures >= domain.LoginDelayAfterFailures && now.Sub(throttle.LastFailedAt) <= domain.LoginFailureWindow {
+		if wait := throttle.LastFailedAt.Add(loginDelay(throttle.Failures)).Sub(now); wait > 0 {
+			return wait, false
+		}
+	}
+	return 0, false
+}
+
+// loginDelay doubles from one second for every failure past LoginDelayAfterFailures
+func loginDelay(failures int) time.Duration {
+	shift := failures - domain.LoginDelayAfterFailures
+	if shift > 5 {
+		return domain.LoginMaxDelay
+	}
+	delay := time.Second << shift
+	if delay > domain.LoginMaxDelay {
+		return domain.LoginMaxDelay
+	}
+	return delay
+}
+
+// loginLockoutLimit is the number of failures that locks out a username or an address
+func loginLockoutLimit(kind string) int {
+	if kind == domain.LoginThrottleIP {
+		return domain.LoginIPLockoutFailures
+	}
+	return domain.LoginUsernameLockoutFailures
+}
+
+// countLoginAttempt adds one failure to throttle, starting over once the window or an earlier lockout has passed.
+// It reports whether this failure started a lockout.
+func countLoginAttempt(throttle *domain.LoginThrottle, now time.Time) bool {
+	lockExpired := throttle.LockedUntil != nil && !now.Before(*throttle.LockedUntil)
+	if lockExpired || (throttle.LockedUntil == nil && now.Sub(throttle.LastFailedAt) > domain.LoginFailureWindow) {
+		throttle.Failures = 0
+		throttle.LockedUntil = nil
+	}
+	throttle.Failures++
+	throttle.LastFailedAt = now
+
+	if throttle.Failures >= loginLockoutLimit(throttle.Kind) && throttle.LockedUntil == nil {
+		lockedUntil := now.Add(domain.LoginLockoutDuration)
+		throttle.LockedUntil = &lockedUntil
+		return true
+	}
+	return false
+}
+
+// releaseLoginAttempt hands back the attempt reserved for a login that succeeded.
+// The username forgets its failures, the address only gets its reserved failure back.
+func (ar *authRepository) releaseLoginAttempt(ctx context.Context, keys []domain.LoginThrottle) {
+	err := ar.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, key := range keys {
+			if key.Kind == domain.LoginThrottleUsername {
+				if err := tx.Where("kind = ? AND subject = ?", key.Kind, key.Subject).Delete(&domain.LoginThrottle{}).Error; err != nil {
+					return err
+				}
+				continue
+			}
+
+			// A lockout still in place after the reservation was started by it, the attempt was refused otherwise
+			err := tx.Model(&domain.LoginThrottle{}).
+				Where("kind = ? AND subject = ?", key.Kind, key.Subject).
+				Updates(map[string]interface{}{
+					"failures":     gorm.Expr("GREATEST(failures - 1, 0)"),
+					"locked_until": gorm.Expr("CASE WHEN failures - 1 < ? THEN NULL ELSE locked_until END", loginLockoutLimit(key.Kind)),
+				}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		config.Logger(ctx).WithError(err).Error("could not release login attempt")
+	}
+}
+
+// GetLoginThrottles lists the usernames and addresses that are locked out or failed recently, latest first
+func (ar *authRepository) GetLoginThrottles(ctx context.Context) (*[]domain.LoginThrottle, error) {
+	now := time.Now()
+	throttles := []domain.LoginThrottle{}
+	err := ar.db.WithContext(ctx).
+		Where("locked_until > ? OR last_failed_at > ?", now, now.Add(-domain.LoginFailureWindow)).
+		Order("last_failed_at DESC").
+		Find(&throttles).Error
+	if err != nil {
+		return nil, fmt.Errorf("could not get failed logins: %w", err)
+	}
+
+	return &throttles, nil
+}
+
+// UnlockLogin lifts the lockout and clears the failures of the requested username and address
+func (ar *authRepository) UnlockLogin(ctx context.Context, req *domain.LoginUnlockRequest) error {
+	username := strings.TrimSpace(req.Username)
+	ip := strings.TrimSpace(req.IP)
+	if username == "" && ip == "" {
+		return fmt.Errorf("username or ip is required")
+	}
+
+	var keys []domain.LoginThrottle
+	if username != "" {
+		keys = append(keys, loginThrottleKeys(username, "")[0])
+	}
+	if ip != "" {
+		keys = append(keys, domain.LoginThrottle{Kind: domain.LoginThrottleIP, Subject: truncate(ip, 50)})
+	}
+
+	return ar.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, key := range keys {
+			result := tx.Where("kind = ? AND subject = ?", key.Kind, key.Subject).Delete(&domain.LoginThrottle{})
+			if result.Error != nil {
+				return fmt.Errorf("could not unlock %s %s: %w", key.Kind, key.Subject, result.Error)
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+
+			err := recordAudit(ctx, tx, domain.AuditActionUnlock, domain.AuditEntityLoginThrottle, key.Subject, nil, map[string]interface{}{
+				"kind": key.Kind,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
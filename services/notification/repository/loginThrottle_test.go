@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"notification/domain"
+	"testing"
+	"time"
+)
+
+func TestLoginDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{domain.LoginDelayAfterFailures, time.Second},
+		{domain.LoginDelayAfterFailures + 1, 2 * time.Second},
+		{domain.LoginDelayAfterFailures + 4, 16 * time.Second},
+		{domain.LoginDelayAfterFailures + 5, domain.LoginMaxDelay},
+		{domain.LoginDelayAfterFailures + 6, domain.LoginMaxDelay},
+		{domain.LoginDelayAfterFailures + 100, domain.LoginMaxDelay},
+	}
+	for _, tt := range tests {
+		if got := loginDelay(tt.failures); got != tt.want {
+			t.Errorf("loginDelay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLoginThrottleWait(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	at := func(offset time.Duration) *time.Time {
+		t := now.Add(offset)
+		return &t
+	}
+
+	tests := []struct {
+		name       string
+		throttle   domain.LoginThrottle
+		wantWait   time.Duration
+		wantLocked bool
+	}{
+		{"no failures", domain.LoginThrottle{}, 0, false},
+		{"below the delay threshold", domain.LoginThrottle{Failures: domain.LoginDelayAfterFailures - 1, LastFailedAt: now}, 0, false},
+		{"first delay", domain.LoginThrottle{Failures: domain.LoginDelayAfterFailures, LastFailedAt: now}, time.Second, false},
+		{"delay partly waited out", domain.LoginThrottle{Failures: domain.LoginDelayAfterFailures + 2, LastFailedAt: now.Add(-time.Second)}, 3 * time.Second, false},
+		{"delay waited out", domain.LoginThrottle{Failures: domain.LoginDelayAfterFailures + 2, LastFailedAt: now.Add(-5 * time.Second)}, 0, false},
+		{"failures outside the window", domain.LoginThrottle{Failures: 9, LastFailedAt: now.Add(-domain.LoginFailureWindow - time.Second)}, 0, false},
+		{"locked out", domain.LoginThrottle{Failures: 10, LastFailedAt: now, LockedUntil: at(10 * time.Minute)}, 10 * time.Minute, true},
+		{"lockout passed", domain.LoginThrottle{Failures: 1, LastFailedAt: now.Add(-time.Hour), LockedUntil: at(-time.Minute)}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wait, locked := loginThrottleWait(tt.throttle, now)
+			if wait != tt.wantWait || locked != tt.wantLocked {
+				t.Errorf("loginThrottleWait() = (%v, %v), want (%v, %v)", wait, locked, tt.wantWait, tt.wantLocked)
+			}
+		})
+	}
+}
+
+func TestCountLoginAttempt(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	lockedUntil := now.Add(domain.LoginLockoutDuration)
+	expiredLock := now.Add(-time.Second)
+	activeLock := now.Add(time.Minute)
+
+	tests := []struct {
+		name            string
+		throttle        domain.LoginThrottle
+		wantFailures    int
+		wantLockout     bool
+		wantLockedUntil *time.Time
+	}{
+		{"first failure", domain.LoginThrottle{Kind: domain.LoginThrottleUsername, LastFailedAt: now}, 1, false, nil},
+		{"adds up within the window", domain.LoginThrottle{Kind: domain.LoginThrottleUsername, Failures: 4, LastFailedAt: now.Add(-time.Minute)}, 5, false, nil},
+		{"starts over after the window", domain.LoginThrottle{Kind: domain.LoginThrottleUsername, Failures: 9, LastFailedAt: now.Add(-domain.LoginFailureWindow - time.Second)}, 1, false, nil},
+		{"username reaches its limit", domain.LoginThrottle{Kind: domain.LoginThrottleUsername, Failures: domain.LoginUsernameLockoutFailures - 1, LastFailedAt: now}, domain.LoginUsernameLockoutFailures, true, &lockedUntil},
+		{"address below the username limit", domain.LoginThrottle{Kind: domain.LoginThrottleIP, Failures: domain.LoginUsernameLockoutFailures - 1, LastFailedAt: now}, domain.LoginUsernameLockoutFailures, false, nil},
+		{"address reaches its limit", domain.LoginThrottle{Kind: domain.LoginThrottleIP, Failures: domain.LoginIPLockoutFailures - 1, LastFailedAt: now}, domain.LoginIPLockoutFailures, true, &lockedUntil},
+		{"starts over after a lockout", domain.LoginThrottle{Kind: domain.LoginThrottleUsername, Failures: 10, LastFailedAt: now.Add(-time.Minute), LockedUntil: &expiredLock}, 1, false, nil},
+		{"an active lockout is not started again", domain.LoginThrottle{Kind: domain.LoginThrottleUsername, Failures: 10, LastFailedAt: now, LockedUntil: &activeLock}, 11, false, &activeLock},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			throttle := tt.throttle
+			lockout := countLoginAttempt(&throttle, now)
+			if lockout != tt.wantLockout {
+				t.Errorf("countLoginAttempt() = %v, want %v", lockout, tt.wantLockout)
+			}
+			if throttle.Failures != tt.wantFailures {
+				t.Errorf("failures = %d, want %d", throttle.Failures, tt.wantFailures)
+			}
+			if !throttle.LastFailedAt.Equal(now) {
+				t.Errorf("last failed at = %v, want %v", throttle.LastFailedAt, now)
+			}
+			switch {
+			case tt.wantLockedUntil == nil && throttle.LockedUntil != nil:
+				t.Errorf("locked until = %v, want no lockout", *throttle.LockedUntil)
+			case tt.wantLockedUntil != nil && (throttle.LockedUntil == nil || !throttle.LockedUntil.Equal(*tt.wantLockedUntil)):
+				t.Errorf("locked until = %v, want %v", throttle.LockedUntil, *tt.wantLockedUntil)
+			}
+		})
+	}
+}
@@ -2,10 +2,17 @@ package repository
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"notification/domain"
+	"strings"
+	"time"
+	"unicode"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type notificationRepo struct {
@@ -18,23 +25,211 @@ func NewNotificationRepository(db *gorm.DB) domain.NotificationRepo {
 	}
 }
 
-func (np *notificationRepo) GetAllAttendanceNotificationHistory(ctx context.Context) (*[]domain.AttendanceNotificationHistoryResponse, error) {
+// historyCursor marks the last row of a page, Value is the sort column of that row
+type historyCursor struct {
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+var attendanceHistorySortColumns = map[string]string{
+	"created_at":   "attendance_notification_histories.created_at",
+	"student_nsn":  "attendance_notification_histories.student_nsn",
+	"subject_code": "attendance_notification_histories.subject_code",
+}
+
+// attendanceHistoryQuery applies every filter in SQL, the class filter joins students
+func (np *notificationRepo) attendanceHistoryQuery(ctx context.Context, filter *domain.AttendanceHistoryFilter) *gorm.DB {
+	query := np.db.WithContext(ctx).Model(&domain.AttendanceNotificationHistory{})
+
+	if filter.From != nil {
+		query = query.Where("attendance_notification_histories.created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("attendance_notification_histories.created_at < ?", *filter.To)
+	}
+	if filter.Grade != 0 || filter.GradeLabel != "" {
+		query = query.Joins("JOIN students ON students.student_nsn = attendance_notification_histories.student_nsn")
+		if filter.Grade != 0 {
+			query = query.Where("students.grade = ?", filter.Grade)
+		}
+		if filter.GradeLabel != "" {
+			query = query.Where("students.grade_label = ?", filter.GradeLabel)
+		}
+	}
+	if filter.SubjectCode != "" {
+		query = query.Where("attendance_notification_histories.subject_code = ?", filter.SubjectCode)
+	}
+	if filter.StudentNSN != "" {
+		query = query.Where("attendance_notification_histories.student_nsn = ?", filter.StudentNSN)
+	}
+	if filter.UserID != 0 {
+		query = query.Where("attendance_notification_histories.user_id = ?", filter.UserID)
+	}
+	if filter.WhatsappDelivery != "" {
+		query = query.Where("attendance_notification_histories.whatsapp_delivery = ?", filter.WhatsappDelivery)
+	}
+	if filter.EmailDelivery != "" {
+		query = query.Where("attendance_notification_histories.email_delivery = ?", filter.EmailDelivery)
+	}
+
+	return query
+}
+
+// GetAllAttendanceNotificationHistory returns one page of history, pages are chained with the cursor of the last row
+func (np *notificationRepo) GetAllAttendanceNotificationHistory(ctx context.Context, filter *domain.AttendanceHistoryFilter) (*domain.AttendanceHistoryPage, error) {
 	var dataHolder []domain.AttendanceNotificationHistory
-	var finalDatas []domain.AttendanceNotificationHistoryResponse
 
-	// Query the attendance notification history
-	if err := np.db.WithContext(ctx).
+	sortBy := filter.SortBy
+	if sortBy == "" {
+		sortBy = "created_at"
+	}
+	column, ok := attendanceHistorySortColumns[sortBy]
+	if !ok {
+		return nil, fmt.Errorf("%w: cannot sort truancy history by %s", domain.ErrInvalidInput, sortBy)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = domain.AttendanceHistoryDefaultLimit
+	}
+	if limit > domain.AttendanceHistoryMaxLimit {
+		limit = domain.AttendanceHistoryMaxLimit
+	}
+
+	direction, comparator := "ASC", ">"
+	if filter.Descending {
+		direction, comparator = "DESC", "<"
+	}
+
+	query := np.attendanceHistoryQuery(ctx, filter)
+
+	if filter.Cursor != "" {
+		cursor, err := decodeHistoryCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		var value interface{} = cursor.Value
+		if sortBy == "created_at" {
+			value, err = time.Parse(time.RFC3339Nano, cursor.Value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid cursor", domain.ErrInvalidInput)
+			}
+		}
+
+		query = query.Where(
+			fmt.Sprintf("(%s %s ?) OR (%s = ? AND attendance_notification_histories.notification_history_id %s ?)", column, comparator, column, comparator),
+			value, value, cursor.ID,
+		)
+	}
+
+	// One extra row tells whether another page follows
+	err := query.
+		Select("attendance_notification_histories.*").
 		Preload("Student").
 		Preload("Parent").
 		Preload("User").
 		Preload("Subject").
-		Find(&dataHolder).Error; err != nil {
+		Preload("Replies", func(db *gorm.DB) *gorm.DB {
+			return db.Order("received_at ASC")
+		}).
+		Order(fmt.Sprintf("%s %s, attendance_notification_histories.notification_history_id %s", column, direction, direction)).
+		Limit(limit + 1).
+		Find(&dataHolder).Error
+	if err != nil {
 		return nil, fmt.Errorf("could not get all attendance notification history, error: %v", err)
 	}
 
+	page := domain.AttendanceHistoryPage{Items: []domain.AttendanceNotificationHistoryResponse{}}
+	if len(dataHolder) > limit {
+		dataHolder = dataHolder[:limit]
+		last := dataHolder[limit-1]
+
+		value := last.StudentNSN
+		switch sortBy {
+		case "created_at":
+			value = last.CreatedAt.Format(time.RFC3339Nano)
+		case "subject_code":
+			value = last.SubjectCode
+		}
+		page.NextCursor = encodeHistoryCursor(historyCursor{Value: value, ID: last.NotificationHistoryID})
+	}
+
 	// Iterate over the fetched records to prepare the response
+	// Rows whose student or subject is gone stay on the page with those fields empty, skipping them would shorten it
 	for _, record := range dataHolder {
-		if record.Student.StudentNSN == "" || record.Subject.SubjectCode == "" {
+		userResponse := domain.UserResponse{
+			UserID:    record.User.UserID,
+			Username:  record.User.Username,
+			Name:      record.User.Name,
+			Role:      record.User.Role,
+			CreatedAt: record.User.CreatedAt,
+			UpdatedAt: record.User.UpdatedAt,
+			DeletedAt: record.User.DeletedAt,
+		}
+
+		// Append to final response slice
+		page.Items = append(page.Items, domain.AttendanceNotificationHistoryResponse{
+			NotificationHistoryID: record.NotificationHistoryID,
+			Student:               record.Student,
+			Parent:                record.Parent,
+			User:                  userResponse,
+			Subject:               record.Subject,
+			WhatsappStatus:        record.WhatsappStatus,
+			WhatsappDelivery:      record.WhatsappDelivery,
+			WhatsappError:         record.WhatsappError,
+			EmailStatus:           record.EmailStatus,
+			EmailDelivery:         record.EmailDelivery,
+			EmailError:            record.EmailError,
+			Attempts:              record.Attempts,
+			Replies:               record.Replies,
+			CreatedAt:             record.CreatedAt,
+			UpdatedAt:             record.UpdatedAt,
+		})
+	}
+
+	return &page, nil
+}
+
+func encodeHistoryCursor(cursor historyCursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeHistoryCursor(encoded string) (*historyCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid cursor", domain.ErrInvalidInput)
+	}
+
+	var cursor historyCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil || cursor.ID == 0 {
+		return nil, fmt.Errorf("%w: invalid cursor", domain.ErrInvalidInput)
+	}
+
+	return &cursor, nil
+}
+
+// GetAllExamResultNotificationHistory lists exam result notices, examID 0 returns every exam
+func (np *notificationRepo) GetAllExamResultNotificationHistory(ctx context.Context, examID int) (*[]domain.ExamResultNotificationHistoryResponse, error) {
+	var dataHolder []domain.ExamResultNotificationHistory
+	var finalDatas []domain.ExamResultNotificationHistoryResponse
+
+	query := np.db.WithContext(ctx).
+		Preload("Exam").
+		Preload("Student").
+		Preload("Parent").
+		Preload("User")
+	if examID != 0 {
+		query = query.Where("exam_id = ?", examID)
+	}
+
+	if err := query.Order("created_at DESC").Find(&dataHolder).Error; err != nil {
+		return nil, fmt.Errorf("could not get all exam result notification history, error: %v", err)
+	}
+
+	for _, record := range dataHolder {
+		if record.Student.StudentNSN == "" || record.Exam.ExamID == 0 {
 			continue
 		}
 
@@ -48,17 +243,123 @@ func (np *notificationRepo) GetAllAttendanceNotificationHistory(ctx context.Cont
 			DeletedAt: record.User.DeletedAt,
 		}
 
-		// Append to final response slice
-		finalDatas = append(finalDatas, domain.AttendanceNotificationHistoryResponse{
-			Student:        record.Student,
-			Parent:         record.Parent,
-			User:           userResponse,
-			Subject:        record.Subject,
-			WhatsappStatus: record.WhatsappStatus,
-			EmailStatus:    record.EmailStatus,
-			CreatedAt:      record.CreatedAt,
+		finalDatas = append(finalDatas, domain.ExamResultNotificationHistoryResponse{
+			ExamResultHistoryID: record.ExamResultHistoryID,
+			Exam:                record.Exam,
+			Student:             record.Student,
+			Parent:              record.Parent,
+			User:                userResponse,
+			WhatsappStatus:      record.WhatsappStatus,
+			WhatsappReason:      record.WhatsappReason,
+			EmailStatus:         record.EmailStatus,
+			EmailReason:         record.EmailReason,
+			CreatedAt:           record.CreatedAt,
 		})
 	}
 
 	return &finalDatas, nil
 }
+
+func (np *notificationRepo) SaveParentReply(ctx context.Context, reply *domain.ParentReply) error {
+	localTelephone := normalizeTelephone(reply.SenderTelephone)
+	reply.SenderTelephone = localTelephone
+
+	// Match the sender to a registered parent, parents may be stored in either local or international format.
+	// Guardians of several families can share a number, the reply goes to the one that was notified last.
+	var parentIDs []int
+	err := np.db.WithContext(ctx).Model(&domain.Parent{}).
+		Where("telephone IN ? AND deleted_at IS NULL", []string{localTelephone, internationalTelephone(localTelephone)}).
+		Order("parent_id").
+		Pluck("parent_id", &parentIDs).Error
+	if err != nil {
+		return fmt.Errorf("could not match reply sender to parent: %v", err)
+	}
+
+	if len(parentIDs) > 0 {
+		reply.ParentID = &parentIDs[0]
+
+		// Link the reply to the most recent notice any of these parents received
+		var latest domain.AttendanceNotificationHistory
+		err = np.db.WithContext(ctx).
+			Where("parent_id IN ? AND created_at <= ?", parentIDs, reply.ReceivedAt).
+			Order("created_at DESC, notification_history_id DESC").
+			First(&latest).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("could not find notification history for reply: %v", err)
+		}
+		if err == nil {
+			reply.ParentID = &latest.ParentID
+			reply.NotificationHistoryID = &latest.NotificationHistoryID
+		}
+	}
+
+	// WhatsApp may redeliver the same message after a reconnect, keep the first copy only
+	err = np.db.WithContext(ctx).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "message_id"}}, DoNothing: true}).
+		Create(reply).Error
+	if err != nil {
+		return fmt.Errorf("could not save parent reply: %v", err)
+	}
+
+	return nil
+}
+
+func (np *notificationRepo) GetAllParentReplies(ctx context.Context, unreadOnly bool) (*[]domain.ParentReply, error) {
+	var replies []domain.ParentReply
+
+	query := np.db.WithContext(ctx).Preload("Parent")
+	if unreadOnly {
+		query = query.Where("is_read IS FALSE")
+	}
+
+	if err := query.Order("received_at DESC").Find(&replies).Error; err != nil {
+		return nil, fmt.Errorf("could not get parent replies: %v", err)
+	}
+
+	return &replies, nil
+}
+
+func (np *notificationRepo) MarkParentReplyRead(ctx context.Context, replyID int) error {
+	result := np.db.WithContext(ctx).
+		Model(&domain.ParentReply{}).
+		Where("reply_id = ?", replyID).
+		Update("is_read", true)
+
+	if result.Error != nil {
+		return fmt.Errorf("failed to mark reply %d as read: %w", replyID, result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: no parent reply found with reply id %d", domain.ErrNotFound, replyID)
+	}
+
+	return nil
+}
+
+// normalizeTelephone converts a WhatsApp number (628xxx, +62 8xxx, 08xxx) to the local 08xxx format used in the parent table
+func normalizeTelephone(raw string) string {
+	var digits strings.Builder
+	for _, r := range raw {
+		if unicode.IsDigit(r) {
+			digits.WriteRune(r)
+		}
+	}
+
+	tel := digits.String()
+	switch {
+	case strings.HasPrefix(tel, "62"):
+		return "0" + tel[2:]
+	case strings.HasPrefix(tel, "8"):
+		return "0" + tel
+	default:
+		return tel
+	}
+}
+
+// internationalTelephone converts a local 08xxx number to the 628xxx format expected by WhatsApp
+func internationalTelephone(local string) string {
+	if strings.HasPrefix(local, "0") {
+		return "62" + local[1:]
+	}
+	return local
+}
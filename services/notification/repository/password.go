@@ -0,0 +1,275 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/url"
+	"notification/config"
+	"notification/domain"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errInvalidResetCode = fmt.Errorf("%w: invalid or expired code", domain.ErrInvalidInput)
+
+// ChangePassword replaces the password of the user after checking the current one.
+// Every other session is logged out, the response carries the tokens of a fresh session.
+func (ar *authRepository) ChangePassword(ctx context.Context, userID int, req *domain.ChangePasswordRequest) (*domain.LoginResponse, *[]string, error) {
+	var user domain.User
+	err := ar.db.WithContext(ctx).Where("user_id = ? AND deleted_at IS NULL", userID).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, fmt.Errorf("user not found")
+		}
+		return nil, nil, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)); err != nil {
+		return nil, &[]string{"current password is incorrect"}, nil
+	}
+
+	errList := config.GetPasswordPolicy().Validate(req.NewPassword)
+	if req.NewPassword == req.CurrentPassword {
+		errList = append(errList, "new password must be different from the current password")
+	}
+	if len(errList) > 0 {
+		return nil, &errList, nil
+	}
+
+	var response *domain.LoginResponse
+	err = ar.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := setPassword(ctx, tx, &user, req.NewPassword); err != nil {
+			return err
+		}
+
+		response, err = openSession(tx, &user, req.UserAgent, req.IP)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return response, nil, nil
+}
+
+// RequestPasswordReset sends the staff member a one-time code, channel may be empty to pick whichever contact they have.
+// It returns the channel the code went out on and whether it was sent or queued for WhatsApp to come back.
+func (ar *authRepository) RequestPasswordReset(ctx context.Context, userID int, channel string) (*domain.PasswordResetDelivery, error) {
+	var user domain.User
+	err := ar.db.WithContext(ctx).Where("user_id = ? AND deleted_at IS NULL", userID).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: staff not found", domain.ErrNotFound)
+		}
+		return nil, err
+	}
+
+	hasEmail := user.Email != nil && *user.Email != ""
+	hasTelephone := user.Telephone != nil && *user.Telephone != ""
+
+	switch strings.ToLower(strings.TrimSpace(channel)) {
+	case "":
+		if hasEmail {
+			channel = domain.PasswordResetChannelEmail
+		} else if hasTelephone {
+			channel = domain.PasswordResetChannelWhatsapp
+		} else {
+			return nil, fmt.Errorf("%w: staff has neither an email nor a telephone number", domain.ErrInvalidInput)
+		}
+	case domain.PasswordResetChannelEmail:
+		if !hasEmail {
+			return nil, fmt.Errorf("%w: staff has no email", domain.ErrInvalidInput)
+		}
+		channel = domain.PasswordResetChannelEmail
+	case domain.PasswordResetChannelWhatsapp:
+		if !hasTelephone {
+			return nil, fmt.Errorf("%w: staff has no telephone number", domain.ErrInvalidInput)
+		}
+		channel = domain.PasswordResetChannelWhatsapp
+	default:
+		return nil, fmt.Errorf("%w: channel must be %s or %s", domain.ErrInvalidInput, domain.PasswordResetChannelEmail, domain.PasswordResetChannelWhatsapp)
+	}
+
+	code, err := resetCode()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate reset code, err : %v", err)
+	}
+	codeHash := hashToken(code)
+
+	err = ar.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		// Only the newest code can be used
+		err := tx.Model(&domain.PasswordReset{}).
+			Where("user_id = ? AND used_at IS NULL AND expires_at > ?", user.UserID, now).
+			Update("expires_at", now).Error
+		if err != nil {
+			return fmt.Errorf("could not expire earlier reset codes: %w", err)
+		}
+
+		reset := domain.PasswordReset{
+			CodeHash:  codeHash,
+			UserID:    user.UserID,
+			Channel:   channel,
+			ExpiresAt: now.Add(domain.PasswordResetTTL),
+		}
+		if claims, ok := ctx.Value("user").(*domain.Claims); ok && claims != nil {
+			reset.RequestedBy = &claims.UserID
+		}
+		if err := tx.Create(&reset).Error; err != nil {
+			return fmt.Errorf("could not store reset code: %w", err)
+		}
+
+		return recordAudit(ctx, tx, domain.AuditActionPasswordReset, domain.AuditEntityStaff, strconv.Itoa(user.UserID), nil, map[string]interface{}{"channel": channel})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	delivery := &domain.PasswordResetDelivery{Channel: channel, Status: domain.DeliveryStatusSent}
+	subject, body := passwordResetMessage(user.Name, code)
+	if channel == domain.PasswordResetChannelEmail {
+		err = ar.sendResetEmail(*user.Email, subject, body)
+	} else {
+		err = sendOrQueueWhatsapp(ctx, ar.db, ar.meowClient.Client(), whatsappNumber(*user.Telephone), body)
+		if errors.Is(err, errWhatsappQueued) {
+			delivery.Status = domain.DeliveryStatusQueued
+			err = nil
+		}
+	}
+	if err != nil {
+		// A code nobody received must not stay usable
+		if expireErr := ar.db.WithContext(ctx).Model(&domain.PasswordReset{}).
+			Where("code_hash = ?", codeHash).
+			Update("expires_at", time.Now()).Error; expireErr != nil {
+			config.Logger(ctx).WithError(expireErr).WithField("user_id", user.UserID).Error("could not expire undelivered reset code")
+		}
+		return nil, fmt.Errorf("failed to send reset code: %w", err)
+	}
+
+	return delivery, nil
+}
+
+// ResetPassword sets a new password with a code from RequestPasswordReset, the code can only be used once
+func (ar *authRepository) ResetPassword(ctx context.Context, req *domain.ResetPasswordRequest) (*[]string, error) {
+	if errList := config.GetPasswordPolicy().Validate(req.NewPassword); len(errList) > 0 {
+		return &errList, nil
+	}
+
+	err := ar.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var reset domain.PasswordReset
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("User").
+			Where("code_hash = ?", hashToken(strings.ToUpper(strings.TrimSpace(req.Code)))).
+			First(&reset).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errInvalidResetCode
+			}
+			return fmt.Errorf("could not read reset code: %w", err)
+		}
+
+		now := time.Now()
+		if reset.UsedAt != nil || now.After(reset.ExpiresAt) || reset.User.DeletedAt != nil {
+			return errInvalidResetCode
+		}
+
+		if err := tx.Model(&reset).Update("used_at", now).Error; err != nil {
+			return fmt.Errorf("could not spend reset code: %w", err)
+		}
+
+		return setPassword(ctx, tx, &reset.User, req.NewPassword)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// setPassword stores a new password for user, clears the first-login flag and logs every session out
+func setPassword(ctx context.Context, tx *gorm.DB, user *domain.User, password string) error {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password, err : %v", err)
+	}
+
+	before := staffAuditRecord(user)
+	now := time.Now()
+	err = tx.Model(&domain.User{}).
+		Where("user_id = ?", user.UserID).
+		Updates(map[string]interface{}{
+			"password":             string(hashed),
+			"must_change_password": false,
+			"password_changed_at":  now,
+		}).Error
+	if err != nil {
+		return fmt.Errorf("could not update password: %w", err)
+	}
+	user.Password = string(hashed)
+	user.MustChangePassword = false
+	user.PasswordChangedAt = &now
+
+	if err := revokeUserSessions(tx, user.UserID); err != nil {
+		return err
+	}
+
+	return recordAudit(ctx, tx, domain.AuditActionUpdate, domain.AuditEntityStaff, strconv.Itoa(user.UserID), before, staffAuditRecord(user))
+}
+
+// resetCode is short enough to type from a phone, 10 characters of base32
+func resetCode() (string, error) {
+	random := make([]byte, 6)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(random), nil
+}
+
+// passwordResetMessage links to PASSWORD_RESET_URL when it is set, otherwise only the code is sent
+func passwordResetMessage(name, code string) (string, string) {
+	minutes := int(domain.PasswordResetTTL.Minutes())
+	link := ""
+	if base := os.Getenv("PASSWORD_RESET_URL"); base != "" {
+		link = base + "?code=" + url.QueryEscape(code)
+	}
+
+	if strings.ToLower(os.Getenv("MESSENGER_LANGUAGE")) == "ind" {
+		body := fmt.Sprintf("Halo %s,\n\nAdmin SINOAN telah mengatur ulang kata sandi Anda. Kode Anda: %s\n", name, code)
+		if link != "" {
+			body += fmt.Sprintf("Atur kata sandi baru melalui tautan berikut: %s\n", link)
+		}
+		body += fmt.Sprintf("Kode berlaku selama %d menit dan hanya dapat digunakan sekali. Abaikan pesan ini jika Anda tidak memintanya.", minutes)
+		return "Atur Ulang Kata Sandi SINOAN", body
+	}
+
+	body := fmt.Sprintf("Hello %s,\n\nA SINOAN admin has reset your password. Your code: %s\n", name, code)
+	if link != "" {
+		body += fmt.Sprintf("Set a new password here: %s\n", link)
+	}
+	body += fmt.Sprintf("The code is valid for %d minutes and can be used once. Ignore this message if you did not ask for it.", minutes)
+	return "SINOAN Password Reset", body
+}
+
+func (ar *authRepository) sendResetEmail(recipient, subject, body string) error {
+	msg := "From: " + ar.emailSender + "\r\n" +
+		"To: " + recipient + "\r\n" +
+		"Message-ID: " + newMessageID(ar.emailSender) + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + body
+
+	if err := ar.mailer.Send(ar.emailSender, []string{recipient}, []byte(msg)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
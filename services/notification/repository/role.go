@@ -0,0 +1,328 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"notification/domain"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// rolePermissionCacheTTL bounds how long another instance may keep serving permissions a role no longer has
+const rolePermissionCacheTTL = 30 * time.Second
+
+var roleNameRegex = regexp.MustCompile(`^[a-z][a-z_]{1,29}$`)
+
+type roleRepository struct {
+	db *gorm.DB
+
+	mu          sync.RWMutex
+	permissions map[string]map[string]bool
+	loadedAt    time.Time
+}
+
+func NewRoleRepository(db *gorm.DB) domain.RoleRepo {
+	return &roleRepository{
+		db: db,
+	}
+}
+
+func (rr *roleRepository) GetAllRoles(ctx context.Context) (*[]domain.Role, error) {
+	roles := []domain.Role{}
+	if err := rr.db.WithContext(ctx).Order("built_in DESC, name").Find(&roles).Error; err != nil {
+		return nil, fmt.Errorf("could not get roles: %w", err)
+	}
+
+	var grants []domain.RolePermission
+	if err := rr.db.WithContext(ctx).Order("permission").Find(&grants).Error; err != nil {
+		return nil, fmt.Errorf("could not get role permissions: %w", err)
+	}
+	byRole := map[string][]string{}
+	for _, grant := range grants {
+		byRole[grant.RoleName] = append(byRole[grant.RoleName], grant.Permission)
+	}
+
+	for i := range roles {
+		roles[i].Permissions = rolePermissionList(roles[i].Name, byRole[roles[i].Name])
+	}
+
+	return &roles, nil
+}
+
+func (rr *roleRepository) GetRole(ctx context.Context, name string) (*domain.Role, error) {
+	return findRole(ctx, rr.db, name)
+}
+
+func (rr *roleRepository) CreateRole(ctx context.Context, req *domain.RoleRequest) (*domain.Role, error) {
+	name := strings.ToLower(strings.TrimSpace(req.Name))
+	if !roleNameRegex.MatchString(name) {
+		return nil, fmt.Errorf("%w: role name must be 2 to 30 lower case letters or underscores", domain.ErrInvalidInput)
+	}
+	permissions, err := validRolePermissions(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	role := domain.Role{
+		Name:        name,
+		Description: strings.TrimSpace(req.Description),
+	}
+	err = rr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Model(&domain.Role{}).Where("name = ?", name).Count(&existing).Error; err != nil {
+			return fmt.Errorf("could not check role: %w", err)
+		}
+		if existing > 0 {
+			return fmt.Errorf("%w: role %s already exists", domain.ErrConflict, name)
+		}
+
+		if err := tx.Create(&role).Error; err != nil {
+			return fmt.Errorf("could not create role: %w", err)
+		}
+		if err := grantPermissions(tx, name, permissions); err != nil {
+			return err
+		}
+
+		role.Permissions = permissions
+		return recordAudit(ctx, tx, domain.AuditActionCreate, domain.AuditEntityRole, name, nil, role)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rr.invalidate()
+	return &role, nil
+}
+
+// UpdateRole replaces the description and permissions of a role, admin can not be modified
+func (rr *roleRepository) UpdateRole(ctx context.Context, name string, req *domain.RoleRequest) (*domain.Role, error) {
+	if name == domain.RoleAdmin {
+		return nil, fmt.Errorf("%w: admin always holds every permission and can not be modified", domain.ErrConflict)
+	}
+	permissions, err := validRolePermissions(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	var role *domain.Role
+	err = rr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		before, err := findRole(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&domain.Role{}).Where("name = ?", name).Updates(map[string]interface{}{
+			"description": strings.TrimSpace(req.Description),
+			"updated_at":  time.Now(),
+		}).Error
+		if err != nil {
+			return fmt.Errorf("could not update role: %w", err)
+		}
+
+		if err := tx.Where("role_name = ?", name).Delete(&domain.RolePermission{}).Error; err != nil {
+			return fmt.Errorf("could not clear role permissions: %w", err)
+		}
+		if err := grantPermissions(tx, name, permissions); err != nil {
+			return err
+		}
+
+		role, err = findRole(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, domain.AuditActionUpdate, domain.AuditEntityRole, name, before, role)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rr.invalidate()
+	return role, nil
+}
+
+// DeleteRole removes a role nobody holds, built-in roles can not be deleted
+func (rr *roleRepository) DeleteRole(ctx context.Context, name string) error {
+	err := rr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		role, err := findRole(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+		if role.BuiltIn {
+			return fmt.Errorf("%w: built-in role %s can not be deleted", domain.ErrConflict, name)
+		}
+
+		var holders int64
+		if err := tx.Model(&domain.User{}).Where("role = ? AND deleted_at IS NULL", name).Count(&holders).Error; err != nil {
+			return fmt.Errorf("could not check role holders: %w", err)
+		}
+		if holders > 0 {
+			return fmt.Errorf("%w: role %s is still assigned to %d staff", domain.ErrConflict, name, holders)
+		}
+
+		if err := tx.Where("name = ?", name).Delete(&domain.Role{}).Error; err != nil {
+			return fmt.Errorf("could not delete role: %w", err)
+		}
+		return recordAudit(ctx, tx, domain.AuditActionDelete, domain.AuditEntityRole, name, role, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	rr.invalidate()
+	return nil
+}
+
+// HasPermission answers from a cache of every role's permissions, refreshed after rolePermissionCacheTTL or a change
+func (rr *roleRepository) HasPermission(ctx context.Context, role, permission string) (bool, error) {
+	if role == domain.RoleAdmin {
+		return true, nil
+	}
+
+	rr.mu.RLock()
+	permissions, loadedAt := rr.permissions, rr.loadedAt
+	rr.mu.RUnlock()
+
+	if permissions == nil || time.Since(loadedAt) > rolePermissionCacheTTL {
+		var grants []domain.RolePermission
+		if err := rr.db.WithContext(ctx).Find(&grants).Error; err != nil {
+			return false, fmt.Errorf("could not load role permissions: %w", err)
+		}
+
+		permissions = permissionsByRole(grants)
+
+		rr.mu.Lock()
+		rr.permissions, rr.loadedAt = permissions, time.Now()
+		rr.mu.Unlock()
+	}
+
+	return permissions[role][permission], nil
+}
+
+// permissionsByRole indexes grants by role and then permission
+func permissionsByRole(grants []domain.RolePermission) map[string]map[string]bool {
+	permissions := map[string]map[string]bool{}
+	for _, grant := range grants {
+		if permissions[grant.RoleName] == nil {
+			permissions[grant.RoleName] = map[string]bool{}
+		}
+		permissions[grant.RoleName][grant.Permission] = true
+	}
+	return permissions
+}
+
+func (rr *roleRepository) invalidate() {
+	rr.mu.Lock()
+	rr.permissions = nil
+	rr.mu.Unlock()
+}
+
+func findRole(ctx context.Context, db *gorm.DB, name string) (*domain.Role, error) {
+	var role domain.Role
+	if err := db.WithContext(ctx).Where("name = ?", name).First(&role).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: role %s", domain.ErrNotFound, name)
+		}
+		return nil, fmt.Errorf("could not get role: %w", err)
+	}
+
+	var permissions []string
+	err := db.WithContext(ctx).Model(&domain.RolePermission{}).
+		Where("role_name = ?", name).
+		Order("permission").
+		Pluck("permission", &permissions).Error
+	if err != nil {
+		return nil, fmt.Errorf("could not get role permissions: %w", err)
+	}
+	role.Permissions = rolePermissionList(name, permissions)
+
+	return &role, nil
+}
+
+// roleHasPermission is for the checks that scope data rather than routes, it reads the database every time
+func roleHasPermission(ctx context.Context, db *gorm.DB, role, permission string) (bool, error) {
+	if role == domain.RoleAdmin {
+		return true, nil
+	}
+
+	var count int64
+	err := db.WithContext(ctx).Model(&domain.RolePermission{}).
+		Where("role_name = ? AND permission = ?", role, permission).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("could not check role permission: %w", err)
+	}
+	return count > 0, nil
+}
+
+// rolePermissionList lists every permission for admin and never returns nil
+func rolePermissionList(role string, permissions []string) []string {
+	if role == domain.RoleAdmin {
+		permissions = make([]string, 0, len(domain.Permissions))
+		for _, permission := range domain.Permissions {
+			permissions = append(permissions, permission.Name)
+		}
+		sort.Strings(permissions)
+	}
+	if permissions == nil {
+		return []string{}
+	}
+	return permissions
+}
+
+func validRolePermissions(requested []string) ([]string, error) {
+	seen := map[string]bool{}
+	permissions := []string{}
+	for _, permission := range requested {
+		permission = strings.TrimSpace(permission)
+		if !domain.IsPermission(permission) {
+			return nil, fmt.Errorf("%w: unknown permission %q", domain.ErrInvalidInput, permission)
+		}
+		if !seen[permission] {
+			seen[permission] = true
+			permissions = append(permissions, permission)
+		}
+	}
+	sort.Strings(permissions)
+	return permissions, nil
+}
+
+func grantPermissions(tx *gorm.DB, role string, permissions []string) error {
+	if len(permissions) == 0 {
+		return nil
+	}
+
+	grants := make([]domain.RolePermission, 0, len(permissions))
+	for _, permission := range permissions {
+		grants = append(grants, domain.RolePermission{RoleName: role, Permission: permission})
+	}
+	if err := tx.Omit("Role").Create(&grants).Error; err != nil {
+		return fmt.Errorf("could not grant permissions: %w", err)
+	}
+	return nil
+}
+
+// staffRole checks the role given to a staff member, empty falls back to staff and admin can not be handed out
+func staffRole(ctx context.Context, db *gorm.DB, role string) (string, error) {
+	role = strings.ToLower(strings.TrimSpace(role))
+	if role == "" {
+		return domain.RoleStaff, nil
+	}
+	if role == domain.RoleAdmin {
+		return "", fmt.Errorf("staff can not be given the admin role")
+	}
+
+	var count int64
+	if err := db.WithContext(ctx).Model(&domain.Role{}).Where("name = ?", role).Count(&count).Error; err != nil {
+		return "", fmt.Errorf("could not check role: %w", err)
+	}
+	if count == 0 {
+		return "", fmt.Errorf("role %s not found", role)
+	}
+	return role, nil
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"notification/domain"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHasPermission(t *testing.T) {
+	grants := []domain.RolePermission{
+		{RoleName: domain.RoleStaff, Permission: domain.PermissionSendAbsence},
+		{RoleName: domain.RoleStaff, Permission: domain.PermissionInputScores},
+		{RoleName: domain.RolePrincipal, Permission: domain.PermissionAllSubjects},
+	}
+	// A fresh cache keeps the lookups away from the database
+	rr := &roleRepository{permissions: permissionsByRole(grants), loadedAt: time.Now()}
+
+	tests := []struct {
+		name       string
+		role       string
+		permission string
+		want       bool
+	}{
+		{"admin holds every permission", domain.RoleAdmin, domain.PermissionManageRoles, true},
+		{"admin holds permissions nobody was granted", domain.RoleAdmin, domain.PermissionAllSubjects, true},
+		{"granted permission", domain.RoleStaff, domain.PermissionInputScores, true},
+		{"permission of another role", domain.RoleStaff, domain.PermissionAllSubjects, false},
+		{"permission granted to nobody", domain.RolePrincipal, domain.PermissionManageStaff, false},
+		{"unknown role", "janitor", domain.PermissionSendAbsence, false},
+		{"empty role", "", domain.PermissionSendAbsence, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rr.HasPermission(context.Background(), tt.role, tt.permission)
+			if err != nil {
+				t.Fatalf("HasPermission() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("HasPermission(%q, %q) = %v, want %v", tt.role, tt.permission, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidRolePermissions(t *testing.T) {
+	got, err := validRolePermissions([]string{" " + domain.PermissionViewHistory, domain.PermissionSendAbsence, domain.PermissionViewHistory})
+	if err != nil {
+		t.Fatalf("validRolePermissions() error = %v", err)
+	}
+	if want := []string{domain.PermissionSendAbsence, domain.PermissionViewHistory}; !reflect.DeepEqual(got, want) {
+		t.Errorf("validRolePermissions() = %v, want %v", got, want)
+	}
+
+	if got, err := validRolePermissions(nil); err != nil || got == nil || len(got) != 0 {
+		t.Errorf("validRolePermissions(nil) = %v, %v, want an empty list", got, err)
+	}
+
+	if _, err := validRolePermissions([]string{domain.PermissionSendAbsence, "launch_rockets"}); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("validRolePermissions() error = %v, want it to wrap %v", err, domain.ErrInvalidInput)
+	}
+}
@@ -2,38 +2,41 @@ package repository
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"net/smtp"
+	"net/url"
+	"notification/config"
 	"notification/domain"
+	"notification/metrics"
+	"notification/middleware"
 	"os"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"go.mau.fi/whatsmeow"
-	"go.mau.fi/whatsmeow/proto/waE2E"
-	"go.mau.fi/whatsmeow/types"
+	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // init var
 type senderRepository struct {
 	db          *gorm.DB
-	client      smtp.Auth
+	mailer      domain.Mailer
 	emailSender string
 	schoolPhone string
-	smtpAdress  string
-	meowClient  *whatsmeow.Client
+	meowClient  domain.WhatsappClientProvider
 }
 
-func NewSenderRepository(db *gorm.DB, client smtp.Auth, smtpAddress, schoolPhone, emailSender string, meow *whatsmeow.Client) domain.SenderRepo {
+func NewSenderRepository(db *gorm.DB, mailer domain.Mailer, schoolPhone, emailSender string, meow domain.WhatsappClientProvider) domain.SenderRepo {
 	return &senderRepository{
 		db:          db,
-		client:      client,
+		mailer:      mailer,
 		emailSender: emailSender,
 		schoolPhone: schoolPhone,
-		smtpAdress:  smtpAddress,
 		meowClient:  meow,
 	}
 }
@@ -181,39 +184,60 @@ Tim SINOAN`, m.schoolPhone)
 	}
 }
 
-func (m *senderRepository) SendTestScores(ctx context.Context, examType string) error {
+func (m *senderRepository) SendTestScores(ctx context.Context, examID int, userID int, dryRun bool, resend bool) (*domain.ExamBroadcastReport, error) {
+	exam, err := findExam(ctx, m.db, examID)
+	if err != nil {
+		return nil, err
+	}
+
+	recipients, err := m.examResultRecipients(ctx, examID)
+	if err != nil {
+		return nil, err
+	}
+
+	work := make([]examResultWork, 0, len(recipients))
+	for _, recipient := range recipients {
+		work = append(work, examResultWork{score: recipient, channels: []string{domain.ChannelEmail, domain.ChannelWhatsapp}})
+	}
+
+	// A preview leaves no broadcast state behind so the real broadcast can follow
+	if dryRun {
+		results, err := m.deliverExamResults(ctx, exam, userID, 0, work, true)
+		return &domain.ExamBroadcastReport{Results: results}, err
+	}
+
+	broadcast, err := m.startExamBroadcast(ctx, exam.ExamID, userID, work, resend)
+	if err != nil {
+		return nil, err
+	}
+
+	results, sendErr := m.deliverExamResults(ctx, exam, userID, broadcast.BroadcastID, work, false)
+	return m.finishExamBroadcast(ctx, broadcast.BroadcastID, results, sendErr)
+}
+
+// examResultWork is one guardian of one student and the channels still to be sent to
+type examResultWork struct {
+	score    domain.IndividualExamScore
+	channels []string
+}
+
+// examResultRecipients builds the exam result of every student, one entry per guardian receiving exam results
+func (m *senderRepository) examResultRecipients(ctx context.Context, examID int) ([]domain.IndividualExamScore, error) {
 	var testScores []domain.TestScore
 	var students []domain.Student
 	var resultsMap = make(map[string]domain.IndividualExamScore)
-	langValue := os.Getenv("MESSENGER_LANGUAGE")
-	langValueLowered := strings.ToLower(langValue)
-	var examTypeProcessed string
-
-	// Process exam type based on language
-	if langValueLowered == "ind" {
-		switch examType {
-		case "Midterm Tests":
-			examTypeProcessed = "Ulangan Tengah Semester (UTS)"
-		case "End of Semester Tests":
-			examTypeProcessed = "Ulangan Akhir Semester (UAS)"
-		default:
-			examTypeProcessed = examType
-		}
-	} else {
-		examTypeProcessed = examType
-	}
 
-	// Fetch all test scores with related data
+	// Fetch the test scores of this exam with related data
 	err := m.db.WithContext(ctx).
 		Preload("Student").
 		Preload("Subject").
 		Preload("User", func(db *gorm.DB) *gorm.DB {
 			return db.Select("user_id", "username", "name", "role", "created_at", "updated_at", "deleted_at")
 		}).
-		Where("deleted_at IS NULL").
+		Where("exam_id = ? AND deleted_at IS NULL", examID).
 		Find(&testScores).Error
 	if err != nil {
-		return fmt.Errorf("failed to fetch test scores: %w", err)
+		return nil, fmt.Errorf("failed to fetch test scores: %w", err)
 	}
 
 	// Extract student IDs from test scores
@@ -225,10 +249,11 @@ func (m *senderRepository) SendTestScores(ctx context.Context, examType string)
 	// Fetch all students associated with the test scores
 	err = m.db.WithContext(ctx).
 		Preload("Parent").
+		Preload("Guardians.Parent").
 		Where("student_nsn IN (?)", studentIDs).
 		Find(&students).Error
 	if err != nil {
-		return fmt.Errorf("failed to fetch students: %w", err)
+		return nil, fmt.Errorf("failed to fetch students: %w", err)
 	}
 
 	// Build a map of students for quick lookup
@@ -272,48 +297,91 @@ func (m *senderRepository) SendTestScores(ctx context.Context, examType string)
 		resultsMap[student.StudentNSN] = individual
 	}
 
-	// Convert the resultsMap to a slice
+	// Convert the resultsMap to a slice, one entry per guardian receiving exam results
 	results := make([]domain.IndividualExamScore, 0, len(resultsMap))
 	for _, result := range resultsMap {
-		results = append(results, result)
+		for _, guardian := range guardianRecipients(result.Student, result.Student.Parent, domain.NotificationTypeExamResult) {
+			perGuardian := result
+			perGuardian.Student.Parent = guardian
+			results = append(results, perGuardian)
+		}
 	}
 
+	return results, nil
+}
+
+// deliverExamResults sends every work item on its channels, broadcastID 0 means no send state is kept
+func (m *senderRepository) deliverExamResults(ctx context.Context, exam *domain.Exam, userID, broadcastID int, work []examResultWork, dryRun bool) ([]domain.DeliveryResult, error) {
+	isIndonesian := strings.ToLower(os.Getenv("MESSENGER_LANGUAGE")) == "ind"
+	examTypeProcessed := examLabel(exam, isIndonesian)
+
 	// Worker pool to limit concurrency
 	const maxWorkers = 10 // Adjust based on system capacity
 	var wg sync.WaitGroup
+	var resultsMu sync.Mutex
+	var deliveryResults []domain.DeliveryResult
 	workerPool := make(chan struct{}, maxWorkers)
-	errChan := make(chan error, len(results)) // Channel to collect errors
+	errChan := make(chan error, 2*len(work)) // Channel to collect errors, at most one per channel
+
+	addResult := func(result domain.DeliveryResult) {
+		resultsMu.Lock()
+		deliveryResults = append(deliveryResults, result)
+		resultsMu.Unlock()
+	}
 
 	// Process results concurrently
-	for _, idv := range results {
+	for _, item := range work {
 		wg.Add(1)
 		workerPool <- struct{}{} // Acquire a worker slot
 
-		go func(idv domain.IndividualExamScore) {
+		go func(item examResultWork) {
 			defer wg.Done()
 			defer func() { <-workerPool }() // Release the worker slot
 
+			idv := item.score
 			var messageString string
-			if langValueLowered == "ind" {
+			if isIndonesian {
 				messageString = m.buatNilaiTesEmail(idv, examTypeProcessed)
 			} else {
 				messageString = m.createTestScoreEmail(idv, examTypeProcessed)
 			}
 
-			// Send email
-			if idv.Student.Parent.Email != nil && *idv.Student.Parent.Email != "" {
-				if err := m.sendEmailTestScore(&idv, messageString); err != nil {
-					errChan <- fmt.Errorf("failed to send email to %s: %w", *idv.Student.Parent.Email, err)
-					return
+			// Every guardian gets one history row holding the outcome of the channels tried
+			history := domain.ExamResultNotificationHistory{
+				ExamID:     exam.ExamID,
+				StudentNSN: idv.StudentNSN,
+				ParentID:   idv.Student.Parent.ParentID,
+				UserID:     userID,
+			}
+			var queuedOutbox *int
+
+			for _, channel := range item.channels {
+				var result domain.DeliveryResult
+				var err error
+				if channel == domain.ChannelEmail {
+					result, err = m.deliverExamResultEmail(ctx, idv, messageString, dryRun)
+				} else {
+					result, err = m.deliverExamResultWhatsapp(ctx, idv, messageString, dryRun)
+				}
+				if err != nil {
+					errChan <- err
+				}
+
+				addResult(result)
+				recordDeliveryMetric(domain.NotificationTypeExamResult, result)
+				recordExamResultOutcome(&history, result)
+				if result.OutboxID != nil {
+					queuedOutbox = result.OutboxID
+				}
+				if broadcastID != 0 {
+					m.updateExamBroadcastRecipient(ctx, broadcastID, result)
 				}
 			}
 
-			// Send WhatsApp
-			if err := m.sendWATestScore(ctx, &idv, messageString); err != nil {
-				errChan <- fmt.Errorf("failed to send WhatsApp for student %s: %w", idv.StudentNSN, err)
-				return
+			if !dryRun {
+				m.logExamResultHistory(ctx, &history, queuedOutbox)
 			}
-		}(idv)
+		}(item)
 	}
 
 	// Wait for all goroutines to finish
@@ -329,94 +397,259 @@ func (m *senderRepository) SendTestScores(ctx context.Context, examType string)
 	// Log all errors
 	if len(errors) > 0 {
 		for _, err := range errors {
-			fmt.Println("Error:", err)
+			config.Logger(ctx).WithError(err).WithField("exam_id", exam.ExamID).Error("exam result delivery failed")
 		}
-		return fmt.Errorf("encountered %d errors while sending test scores", len(errors))
+		return deliveryResults, fmt.Errorf("encountered %d errors while sending test scores", len(errors))
 	}
 
-	// Mark test scores as deleted
-	err = m.db.WithContext(ctx).
-		Model(&domain.TestScore{}).
-		Where("deleted_at IS NULL").
-		Updates(map[string]interface{}{
-			"deleted_at": time.Now(),
-		}).Error
+	return deliveryResults, nil
+}
+
+func (m *senderRepository) deliverExamResultEmail(ctx context.Context, idv domain.IndividualExamScore, messageString string, dryRun bool) (domain.DeliveryResult, error) {
+	parent := idv.Student.Parent
+	result := domain.DeliveryResult{StudentNSN: idv.StudentNSN, ParentID: parent.ParentID, Channel: domain.ChannelEmail}
+
+	if parent.Email == nil || *parent.Email == "" {
+		result.Status, result.Reason = domain.DeliveryStatusSkipped, domain.DeliveryReasonNoEmail
+		return result, nil
+	}
+
+	if parent.EmailInvalid {
+		result.Status, result.Reason = domain.DeliveryStatusSkipped, domain.DeliveryReasonEmailInvalid
+		return result, nil
+	}
 
+	suppressed, err := isSuppressedByPreference(ctx, m.db, parent.ParentID, domain.ChannelEmail, domain.NotificationTypeExamResult)
 	if err != nil {
-		return fmt.Errorf("failed to soft delete all test scores: %w", err)
+		result.Status, result.Reason = domain.DeliveryStatusFailed, err.Error()
+		return result, err
 	}
 
-	return nil
+	if suppressed {
+		result.Status, result.Reason = domain.DeliveryStatusSuppressed, domain.DeliveryReasonSuppressed
+		return result, nil
+	}
+
+	if dryRun {
+		subject, err := testScoreSubject(idv)
+		if err != nil {
+			result.Status, result.Reason = domain.DeliveryStatusFailed, err.Error()
+			return result, err
+		}
+		renderedBody := renderEmailBody(messageString, unsubscribePreviewLink())
+		result.Status, result.Recipient, result.Subject, result.Body = domain.DeliveryStatusWouldSend, *parent.Email, subject, renderedBody
+		return result, nil
+	}
+
+	if err := m.sendEmailTestScore(ctx, &idv, messageString); err != nil {
+		result.Status, result.Reason = domain.DeliveryStatusFailed, err.Error()
+		return result, fmt.Errorf("failed to send email to %s: %w", *parent.Email, err)
+	}
+
+	result.Status = domain.DeliveryStatusSent
+	return result, nil
 }
 
-func (m *senderRepository) SendMass(ctx context.Context, nsnList *[]string, userID *int, subjectCode string) error {
+func (m *senderRepository) deliverExamResultWhatsapp(ctx context.Context, idv domain.IndividualExamScore, messageString string, dryRun bool) (domain.DeliveryResult, error) {
+	parent := idv.Student.Parent
+	result := domain.DeliveryResult{StudentNSN: idv.StudentNSN, ParentID: parent.ParentID, Channel: domain.ChannelWhatsapp}
+
+	suppressed, err := isSuppressedByPreference(ctx, m.db, parent.ParentID, domain.ChannelWhatsapp, domain.NotificationTypeExamResult)
+	if err != nil {
+		result.Status, result.Reason = domain.DeliveryStatusFailed, err.Error()
+		return result, err
+	}
+
+	if suppressed {
+		result.Status, result.Reason = domain.DeliveryStatusSuppressed, domain.DeliveryReasonSuppressed
+		return result, nil
+	}
+
+	if dryRun {
+		result.Status, result.Recipient, result.Body = domain.DeliveryStatusWouldSend, whatsappNumber(parent.Telephone), messageString
+		return result, nil
+	}
+
+	if err := m.sendWATestScore(ctx, &idv, messageString); errors.Is(err, errWhatsappQueued) {
+		result.Status, result.Reason, result.OutboxID = domain.DeliveryStatusQueued, domain.DeliveryReasonQueued, queuedOutboxID(err)
+		return result, nil
+	} else if err != nil {
+		result.Status, result.Reason = domain.DeliveryStatusFailed, err.Error()
+		return result, fmt.Errorf("failed to send WhatsApp for student %s: %w", idv.StudentNSN, err)
+	}
+
+	result.Status = domain.DeliveryStatusSent
+	return result, nil
+}
+
+// recordExamResultOutcome copies one channel result onto the guardian's history row
+func recordExamResultOutcome(history *domain.ExamResultNotificationHistory, result domain.DeliveryResult) {
+	reason := result.Reason
+	if reason == "" && result.Status != domain.DeliveryStatusSent {
+		reason = result.Status
+	}
+
+	switch result.Channel {
+	case domain.ChannelEmail:
+		history.EmailStatus = result.Status == domain.DeliveryStatusSent
+		history.EmailReason = reason
+	case domain.ChannelWhatsapp:
+		history.WhatsappStatus = result.Status == domain.DeliveryStatusSent
+		history.WhatsappReason = reason
+	}
+}
+
+// logExamResultHistory stores the history row and links a queued WhatsApp message to it, so the flush can report its outcome
+func (m *senderRepository) logExamResultHistory(ctx context.Context, history *domain.ExamResultNotificationHistory, queuedOutbox *int) {
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(history).Error; err != nil {
+			return err
+		}
+		return linkOutboxToExamResultHistory(tx, queuedOutbox, history.ExamResultHistoryID)
+	})
+	if err != nil {
+		config.Logger(ctx).WithError(err).WithField("student_nsn", history.StudentNSN).Error("could not log exam result history")
+	}
+}
+
+// examLabel is how an exam is named in the notice sent to parents
+func examLabel(exam *domain.Exam, isIndonesian bool) string {
+	name := exam.Name
+	if isIndonesian {
+		switch exam.ExamType {
+		case domain.ExamTypeMidterm:
+			name = "Ulangan Tengah Semester (UTS)"
+		case domain.ExamTypeFinal:
+			name = "Ulangan Akhir Semester (UAS)"
+		}
+		return fmt.Sprintf("%s semester %d tahun ajaran %s", name, exam.Semester, exam.AcademicYear)
+	}
+
+	switch exam.ExamType {
+	case domain.ExamTypeMidterm:
+		name = "Midterm Tests"
+	case domain.ExamTypeFinal:
+		name = "End of Semester Tests"
+	}
+	return fmt.Sprintf("%s of semester %d, %s academic year", name, exam.Semester, exam.AcademicYear)
+}
+
+func (m *senderRepository) SendMass(ctx context.Context, nsnList *[]string, userID *int, subjectCode string, dryRun bool) (*[]domain.DeliveryResult, error) {
 	// Fetch the subject details
 	langValue := os.Getenv("MESSENGER_LANGUAGE")
 	langValueLowered := strings.ToLower(langValue)
 	var subject domain.Subject
 	err := m.db.WithContext(ctx).Where("subject_code = ?", subjectCode).First(&subject).Error
 	if err != nil {
-		return fmt.Errorf("failed to fetch subject details: %v", err)
+		return nil, fmt.Errorf("failed to fetch subject details: %v", err)
 	}
 
+	var results []domain.DeliveryResult
 	for _, nsn := range *nsnList {
-		var waStatus, emailStatus bool
-		waStatus, emailStatus = false, false
-
 		// Fetch student and parent details
 		student, err := m.fetchStudentDetails(ctx, nsn)
 		if err != nil {
-			continue // Skip the current student if details cannot be fetched
+			// Skip the current student if details cannot be fetched
+			results = append(results, domain.DeliveryResult{
+				StudentNSN: nsn,
+				Status:     domain.DeliveryStatusSkipped,
+				Reason:     err.Error(),
+			})
+			continue
+		}
+
+		recipients := guardianRecipients(student.Student, student.Parent, domain.NotificationTypeAttendance)
+		if len(recipients) == 0 {
+			results = append(results, domain.DeliveryResult{
+				StudentNSN: nsn,
+				Status:     domain.DeliveryStatusSkipped,
+				Reason:     "no guardian receives attendance notifications",
+			})
+			continue
 		}
 
-		var subjectForEmailSender *string
-		var body *string
-		if langValueLowered == "ind" {
-			// Initialize notification text with subject name
-			subjectForEmailSender, body, err = m.inisialisasiTeksDenganSubjek(student, subject.Name)
+		for _, guardian := range recipients {
+			payload := &domain.StudentAndParent{Student: student.Student, Parent: guardian}
+
+			var subjectForEmailSender *string
+			var body *string
+			if langValueLowered == "ind" {
+				// Initialize notification text with subject name
+				subjectForEmailSender, body, err = m.inisialisasiTeksDenganSubjek(payload, subject.Name)
+				if err != nil {
+					return &results, err
+				}
+			} else {
+				// Initialize notification text with subject name
+				subjectForEmailSender, body, err = m.initTextWithSubject(payload, subject.Name)
+				if err != nil {
+					return &results, err
+				}
+			}
+
+			// Attempt to send an email notification
+			emailResult, err := m.deliverAttendanceEmail(ctx, payload, *subjectForEmailSender, *body, dryRun)
 			if err != nil {
-				return err
+				return &results, err
 			}
-		} else {
-			// Initialize notification text with subject name
-			subjectForEmailSender, body, err = m.initTextWithSubject(student, subject.Name)
+			results = append(results, emailResult)
+
+			// Attempt to send a WhatsApp notification, a failed email does not stop it
+			waResult, err := m.deliverAttendanceWhatsapp(ctx, payload, *body, dryRun)
 			if err != nil {
-				return err
+				return &results, err
 			}
-		}
+			results = append(results, waResult)
+			recordDeliveryMetric(domain.NotificationTypeAttendance, emailResult, waResult)
 
-		// Attempt to send an email notification
-		if student.Parent.Email != nil && *student.Parent.Email != "" {
-			if err := m.sendEmail(student, *subjectForEmailSender, *body); err != nil {
-				fmt.Printf("Failed to send email to: %s\n", *student.Parent.Email)
+			// Only real attempts are logged, a guardian whose channels were all suppressed or skipped was never contacted
+			if dryRun || (!isDeliveryAttempt(emailResult.Status) && !isDeliveryAttempt(waResult.Status)) {
 				continue
 			}
-			emailStatus = true
+
+			// Log the notification history, one row per guardian
+			err = m.logNotificationHistory(ctx, student.Student.StudentNSN, subjectCode, guardian.ParentID, *userID, emailResult, waResult)
+			if err != nil {
+				return &results, fmt.Errorf("failed saving the data to notification history, error: %v", err)
+			}
 		}
+	}
 
-		// Attempt to send a WhatsApp notification
-		err = m.sendWA(ctx, student, *body)
-		if err != nil {
-			fmt.Printf("Failed to send WhatsApp message to: %s\n", student.Parent.Telephone)
-			continue
+	return &results, nil
+}
+
+// guardianRecipients lists the active guardians of a student that opted into the notification type.
+// Students without guardian rows fall back to their primary parent.
+func guardianRecipients(student domain.Student, primary domain.Parent, notificationType string) []domain.Parent {
+	if len(student.Guardians) == 0 {
+		if primary.ParentID == 0 || primary.DeletedAt != nil {
+			return nil
 		}
-		waStatus = true
+		return []domain.Parent{primary}
+	}
 
-		// Log the notification history
-		err = m.logNotificationHistory(student.Student.StudentNSN, subjectCode, student.Student.ParentID, *userID, waStatus, emailStatus)
-		if err != nil {
-			return fmt.Errorf("failed saving the data to notification history, error: %v", err)
+	var recipients []domain.Parent
+	for _, guardian := range student.Guardians {
+		if guardian.Parent.ParentID == 0 || guardian.Parent.DeletedAt != nil {
+			continue
+		}
+		if notificationType == domain.NotificationTypeAttendance && !guardian.ReceiveAttendance {
+			continue
+		}
+		if notificationType == domain.NotificationTypeExamResult && !guardian.ReceiveExamResult {
+			continue
 		}
+		recipients = append(recipients, guardian.Parent)
 	}
 
-	return nil
+	return recipients
 }
 
 func (m *senderRepository) fetchStudentDetails(ctx context.Context, nsn string) (*domain.StudentAndParent, error) {
 	var student domain.Student
 	var parent domain.Parent
 
-	err := m.db.WithContext(ctx).Where("student_nsn = ?", nsn).Preload("Parent").First(&student).Error
+	err := m.db.WithContext(ctx).Where("student_nsn = ?", nsn).Preload("Parent").Preload("Guardians.Parent").First(&student).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, fmt.Errorf("student with StudentNSN %s not found", nsn)
@@ -438,23 +671,80 @@ func (m *senderRepository) fetchStudentDetails(ctx context.Context, nsn string)
 	}, nil
 }
 
-func (m *senderRepository) sendEmail(payload *domain.StudentAndParent, subjectEmail string, body string) error {
-	msg := "From: " + m.emailSender + "\r\n" +
+// unsubscribeLink builds the signed opt-out link for a parent, empty when APP_BASE_URL is not configured
+func (m *senderRepository) unsubscribeLink(ctx context.Context, parentID int, notificationType string) string {
+	baseURL := strings.TrimRight(os.Getenv("APP_BASE_URL"), "/")
+	if baseURL == "" {
+		return ""
+	}
+
+	token, err := middleware.GenerateUnsubscribeToken(parentID, domain.ChannelEmail, notificationType)
+	if err != nil {
+		config.Logger(ctx).WithError(err).WithField("parent_id", parentID).Error("could not generate unsubscribe token")
+		return ""
+	}
+
+	return fmt.Sprintf("%s/consent/unsubscribe?token=%s", baseURL, url.QueryEscape(token))
+}
+
+// unsubscribePreviewLink stands in for the opt-out link in dry runs, so a preview never carries a working token
+func unsubscribePreviewLink() string {
+	baseURL := strings.TrimRight(os.Getenv("APP_BASE_URL"), "/")
+	if baseURL == "" {
+		return ""
+	}
+
+	return baseURL + "/consent/unsubscribe?token=PREVIEW"
+}
+
+// renderEmailBody appends the localized opt-out footer with link to the body, the body is left alone without a link
+func renderEmailBody(body, link string) string {
+	if link == "" {
+		return body
+	}
+
+	footer := fmt.Sprintf("\n\n---\nTo stop receiving these emails, open: %s", link)
+	if strings.ToLower(os.Getenv("MESSENGER_LANGUAGE")) == "ind" {
+		footer = fmt.Sprintf("\n\n---\nUntuk berhenti menerima email ini, buka: %s", link)
+	}
+
+	return body + footer
+}
+
+// withUnsubscribe appends the List-Unsubscribe headers and a localized footer to a plain text email.
+// List-Unsubscribe-Post lets mail clients opt out with one click (RFC 8058), the link itself only opens a confirmation page.
+func (m *senderRepository) withUnsubscribe(ctx context.Context, headers, body string, parentID int, notificationType string) string {
+	link := m.unsubscribeLink(ctx, parentID, notificationType)
+	fullBody := renderEmailBody(body, link)
+	if link == "" {
+		return headers + "\r\n" + fullBody
+	}
+
+	return headers + "List-Unsubscribe: <" + link + ">\r\n" +
+		"List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n\r\n" + fullBody
+}
+
+func (m *senderRepository) sendEmail(ctx context.Context, payload *domain.StudentAndParent, subjectEmail string, body string, notificationType string) error {
+	messageID := newMessageID(m.emailSender)
+	headers := "From: " + m.emailSender + "\r\n" +
 		"To: " + *payload.Parent.Email + "\r\n" +
+		"Message-ID: " + messageID + "\r\n" +
 		"Subject: " + subjectEmail + "\r\n" +
 		"MIME-Version: 1.0\r\n" +
-		"Content-Type: text/plain; charset=UTF-8\r\n\r\n" +
-		body
+		"Content-Type: text/plain; charset=UTF-8\r\n"
+	msg := m.withUnsubscribe(ctx, headers, body, payload.Parent.ParentID, notificationType)
 
-	err := smtp.SendMail(m.smtpAdress, m.client, m.emailSender, []string{*payload.Parent.Email}, []byte(msg))
+	err := m.mailer.Send(m.emailSender, []string{*payload.Parent.Email}, []byte(msg))
 	if err != nil {
 		return fmt.Errorf("failed to send email: %w", err)
 	}
+
+	m.recordSentEmail(ctx, messageID, payload.Parent.ParentID, payload.Student.StudentNSN, *payload.Parent.Email, notificationType)
 	return nil
 }
 
-func (m *senderRepository) sendEmailTestScore(idv *domain.IndividualExamScore, body string) error {
-
+// testScoreSubject is the email subject of an exam result notice
+func testScoreSubject(idv domain.IndividualExamScore) (string, error) {
 	tNow := time.Now()
 
 	// Format the date and time
@@ -464,7 +754,7 @@ func (m *senderRepository) sendEmailTestScore(idv *domain.IndividualExamScore, b
 
 	intHourOnly, err := strconv.Atoi(hourOnly)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	isAM := "AM"
@@ -472,53 +762,84 @@ func (m *senderRepository) sendEmailTestScore(idv *domain.IndividualExamScore, b
 		isAM = "PM"
 	}
 
-	subjectTestScoreEmail := fmt.Sprintf("Pemberitahuan Hasil Penilaian %s pada %s %s, tanggal %s", idv.Student.Name, hourAndMinute, isAM, formattedDate)
+	return fmt.Sprintf("Pemberitahuan Hasil Penilaian %s pada %s %s, tanggal %s", idv.Student.Name, hourAndMinute, isAM, formattedDate), nil
+}
+
+func (m *senderRepository) sendEmailTestScore(ctx context.Context, idv *domain.IndividualExamScore, body string) error {
+	subjectTestScoreEmail, err := testScoreSubject(*idv)
+	if err != nil {
+		return err
+	}
 
-	msg := "From: " + m.emailSender + "\r\n" +
+	messageID := newMessageID(m.emailSender)
+	headers := "From: " + m.emailSender + "\r\n" +
 		"To: " + *idv.Student.Parent.Email + "\r\n" +
+		"Message-ID: " + messageID + "\r\n" +
 		"Subject: " + subjectTestScoreEmail + "\r\n" +
 		"MIME-Version: 1.0\r\n" +
-		"Content-Type: text/plain; charset=UTF-8\r\n\r\n" +
-		body
+		"Content-Type: text/plain; charset=UTF-8\r\n"
+	msg := m.withUnsubscribe(ctx, headers, body, idv.Student.Parent.ParentID, domain.NotificationTypeExamResult)
 
-	err = smtp.SendMail(m.smtpAdress, m.client, m.emailSender, []string{*idv.Student.Parent.Email}, []byte(msg))
+	err = m.mailer.Send(m.emailSender, []string{*idv.Student.Parent.Email}, []byte(msg))
 	if err != nil {
 		return fmt.Errorf("failed to send email: %w", err)
 	}
+
+	m.recordSentEmail(ctx, messageID, idv.Student.Parent.ParentID, idv.StudentNSN, *idv.Student.Parent.Email, domain.NotificationTypeExamResult)
 	return nil
 }
 
-func (m *senderRepository) sendWA(ctx context.Context, payload *domain.StudentAndParent, body string) error {
-	completeFormat := fmt.Sprintf("%s%s", "62", payload.Parent.Telephone[1:])
-
-	jid := types.NewJID(completeFormat, types.DefaultUserServer)
+// recordSentEmail keeps the Message-ID so a later bounce can be matched to the parent, failures only lose bounce tracking
+func (m *senderRepository) recordSentEmail(ctx context.Context, messageID string, parentID int, studentNSN, recipient, notificationType string) {
+	err := m.db.WithContext(ctx).Create(&domain.SentEmail{
+		MessageID:        messageID,
+		ParentID:         parentID,
+		StudentNSN:       studentNSN,
+		Recipient:        strings.ToLower(recipient),
+		NotificationType: notificationType,
+	}).Error
+	if err != nil {
+		config.Logger(ctx).WithError(err).WithField("student_nsn", studentNSN).Error("could not record sent email")
+	}
+}
 
-	conversationMessage := &waE2E.Message{
-		Conversation: &body,
+// newMessageID builds a globally unique Message-ID on the sender's domain
+func newMessageID(sender string) string {
+	host := "localhost"
+	if at := strings.LastIndex(sender, "@"); at != -1 && at < len(sender)-1 {
+		host = sender[at+1:]
 	}
 
-	_, err := m.meowClient.SendMessage(ctx, jid, conversationMessage)
-	if err != nil {
-		fmt.Println("meow client error")
-		return err
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return fmt.Sprintf("<%d@%s>", time.Now().UnixNano(), host)
 	}
-	return nil
+
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), hex.EncodeToString(random), host)
 }
 
-func (m *senderRepository) sendWATestScore(ctx context.Context, idv *domain.IndividualExamScore, strBody string) error {
-	completeFormat := fmt.Sprintf("%s%s", "62", idv.Student.Parent.Telephone[1:])
+// whatsappNumber turns the stored local 08 number into the international form WhatsApp uses
+func whatsappNumber(telephone string) string {
+	if strings.HasPrefix(telephone, "0") {
+		return "62" + telephone[1:]
+	}
+	return telephone
+}
 
-	jid := types.NewJID(completeFormat, types.DefaultUserServer)
+func (m *senderRepository) sendWA(ctx context.Context, payload *domain.StudentAndParent, body string) error {
+	completeFormat := whatsappNumber(payload.Parent.Telephone)
 
-	conversationMessage := &waE2E.Message{
-		Conversation: &strBody,
+	err := sendOrQueueWhatsapp(ctx, m.db, m.meowClient.Client(), completeFormat, body)
+	if err != nil && !errors.Is(err, errWhatsappQueued) {
+		config.Logger(ctx).WithError(err).WithField("student_nsn", payload.Student.StudentNSN).Error("could not send WhatsApp message")
 	}
+	return err
+}
 
-	_, err := m.meowClient.SendMessage(ctx, jid, conversationMessage)
-	if err != nil {
-		return err
-	}
-	return nil
+func (m *senderRepository) sendWATestScore(ctx context.Context, idv *domain.IndividualExamScore, strBody string) error {
+	completeFormat := whatsappNumber(idv.Student.Parent.Telephone)
+
+	return sendOrQueueWhatsapp(ctx, m.db, m.meowClient.Client(), completeFormat, strBody)
 }
 
 func (m *senderRepository) initTextWithSubject(payload *domain.StudentAndParent, subjectName string) (*string, *string, error) {
@@ -645,20 +966,276 @@ Terima kasih atas perhatian dan kerjasamanya.`, payload.Parent.Name, payload.Stu
 	}
 }
 
-func (m *senderRepository) logNotificationHistory(StudentNSN, subjectCode string, parentID, userID int, whatsappSuccess, emailSuccess bool) error {
+func (m *senderRepository) logNotificationHistory(ctx context.Context, StudentNSN, subjectCode string, parentID, userID int, emailResult, waResult domain.DeliveryResult) error {
 	history := &domain.AttendanceNotificationHistory{
-		StudentNSN:     StudentNSN,
-		ParentID:       parentID,
-		UserID:         userID,
-		SubjectCode:    subjectCode,
-		WhatsappStatus: whatsappSuccess,
-		EmailStatus:    emailSuccess,
+		StudentNSN:       StudentNSN,
+		ParentID:         parentID,
+		UserID:           userID,
+		SubjectCode:      subjectCode,
+		WhatsappStatus:   waResult.Status == domain.DeliveryStatusSent,
+		WhatsappDelivery: waResult.Status,
+		WhatsappError:    deliveryError(waResult),
+		EmailStatus:      emailResult.Status == domain.DeliveryStatusSent,
+		EmailDelivery:    emailResult.Status,
+		EmailError:       deliveryError(emailResult),
+		Attempts:         1,
 	}
 
-	err := m.db.Create(history).Error
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(history).Error; err != nil {
+			return err
+		}
+		return linkOutboxToHistory(tx, waResult.OutboxID, history.NotificationHistoryID)
+	})
 	if err != nil {
 		return fmt.Errorf("could not log notification history: %v", err)
 	}
 
 	return nil
 }
+
+// A channel claimed by a resend longer ago than this was interrupted and may be resent again
+const attendanceResendStaleAfter = 10 * time.Minute
+
+// ResendAttendanceNotification retries the failed channels of one attendance notice and updates its history row.
+// The channels are claimed as pending under a row lock first and sent once the lock is released,
+// so a slow SMTP server never holds the row and a second resend of the same notice finds nothing to claim.
+func (m *senderRepository) ResendAttendanceNotification(ctx context.Context, historyID int) (*[]domain.DeliveryResult, error) {
+	var results []domain.DeliveryResult
+	var payload *domain.StudentAndParent
+	var subjectForEmailSender, body *string
+	var emailFailed, waFailed bool
+
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var history domain.AttendanceNotificationHistory
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("notification_history_id = ?", historyID).
+			First(&history).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: notification history with id %d", domain.ErrNotFound, historyID)
+		}
+		if err != nil {
+			return fmt.Errorf("could not get notification history: %w", err)
+		}
+
+		err = tx.Preload("Student").Preload("Parent").Preload("Subject").
+			Where("notification_history_id = ?", historyID).
+			First(&history).Error
+		if err != nil {
+			return fmt.Errorf("could not get notification history: %w", err)
+		}
+
+		staleClaim := history.UpdatedAt.Before(time.Now().Add(-attendanceResendStaleAfter))
+		emailFailed = needsResend(history.EmailDelivery, history.EmailStatus, staleClaim)
+		waFailed = needsResend(history.WhatsappDelivery, history.WhatsappStatus, staleClaim)
+		if !emailFailed && !waFailed {
+			return fmt.Errorf("%w: notification history %d has no failed channel to resend", domain.ErrConflict, historyID)
+		}
+
+		if history.Student.StudentNSN == "" || history.Parent.ParentID == 0 || history.Parent.DeletedAt != nil {
+			return fmt.Errorf("%w: student or parent of notification history %d no longer exists", domain.ErrConflict, historyID)
+		}
+
+		payload = &domain.StudentAndParent{Student: history.Student, Parent: history.Parent}
+
+		if strings.ToLower(os.Getenv("MESSENGER_LANGUAGE")) == "ind" {
+			subjectForEmailSender, body, err = m.inisialisasiTeksDenganSubjek(payload, history.Subject.Name)
+		} else {
+			subjectForEmailSender, body, err = m.initTextWithSubject(payload, history.Subject.Name)
+		}
+		if err != nil {
+			return err
+		}
+
+		claim := map[string]interface{}{"attempts": gorm.Expr("attempts + 1")}
+		if emailFailed {
+			claim["email_delivery"] = domain.DeliveryStatusPending
+		}
+		if waFailed {
+			claim["whatsapp_delivery"] = domain.DeliveryStatusPending
+		}
+		err = tx.Model(&domain.AttendanceNotificationHistory{}).Where("notification_history_id = ?", historyID).
+			Updates(claim).Error
+		if err != nil {
+			return fmt.Errorf("could not update notification history: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return &results, err
+	}
+
+	if emailFailed {
+		emailResult, err := m.deliverAttendanceEmail(ctx, payload, *subjectForEmailSender, *body, false)
+		if err != nil {
+			m.releaseResendClaim(ctx, historyID, true, waFailed)
+			return &results, err
+		}
+		results = append(results, emailResult)
+		recordDeliveryMetric(domain.NotificationTypeAttendance, emailResult)
+
+		// The email outcome is stored before WhatsApp is tried, a failing WhatsApp step must not resend it again
+		err = m.db.WithContext(ctx).Model(&domain.AttendanceNotificationHistory{}).Where("notification_history_id = ?", historyID).
+			Updates(map[string]interface{}{
+				"email_status":   emailResult.Status == domain.DeliveryStatusSent,
+				"email_delivery": emailResult.Status,
+				"email_error":    deliveryError(emailResult),
+			}).Error
+		if err != nil {
+			m.releaseResendClaim(ctx, historyID, false, waFailed)
+			return &results, fmt.Errorf("could not update notification history: %w", err)
+		}
+	}
+
+	if waFailed {
+		waResult, err := m.deliverAttendanceWhatsapp(ctx, payload, *body, false)
+		if err != nil {
+			m.releaseResendClaim(ctx, historyID, false, true)
+			return &results, err
+		}
+		results = append(results, waResult)
+		recordDeliveryMetric(domain.NotificationTypeAttendance, waResult)
+
+		err = m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			err := tx.Model(&domain.AttendanceNotificationHistory{}).Where("notification_history_id = ?", historyID).
+				Updates(map[string]interface{}{
+					"whatsapp_status":   waResult.Status == domain.DeliveryStatusSent,
+					"whatsapp_delivery": waResult.Status,
+					"whatsapp_error":    deliveryError(waResult),
+				}).Error
+			if err != nil {
+				return fmt.Errorf("could not update notification history: %w", err)
+			}
+			return linkOutboxToHistory(tx, waResult.OutboxID, historyID)
+		})
+		if err != nil {
+			return &results, err
+		}
+	}
+
+	return &results, nil
+}
+
+// releaseResendClaim hands claimed channels back as failed when a resend stops before sending them
+func (m *senderRepository) releaseResendClaim(ctx context.Context, historyID int, email, whatsapp bool) {
+	updates := map[string]interface{}{}
+	if email {
+		updates["email_delivery"] = domain.DeliveryStatusFailed
+	}
+	if whatsapp {
+		updates["whatsapp_delivery"] = domain.DeliveryStatusFailed
+	}
+	if len(updates) == 0 {
+		return
+	}
+
+	err := m.db.WithContext(ctx).Model(&domain.AttendanceNotificationHistory{}).
+		Where("notification_history_id = ?", historyID).
+		Updates(updates).Error
+	if err != nil {
+		config.Logger(ctx).WithError(err).WithField("notification_history_id", historyID).Error("could not release resend claim")
+	}
+}
+
+// needsResend tells whether a channel of an attendance notice can be resent. Rows written before
+// per-channel delivery status have no status at all, those count as failed unless the channel succeeded.
+// A channel left pending by an interrupted resend is resent once its claim is stale.
+func needsResend(delivery string, succeeded bool, staleClaim bool) bool {
+	return delivery == domain.DeliveryStatusFailed ||
+		(delivery == "" && !succeeded) ||
+		(delivery == domain.DeliveryStatusPending && staleClaim)
+}
+
+// deliverAttendanceEmail sends one attendance notice by email, only a failed preference lookup is returned as error
+func (m *senderRepository) deliverAttendanceEmail(ctx context.Context, payload *domain.StudentAndParent, subject, body string, dryRun bool) (domain.DeliveryResult, error) {
+	guardian := payload.Parent
+	result := domain.DeliveryResult{StudentNSN: payload.Student.StudentNSN, ParentID: guardian.ParentID, Channel: domain.ChannelEmail}
+
+	if guardian.Email == nil || *guardian.Email == "" {
+		result.Status, result.Reason = domain.DeliveryStatusSkipped, domain.DeliveryReasonNoEmail
+		return result, nil
+	}
+
+	if guardian.EmailInvalid {
+		result.Status, result.Reason = domain.DeliveryStatusSkipped, domain.DeliveryReasonEmailInvalid
+		return result, nil
+	}
+
+	suppressed, err := isSuppressedByPreference(ctx, m.db, guardian.ParentID, domain.ChannelEmail, domain.NotificationTypeAttendance)
+	if err != nil {
+		return result, err
+	}
+
+	if suppressed {
+		result.Status, result.Reason = domain.DeliveryStatusSuppressed, domain.DeliveryReasonSuppressed
+		return result, nil
+	}
+
+	if dryRun {
+		result.Status, result.Recipient, result.Subject = domain.DeliveryStatusWouldSend, *guardian.Email, subject
+		result.Body = renderEmailBody(body, unsubscribePreviewLink())
+		return result, nil
+	}
+
+	if err := m.sendEmail(ctx, payload, subject, body, domain.NotificationTypeAttendance); err != nil {
+		config.Logger(ctx).WithError(err).WithFields(logrus.Fields{"student_nsn": payload.Student.StudentNSN, "parent_id": guardian.ParentID}).Warn("attendance email failed")
+		result.Status, result.Reason = domain.DeliveryStatusFailed, err.Error()
+		return result, nil
+	}
+
+	result.Status = domain.DeliveryStatusSent
+	return result, nil
+}
+
+// deliverAttendanceWhatsapp sends one attendance notice by WhatsApp, only a failed preference lookup is returned as error
+func (m *senderRepository) deliverAttendanceWhatsapp(ctx context.Context, payload *domain.StudentAndParent, body string, dryRun bool) (domain.DeliveryResult, error) {
+	guardian := payload.Parent
+	result := domain.DeliveryResult{StudentNSN: payload.Student.StudentNSN, ParentID: guardian.ParentID, Channel: domain.ChannelWhatsapp}
+
+	suppressed, err := isSuppressedByPreference(ctx, m.db, guardian.ParentID, domain.ChannelWhatsapp, domain.NotificationTypeAttendance)
+	if err != nil {
+		return result, err
+	}
+
+	if suppressed {
+		result.Status, result.Reason = domain.DeliveryStatusSuppressed, domain.DeliveryReasonSuppressed
+		return result, nil
+	}
+
+	if dryRun {
+		result.Status, result.Recipient, result.Body = domain.DeliveryStatusWouldSend, whatsappNumber(guardian.Telephone), body
+		return result, nil
+	}
+
+	if err := m.sendWA(ctx, payload, body); errors.Is(err, errWhatsappQueued) {
+		result.Status, result.Reason, result.OutboxID = domain.DeliveryStatusQueued, domain.DeliveryReasonQueued, queuedOutboxID(err)
+	} else if err != nil {
+		config.Logger(ctx).WithError(err).WithFields(logrus.Fields{"student_nsn": payload.Student.StudentNSN, "parent_id": guardian.ParentID}).Warn("attendance WhatsApp message failed")
+		result.Status, result.Reason = domain.DeliveryStatusFailed, err.Error()
+	} else {
+		result.Status = domain.DeliveryStatusSent
+	}
+
+	return result, nil
+}
+
+// isDeliveryAttempt tells whether the notice actually went out to the channel, successfully or not
+func isDeliveryAttempt(status string) bool {
+	return status == domain.DeliveryStatusSent || status == domain.DeliveryStatusFailed || status == domain.DeliveryStatusQueued
+}
+
+// recordDeliveryMetric counts the notices that actually went out, dry runs and skipped channels are left out
+func recordDeliveryMetric(notificationType string, results ...domain.DeliveryResult) {
+	for _, result := range results {
+		if isDeliveryAttempt(result.Status) {
+			metrics.RecordMessage(result.Channel, notificationType, result.Status)
+		}
+	}
+}
+
+func deliveryError(result domain.DeliveryResult) *string {
+	if result.Status != domain.DeliveryStatusFailed {
+		return nil
+	}
+	return &result.Reason
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"notification/domain"
+	"testing"
+)
+
+func TestRenderEmailBody(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		link     string
+		want     string
+	}{
+		{"no link", "", "", "Your child was absent today."},
+		{"english footer", "eng", "https://school.example/consent/unsubscribe?token=abc",
+			"Your child was absent today.\n\n---\nTo stop receiving these emails, open: https://school.example/consent/unsubscribe?token=abc"},
+		{"indonesian footer", "IND", "https://school.example/consent/unsubscribe?token=abc",
+			"Your child was absent today.\n\n---\nUntuk berhenti menerima email ini, buka: https://school.example/consent/unsubscribe?token=abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MESSENGER_LANGUAGE", tt.language)
+			if got := renderEmailBody("Your child was absent today.", tt.link); got != tt.want {
+				t.Errorf("renderEmailBody() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnsubscribePreviewLink(t *testing.T) {
+	t.Setenv("APP_BASE_URL", "")
+	if got := unsubscribePreviewLink(); got != "" {
+		t.Errorf("unsubscribePreviewLink() without APP_BASE_URL = %q, want empty", got)
+	}
+
+	t.Setenv("APP_BASE_URL", "https://school.example/")
+	if got, want := unsubscribePreviewLink(), "https://school.example/consent/unsubscribe?token=PREVIEW"; got != want {
+		t.Errorf("unsubscribePreviewLink() = %q, want %q", got, want)
+	}
+}
+
+func TestNeedsResend(t *testing.T) {
+	tests := []struct {
+		delivery   string
+		succeeded  bool
+		staleClaim bool
+		want       bool
+	}{
+		{domain.DeliveryStatusFailed, false, false, true},
+		{domain.DeliveryStatusSent, true, false, false},
+		{domain.DeliveryStatusQueued, false, false, false},
+		{domain.DeliveryStatusSkipped, false, false, false},
+		{domain.DeliveryStatusSuppressed, false, false, false},
+		{"", false, false, true},
+		{"", true, false, false},
+		{domain.DeliveryStatusPending, false, false, false},
+		{domain.DeliveryStatusPending, false, true, true},
+	}
+	for _, tt := range tests {
+		if got := needsResend(tt.delivery, tt.succeeded, tt.staleClaim); got != tt.want {
+			t.Errorf("needsResend(%q, %v, %v) = %v, want %v", tt.delivery, tt.succeeded, tt.staleClaim, got, tt.want)
+		}
+	}
+}
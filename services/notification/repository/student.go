@@ -23,14 +23,17 @@ func NewStudentRepository(database *gorm.DB) domain.StudentRepo {
 func (spr *studentRepository) GetStudentByParentTelephone(ctx context.Context, parTel string) (*domain.StudentsAssociateWithParent, error) {
 	var result domain.StudentsAssociateWithParent
 
+	// Accept both the stored local format and the WhatsApp international format
+	localTel := normalizeTelephone(parTel)
+
 	var parent domain.Parent
 	err := spr.db.WithContext(ctx).Model(&domain.Parent{}).
-		Where("telephone = ? AND deleted_at IS NULL", parTel).
+		Where("telephone IN ? AND deleted_at IS NULL", []string{localTel, internationalTelephone(localTel)}).
 		First(&parent).Error
 
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, fmt.Errorf("no parent found with telephone %s", parTel)
+			return nil, fmt.Errorf("%w: no parent found with telephone %s", domain.ErrNotFound, parTel)
 		}
 		return nil, fmt.Errorf("error fetching parent details: %v", err)
 	}
@@ -38,10 +41,10 @@ func (spr *studentRepository) GetStudentByParentTelephone(ctx context.Context, p
 	// Assign the fetched parent to the result
 	result.Parent = parent
 
-	// Fetch students associated with the parent
+	// Fetch students associated with the parent, as primary or additional guardian
 	var students []domain.Student
 	err = spr.db.WithContext(ctx).
-		Where("parent_id = ?", parent.ParentID).
+		Where("parent_id = ? OR student_nsn IN (SELECT student_nsn FROM student_guardians WHERE parent_id = ?)", parent.ParentID, parent.ParentID).
 		Find(&students).Error
 
 	if err != nil {
@@ -66,7 +69,12 @@ func (sp *studentRepository) GetAllStudent(ctx context.Context, userID int) (*[]
 
 	var students []domain.Student
 
-	if existingUser.Role == "admin" {
+	all, err := roleHasPermission(ctx, sp.db, existingUser.Role, domain.PermissionViewAllStudents)
+	if err != nil {
+		return nil, err
+	}
+
+	if all {
 		err = sp.db.WithContext(ctx).Preload("Parent").Find(&students).Error
 		if err != nil {
 			return nil, fmt.Errorf("failed to retrieve all students: %w", err)
@@ -7,11 +7,13 @@ import (
 	"notification/domain"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 	"unicode"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type studentParentRepository struct {
@@ -53,7 +55,6 @@ func (spr *studentParentRepository) ApproveDCR(ctx context.Context, req map[stri
 	tx := spr.db.WithContext(ctx).Begin()
 	defer func() {
 		if r := recover(); r != nil {
-			fmt.Print("==========================================================")
 			tx.Rollback()
 			panic(r)
 		}
@@ -123,6 +124,25 @@ func (spr *studentParentRepository) ApproveDCR(ctx context.Context, req map[stri
 			tx.Rollback()
 			return nil, fmt.Errorf("failed to update parent, error: %v", err)
 		}
+		if comparedData.Email != nil {
+			if err := clearEmailInvalid(tx, Parent.ParentID); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
+		var updatedParent domain.Parent
+		if err := tx.Where("parent_id = ?", Parent.ParentID).First(&updatedParent).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to get updated parent, error: %v", err)
+		}
+		if err := recordAudit(ctx, tx, domain.AuditActionUpdate, domain.AuditEntityParent, strconv.Itoa(Parent.ParentID), Parent, updatedParent); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err := recordDCRApproval(ctx, tx, dcr); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
 		err = spr.db.WithContext(ctx).Model(&domain.ParentDataChangeRequest{}).Where("old_parent_telephone = ? AND is_reviewed IS FALSE", oldTelephone).Updates(&domain.ParentDataChangeRequest{
 			IsReviewed: true,
 		}).Error
@@ -144,6 +164,16 @@ func (spr *studentParentRepository) ApproveDCR(ctx context.Context, req map[stri
 			tx.Rollback()
 			return nil, fmt.Errorf("failed to assign student to existing parent, error: %v", err)
 		}
+		if err := setPrimaryGuardian(tx, student.StudentNSN, ExistingParent.ParentID, ""); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		err = recordAudit(ctx, tx, domain.AuditActionUpdate, domain.AuditEntityStudent, student.StudentNSN,
+			map[string]interface{}{"parent_id": Parent.ParentID}, map[string]interface{}{"parent_id": ExistingParent.ParentID})
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
 		message := fmt.Sprintf(`Parent data already exists, allocating %d students to the existing Parent:
 
 - name: %s
@@ -157,6 +187,12 @@ func (spr *studentParentRepository) ApproveDCR(ctx context.Context, req map[stri
 		msgs = &message
 	}
 
+	// Additional guardian links follow the parent record as well
+	if err := moveGuardianLinks(tx, Parent.ParentID, ExistingParent.ParentID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
 	err = spr.db.WithContext(ctx).Model(&domain.ParentDataChangeRequest{}).Where("old_parent_telephone = ? AND is_reviewed IS FALSE", oldTelephone).Updates(&domain.ParentDataChangeRequest{
 		IsReviewed: true,
 	}).Error
@@ -164,10 +200,12 @@ func (spr *studentParentRepository) ApproveDCR(ctx context.Context, req map[stri
 		tx.Rollback()
 		return nil, fmt.Errorf("failed to review data change request, error: %v", err)
 	}
+	if err := recordDCRApproval(ctx, tx, dcr); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
 
-	var counter int64
-
-	err = tx.WithContext(ctx).Model(&domain.Student{}).Where("parent_id = ?", Parent.ParentID).Count(&counter).Error
+	counter, err := countGuardianLinks(tx.WithContext(ctx), Parent.ParentID)
 	if err != nil {
 		tx.Rollback()
 	}
@@ -182,6 +220,12 @@ func (spr *studentParentRepository) ApproveDCR(ctx context.Context, req map[stri
 		if result.RowsAffected == 0 {
 			tx.Rollback()
 		}
+		if result.Error == nil && result.RowsAffected > 0 {
+			if err := recordAudit(ctx, tx, domain.AuditActionDelete, domain.AuditEntityParent, strconv.Itoa(Parent.ParentID), Parent, nil); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
 	}
 
 	if msgs != nil {
@@ -193,6 +237,13 @@ func (spr *studentParentRepository) ApproveDCR(ctx context.Context, req map[stri
 	return nil, nil
 }
 
+// recordDCRApproval records that an admin approved the data change request
+func recordDCRApproval(ctx context.Context, tx *gorm.DB, dcr domain.ParentDataChangeRequest) error {
+	reviewed := dcr
+	reviewed.IsReviewed = true
+	return recordAudit(ctx, tx, domain.AuditActionApprove, domain.AuditEntityDataChangeRequest, strconv.Itoa(dcr.RequestID), dcr, reviewed)
+}
+
 func (spr *studentParentRepository) CreateStudentAndParent(ctx context.Context, req *domain.StudentAndParent) (*string, *[]string) {
 	var errList []string
 
@@ -295,6 +346,13 @@ func (spr *studentParentRepository) CreateStudentAndParent(ctx context.Context,
 		errList = append(errList, fmt.Sprintf("Parent telephone %s already exist in student", req.Parent.Telephone))
 	}
 
+	// ========================================GUARDIANS=====================================================
+	req.Relationship = strings.ToLower(req.Relationship)
+	if req.Relationship != "" && !isGuardianRelationship(req.Relationship) {
+		errList = append(errList, fmt.Sprintf("Invalid relationship %s, must be one of %s", req.Relationship, strings.Join(domain.GuardianRelationships, ", ")))
+	}
+	errList = append(errList, validateGuardians(ctx, spr.db, req.Guardians, req.Student.Telephone)...)
+
 	// If errors exist, return immediately
 	if len(errList) > 0 {
 		return nil, &errList
@@ -326,6 +384,9 @@ func (spr *studentParentRepository) CreateStudentAndParent(ctx context.Context,
 	if tx.Error != nil {
 		return nil, &[]string{fmt.Sprintf("Could not begin transaction: %v", tx.Error)}
 	}
+	// Guardian links are managed below, not through gorm associations
+	req.Student.Guardians = nil
+
 	var msgs *string
 	if oneToManyCondition {
 		// Parent exists, create student with reference
@@ -364,6 +425,10 @@ func (spr *studentParentRepository) CreateStudentAndParent(ctx context.Context,
 			tx.Rollback()
 			return nil, &[]string{fmt.Sprintf("Could not insert parent: %v", err)}
 		}
+		if err := recordAudit(ctx, tx.WithContext(ctx), domain.AuditActionCreate, domain.AuditEntityParent, strconv.Itoa(req.Parent.ParentID), nil, req.Parent); err != nil {
+			tx.Rollback()
+			return nil, &[]string{err.Error()}
+		}
 
 		// Create new student
 		req.Student.ParentID = req.Parent.ParentID
@@ -377,14 +442,27 @@ func (spr *studentParentRepository) CreateStudentAndParent(ctx context.Context,
 		}
 	}
 
+	if err := recordAudit(ctx, tx.WithContext(ctx), domain.AuditActionCreate, domain.AuditEntityStudent, req.Student.StudentNSN, nil, req.Student); err != nil {
+		tx.Rollback()
+		return nil, &[]string{err.Error()}
+	}
+
+	if err := setPrimaryGuardian(tx.WithContext(ctx), req.Student.StudentNSN, req.Student.ParentID, req.Relationship); err != nil {
+		tx.Rollback()
+		return nil, &[]string{err.Error()}
+	}
+
+	if err := linkGuardians(tx.WithContext(ctx), req.Student.StudentNSN, req.Guardians); err != nil {
+		tx.Rollback()
+		return nil, &[]string{err.Error()}
+	}
+
 	if msgs != nil {
-		fmt.Println("masuk allocated parent")
 		tx.Commit()
 		return msgs, nil
 	}
 
 	if err := tx.Commit().Error; err != nil {
-		fmt.Println("masuk biase")
 		return nil, &[]string{fmt.Sprintf("Could not commit transaction: %v", err)}
 	}
 
@@ -459,6 +537,12 @@ func (spr *studentParentRepository) ImportCSV(ctx context.Context, payload *[]do
 			isDuplicate = true
 		}
 
+		// Validate additional guardians
+		for _, guardianErr := range validateGuardians(ctx, spr.db, record.Guardians, record.Student.Telephone) {
+			duplicateMessages = append(duplicateMessages, fmt.Sprintf("row %d: %s", index+2, guardianErr))
+			isDuplicate = true
+		}
+
 		// Skip records with validation errors
 		if isDuplicate {
 			continue
@@ -505,6 +589,9 @@ func (spr *studentParentRepository) ImportCSV(ctx context.Context, payload *[]do
 				if err := tx.Create(&record.Parent).Error; err != nil {
 					return fmt.Errorf("failed to insert parent: %w", err)
 				}
+				if err := recordAudit(ctx, tx, domain.AuditActionImport, domain.AuditEntityParent, strconv.Itoa(record.Parent.ParentID), nil, record.Parent); err != nil {
+					return err
+				}
 				record.Student.ParentID = record.Parent.ParentID
 			} else {
 				// Use the existing parent's ID
@@ -512,9 +599,21 @@ func (spr *studentParentRepository) ImportCSV(ctx context.Context, payload *[]do
 			}
 
 			// Insert student
+			record.Student.Guardians = nil
 			if err := tx.Create(&record.Student).Error; err != nil {
 				return fmt.Errorf("failed to insert student: %w", err)
 			}
+			if err := recordAudit(ctx, tx, domain.AuditActionImport, domain.AuditEntityStudent, record.Student.StudentNSN, nil, record.Student); err != nil {
+				return err
+			}
+
+			if err := setPrimaryGuardian(tx, record.Student.StudentNSN, record.Student.ParentID, record.Relationship); err != nil {
+				return err
+			}
+
+			if err := linkGuardians(tx, record.Student.StudentNSN, record.Guardians); err != nil {
+				return err
+			}
 		}
 		return nil
 	})
@@ -566,15 +665,10 @@ func (spr *studentParentRepository) UpdateStudentAndParent(ctx context.Context,
 
 	// Convert empty string email to nil
 	if req.Parent.Email != nil && *req.Parent.Email == "" {
-		fmt.Println("Email is empty")
-		fmt.Println(req.Parent.Email)
 		req.Parent.Email = nil
 	}
 
 	if req.Parent.Email != nil {
-		fmt.Println("Email is not empty")
-		fmt.Println(req.Parent.Email)
-
 		emailLowered := strings.ToLower(strings.TrimSpace(*req.Parent.Email))
 		req.Parent.Email = &emailLowered
 
@@ -708,6 +802,10 @@ func (spr *studentParentRepository) UpdateStudentAndParent(ctx context.Context,
 		(req.Parent.Email != nil && student.Parent.Email == nil) ||
 		(req.Parent.Email != nil && student.Parent.Email != nil && *req.Parent.Email != *student.Parent.Email) {
 		updatedParentFields["email"] = req.Parent.Email
+		// A new address gets a fresh start after bounces
+		updatedParentFields["email_invalid"] = false
+		updatedParentFields["email_invalid_at"] = nil
+		updatedParentFields["email_bounce_reason"] = nil
 	}
 	if len(updatedParentFields) > 0 {
 		updatedParentFields["updated_at"] = now
@@ -743,6 +841,17 @@ func (spr *studentParentRepository) UpdateStudentAndParent(ctx context.Context,
 				errList = append(errList, fmt.Sprintf("failed to update parent: %v", err))
 				return nil, &errList
 			}
+			var updatedParent domain.Parent
+			if err := tx.WithContext(ctx).Where("parent_id = ?", student.ParentID).First(&updatedParent).Error; err != nil {
+				tx.Rollback()
+				errList = append(errList, fmt.Sprintf("failed to get updated parent: %v", err))
+				return nil, &errList
+			}
+			if err := recordAudit(ctx, tx.WithContext(ctx), domain.AuditActionUpdate, domain.AuditEntityParent, strconv.Itoa(student.ParentID), student.Parent, updatedParent); err != nil {
+				tx.Rollback()
+				errList = append(errList, err.Error())
+				return nil, &errList
+			}
 		} else {
 			tx.Rollback()
 			errList = append(errList, fmt.Sprintf("database error while checking parent: %v", err))
@@ -760,9 +869,32 @@ func (spr *studentParentRepository) UpdateStudentAndParent(ctx context.Context,
 		return nil, &errList
 	}
 
-	var counter int64
+	currentNSN := student.StudentNSN
+	if nsn, ok := updatedStudentFields["student_nsn"].(string); ok {
+		currentNSN = nsn
+	}
 
-	err = tx.WithContext(ctx).Model(&domain.Student{}).Where("parent_id = ?", student.ParentID).Count(&counter).Error
+	var updatedStudent domain.Student
+	if err := tx.WithContext(ctx).Where("student_nsn = ?", currentNSN).First(&updatedStudent).Error; err != nil {
+		tx.Rollback()
+		errList = append(errList, fmt.Sprintf("failed to get updated student: %v", err))
+		return nil, &errList
+	}
+	if err := recordAudit(ctx, tx.WithContext(ctx), domain.AuditActionUpdate, domain.AuditEntityStudent, studentNSN, student, updatedStudent); err != nil {
+		tx.Rollback()
+		errList = append(errList, err.Error())
+		return nil, &errList
+	}
+
+	if newParentID, ok := updatedStudentFields["ParentID"].(int); ok {
+		if err := setPrimaryGuardian(tx.WithContext(ctx), currentNSN, newParentID, req.Relationship); err != nil {
+			tx.Rollback()
+			errList = append(errList, err.Error())
+			return nil, &errList
+		}
+	}
+
+	counter, err := countGuardianLinks(tx.WithContext(ctx), student.ParentID)
 	if err != nil {
 		tx.Rollback()
 		errList = append(errList, fmt.Sprintf("failed to count parent in student associate: %v", err))
@@ -783,6 +915,11 @@ func (spr *studentParentRepository) UpdateStudentAndParent(ctx context.Context,
 			errList = append(errList, "no parent found to delete")
 			return nil, &errList
 		}
+		if err := recordAudit(ctx, tx.WithContext(ctx), domain.AuditActionDelete, domain.AuditEntityParent, strconv.Itoa(student.ParentID), student.Parent, nil); err != nil {
+			tx.Rollback()
+			errList = append(errList, err.Error())
+			return nil, &errList
+		}
 	}
 
 	if msgs != nil {
@@ -1028,6 +1165,7 @@ func (spr *studentParentRepository) GetStudentDetailsByID(ctx context.Context, s
 	var result domain.StudentAndParent
 	err := spr.db.WithContext(ctx).Model(&domain.Student{}).
 		Preload("Parent").
+		Preload("Guardians.Parent").
 		Where("student_nsn = ?", studentNSN).
 		First(&result.Student).Error
 
@@ -1065,14 +1203,16 @@ func (spr *studentParentRepository) GetAllDataChangeRequestByID(ctx context.Cont
 
 func (spr *studentParentRepository) DataChangeRequest(ctx context.Context, datas domain.ParentDataChangeRequest) error {
 	var countVariable int64
-	var parentCount int64
-	err := spr.db.WithContext(ctx).Model(&domain.Parent{}).Where("telephone = ? AND deleted_at IS NULL", datas.OldParentTelephone).Count(&parentCount).Error
+	var parent domain.Parent
+	err := spr.db.WithContext(ctx).Where("telephone = ? AND deleted_at IS NULL", datas.OldParentTelephone).First(&parent).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("parent with telephone %s does not exist or registered", datas.OldParentTelephone)
+	}
 	if err != nil {
 		return err
 	}
-	if parentCount == 0 {
-		return fmt.Errorf("parent with telephone %s does not exist or registered", datas.OldParentTelephone)
-	}
+	// The request follows the parent, not the telephone that is about to change
+	datas.ParentID = &parent.ParentID
 
 	err = spr.db.WithContext(ctx).Model(&domain.ParentDataChangeRequest{}).Where("old_parent_telephone = ? AND is_reviewed IS FALSE AND deleted_at IS NULL", datas.OldParentTelephone).Count(&countVariable).Error
 	if err != nil {
@@ -1118,20 +1258,30 @@ func (spr *studentParentRepository) DataChangeRequest(ctx context.Context, datas
 }
 
 func (spr *studentParentRepository) DeleteDCR(ctx context.Context, dcrID int) error {
-	result := spr.db.WithContext(ctx).
-		Model(&domain.ParentDataChangeRequest{}).
-		Where("request_id = ?", dcrID).
-		Update("deleted_at", time.Now())
+	return spr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var dcr domain.ParentDataChangeRequest
+		err := tx.Where("request_id = ?", dcrID).First(&dcr).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("no data change request found with request id %d", dcrID)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to delete for request_id %d: %w", dcrID, err)
+		}
 
-	if result.Error != nil {
-		return fmt.Errorf("failed to delete for request_id %d: %w", dcrID, result.Error)
-	}
+		result := tx.Model(&domain.ParentDataChangeRequest{}).
+			Where("request_id = ?", dcrID).
+			Update("deleted_at", time.Now())
 
-	if result.RowsAffected == 0 {
-		return fmt.Errorf("no data change request found with request id %d", dcrID)
-	}
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete for request_id %d: %w", dcrID, result.Error)
+		}
 
-	return nil
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("no data change request found with request id %d", dcrID)
+		}
+
+		return recordAudit(ctx, tx, domain.AuditActionDelete, domain.AuditEntityDataChangeRequest, strconv.Itoa(dcrID), dcr, nil)
+	})
 }
 
 func (spr *studentParentRepository) GetAllDataChangeRequest(ctx context.Context) (*[]domain.ParentDataChangeRequest, error) {
@@ -1157,3 +1307,267 @@ func (spr *studentParentRepository) GetAllDataChangeRequest(ctx context.Context)
 
 // 	return &class.ClassID, nil
 // }
+
+// setPrimaryGuardian keeps the guardian table in sync with the student's parent_id
+func setPrimaryGuardian(tx *gorm.DB, studentNSN string, parentID int, relationship string) error {
+	if relationship == "" {
+		relationship = "parent"
+	}
+
+	// The previous primary guardian is no longer linked once parent_id moves to someone else
+	err := tx.Where("student_nsn = ? AND is_primary IS TRUE AND parent_id != ?", studentNSN, parentID).
+		Delete(&domain.StudentGuardian{}).Error
+	if err != nil {
+		return fmt.Errorf("failed to unlink previous primary guardian: %w", err)
+	}
+
+	guardian := domain.StudentGuardian{
+		StudentNSN:        studentNSN,
+		ParentID:          parentID,
+		Relationship:      relationship,
+		IsPrimary:         true,
+		ReceiveAttendance: true,
+		ReceiveExamResult: true,
+	}
+	err = tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "student_nsn"}, {Name: "parent_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"is_primary": true, "updated_at": time.Now()}),
+	}).Create(&guardian).Error
+	if err != nil {
+		return fmt.Errorf("failed to link primary guardian: %w", err)
+	}
+
+	return nil
+}
+
+// linkGuardians reuses or creates the parent record of every additional guardian and links it to the student
+func linkGuardians(tx *gorm.DB, studentNSN string, guardians []domain.GuardianPayload) error {
+	for _, guardian := range guardians {
+		parent := guardian.Parent
+		if parent.Email != nil && *parent.Email == "" {
+			parent.Email = nil
+		}
+
+		existingParent, err := findGuardianParent(tx, parent)
+		if err != nil {
+			return err
+		}
+
+		if existingParent == nil {
+			parent.ParentID = 0
+			if err := tx.Create(&parent).Error; err != nil {
+				return fmt.Errorf("failed to insert guardian %s: %w", parent.Name, err)
+			}
+			existingParent = &parent
+		}
+
+		relationship := guardian.Relationship
+		if relationship == "" {
+			relationship = "guardian"
+		}
+
+		link := domain.StudentGuardian{
+			StudentNSN:        studentNSN,
+			ParentID:          existingParent.ParentID,
+			Relationship:      relationship,
+			IsPrimary:         false,
+			ReceiveAttendance: guardian.ReceiveAttendance == nil || *guardian.ReceiveAttendance,
+			ReceiveExamResult: guardian.ReceiveExamResult == nil || *guardian.ReceiveExamResult,
+		}
+
+		// A guardian that is already linked (e.g. the primary parent) keeps its existing settings
+		err = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&link).Error
+		if err != nil {
+			return fmt.Errorf("failed to link guardian %s: %w", parent.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// findGuardianParent looks up the parent a guardian refers to by telephone or email. A guardian whose
+// telephone and email belong to different parents, or whose match carries another telephone or email,
+// is rejected rather than attached to the wrong family
+func findGuardianParent(tx *gorm.DB, parent domain.Parent) (*domain.Parent, error) {
+	condition, args := "telephone = ?", []interface{}{parent.Telephone}
+	if parent.Email != nil {
+		condition, args = "(telephone = ? OR email = ?)", append(args, *parent.Email)
+	}
+
+	var matches []domain.Parent
+	if err := tx.Where(condition+" AND deleted_at IS NULL", args...).Limit(2).Find(&matches).Error; err != nil {
+		return nil, fmt.Errorf("failed to query guardian: %w", err)
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, nil
+	case 1:
+		match := matches[0]
+		if match.Telephone != parent.Telephone {
+			return nil, fmt.Errorf("guardian %s: email %s already belongs to a parent with another telephone", parent.Name, *parent.Email)
+		}
+		if parent.Email != nil && match.Email != nil && *match.Email != *parent.Email {
+			return nil, fmt.Errorf("guardian %s: telephone %s already belongs to a parent with another email", parent.Name, parent.Telephone)
+		}
+		return &match, nil
+	default:
+		return nil, fmt.Errorf("guardian %s: telephone %s and email %s belong to different parents", parent.Name, parent.Telephone, *parent.Email)
+	}
+}
+
+// UpdateGuardianSettings changes the relationship and notification preferences of one guardian of a student
+func (spr *studentParentRepository) UpdateGuardianSettings(ctx context.Context, studentNSN string, parentID int, payload *domain.GuardianSettingsPayload) (*domain.StudentGuardian, error) {
+	if payload.Relationship != nil {
+		relationship := strings.ToLower(strings.TrimSpace(*payload.Relationship))
+		if !isGuardianRelationship(relationship) {
+			return nil, fmt.Errorf("%w: invalid relationship %s, must be one of %s", domain.ErrInvalidInput, relationship, strings.Join(domain.GuardianRelationships, ", "))
+		}
+		payload.Relationship = &relationship
+	}
+
+	var guardian domain.StudentGuardian
+	err := spr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("student_nsn = ? AND parent_id = ?", studentNSN, parentID).
+			First(&guardian).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: parent %d is not a guardian of student %s", domain.ErrNotFound, parentID, studentNSN)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get guardian: %w", err)
+		}
+
+		before := guardian
+		if payload.Relationship != nil {
+			guardian.Relationship = *payload.Relationship
+		}
+		if payload.ReceiveAttendance != nil {
+			guardian.ReceiveAttendance = *payload.ReceiveAttendance
+		}
+		if payload.ReceiveExamResult != nil {
+			guardian.ReceiveExamResult = *payload.ReceiveExamResult
+		}
+
+		err = tx.Model(&domain.StudentGuardian{}).
+			Where("student_nsn = ? AND parent_id = ?", studentNSN, parentID).
+			Updates(map[string]interface{}{
+				"relationship":        guardian.Relationship,
+				"receive_attendance":  guardian.ReceiveAttendance,
+				"receive_exam_result": guardian.ReceiveExamResult,
+				"updated_at":          time.Now(),
+			}).Error
+		if err != nil {
+			return fmt.Errorf("failed to update guardian: %w", err)
+		}
+
+		entityID := fmt.Sprintf("%s/%d", studentNSN, parentID)
+		return recordAudit(ctx, tx, domain.AuditActionUpdate, domain.AuditEntityStudentGuardian, entityID, before, guardian)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &guardian, nil
+}
+
+// moveGuardianLinks hands the additional guardian links of one parent over to another parent
+func moveGuardianLinks(tx *gorm.DB, fromParentID, toParentID int) error {
+	err := tx.Exec(`DELETE FROM student_guardians WHERE parent_id = ? AND student_nsn IN (SELECT student_nsn FROM student_guardians WHERE parent_id = ?)`, fromParentID, toParentID).Error
+	if err != nil {
+		return fmt.Errorf("failed to remove duplicate guardian links: %w", err)
+	}
+
+	err = tx.Model(&domain.StudentGuardian{}).Where("parent_id = ?", fromParentID).Update("parent_id", toParentID).Error
+	if err != nil {
+		return fmt.Errorf("failed to move guardian links: %w", err)
+	}
+
+	return nil
+}
+
+// countGuardianLinks counts the students that still reference a parent as primary or additional guardian
+func countGuardianLinks(tx *gorm.DB, parentID int) (int64, error) {
+	var counter int64
+	err := tx.Model(&domain.Student{}).
+		Where("parent_id = ? OR student_nsn IN (SELECT student_nsn FROM student_guardians WHERE parent_id = ?)", parentID, parentID).
+		Count(&counter).Error
+	return counter, err
+}
+
+// validateGuardians checks additional guardians the same way the primary parent is checked
+func validateGuardians(ctx context.Context, db *gorm.DB, guardians []domain.GuardianPayload, studentTelephone string) []string {
+	var errList []string
+
+	for i := range guardians {
+		guardian := &guardians[i]
+		label := fmt.Sprintf("Guardian %d", i+1)
+
+		if guardian.Parent.Name == "" || guardian.Parent.Telephone == "" || guardian.Parent.Gender == "" {
+			errList = append(errList, fmt.Sprintf("%s: name, gender and telephone are required", label))
+			continue
+		}
+
+		if containsDigit(guardian.Parent.Name) {
+			errList = append(errList, fmt.Sprintf("%s: name should not contain numbers", label))
+		}
+
+		guardian.Parent.Gender = strings.ToLower(guardian.Parent.Gender)
+		if guardian.Parent.Gender != "male" && guardian.Parent.Gender != "female" {
+			errList = append(errList, fmt.Sprintf("%s: invalid gender %s, must be 'male' or 'female'", label, guardian.Parent.Gender))
+		}
+
+		if len(guardian.Parent.Telephone) > 13 {
+			errList = append(errList, fmt.Sprintf("%s: telephone should not be more than 13 number", label))
+		}
+
+		if guardian.Parent.Telephone == studentTelephone {
+			errList = append(errList, fmt.Sprintf("%s: student and guardian cant have the same telephone", label))
+		}
+
+		if guardian.Parent.Email != nil && *guardian.Parent.Email != "" {
+			emailLowered := strings.ToLower(strings.TrimSpace(*guardian.Parent.Email))
+			guardian.Parent.Email = &emailLowered
+			if !emailFormatRegex.MatchString(emailLowered) {
+				errList = append(errList, fmt.Sprintf("%s: invalid email format: %s", label, emailLowered))
+			}
+		}
+
+		guardian.Relationship = strings.ToLower(guardian.Relationship)
+		if guardian.Relationship != "" && !isGuardianRelationship(guardian.Relationship) {
+			errList = append(errList, fmt.Sprintf("%s: invalid relationship %s, must be one of %s", label, guardian.Relationship, strings.Join(domain.GuardianRelationships, ", ")))
+		}
+
+		var guardianTelInStudent int64
+		err := db.WithContext(ctx).Model(&domain.Student{}).Where("telephone = ?", guardian.Parent.Telephone).Count(&guardianTelInStudent).Error
+		if err != nil {
+			errList = append(errList, fmt.Sprintf("%s: error checking telephone in student: %v", label, err))
+		} else if guardianTelInStudent > 0 {
+			errList = append(errList, fmt.Sprintf("%s: telephone %s already exist in student", label, guardian.Parent.Telephone))
+		}
+	}
+
+	return errList
+}
+
+func isGuardianRelationship(relationship string) bool {
+	for _, r := range domain.GuardianRelationships {
+		if r == relationship {
+			return true
+		}
+	}
+	return false
+}
+
+// clearEmailInvalid resets the bounce flag once a parent's email address changes
+func clearEmailInvalid(tx *gorm.DB, parentID int) error {
+	err := tx.Model(&domain.Parent{}).Where("parent_id = ?", parentID).Updates(map[string]interface{}{
+		"email_invalid":       false,
+		"email_invalid_at":    nil,
+		"email_bounce_reason": nil,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to reset email bounce status: %w", err)
+	}
+	return nil
+}
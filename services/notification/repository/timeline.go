@@ -0,0 +1,305 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"notification/domain"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// timelineCursor marks the last event of a page, events are ordered by time, then kind, then id, all descending
+type timelineCursor struct {
+	At   time.Time `json:"t"`
+	Kind string    `json:"k"`
+	ID   int       `json:"id"`
+}
+
+// timelineAbsenceRow is one subject missed on one day, HistoryIDs lists the notices sent about it
+type timelineAbsenceRow struct {
+	ID          int
+	OccurredAt  time.Time
+	SubjectCode string
+	AbsentOn    string
+	HistoryIDs  string
+}
+
+// GetStudentTimeline merges absences with the notices sent about them, parent replies, data change requests
+// and exam result notices into one feed. Lateness and excuses are not recorded anywhere yet so they cannot show up.
+// Staff only see students of the grades they teach.
+func (sp *studentRepository) GetStudentTimeline(ctx context.Context, userID int, nsn string, cursor string, limit int) (*domain.StudentTimeline, error) {
+	if limit <= 0 {
+		limit = domain.TimelineDefaultLimit
+	}
+	if limit > domain.TimelineMaxLimit {
+		limit = domain.TimelineMaxLimit
+	}
+
+	var after *timelineCursor
+	if cursor != "" {
+		decoded, err := decodeTimelineCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = decoded
+	}
+
+	var student domain.Student
+	err := sp.db.WithContext(ctx).
+		Preload("Parent").
+		Preload("Guardians.Parent").
+		Where("student_nsn = ?", nsn).
+		First(&student).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: student with NSN %s", domain.ErrNotFound, nsn)
+		}
+		return nil, fmt.Errorf("could not get student: %w", err)
+	}
+
+	grades, all, err := taughtGrades(ctx, sp.db, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !all && !containsInt(grades, student.Grade) {
+		return nil, fmt.Errorf("%w: student with NSN %s", domain.ErrNotFound, nsn)
+	}
+
+	parentIDs := []int{}
+	if student.ParentID != 0 {
+		parentIDs = append(parentIDs, student.ParentID)
+	}
+	for _, guardian := range student.Guardians {
+		parentIDs = append(parentIDs, guardian.ParentID)
+	}
+
+	var events []domain.TimelineEvent
+
+	absences, err := sp.timelineAbsences(ctx, nsn, after, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	events = append(events, absences...)
+
+	var replies []domain.ParentReply
+	condition, args := timelineAfter("received_at", "reply_id", domain.TimelineKindParentReply, after)
+	err = sp.db.WithContext(ctx).
+		Preload("Parent").
+		Where("notification_history_id IN (SELECT notification_history_id FROM attendance_notification_histories WHERE student_nsn = ?) OR (notification_history_id IS NULL AND parent_id IN ?)", nsn, parentIDs).
+		Where(condition, args...).
+		Order("received_at DESC, reply_id DESC").
+		Limit(limit + 1).
+		Find(&replies).Error
+	if err != nil {
+		return nil, fmt.Errorf("could not get parent replies: %w", err)
+	}
+	for _, reply := range replies {
+		events = append(events, domain.TimelineEvent{Kind: domain.TimelineKindParentReply, ID: reply.ReplyID, OccurredAt: reply.ReceivedAt, Data: reply})
+	}
+
+	var requests []domain.ParentDataChangeRequest
+	condition, args = timelineAfter("created_at", "request_id", domain.TimelineKindDataChangeRequest, after)
+	err = sp.db.WithContext(ctx).
+		Where("deleted_at IS NULL AND parent_id IN ?", parentIDs).
+		Where(condition, args...).
+		Order("created_at DESC, request_id DESC").
+		Limit(limit + 1).
+		Find(&requests).Error
+	if err != nil {
+		return nil, fmt.Errorf("could not get data change requests: %w", err)
+	}
+	for _, request := range requests {
+		events = append(events, domain.TimelineEvent{Kind: domain.TimelineKindDataChangeRequest, ID: request.RequestID, OccurredAt: request.CreatedAt, Data: request})
+	}
+
+	var examResults []domain.ExamResultNotificationHistory
+	condition, args = timelineAfter("created_at", "exam_result_history_id", domain.TimelineKindExamResult, after)
+	err = sp.db.WithContext(ctx).
+		Preload("Exam").
+		Preload("Parent").
+		Preload("User", func(db *gorm.DB) *gorm.DB {
+			return db.Select("user_id", "username", "name", "role", "created_at", "updated_at", "deleted_at")
+		}).
+		Where("student_nsn = ?", nsn).
+		Where(condition, args...).
+		Order("created_at DESC, exam_result_history_id DESC").
+		Limit(limit + 1).
+		Find(&examResults).Error
+	if err != nil {
+		return nil, fmt.Errorf("could not get exam result notices: %w", err)
+	}
+	for _, result := range examResults {
+		events = append(events, domain.TimelineEvent{Kind: domain.TimelineKindExamResult, ID: result.ExamResultHistoryID, OccurredAt: result.CreatedAt, Data: result})
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return timelineBefore(events[i], events[j])
+	})
+
+	timeline := domain.StudentTimeline{Student: student, Events: events}
+	if len(events) > limit {
+		timeline.Events = events[:limit]
+		last := timeline.Events[limit-1]
+		timeline.NextCursor = encodeTimelineCursor(timelineCursor{At: last.OccurredAt, Kind: last.Kind, ID: last.ID})
+	}
+	if timeline.Events == nil {
+		timeline.Events = []domain.TimelineEvent{}
+	}
+
+	return &timeline, nil
+}
+
+// timelineAbsences groups the attendance notices of a student by subject and day, each group is one absence
+func (sp *studentRepository) timelineAbsences(ctx context.Context, nsn string, after *timelineCursor, limit int) ([]domain.TimelineEvent, error) {
+	condition, args := timelineAfter("occurred_at", "id", domain.TimelineKindAbsence, after)
+
+	var rows []timelineAbsenceRow
+	err := sp.db.WithContext(ctx).Raw(`
+		SELECT * FROM (
+			SELECT MIN(notification_history_id) AS id,
+				MIN(created_at) AS occurred_at,
+				subject_code,
+				TO_CHAR(CAST(created_at AS date), 'YYYY-MM-DD') AS absent_on,
+				STRING_AGG(CAST(notification_history_id AS text), ',') AS history_ids
+			FROM attendance_notification_histories
+			WHERE student_nsn = ?
+			GROUP BY subject_code, CAST(created_at AS date)
+		) absences
+		WHERE `+condition+`
+		ORDER BY occurred_at DESC, id DESC
+		LIMIT ?`, append(append([]interface{}{nsn}, args...), limit)...).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("could not get absences: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	var historyIDs []int
+	for _, row := range rows {
+		for _, id := range strings.Split(row.HistoryIDs, ",") {
+			historyID, err := strconv.Atoi(id)
+			if err != nil {
+				return nil, fmt.Errorf("could not read absence notices: %w", err)
+			}
+			historyIDs = append(historyIDs, historyID)
+		}
+	}
+
+	var histories []domain.AttendanceNotificationHistory
+	err = sp.db.WithContext(ctx).
+		Preload("Subject").
+		Preload("Parent").
+		Preload("User", func(db *gorm.DB) *gorm.DB {
+			return db.Select("user_id", "username", "name", "role", "created_at", "updated_at", "deleted_at")
+		}).
+		Where("notification_history_id IN ?", historyIDs).
+		Order("created_at ASC, notification_history_id ASC").
+		Find(&histories).Error
+	if err != nil {
+		return nil, fmt.Errorf("could not get absence notices: %w", err)
+	}
+
+	byID := make(map[int]domain.AttendanceNotificationHistory, len(histories))
+	for _, history := range histories {
+		byID[history.NotificationHistoryID] = history
+	}
+
+	events := make([]domain.TimelineEvent, 0, len(rows))
+	for _, row := range rows {
+		absence := domain.TimelineAbsence{AbsentOn: row.AbsentOn}
+		for _, id := range strings.Split(row.HistoryIDs, ",") {
+			historyID, _ := strconv.Atoi(id)
+			if history, ok := byID[historyID]; ok {
+				absence.Subject = history.Subject
+				absence.Notifications = append(absence.Notifications, history)
+			}
+		}
+		events = append(events, domain.TimelineEvent{Kind: domain.TimelineKindAbsence, ID: row.ID, OccurredAt: row.OccurredAt, Data: absence})
+	}
+
+	return events, nil
+}
+
+// timelineAfter is the condition selecting the events of one kind that come after the cursor
+func timelineAfter(timeColumn, idColumn, kind string, after *timelineCursor) (string, []interface{}) {
+	if after == nil {
+		return "1 = 1", nil
+	}
+
+	switch {
+	case kind < after.Kind:
+		return fmt.Sprintf("%s <= ?", timeColumn), []interface{}{after.At}
+	case kind > after.Kind:
+		return fmt.Sprintf("%s < ?", timeColumn), []interface{}{after.At}
+	default:
+		return fmt.Sprintf("(%s < ?) OR (%s = ? AND %s < ?)", timeColumn, timeColumn, idColumn), []interface{}{after.At, after.At, after.ID}
+	}
+}
+
+// timelineBefore tells whether event a comes before event b in the feed
+func timelineBefore(a, b domain.TimelineEvent) bool {
+	if !a.OccurredAt.Equal(b.OccurredAt) {
+		return a.OccurredAt.After(b.OccurredAt)
+	}
+	if a.Kind != b.Kind {
+		return a.Kind > b.Kind
+	}
+	return a.ID > b.ID
+}
+
+func encodeTimelineCursor(cursor timelineCursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeTimelineCursor(encoded string) (*timelineCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid cursor", domain.ErrInvalidInput)
+	}
+
+	var cursor timelineCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil || cursor.ID == 0 || cursor.Kind == "" {
+		return nil, fmt.Errorf("%w: invalid cursor", domain.ErrInvalidInput)
+	}
+
+	return &cursor, nil
+}
+
+// taughtGrades lists the grades a user may see, all is true for roles that may view every student
+func taughtGrades(ctx context.Context, db *gorm.DB, userID int) (grades []int, all bool, err error) {
+	var existingUser domain.User
+	err = db.WithContext(ctx).Where("user_id = ?", userID).Preload("Teaching").First(&existingUser).Error
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid user: %w", err)
+	}
+
+	all, err = roleHasPermission(ctx, db, existingUser.Role, domain.PermissionViewAllStudents)
+	if err != nil || all {
+		return nil, all, err
+	}
+
+	for _, subject := range existingUser.Teaching {
+		grades = append(grades, subject.Grade)
+	}
+
+	return uniqueIntSlice(grades), false, nil
+}
+
+func containsInt(list []int, value int) bool {
+	for _, entry := range list {
+		if entry == value {
+			return true
+		}
+	}
+	return false
+}
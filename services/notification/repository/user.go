@@ -4,7 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
+	"notification/config"
 	"notification/domain"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,17 +18,21 @@ import (
 	"gorm.io/gorm"
 )
 
+var emailFormatRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+
 type userRepository struct {
-	db *gorm.DB
+	db      *gorm.DB
+	session domain.WhatsappSessionManager
 }
 
-func NewUserRepository(database *gorm.DB) domain.UserRepo {
+func NewUserRepository(database *gorm.DB, session domain.WhatsappSessionManager) domain.UserRepo {
 	return &userRepository{
-		db: database,
+		db:      database,
+		session: session,
 	}
 }
 
-func (ur *userRepository) GetAllTestScoresBySubjectID(ctx context.Context, subjectCode string) (*[]domain.TestScore, error) {
+func (ur *userRepository) GetAllTestScoresBySubjectID(ctx context.Context, subjectCode string, examID int) (*[]domain.TestScore, error) {
 	// Get the subject first
 	var subject domain.Subject
 	var testScores []domain.TestScore
@@ -35,6 +44,14 @@ func (ur *userRepository) GetAllTestScoresBySubjectID(ctx context.Context, subje
 		return nil, err
 	}
 
+	// Without an exam picked the latest one is shown
+	if examID == 0 {
+		examID, err = latestExamID(ctx, ur.db)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Fetch all students with matching grade
 	err = ur.db.WithContext(ctx).Where("grade = ?", subject.Grade).Find(&students).Error
 	if err != nil {
@@ -48,7 +65,7 @@ func (ur *userRepository) GetAllTestScoresBySubjectID(ctx context.Context, subje
 		Preload("User", func(db *gorm.DB) *gorm.DB {
 			return db.Select("user_id", "username", "name", "role", "created_at", "updated_at", "deleted_at")
 		}).
-		Where("subject_code = ? AND deleted_at IS NULL", subjectCode).
+		Where("subject_code = ? AND exam_id = ? AND deleted_at IS NULL", subjectCode, examID).
 		Find(&testScores).Error
 	if err != nil {
 		return nil, err
@@ -78,11 +95,15 @@ func (ur *userRepository) GetAllTestScoresBySubjectID(ctx context.Context, subje
 	// Add students without test scores if they are active
 	for _, student := range students {
 		if _, exists := validStudentIDs[student.StudentNSN]; !exists {
-			validTestScores = append(validTestScores, domain.TestScore{
+			placeholder := domain.TestScore{
 				StudentNSN: student.StudentNSN,
 				Student:    student,
 				Score:      floatPointer(0), // Set Score to 0
-			})
+			}
+			if examID != 0 {
+				placeholder.ExamID = &examID
+			}
+			validTestScores = append(validTestScores, placeholder)
 		}
 	}
 
@@ -93,9 +114,17 @@ func floatPointer(f float64) *float64 {
 	return &f
 }
 
-func (ur *userRepository) GetAllTestScores(ctx context.Context) (*[]domain.TestScore, error) {
+func (ur *userRepository) GetAllTestScores(ctx context.Context, examID int) (*[]domain.TestScore, error) {
+	var err error
+	if examID == 0 {
+		examID, err = latestExamID(ctx, ur.db)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var testScores []domain.TestScore
-	err := ur.db.WithContext(ctx).Preload("Student").Preload("User").Preload("Subject").Where("deleted_at IS NULL").Find(&testScores).Error
+	err = ur.db.WithContext(ctx).Preload("Student").Preload("User").Preload("Subject").Preload("Exam").Where("exam_id = ? AND deleted_at IS NULL", examID).Find(&testScores).Error
 	if err != nil {
 		return nil, err
 	}
@@ -121,8 +150,14 @@ func (r *userRepository) InputTestScores(ctx context.Context, teacherID int, tes
 	var userDetail domain.User
 	err := r.db.WithContext(ctx).Where("user_id = ? AND deleted_at is NULL", teacherID).First(&userDetail).Error
 	if err != nil {
+		tx.Rollback()
 		return fmt.Errorf("user with id %d not found", teacherID)
 	}
+	allSubjects, err := roleHasPermission(ctx, r.db, userDetail.Role, domain.PermissionAllSubjects)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
 
 	var subject domain.Subject
 	if err := tx.Where("subject_code = ?", testScores.SubjectCode).First(&subject).Error; err != nil {
@@ -130,6 +165,11 @@ func (r *userRepository) InputTestScores(ctx context.Context, teacherID int, tes
 		return fmt.Errorf("subject code %s does not exist", testScores.SubjectCode)
 	}
 
+	if _, err := findExam(ctx, tx, testScores.ExamID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	for _, individual := range testScores.StudentTestScore {
 		var student domain.Student
 		if err := tx.Where("student_nsn = ?", individual.StudentNSN).First(&student).Error; err != nil {
@@ -137,8 +177,8 @@ func (r *userRepository) InputTestScores(ctx context.Context, teacherID int, tes
 			return fmt.Errorf("student NSN %s does not exist", individual.StudentNSN)
 		}
 
-		// Authorization check for non-admin users
-		if userDetail.Role != "admin" {
+		// Everyone else may only input scores for the subjects they teach
+		if !allSubjects {
 			var count int64
 			err := tx.Table("user_subjects").
 				Where("user_user_id = ? AND subject_subject_code = ?", teacherID, testScores.SubjectCode).
@@ -150,9 +190,9 @@ func (r *userRepository) InputTestScores(ctx context.Context, teacherID int, tes
 			}
 		}
 
-		// Check if a test individual already exists for this student and subject (ignore teacher)
+		// Check if a test individual already exists for this student, subject and exam (ignore teacher)
 		var existingScore domain.TestScore
-		err := tx.Where("student_nsn = ? AND subject_code = ? AND deleted_at IS NULL", individual.StudentNSN, testScores.SubjectCode).
+		err := tx.Where("student_nsn = ? AND subject_code = ? AND exam_id = ? AND deleted_at IS NULL", individual.StudentNSN, testScores.SubjectCode, testScores.ExamID).
 			First(&existingScore).Error
 
 		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
@@ -162,15 +202,21 @@ func (r *userRepository) InputTestScores(ctx context.Context, teacherID int, tes
 
 		if existingScore.TestScoreID > 0 {
 			// Update the existing individual
+			previousScore := existingScore
 			existingScore.Score = individual.TestScore
 			existingScore.UserID = teacherID // Optionally update the teacher ID to the new one
 			if err := tx.Save(&existingScore).Error; err != nil {
 				tx.Rollback()
 				return err
 			}
+			if err := recordAudit(ctx, tx, domain.AuditActionUpdate, domain.AuditEntityTestScore, strconv.Itoa(existingScore.TestScoreID), previousScore, existingScore); err != nil {
+				tx.Rollback()
+				return err
+			}
 		} else {
 			// Create a new test individual record
 			newScore := domain.TestScore{
+				ExamID:      &testScores.ExamID,
 				StudentNSN:  individual.StudentNSN,
 				SubjectCode: testScores.SubjectCode,
 				UserID:      teacherID,
@@ -180,6 +226,10 @@ func (r *userRepository) InputTestScores(ctx context.Context, teacherID int, tes
 				tx.Rollback()
 				return err
 			}
+			if err := recordAudit(ctx, tx, domain.AuditActionCreate, domain.AuditEntityTestScore, strconv.Itoa(newScore.TestScoreID), nil, newScore); err != nil {
+				tx.Rollback()
+				return err
+			}
 		}
 	}
 
@@ -197,8 +247,13 @@ func (r *userRepository) GetSubjectsForTeacher(ctx context.Context, userID int)
 		return nil, fmt.Errorf("user with id %d not found", userID)
 	}
 
-	// If the user is an admin, retrieve all subjects
-	if user.Role == "admin" {
+	allSubjects, err := roleHasPermission(ctx, r.db, user.Role, domain.PermissionAllSubjects)
+	if err != nil {
+		return nil, err
+	}
+
+	// Staff who work with every subject get all of them
+	if allSubjects {
 		err = r.db.WithContext(ctx).Find(&subjects).Error
 		if err != nil {
 			return nil, fmt.Errorf("failed to get all subjects: %v", err)
@@ -244,6 +299,23 @@ func (ur *userRepository) CreateStaff(ctx context.Context, payload *domain.User)
 		return nil, fmt.Errorf("name %s already exists", payload.Name)
 	}
 
+	if errList := config.GetPasswordPolicy().Validate(payload.Password); len(errList) > 0 {
+		return nil, errors.New(strings.Join(errList, ", "))
+	}
+	if err := normalizeStaffContact(payload); err != nil {
+		return nil, err
+	}
+	role, err := staffRole(ctx, ur.db, payload.Role)
+	if err != nil {
+		return nil, err
+	}
+	if payload.Email != nil && *payload.Email == "" {
+		payload.Email = nil
+	}
+	if payload.Telephone != nil && *payload.Telephone == "" {
+		payload.Telephone = nil
+	}
+
 	// Hash the password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(payload.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -253,10 +325,17 @@ func (ur *userRepository) CreateStaff(ctx context.Context, payload *domain.User)
 
 	// Save the new user (this creates a user record in the user table)
 	payload.Username = payloadUsernameLowered
-	payload.Role = "staff"
-	err = ur.db.WithContext(ctx).Create(payload).Error
+	payload.Role = role
+	// The admin chose this password, the staff member replaces it on first login
+	payload.MustChangePassword = true
+	err = ur.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(payload).Error; err != nil {
+			return fmt.Errorf("could not create user: %v", err)
+		}
+		return recordAudit(ctx, tx, domain.AuditActionCreate, domain.AuditEntityStaff, strconv.Itoa(payload.UserID), nil, staffAuditRecord(payload))
+	})
 	if err != nil {
-		return nil, fmt.Errorf("could not create user: %v", err)
+		return nil, err
 	}
 
 	return payload, nil
@@ -285,14 +364,17 @@ func (ur *userRepository) GetAllStaff(ctx context.Context) (*[]domain.SafeStaffD
 		}
 
 		safeStaffData = append(safeStaffData, domain.SafeStaffData{
-			UserID:    user.UserID,
-			Username:  user.Username,
-			Name:      user.Name,
-			Role:      user.Role,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
-			DeletedAt: user.DeletedAt,
-			Teaching:  teaching,
+			UserID:             user.UserID,
+			Username:           user.Username,
+			Name:               user.Name,
+			Role:               user.Role,
+			Email:              user.Email,
+			Telephone:          user.Telephone,
+			MustChangePassword: user.MustChangePassword,
+			CreatedAt:          user.CreatedAt,
+			UpdatedAt:          user.UpdatedAt,
+			DeletedAt:          user.DeletedAt,
+			Teaching:           teaching,
 		})
 	}
 
@@ -301,7 +383,7 @@ func (ur *userRepository) GetAllStaff(ctx context.Context) (*[]domain.SafeStaffD
 
 func (ur *userRepository) DeleteStaff(ctx context.Context, id int) error {
 	var user domain.User
-	err := ur.db.WithContext(ctx).Where("user_id = ? AND deleted_at IS NULL", id).First(&user).Error
+	err := ur.db.WithContext(ctx).Preload("Teaching").Where("user_id = ? AND deleted_at IS NULL", id).First(&user).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return fmt.Errorf("staff not found")
@@ -315,20 +397,24 @@ func (ur *userRepository) DeleteStaff(ctx context.Context, id int) error {
 	}
 
 	// Soft delete the staff
+	before := staffAuditRecord(&user)
 	now := time.Now()
 	user.DeletedAt = &now // Assign the current time to mark as deleted
-	err = ur.db.WithContext(ctx).Save(&user).Error
-	if err != nil {
-		return fmt.Errorf("could not delete staff: %v", err)
-	}
-
-	return nil
+	return ur.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&user).Error; err != nil {
+			return fmt.Errorf("could not delete staff: %v", err)
+		}
+		if err := revokeUserSessions(tx, user.UserID); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, domain.AuditActionDelete, domain.AuditEntityStaff, strconv.Itoa(user.UserID), before, nil)
+	})
 }
 
 func (ur *userRepository) UpdateStaff(ctx context.Context, id int, payload *domain.User, subjectCodes []string) error {
 	usernameLowered := strings.ToLower(payload.Username)
 	var foundUser domain.User
-	err := ur.db.WithContext(ctx).Where("user_id = ? AND deleted_at IS NULL", id).First(&foundUser).Error
+	err := ur.db.WithContext(ctx).Preload("Teaching").Where("user_id = ? AND deleted_at IS NULL", id).First(&foundUser).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return fmt.Errorf("staff not found")
@@ -351,15 +437,30 @@ func (ur *userRepository) UpdateStaff(ctx context.Context, id int, payload *doma
 		return fmt.Errorf("name %s already exists", payload.Name)
 	}
 
+	if err := normalizeStaffContact(payload); err != nil {
+		return err
+	}
+
+	// The role is kept when left out
+	role := foundUser.Role
+	if payload.Role != "" {
+		if role, err = staffRole(ctx, ur.db, payload.Role); err != nil {
+			return err
+		}
+	}
+
 	updateUser := domain.User{
 		Username:  usernameLowered,
 		Name:      payload.Name,
-		Role:      "staff",
+		Role:      role,
 		UpdatedAt: time.Now(),
 	}
 
-	// Hash the password if it has been updated
+	// Hash the password if it has been updated, an admin set it so it has to be changed on next login
 	if payload.Password != "" {
+		if errList := config.GetPasswordPolicy().Validate(payload.Password); len(errList) > 0 {
+			return errors.New(strings.Join(errList, ", "))
+		}
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(payload.Password), bcrypt.DefaultCost)
 		if err != nil {
 			return fmt.Errorf("could not hash password: %v", err)
@@ -367,58 +468,281 @@ func (ur *userRepository) UpdateStaff(ctx context.Context, id int, payload *doma
 		updateUser.Password = string(hashedPassword)
 	}
 
-	err = ur.db.WithContext(ctx).Model(&domain.User{}).
-		Where("user_id = ? AND deleted_at IS NULL", id).
-		Updates(&updateUser).Error
+	// Email and telephone are left alone when omitted and cleared when sent empty
+	contact := map[string]interface{}{}
+	if payload.Email != nil {
+		contact["email"] = emptyToNil(*payload.Email)
+	}
+	if payload.Telephone != nil {
+		contact["telephone"] = emptyToNil(*payload.Telephone)
+	}
+	if updateUser.Password != "" {
+		contact["must_change_password"] = true
+	}
 
-	if err != nil {
-		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23505" {
-			return fmt.Errorf("username %s already exists", usernameLowered)
+	return ur.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&domain.User{}).
+			Where("user_id = ? AND deleted_at IS NULL", id).
+			Updates(&updateUser).Error
+		if err != nil {
+			if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23505" {
+				return fmt.Errorf("username %s already exists", usernameLowered)
+			}
+			return fmt.Errorf("could not update staff: %v", err)
 		}
-		return fmt.Errorf("could not update staff: %v", err)
-	}
 
-	var user domain.User
-	if err := ur.db.WithContext(ctx).First(&user, id).Error; err != nil {
-		return fmt.Errorf("could not find user: %v", err)
-	}
+		if len(contact) > 0 {
+			if err := tx.Model(&domain.User{}).Where("user_id = ?", id).Updates(contact).Error; err != nil {
+				return fmt.Errorf("could not update staff: %v", err)
+			}
+		}
+		// Tokens carry the role, a new role or password takes effect on the next login
+		if updateUser.Password != "" || role != foundUser.Role {
+			if err := revokeUserSessions(tx, id); err != nil {
+				return err
+			}
+		}
 
-	if err := ur.db.WithContext(ctx).Model(&user).Association("Teaching").Clear(); err != nil {
-		return fmt.Errorf("could not clear existing subjects: %v", err)
-	}
+		var user domain.User
+		if err := tx.First(&user, id).Error; err != nil {
+			return fmt.Errorf("could not find user: %v", err)
+		}
 
-	var subjects []domain.Subject
+		if err := tx.Model(&user).Association("Teaching").Clear(); err != nil {
+			return fmt.Errorf("could not clear existing subjects: %v", err)
+		}
+
+		var subjects []domain.Subject
+
+		if err := tx.Where("subject_code IN ?", subjectCodes).Find(&subjects).Error; err != nil {
+			return fmt.Errorf("could not find new subjects: %v", err)
+		}
+
+		subjectPointers := make([]*domain.Subject, len(subjects))
+		for i := range subjects {
+			subjectPointers[i] = &subjects[i]
+		}
+
+		if err := tx.Model(&user).Association("Teaching").Replace(subjectPointers); err != nil {
+			return fmt.Errorf("could not update subjects: %v", err)
+		}
 
-	if err := ur.db.WithContext(ctx).Where("subject_code IN ?", subjectCodes).Find(&subjects).Error; err != nil {
-		return fmt.Errorf("could not find new subjects: %v", err)
+		user.Teaching = subjectPointers
+		return recordAudit(ctx, tx, domain.AuditActionUpdate, domain.AuditEntityStaff, strconv.Itoa(id), staffAuditRecord(&foundUser), staffAuditRecord(&user))
+	})
+}
+
+// staffAuditRecord is what the audit log keeps of a staff member, subjects by code
+func staffAuditRecord(user *domain.User) map[string]interface{} {
+	teaching := make([]string, 0, len(user.Teaching))
+	for _, subject := range user.Teaching {
+		teaching = append(teaching, subject.SubjectCode)
+	}
+	sort.Strings(teaching)
+
+	return map[string]interface{}{
+		"user_id":              user.UserID,
+		"username":             user.Username,
+		"name":                 user.Name,
+		"role":                 user.Role,
+		"password":             user.Password,
+		"email":                user.Email,
+		"telephone":            user.Telephone,
+		"must_change_password": user.MustChangePassword,
+		"teaching":             teaching,
+		"deleted_at":           user.DeletedAt,
 	}
+}
 
-	subjectPointers := make([]*domain.Subject, len(subjects))
-	for i := range subjects {
-		subjectPointers[i] = &subjects[i]
+// normalizeStaffContact lower cases and checks the email and telephone of a staff payload, empty values stay empty
+func normalizeStaffContact(user *domain.User) error {
+	if user.Email != nil {
+		email := strings.ToLower(strings.TrimSpace(*user.Email))
+		user.Email = &email
+		if email != "" && !emailFormatRegex.MatchString(email) {
+			return fmt.Errorf("invalid email format: %s", email)
+		}
 	}
 
-	if err := ur.db.WithContext(ctx).Model(&user).Association("Teaching").Replace(subjectPointers); err != nil {
-		return fmt.Errorf("could not update subjects: %v", err)
+	if user.Telephone != nil {
+		telephone := strings.TrimSpace(*user.Telephone)
+		user.Telephone = &telephone
+		if len(telephone) > 13 {
+			return fmt.Errorf("telephone should not be more than 13 number")
+		}
+		for _, r := range telephone {
+			if r < '0' || r > '9' {
+				return fmt.Errorf("telephone should only contain numbers")
+			}
+		}
 	}
 
 	return nil
 }
 
-func (ur *userRepository) ShowProfile(ctx context.Context, uID int) (*domain.SafeStaffData, error) {
+func emptyToNil(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+func (ur *userRepository) ShowProfile(ctx context.Context, uID int) (*domain.ProfileDashboard, error) {
 	var user domain.User
-	var safeUser domain.SafeStaffData
+	var dashboard domain.ProfileDashboard
 
 	err := ur.db.WithContext(ctx).Where("user_id = ? AND deleted_at IS NULL", uID).First(&user).Error
 	if err != nil {
 		return nil, err
 	}
-	safeUser.Username = user.Username
-	safeUser.UserID = user.UserID
-	safeUser.Name = user.Name
-	safeUser.Role = user.Role
+	dashboard.Username = user.Username
+	dashboard.UserID = user.UserID
+	dashboard.Name = user.Name
+	dashboard.Role = user.Role
 
-	return &safeUser, nil
+	// Whoever may read the school-wide analytics gets the school overview, everyone else their own work
+	schoolWide, err := roleHasPermission(ctx, ur.db, user.Role, domain.PermissionViewAnalytics)
+	if err != nil {
+		return nil, err
+	}
+	if schoolWide {
+		dashboard.AdminMetrics, err = ur.adminDashboardMetrics(ctx)
+	} else {
+		dashboard.StaffMetrics, err = ur.staffDashboardMetrics(ctx, user.UserID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &dashboard, nil
+}
+
+func (ur *userRepository) staffDashboardMetrics(ctx context.Context, userID int) (*domain.StaffDashboardMetrics, error) {
+	now := time.Now()
+	// Weeks start on Monday
+	metrics := domain.StaffDashboardMetrics{
+		WeekStart:          time.Date(now.Year(), now.Month(), now.Day()-(int(now.Weekday())+6)%7, 0, 0, 0, 0, time.Local),
+		PendingScoreInputs: []domain.PendingScoreInput{},
+	}
+
+	err := ur.db.WithContext(ctx).Model(&domain.AttendanceNotificationHistory{}).
+		Where("user_id = ? AND created_at >= ?", userID, metrics.WeekStart).
+		Count(&metrics.AttendanceNoticesThisWeek).Error
+	if err != nil {
+		return nil, fmt.Errorf("could not count attendance notices: %w", err)
+	}
+
+	err = ur.db.WithContext(ctx).Model(&domain.ExamResultNotificationHistory{}).
+		Where("user_id = ? AND created_at >= ?", userID, metrics.WeekStart).
+		Count(&metrics.ExamResultNoticesThisWeek).Error
+	if err != nil {
+		return nil, fmt.Errorf("could not count exam result notices: %w", err)
+	}
+
+	var failedNotices, failedRecipients int64
+	err = ur.db.WithContext(ctx).Model(&domain.AttendanceNotificationHistory{}).
+		Where("user_id = ? AND (whatsapp_delivery = ? OR email_delivery = ?)", userID, domain.DeliveryStatusFailed, domain.DeliveryStatusFailed).
+		Count(&failedNotices).Error
+	if err != nil {
+		return nil, fmt.Errorf("could not count failed deliveries: %w", err)
+	}
+	err = ur.db.WithContext(ctx).Model(&domain.ExamBroadcastRecipient{}).
+		Joins("JOIN exam_broadcasts ON exam_broadcasts.broadcast_id = exam_broadcast_recipients.broadcast_id").
+		Where("exam_broadcasts.user_id = ? AND exam_broadcast_recipients.status = ?", userID, domain.DeliveryStatusFailed).
+		Count(&failedRecipients).Error
+	if err != nil {
+		return nil, fmt.Errorf("could not count failed deliveries: %w", err)
+	}
+	metrics.FailedDeliveries = failedNotices + failedRecipients
+
+	examID, err := latestExamID(ctx, ur.db)
+	if err != nil {
+		return nil, err
+	}
+	if examID == 0 {
+		return &metrics, nil
+	}
+	if metrics.PendingScoreExam, err = findExam(ctx, ur.db, examID); err != nil {
+		return nil, err
+	}
+
+	err = ur.db.WithContext(ctx).Raw(`
+		SELECT sub.subject_code, sub.name, sub.grade,
+			(SELECT COUNT(*) FROM students s WHERE s.grade = sub.grade AND s.deleted_at IS NULL) AS students,
+			(SELECT COUNT(DISTINCT ts.student_nsn) FROM test_scores ts
+				WHERE ts.subject_code = sub.subject_code AND ts.exam_id = ? AND ts.deleted_at IS NULL) AS scored
+		FROM user_subjects us
+		JOIN subjects sub ON sub.subject_code = us.subject_subject_code
+		WHERE us.user_user_id = ?
+		ORDER BY sub.grade, sub.subject_code`, examID, userID).
+		Scan(&metrics.PendingScoreInputs).Error
+	if err != nil {
+		return nil, fmt.Errorf("could not count pending score inputs: %w", err)
+	}
+	for i := range metrics.PendingScoreInputs {
+		input := &metrics.PendingScoreInputs[i]
+		if input.Students > input.Scored {
+			input.Pending = input.Students - input.Scored
+		}
+	}
+
+	return &metrics, nil
+}
+
+func (ur *userRepository) adminDashboardMetrics(ctx context.Context) (*domain.AdminDashboardMetrics, error) {
+	var metrics domain.AdminDashboardMetrics
+
+	err := ur.db.WithContext(ctx).Model(&domain.ParentDataChangeRequest{}).
+		Where("is_reviewed IS FALSE AND deleted_at IS NULL").
+		Count(&metrics.PendingDataChangeRequests).Error
+	if err != nil {
+		return nil, fmt.Errorf("could not count pending data change requests: %w", err)
+	}
+
+	if ur.session != nil {
+		metrics.Whatsapp = ur.session.Status()
+		metrics.QueueDepth = metrics.Whatsapp.QueuedMessages
+	}
+
+	// Attendance notices count once per channel, a resend moves the row to the day it was retried
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	countDeliveries := func(status string) (int64, error) {
+		var total int64
+		for _, column := range []string{"whatsapp_delivery", "email_delivery"} {
+			var counter int64
+			err := ur.db.WithContext(ctx).Model(&domain.AttendanceNotificationHistory{}).
+				Where("updated_at >= ? AND "+column+" = ?", today, status).
+				Count(&counter).Error
+			if err != nil {
+				return 0, err
+			}
+			total += counter
+		}
+
+		var counter int64
+		err := ur.db.WithContext(ctx).Model(&domain.ExamBroadcastRecipient{}).
+			Where("updated_at >= ? AND status = ?", today, status).
+			Count(&counter).Error
+		if err != nil {
+			return 0, err
+		}
+
+		return total + counter, nil
+	}
+
+	if metrics.DeliveriesSentToday, err = countDeliveries(domain.DeliveryStatusSent); err != nil {
+		return nil, fmt.Errorf("could not count today's deliveries: %w", err)
+	}
+	if metrics.DeliveriesFailedToday, err = countDeliveries(domain.DeliveryStatusFailed); err != nil {
+		return nil, fmt.Errorf("could not count today's deliveries: %w", err)
+	}
+	if attempted := metrics.DeliveriesSentToday + metrics.DeliveriesFailedToday; attempted > 0 {
+		rate := math.Round(float64(metrics.DeliveriesSentToday)/float64(attempted)*10000) / 100
+		metrics.DeliverySuccessRateToday = &rate
+	}
+
+	return &metrics, nil
 }
 
 func (ur *userRepository) GetAdminByAdmin(ctx context.Context) (*domain.SafeStaffData, error) {
@@ -433,6 +757,9 @@ func (ur *userRepository) GetAdminByAdmin(ctx context.Context) (*domain.SafeStaf
 	adminSafeData.UserID = admin.UserID
 	adminSafeData.Name = admin.Name
 	adminSafeData.Role = admin.Role
+	adminSafeData.Email = admin.Email
+	adminSafeData.Telephone = admin.Telephone
+	adminSafeData.MustChangePassword = admin.MustChangePassword
 
 	if err := ur.db.WithContext(ctx).Find(&subjects).Error; err != nil {
 		return nil, fmt.Errorf("could not fetch subjects for admin: %w", err)
@@ -453,7 +780,7 @@ func (ur *userRepository) GetStaffDetail(ctx context.Context, id int) (*domain.S
 		return nil, fmt.Errorf("could not get staff details: %v", err)
 	}
 
-	if user.Role != "staff" {
+	if user.Role == domain.RoleAdmin {
 		return nil, fmt.Errorf("staff not found")
 	}
 
@@ -464,14 +791,17 @@ func (ur *userRepository) GetStaffDetail(ctx context.Context, id int) (*domain.S
 	}
 
 	safeData := domain.SafeStaffData{
-		UserID:    user.UserID,
-		Username:  user.Username,
-		Name:      user.Name,
-		Role:      user.Role,
-		Teaching:  subjects,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
-		DeletedAt: user.DeletedAt,
+		UserID:             user.UserID,
+		Username:           user.Username,
+		Name:               user.Name,
+		Role:               user.Role,
+		Email:              user.Email,
+		Telephone:          user.Telephone,
+		MustChangePassword: user.MustChangePassword,
+		Teaching:           subjects,
+		CreatedAt:          user.CreatedAt,
+		UpdatedAt:          user.UpdatedAt,
+		DeletedAt:          user.DeletedAt,
 	}
 
 	return &safeData, nil
@@ -494,12 +824,12 @@ func (ur *userRepository) CreateSubject(ctx context.Context, subject *domain.Sub
 		return fmt.Errorf("subject code %s already exists", subject.SubjectCode)
 	}
 
-	err = ur.db.WithContext(ctx).Create(subject).Error
-	if err != nil {
-		return fmt.Errorf("could not create subject: %v", err)
-	}
-
-	return nil
+	return ur.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(subject).Error; err != nil {
+			return fmt.Errorf("could not create subject: %v", err)
+		}
+		return recordAudit(ctx, tx, domain.AuditActionCreate, domain.AuditEntitySubject, subject.SubjectCode, nil, subject)
+	})
 }
 
 func (ur *userRepository) GetSubjectDetail(ctx context.Context, subjectCode string) (*domain.Subject, error) {
@@ -535,7 +865,18 @@ func (ur *userRepository) CreateSubjectBulk(ctx context.Context, subjects *[]dom
 		return &errList, nil
 	}
 
-	if err := ur.db.WithContext(ctx).Create(subjects).Error; err != nil {
+	err := ur.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(subjects).Error; err != nil {
+			return err
+		}
+		for _, subject := range *subjects {
+			if err := recordAudit(ctx, tx, domain.AuditActionCreate, domain.AuditEntitySubject, subject.SubjectCode, nil, subject); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -549,16 +890,21 @@ func (ur *userRepository) GetAllSubject(ctx context.Context, userID int) (*[]dom
 		return nil, fmt.Errorf("invalid user: %w", err)
 	}
 
+	allSubjects, err := roleHasPermission(ctx, ur.db, existingUser.Role, domain.PermissionAllSubjects)
+	if err != nil {
+		return nil, err
+	}
+
 	var subjects []domain.Subject
 
-	if existingUser.Role == "admin" {
-		// Admin can see all subjects
+	if allSubjects {
+		// Staff who work with every subject see all of them
 		err = ur.db.WithContext(ctx).Find(&subjects).Error
 		if err != nil {
 			return nil, fmt.Errorf("failed to retrieve all subjects: %w", err)
 		}
 	} else {
-		// Non-admin users see only their assigned subjects
+		// Everyone else sees only their assigned subjects
 		err = ur.db.WithContext(ctx).
 			Model(&existingUser).
 			Association("Teaching").
@@ -594,18 +940,35 @@ func (ur *userRepository) UpdateSubject(ctx context.Context, subjectCode string,
 		return fmt.Errorf("subject with name %s already exists", newSubjectData.Name)
 	}
 
-	err = ur.db.WithContext(ctx).Model(&domain.Subject{}).
-		Where("subject_code = ?", subjectCode).
-		Updates(&newSubjectData).Error
-	if err != nil {
-		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23505" {
-			return fmt.Errorf("subject with name %s already exists", newSubjectData.Name)
+	return ur.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before domain.Subject
+		if err := tx.Where("subject_code = ?", subjectCode).First(&before).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("subject not found")
+			}
+			return fmt.Errorf("could not get subject details: %v", err)
 		}
-		return fmt.Errorf("could not update staff: %v", err)
-	}
 
-	return nil
+		err := tx.Model(&domain.Subject{}).
+			Where("subject_code = ?", subjectCode).
+			Updates(&newSubjectData).Error
+		if err != nil {
+			if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23505" {
+				return fmt.Errorf("subject with name %s already exists", newSubjectData.Name)
+			}
+			return fmt.Errorf("could not update staff: %v", err)
+		}
 
+		after := before
+		if newSubjectData.SubjectCode != "" {
+			after.SubjectCode = newSubjectData.SubjectCode
+		}
+		if err := tx.Where("subject_code = ?", after.SubjectCode).First(&after).Error; err != nil {
+			return fmt.Errorf("could not get subject details: %v", err)
+		}
+
+		return recordAudit(ctx, tx, domain.AuditActionUpdate, domain.AuditEntitySubject, subjectCode, before, after)
+	})
 }
 
 func (ur *userRepository) DeleteSubject(ctx context.Context, id int) error {
@@ -656,6 +1019,7 @@ func (ur *userRepository) DeleteSubjectMass(ctx context.Context, ids *[]int) err
 func (spr *userRepository) DeleteStaffMass(ctx context.Context, ids *[]int) error {
 	var users []domain.User
 	err := spr.db.WithContext(ctx).
+		Preload("Teaching").
 		Where("user_id IN (?) AND deleted_at IS NULL", *ids).
 		Find(&users).Error
 	if err != nil {
@@ -674,15 +1038,24 @@ func (spr *userRepository) DeleteStaffMass(ctx context.Context, ids *[]int) erro
 	}
 
 	now := time.Now()
-	err = spr.db.WithContext(ctx).
-		Model(&domain.User{}).
-		Where("user_id IN ?", getIdsFromUsers(staffToDelete)).
-		Update("deleted_at", now).Error
-	if err != nil {
-		return fmt.Errorf("could not delete staff: %v", err)
-	}
+	return spr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&domain.User{}).
+			Where("user_id IN ?", getIdsFromUsers(staffToDelete)).
+			Update("deleted_at", now).Error
+		if err != nil {
+			return fmt.Errorf("could not delete staff: %v", err)
+		}
 
-	return nil
+		for i := range staffToDelete {
+			if err := revokeUserSessions(tx, staffToDelete[i].UserID); err != nil {
+				return err
+			}
+			if err := recordAudit(ctx, tx, domain.AuditActionDelete, domain.AuditEntityStaff, strconv.Itoa(staffToDelete[i].UserID), staffAuditRecord(&staffToDelete[i]), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 // Helper function to extract IDs from the filtered list of users
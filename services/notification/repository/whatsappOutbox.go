@@ -0,0 +1,217 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"notification/config"
+	"notification/domain"
+	"notification/metrics"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"gorm.io/gorm"
+)
+
+// Messages are given up after this many failed flush attempts
+const whatsappOutboxMaxAttempts = 5
+
+// errWhatsappQueued tells the caller the message was queued instead of sent
+var errWhatsappQueued = errors.New(domain.DeliveryReasonQueued)
+
+// whatsappQueuedError is returned by sendOrQueueWhatsapp with the outbox row of the queued message,
+// it matches errWhatsappQueued
+type whatsappQueuedError struct {
+	outboxID int
+}
+
+func (e *whatsappQueuedError) Error() string {
+	return errWhatsappQueued.Error()
+}
+
+func (e *whatsappQueuedError) Is(target error) bool {
+	return target == errWhatsappQueued
+}
+
+// queuedOutboxID returns the outbox row behind an error of sendOrQueueWhatsapp, nil when nothing was queued
+func queuedOutboxID(err error) *int {
+	var queued *whatsappQueuedError
+	if errors.As(err, &queued) {
+		return &queued.outboxID
+	}
+	return nil
+}
+
+// linkOutboxToHistory lets the flush report the final outcome of a queued message on its attendance history row
+func linkOutboxToHistory(tx *gorm.DB, outboxID *int, historyID int) error {
+	if outboxID == nil {
+		return nil
+	}
+	err := tx.Model(&domain.WhatsappOutbox{}).Where("outbox_id = ?", *outboxID).Update("notification_history_id", historyID).Error
+	if err != nil {
+		return fmt.Errorf("could not link queued whatsapp message to notification history: %w", err)
+	}
+	return nil
+}
+
+// linkOutboxToExamResultHistory does the same for the history row of an exam result notice
+func linkOutboxToExamResultHistory(tx *gorm.DB, outboxID *int, historyID int) error {
+	if outboxID == nil {
+		return nil
+	}
+	err := tx.Model(&domain.WhatsappOutbox{}).Where("outbox_id = ?", *outboxID).Update("exam_result_history_id", historyID).Error
+	if err != nil {
+		return fmt.Errorf("could not link queued whatsapp message to exam result history: %w", err)
+	}
+	return nil
+}
+
+type whatsappOutboxRepository struct {
+	db         *gorm.DB
+	meowClient domain.WhatsappClientProvider
+	flushMu    sync.Mutex
+}
+
+func NewWhatsappOutboxRepository(db *gorm.DB, meow domain.WhatsappClientProvider) domain.WhatsappOutboxRepo {
+	return &whatsappOutboxRepository{
+		db:         db,
+		meowClient: meow,
+	}
+}
+
+// Flush sends every pending message in the order it was queued, stopping when the session drops again.
+// A message that fails its last attempt is marked failed, and so is the attendance or exam result notice it belongs to,
+// a failed exam broadcast recipient is sent again by the next resume.
+func (wr *whatsappOutboxRepository) Flush(ctx context.Context) (int, error) {
+	wr.flushMu.Lock()
+	defer wr.flushMu.Unlock()
+
+	var pending []domain.WhatsappOutbox
+	err := wr.db.WithContext(ctx).
+		Where("status = ?", domain.DeliveryStatusPending).
+		Order("outbox_id ASC").
+		Find(&pending).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch queued whatsapp messages: %w", err)
+	}
+
+	sent := 0
+	for _, message := range pending {
+		client := wr.meowClient.Client()
+		if !client.IsLoggedIn() {
+			break
+		}
+
+		body := message.Body
+		jid := types.NewJID(message.Telephone, types.DefaultUserServer)
+		_, sendErr := client.SendMessage(ctx, jid, &waE2E.Message{Conversation: &body})
+
+		attempts := message.Attempts + 1
+		updates := map[string]interface{}{"attempts": attempts}
+		history := map[string]interface{}{}
+		examHistory := map[string]interface{}{}
+		var outcome, reason string
+		if sendErr == nil {
+			outcome = domain.DeliveryStatusSent
+			updates["status"] = domain.DeliveryStatusSent
+			updates["sent_at"] = time.Now()
+			updates["last_error"] = nil
+			history["whatsapp_status"] = true
+			history["whatsapp_delivery"] = domain.DeliveryStatusSent
+			history["whatsapp_error"] = nil
+			examHistory["whatsapp_status"] = true
+			examHistory["whatsapp_reason"] = ""
+			sent++
+			recordOutboxMetric(message, domain.DeliveryStatusSent)
+		} else if attempts >= whatsappOutboxMaxAttempts {
+			outcome = domain.DeliveryStatusFailed
+			reason = fmt.Sprintf("gave up after %d attempts: %v", attempts, sendErr)
+			updates["status"] = domain.DeliveryStatusFailed
+			updates["last_error"] = reason
+			history["whatsapp_delivery"] = domain.DeliveryStatusFailed
+			history["whatsapp_error"] = reason
+			examHistory["whatsapp_reason"] = reason
+			config.Logger(ctx).WithError(sendErr).WithField("outbox_id", message.OutboxID).Error("queued whatsapp message given up")
+			recordOutboxMetric(message, domain.DeliveryStatusFailed)
+		} else {
+			updates["last_error"] = sendErr.Error()
+		}
+
+		err = wr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&domain.WhatsappOutbox{}).Where("outbox_id = ?", message.OutboxID).Updates(updates).Error; err != nil {
+				return err
+			}
+			if outcome == "" {
+				return nil
+			}
+			// A resend may have delivered the notice in the meantime
+			if message.NotificationHistoryID != nil {
+				err := tx.Model(&domain.AttendanceNotificationHistory{}).
+					Where("notification_history_id = ? AND whatsapp_delivery = ?", *message.NotificationHistoryID, domain.DeliveryStatusQueued).
+					Updates(history).Error
+				if err != nil {
+					return err
+				}
+			}
+			if message.ExamResultHistoryID != nil {
+				err := tx.Model(&domain.ExamResultNotificationHistory{}).
+					Where("exam_result_history_id = ? AND whatsapp_reason = ?", *message.ExamResultHistoryID, domain.DeliveryReasonQueued).
+					Updates(examHistory).Error
+				if err != nil {
+					return err
+				}
+			}
+			if message.ExamBroadcastRecipientID != nil {
+				return settleQueuedExamBroadcastRecipient(tx, *message.ExamBroadcastRecipientID, outcome, reason)
+			}
+			return nil
+		})
+		if err != nil {
+			return sent, fmt.Errorf("failed to update queued whatsapp message %d: %w", message.OutboxID, err)
+		}
+	}
+
+	return sent, nil
+}
+
+func (wr *whatsappOutboxRepository) PendingCount(ctx context.Context) (int64, error) {
+	var counter int64
+	err := wr.db.WithContext(ctx).Model(&domain.WhatsappOutbox{}).
+		Where("status = ?", domain.DeliveryStatusPending).
+		Count(&counter).Error
+	return counter, err
+}
+
+// recordOutboxMetric counts the final outcome of a queued message, a notice was counted as queued when it went in
+func recordOutboxMetric(message domain.WhatsappOutbox, status string) {
+	metrics.RecordOutboxMessage(status)
+	if message.NotificationHistoryID != nil {
+		metrics.RecordMessage(domain.ChannelWhatsapp, domain.NotificationTypeAttendance, status)
+	}
+	if message.ExamResultHistoryID != nil {
+		metrics.RecordMessage(domain.ChannelWhatsapp, domain.NotificationTypeExamResult, status)
+	}
+}
+
+// sendOrQueueWhatsapp sends right away when the session is up, otherwise stores the message for the next flush
+func sendOrQueueWhatsapp(ctx context.Context, db *gorm.DB, meow *whatsmeow.Client, telephone, body string) error {
+	if meow.IsLoggedIn() {
+		jid := types.NewJID(telephone, types.DefaultUserServer)
+		_, err := meow.SendMessage(ctx, jid, &waE2E.Message{Conversation: &body})
+		return err
+	}
+
+	message := domain.WhatsappOutbox{
+		Telephone: telephone,
+		Body:      body,
+		Status:    domain.DeliveryStatusPending,
+	}
+	if err := db.WithContext(ctx).Create(&message).Error; err != nil {
+		return fmt.Errorf("whatsapp unavailable and the message could not be queued: %w", err)
+	}
+
+	return &whatsappQueuedError{outboxID: message.OutboxID}
+}
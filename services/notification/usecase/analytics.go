@@ -0,0 +1,85 @@
+package usecase
+
+import (
+	"context"
+	"notification/domain"
+	"time"
+)
+
+type analyticsUC struct {
+	analyticsRepo domain.AnalyticsRepo
+	TimeOut       time.Duration
+}
+
+func NewAnalyticsUseCase(repo domain.AnalyticsRepo, timeOut time.Duration) domain.AnalyticsUseCase {
+	return &analyticsUC{
+		analyticsRepo: repo,
+		TimeOut:       timeOut,
+	}
+}
+
+func (au *analyticsUC) GetClassAbsenceStats(ctx context.Context, userID int, filter *domain.AnalyticsFilter) (*[]domain.ClassAbsenceStat, error) {
+	ctx, cancel := context.WithTimeout(ctx, au.TimeOut)
+	defer cancel()
+
+	v, err := au.analyticsRepo.GetClassAbsenceStats(ctx, userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (au *analyticsUC) GetSubjectAbsenceStats(ctx context.Context, userID int, filter *domain.AnalyticsFilter) (*[]domain.SubjectAbsenceStat, error) {
+	ctx, cancel := context.WithTimeout(ctx, au.TimeOut)
+	defer cancel()
+
+	v, err := au.analyticsRepo.GetSubjectAbsenceStats(ctx, userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (au *analyticsUC) GetTeacherAbsenceStats(ctx context.Context, userID int, filter *domain.AnalyticsFilter) (*[]domain.TeacherAbsenceStat, error) {
+	ctx, cancel := context.WithTimeout(ctx, au.TimeOut)
+	defer cancel()
+
+	v, err := au.analyticsRepo.GetTeacherAbsenceStats(ctx, userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (au *analyticsUC) GetMonthlyAbsenceStats(ctx context.Context, userID int, filter *domain.AnalyticsFilter) (*[]domain.MonthlyAbsenceStat, error) {
+	ctx, cancel := context.WithTimeout(ctx, au.TimeOut)
+	defer cancel()
+
+	v, err := au.analyticsRepo.GetMonthlyAbsenceStats(ctx, userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (au *analyticsUC) GetTopAbsentStudents(ctx context.Context, userID int, filter *domain.AnalyticsFilter) (*[]domain.StudentAbsenceStat, error) {
+	ctx, cancel := context.WithTimeout(ctx, au.TimeOut)
+	defer cancel()
+
+	v, err := au.analyticsRepo.GetTopAbsentStudents(ctx, userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (au *analyticsUC) GetAbsenceTrend(ctx context.Context, userID int, filter *domain.AnalyticsFilter) (*domain.AbsenceTrend, error) {
+	ctx, cancel := context.WithTimeout(ctx, au.TimeOut)
+	defer cancel()
+
+	v, err := au.analyticsRepo.GetAbsenceTrend(ctx, userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
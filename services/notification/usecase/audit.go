@@ -0,0 +1,26 @@
+package usecase
+
+import (
+	"context"
+	"notification/domain"
+	"time"
+)
+
+type auditUC struct {
+	auditRepo domain.AuditRepo
+	TimeOut   time.Duration
+}
+
+func NewAuditUseCase(repo domain.AuditRepo, timeOut time.Duration) domain.AuditUseCase {
+	return &auditUC{
+		auditRepo: repo,
+		TimeOut:   timeOut,
+	}
+}
+
+func (au *auditUC) GetAuditLogs(ctx context.Context, filter *domain.AuditLogFilter) (*domain.AuditLogPage, error) {
+	ctx, cancel := context.WithTimeout(ctx, au.TimeOut)
+	defer cancel()
+
+	return au.auditRepo.GetAuditLogs(ctx, filter)
+}
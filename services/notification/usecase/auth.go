@@ -9,16 +9,52 @@ type authUC struct {
 	authRepo domain.AuthRepo
 }
 
-func NewAuthUseCase(repo domain.AuthRepo) domain.AuthRepo {
+func NewAuthUseCase(repo domain.AuthRepo) domain.AuthUseCase {
 	return &authUC{
 		authRepo: repo,
 	}
 }
 
-func (auc *authUC) Login(ctx context.Context, data *domain.LoginRequest) (*[]string, error) {
+func (auc *authUC) Login(ctx context.Context, data *domain.LoginRequest) (*domain.LoginResponse, error) {
 	datas, err := auc.authRepo.Login(ctx, data)
 	if err != nil {
 		return nil, err
 	}
 	return datas, nil
 }
+
+func (auc *authUC) Refresh(ctx context.Context, refreshToken string) (*domain.LoginResponse, error) {
+	return auc.authRepo.Refresh(ctx, refreshToken)
+}
+
+func (auc *authUC) PurgeExpiredSessions(ctx context.Context) (int64, error) {
+	return auc.authRepo.PurgeExpiredSessions(ctx)
+}
+
+func (auc *authUC) Logout(ctx context.Context, userID int, sessionID string) error {
+	return auc.authRepo.Logout(ctx, userID, sessionID)
+}
+
+func (auc *authUC) LogoutAll(ctx context.Context, userID int) error {
+	return auc.authRepo.LogoutAll(ctx, userID)
+}
+
+func (auc *authUC) ChangePassword(ctx context.Context, userID int, req *domain.ChangePasswordRequest) (*domain.LoginResponse, *[]string, error) {
+	return auc.authRepo.ChangePassword(ctx, userID, req)
+}
+
+func (auc *authUC) RequestPasswordReset(ctx context.Context, userID int, channel string) (*domain.PasswordResetDelivery, error) {
+	return auc.authRepo.RequestPasswordReset(ctx, userID, channel)
+}
+
+func (auc *authUC) ResetPassword(ctx context.Context, req *domain.ResetPasswordRequest) (*[]string, error) {
+	return auc.authRepo.ResetPassword(ctx, req)
+}
+
+func (auc *authUC) GetLoginThrottles(ctx context.Context) (*[]domain.LoginThrottle, error) {
+	return auc.authRepo.GetLoginThrottles(ctx)
+}
+
+func (auc *authUC) UnlockLogin(ctx context.Context, req *domain.LoginUnlockRequest) error {
+	return auc.authRepo.UnlockLogin(ctx, req)
+}
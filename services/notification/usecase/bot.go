@@ -0,0 +1,121 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"notification/domain"
+	"sync"
+	"time"
+)
+
+const (
+	botMaxCommands  = 5
+	botLimitWindow  = 10 * time.Minute
+	botCleanupEvery = 100
+)
+
+type botRateWindow struct {
+	start    time.Time
+	count    int
+	notified bool
+}
+
+type botUC struct {
+	botRepo     domain.BotRepo
+	studentRepo domain.StudentRepo
+	consentRepo domain.ConsentRepo
+	TimeOut     time.Duration
+
+	mu       sync.Mutex
+	windows  map[string]*botRateWindow
+	requests int
+}
+
+func NewBotUseCase(botRepo domain.BotRepo, studentRepo domain.StudentRepo, consentRepo domain.ConsentRepo, timeOut time.Duration) domain.BotUseCase {
+	return &botUC{
+		botRepo:     botRepo,
+		studentRepo: studentRepo,
+		consentRepo: consentRepo,
+		TimeOut:     timeOut,
+		windows:     make(map[string]*botRateWindow),
+	}
+}
+
+func (b *botUC) HandleCommand(ctx context.Context, telephone string, text string) (bool, error) {
+	command := domain.ParseBotCommand(text)
+	if command == "" {
+		return false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, b.TimeOut)
+	defer cancel()
+
+	allowed, notify := b.allow(telephone, time.Now())
+	if !allowed {
+		if !notify {
+			return true, nil
+		}
+		return true, b.botRepo.SendReply(ctx, telephone, b.botRepo.ComposeRateLimitedReply())
+	}
+
+	// Identify the parent the same way staff do when searching by telephone
+	parentAndStudents, err := b.studentRepo.GetStudentByParentTelephone(ctx, telephone)
+	if errors.Is(err, domain.ErrNotFound) {
+		return true, b.botRepo.SendReply(ctx, telephone, b.botRepo.ComposeUnregisteredReply())
+	}
+	if err != nil {
+		// The caller logs the lookup failure, the parent is only told to try again
+		if sendErr := b.botRepo.SendReply(ctx, telephone, b.botRepo.ComposeTemporaryFailureReply()); sendErr != nil {
+			return true, errors.Join(err, sendErr)
+		}
+		return true, err
+	}
+
+	// BERHENTI / MULAI toggle every WhatsApp notification for this parent
+	if command == domain.BotCommandStop || command == domain.BotCommandStart {
+		err = b.consentRepo.SetConsentByTelephone(ctx, telephone, domain.ChannelWhatsapp, domain.NotificationTypeAll, command == domain.BotCommandStop, "whatsapp")
+		if err != nil {
+			return true, err
+		}
+	}
+
+	reply, err := b.botRepo.ComposeReply(ctx, command, parentAndStudents)
+	if err != nil {
+		return true, err
+	}
+
+	return true, b.botRepo.SendReply(ctx, telephone, *reply)
+}
+
+// allow applies a fixed window limit per telephone, notify is true only for the first rejected command of a window
+func (b *botUC) allow(telephone string, now time.Time) (allowed bool, notify bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.requests++
+	if b.requests%botCleanupEvery == 0 {
+		for tel, window := range b.windows {
+			if now.Sub(window.start) > botLimitWindow {
+				delete(b.windows, tel)
+			}
+		}
+	}
+
+	window, found := b.windows[telephone]
+	if !found || now.Sub(window.start) > botLimitWindow {
+		b.windows[telephone] = &botRateWindow{start: now, count: 1}
+		return true, false
+	}
+
+	if window.count < botMaxCommands {
+		window.count++
+		return true, false
+	}
+
+	if !window.notified {
+		window.notified = true
+		return false, true
+	}
+
+	return false, false
+}
@@ -0,0 +1,78 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBotRateLimit(t *testing.T) {
+	start := time.Date(2024, 5, 1, 7, 0, 0, 0, time.UTC)
+
+	type attempt struct {
+		telephone   string
+		after       time.Duration
+		wantAllowed bool
+		wantNotify  bool
+	}
+	allowedUpTo := func(telephone string, n int) []attempt {
+		attempts := make([]attempt, n)
+		for i := range attempts {
+			attempts[i] = attempt{telephone, 0, true, false}
+		}
+		return attempts
+	}
+
+	tests := []struct {
+		name     string
+		attempts []attempt
+	}{
+		{"within the limit", allowedUpTo("0812", botMaxCommands)},
+		{"the first rejection notifies, later ones stay silent", append(allowedUpTo("0812", botMaxCommands),
+			attempt{"0812", 0, false, true},
+			attempt{"0812", time.Minute, false, false},
+		)},
+		{"numbers are limited separately", append(allowedUpTo("0812", botMaxCommands),
+			attempt{"0813", 0, true, false},
+			attempt{"0812", 0, false, true},
+		)},
+		{"a new window starts after the old one passed", append(allowedUpTo("0812", botMaxCommands),
+			attempt{"0812", 0, false, true},
+			attempt{"0812", botLimitWindow + time.Second, true, false},
+			attempt{"0812", botLimitWindow + time.Second, true, false},
+		)},
+		{"the window counts from its first command", []attempt{
+			{"0812", 0, true, false},
+			{"0812", botLimitWindow - time.Second, true, false},
+			{"0812", botLimitWindow + time.Second, true, false},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &botUC{windows: make(map[string]*botRateWindow)}
+			for i, a := range tt.attempts {
+				allowed, notify := b.allow(a.telephone, start.Add(a.after))
+				if allowed != a.wantAllowed || notify != a.wantNotify {
+					t.Fatalf("attempt %d: allow(%q) = (%v, %v), want (%v, %v)", i+1, a.telephone, allowed, notify, a.wantAllowed, a.wantNotify)
+				}
+			}
+		})
+	}
+}
+
+func TestBotRateLimitCleanup(t *testing.T) {
+	start := time.Date(2024, 5, 1, 7, 0, 0, 0, time.UTC)
+	b := &botUC{windows: make(map[string]*botRateWindow)}
+
+	b.allow("stale", start)
+	later := start.Add(botLimitWindow + time.Minute)
+	for i := 1; i < botCleanupEvery; i++ {
+		b.allow("fresh", later)
+	}
+
+	if _, found := b.windows["stale"]; found {
+		t.Errorf("expired window was kept after %d commands", botCleanupEvery)
+	}
+	if _, found := b.windows["fresh"]; !found {
+		t.Errorf("current window was dropped")
+	}
+}
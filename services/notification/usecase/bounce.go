@@ -0,0 +1,37 @@
+package usecase
+
+import (
+	"context"
+	"notification/domain"
+	"time"
+)
+
+type bounceUC struct {
+	bounceRepo domain.BounceRepo
+	TimeOut    time.Duration
+}
+
+func NewBounceUseCase(repo domain.BounceRepo, timeOut time.Duration) domain.BounceUseCase {
+	return &bounceUC{
+		bounceRepo: repo,
+		TimeOut:    timeOut,
+	}
+}
+
+func (bu *bounceUC) ProcessMailbox(ctx context.Context) (*domain.BounceProcessResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, bu.TimeOut)
+	defer cancel()
+
+	return bu.bounceRepo.ProcessMailbox(ctx)
+}
+
+func (bu *bounceUC) GetInvalidEmailReport(ctx context.Context) (*[]domain.BouncedParent, error) {
+	ctx, cancel := context.WithTimeout(ctx, bu.TimeOut)
+	defer cancel()
+
+	v, err := bu.bounceRepo.GetInvalidEmailReport(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
@@ -0,0 +1,52 @@
+package usecase
+
+import (
+	"context"
+	"notification/domain"
+	"time"
+)
+
+type consentUC struct {
+	consentRepo domain.ConsentRepo
+	TimeOut     time.Duration
+}
+
+func NewConsentUseCase(repo domain.ConsentRepo, timeOut time.Duration) domain.ConsentUseCase {
+	return &consentUC{
+		consentRepo: repo,
+		TimeOut:     timeOut,
+	}
+}
+
+func (cu *consentUC) GetParentConsents(ctx context.Context, parentID int) (*[]domain.ParentConsent, error) {
+	ctx, cancel := context.WithTimeout(ctx, cu.TimeOut)
+	defer cancel()
+
+	v, err := cu.consentRepo.GetParentConsents(ctx, parentID)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (cu *consentUC) SetParentConsent(ctx context.Context, consent *domain.ParentConsent) error {
+	ctx, cancel := context.WithTimeout(ctx, cu.TimeOut)
+	defer cancel()
+
+	err := cu.consentRepo.SetParentConsent(ctx, consent)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cu *consentUC) Unsubscribe(ctx context.Context, token string) (*domain.ParentConsent, error) {
+	ctx, cancel := context.WithTimeout(ctx, cu.TimeOut)
+	defer cancel()
+
+	v, err := cu.consentRepo.Unsubscribe(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
@@ -0,0 +1,85 @@
+package usecase
+
+import (
+	"context"
+	"notification/domain"
+	"time"
+)
+
+type examUC struct {
+	examRepo domain.ExamRepo
+	TimeOut  time.Duration
+}
+
+func NewExamUseCase(repo domain.ExamRepo, timeOut time.Duration) domain.ExamUseCase {
+	return &examUC{
+		examRepo: repo,
+		TimeOut:  timeOut,
+	}
+}
+
+func (eu *examUC) CreateExam(ctx context.Context, exam *domain.Exam) error {
+	ctx, cancel := context.WithTimeout(ctx, eu.TimeOut)
+	defer cancel()
+
+	err := eu.examRepo.CreateExam(ctx, exam)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (eu *examUC) GetAllExams(ctx context.Context, academicYear string, semester int) (*[]domain.Exam, error) {
+	ctx, cancel := context.WithTimeout(ctx, eu.TimeOut)
+	defer cancel()
+
+	v, err := eu.examRepo.GetAllExams(ctx, academicYear, semester)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (eu *examUC) GetExamDetail(ctx context.Context, examID int) (*domain.Exam, error) {
+	ctx, cancel := context.WithTimeout(ctx, eu.TimeOut)
+	defer cancel()
+
+	v, err := eu.examRepo.GetExamDetail(ctx, examID)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (eu *examUC) UpdateExam(ctx context.Context, examID int, exam *domain.Exam) error {
+	ctx, cancel := context.WithTimeout(ctx, eu.TimeOut)
+	defer cancel()
+
+	err := eu.examRepo.UpdateExam(ctx, examID, exam)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (eu *examUC) DeleteExam(ctx context.Context, examID int) error {
+	ctx, cancel := context.WithTimeout(ctx, eu.TimeOut)
+	defer cancel()
+
+	err := eu.examRepo.DeleteExam(ctx, examID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (eu *examUC) GetExamScores(ctx context.Context, examID int, subjectCode string) (*[]domain.TestScore, error) {
+	ctx, cancel := context.WithTimeout(ctx, eu.TimeOut)
+	defer cancel()
+
+	v, err := eu.examRepo.GetExamScores(ctx, examID, subjectCode)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
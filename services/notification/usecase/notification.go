@@ -15,10 +15,42 @@ func NewNotificationUseCase(repo domain.NotificationRepo) domain.NotificationUse
 	}
 }
 
-func (nuc *notificationUC) GetAllAttendanceNotificationHistory(ctx context.Context) (*[]domain.AttendanceNotificationHistoryResponse, error) {
-	datas, err := nuc.repo.GetAllAttendanceNotificationHistory(ctx)
+func (nuc *notificationUC) GetAllAttendanceNotificationHistory(ctx context.Context, filter *domain.AttendanceHistoryFilter) (*domain.AttendanceHistoryPage, error) {
+	datas, err := nuc.repo.GetAllAttendanceNotificationHistory(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
 	return datas, nil
 }
+
+func (nuc *notificationUC) GetAllExamResultNotificationHistory(ctx context.Context, examID int) (*[]domain.ExamResultNotificationHistoryResponse, error) {
+	datas, err := nuc.repo.GetAllExamResultNotificationHistory(ctx, examID)
+	if err != nil {
+		return nil, err
+	}
+	return datas, nil
+}
+
+func (nuc *notificationUC) SaveParentReply(ctx context.Context, reply *domain.ParentReply) error {
+	err := nuc.repo.SaveParentReply(ctx, reply)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (nuc *notificationUC) GetAllParentReplies(ctx context.Context, unreadOnly bool) (*[]domain.ParentReply, error) {
+	datas, err := nuc.repo.GetAllParentReplies(ctx, unreadOnly)
+	if err != nil {
+		return nil, err
+	}
+	return datas, nil
+}
+
+func (nuc *notificationUC) MarkParentReplyRead(ctx context.Context, replyID int) error {
+	err := nuc.repo.MarkParentReplyRead(ctx, replyID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package usecase
+
+import (
+	"context"
+	"notification/domain"
+	"time"
+)
+
+type roleUC struct {
+	roleRepo domain.RoleRepo
+	TimeOut  time.Duration
+}
+
+func NewRoleUseCase(repo domain.RoleRepo, timeOut time.Duration) domain.RoleUseCase {
+	return &roleUC{
+		roleRepo: repo,
+		TimeOut:  timeOut,
+	}
+}
+
+func (ru *roleUC) GetAllRoles(ctx context.Context) (*[]domain.Role, error) {
+	ctx, cancel := context.WithTimeout(ctx, ru.TimeOut)
+	defer cancel()
+
+	return ru.roleRepo.GetAllRoles(ctx)
+}
+
+func (ru *roleUC) GetRole(ctx context.Context, name string) (*domain.Role, error) {
+	ctx, cancel := context.WithTimeout(ctx, ru.TimeOut)
+	defer cancel()
+
+	return ru.roleRepo.GetRole(ctx, name)
+}
+
+func (ru *roleUC) CreateRole(ctx context.Context, req *domain.RoleRequest) (*domain.Role, error) {
+	ctx, cancel := context.WithTimeout(ctx, ru.TimeOut)
+	defer cancel()
+
+	return ru.roleRepo.CreateRole(ctx, req)
+}
+
+func (ru *roleUC) UpdateRole(ctx context.Context, name string, req *domain.RoleRequest) (*domain.Role, error) {
+	ctx, cancel := context.WithTimeout(ctx, ru.TimeOut)
+	defer cancel()
+
+	return ru.roleRepo.UpdateRole(ctx, name, req)
+}
+
+func (ru *roleUC) DeleteRole(ctx context.Context, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, ru.TimeOut)
+	defer cancel()
+
+	return ru.roleRepo.DeleteRole(ctx, name)
+}
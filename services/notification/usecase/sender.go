@@ -18,24 +18,62 @@ func NewSenderUseCase(repo domain.SenderRepo, timeOut time.Duration) domain.Send
 	}
 }
 
-func (mUC *senderUC) SendMass(ctx context.Context, nsnList *[]string, userID *int, subjectCode string) error {
+func (mUC *senderUC) SendMass(ctx context.Context, nsnList *[]string, userID *int, subjectCode string, dryRun bool) (*[]domain.DeliveryResult, error) {
 	// ctx, cancel := context.WithTimeout(ctx, mUC.TimeOut)
 	// defer cancel()
 
-	err := mUC.emailSMTPRepo.SendMass(ctx, nsnList, userID, subjectCode)
+	results, err := mUC.emailSMTPRepo.SendMass(ctx, nsnList, userID, subjectCode, dryRun)
 	if err != nil {
-		return err
+		return results, err
 	}
-	return nil
+	return results, nil
 }
 
-func (mUC *senderUC) SendTestScores(ctx context.Context, examType string) error {
+func (mUC *senderUC) SendTestScores(ctx context.Context, examID int, userID int, dryRun bool, resend bool) (*domain.ExamBroadcastReport, error) {
 	// ctx, cancel := context.WithTimeout(ctx, mUC.TimeOut)
 	// defer cancel()
 
-	err := mUC.emailSMTPRepo.SendTestScores(ctx, examType)
+	results, err := mUC.emailSMTPRepo.SendTestScores(ctx, examID, userID, dryRun, resend)
 	if err != nil {
-		return err
+		return results, err
 	}
-	return nil
+	return results, nil
+}
+
+func (mUC *senderUC) ResumeExamBroadcast(ctx context.Context, broadcastID int, userID int) (*domain.ExamBroadcastReport, error) {
+	report, err := mUC.emailSMTPRepo.ResumeExamBroadcast(ctx, broadcastID, userID)
+	if err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+func (mUC *senderUC) ResendAttendanceNotification(ctx context.Context, historyID int) (*[]domain.DeliveryResult, error) {
+	results, err := mUC.emailSMTPRepo.ResendAttendanceNotification(ctx, historyID)
+	if err != nil {
+		return results, err
+	}
+	return results, nil
+}
+
+func (mUC *senderUC) GetExamBroadcasts(ctx context.Context, examID int) (*[]domain.ExamBroadcast, error) {
+	ctx, cancel := context.WithTimeout(ctx, mUC.TimeOut)
+	defer cancel()
+
+	v, err := mUC.emailSMTPRepo.GetExamBroadcasts(ctx, examID)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (mUC *senderUC) GetExamBroadcastSummary(ctx context.Context, broadcastID int) (*domain.ExamBroadcastSummary, error) {
+	ctx, cancel := context.WithTimeout(ctx, mUC.TimeOut)
+	defer cancel()
+
+	v, err := mUC.emailSMTPRepo.GetExamBroadcastSummary(ctx, broadcastID)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
 }
@@ -40,6 +40,17 @@ func (sUC *studentUC) GetAllStudent(ctx context.Context, userID int) (*[]domain.
 	return students, nil
 }
 
+func (sUC *studentUC) GetStudentTimeline(ctx context.Context, userID int, nsn string, cursor string, limit int) (*domain.StudentTimeline, error) {
+	ctx, cancel := context.WithTimeout(ctx, sUC.TimeOut)
+	defer cancel()
+
+	timeline, err := sUC.studentRepo.GetStudentTimeline(ctx, userID, nsn, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	return timeline, nil
+}
+
 func (sUC *studentUC) DownloadInputDataTemplate(ctx context.Context) (*string, error) {
 	ctx, cancel := context.WithTimeout(ctx, sUC.TimeOut)
 	defer cancel()
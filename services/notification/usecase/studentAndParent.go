@@ -155,3 +155,10 @@ func (spu *studentParentUseCase) ApproveDCR(ctx context.Context, req map[string]
 // 	}
 // 	return v, nil
 // }
+
+func (spu *studentParentUseCase) UpdateGuardianSettings(ctx context.Context, studentNSN string, parentID int, payload *domain.GuardianSettingsPayload) (*domain.StudentGuardian, error) {
+	ctx, cancel := context.WithTimeout(ctx, spu.TimeOut)
+	defer cancel()
+
+	return spu.repo.UpdateGuardianSettings(ctx, studentNSN, parentID, payload)
+}
@@ -168,8 +168,8 @@ func (u *userUC) GetSubjectDetail(ctx context.Context, subjectCode string) (*dom
 // 	return nil
 // }
 
-func (u *userUC) GetAllTestScores(ctx context.Context) (*[]domain.TestScore, error) {
-	v, err := u.userRepo.GetAllTestScores(ctx)
+func (u *userUC) GetAllTestScores(ctx context.Context, examID int) (*[]domain.TestScore, error) {
+	v, err := u.userRepo.GetAllTestScores(ctx, examID)
 	if err != nil {
 		return nil, err
 	}
@@ -177,8 +177,8 @@ func (u *userUC) GetAllTestScores(ctx context.Context) (*[]domain.TestScore, err
 	return v, nil
 }
 
-func (u *userUC) GetAllTestScoresBySubjectID(ctx context.Context, subjectCode string) (*[]domain.TestScore, error) {
-	v, err := u.userRepo.GetAllTestScoresBySubjectID(ctx, subjectCode)
+func (u *userUC) GetAllTestScoresBySubjectID(ctx context.Context, subjectCode string, examID int) (*[]domain.TestScore, error) {
+	v, err := u.userRepo.GetAllTestScoresBySubjectID(ctx, subjectCode, examID)
 	if err != nil {
 		return nil, err
 	}
@@ -195,7 +195,7 @@ func (u *userUC) GetAdminByAdmin(ctx context.Context) (*domain.SafeStaffData, er
 	return v, nil
 }
 
-func (u *userUC) ShowProfile(ctx context.Context, uID int) (*domain.SafeStaffData, error) {
+func (u *userUC) ShowProfile(ctx context.Context, uID int) (*domain.ProfileDashboard, error) {
 	v, err := u.userRepo.ShowProfile(ctx, uID)
 	if err != nil {
 		return nil, err